@@ -0,0 +1,128 @@
+// apply-user-template применяет шаблон папок (user_template.path) к уже существующим
+// пользователям, например после изменения шаблона. Создается только недостающее.
+//
+//	go run ./cmd/apply-user-template -config config/config.local.yaml -locale ru -dry-run
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+	"homecloud-file-service/internal/service"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.local.yaml", "путь к файлу конфигурации")
+	users := flag.String("users", "", "ID пользователей через запятую (по умолчанию - все каталоги хранилища)")
+	locale := flag.String("locale", "", "локаль имен папок (по умолчанию user_template.default_locale)")
+	dryRun := flag.Bool("dry-run", false, "только показать, что будет создано")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, *configPath, *users, *locale, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, configPath, users, locale string, dryRun bool) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	logBase, err := logger.New(cfg)
+	if err != nil {
+		return err
+	}
+	ctx = logger.CtxWWithLogger(ctx, logBase)
+
+	template, err := config.LoadUserTemplate(cfg.UserTemplate.Path)
+	if err != nil {
+		return err
+	}
+
+	fileRepo, err := repository.NewFileRepository(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create file repository: %w", err)
+	}
+	storageRepo, err := repository.NewStorageRepository(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage repository: %w", err)
+	}
+	parityStore, err := repository.NewParityStore(storageRepo, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create parity store: %w", err)
+	}
+
+	// Файлы шаблона пишутся тем же путем, что и обычные загрузки, но без журнала
+	// намерений: его открытие убирает недописанные записи работающего сервера
+	fileService := service.NewFileService(fileRepo, storageRepo, cfg, service.FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo), ParityStore: parityStore})
+	applier := service.NewUserTemplateApplier(fileService, service.StorageOwners(storageRepo), template, cfg)
+
+	owners, err := parseOwners(users)
+	if err != nil {
+		return err
+	}
+	if len(owners) == 0 {
+		if owners, err = applier.Owners(ctx); err != nil {
+			return err
+		}
+	}
+
+	var reports []*models.UserTemplateReport
+	failed := false
+	for _, ownerID := range owners {
+		if ctx.Err() != nil {
+			break
+		}
+		// Имени пользователя здесь нет: {{username}} в новых файлах останется пустым
+		report, err := applier.Apply(ctx, ownerID, "", locale, dryRun)
+		if err != nil {
+			logBase.Error(ctx, "Failed to apply user template", zap.Error(err), zap.String("ownerID", ownerID.String()))
+			failed = true
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(reports); err != nil {
+		return err
+	}
+
+	if failed {
+		return fmt.Errorf("template applied with errors")
+	}
+	return ctx.Err()
+}
+
+func parseOwners(users string) ([]uuid.UUID, error) {
+	var owners []uuid.UUID
+	for _, user := range strings.Split(users, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		ownerID, err := uuid.Parse(user)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id %q: %w", user, err)
+		}
+		owners = append(owners, ownerID)
+	}
+	return owners, nil
+}
@@ -0,0 +1,105 @@
+// fsck сверяет хранилище с dbmanager: объекты без записей, записи без объектов,
+// расхождения размера, контрольных сумм и счетчиков ссылок блобов. Печатает JSON-отчет.
+//
+//	go run ./cmd/fsck -config config/config.local.yaml -dry-run
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/repository"
+	"homecloud-file-service/internal/service"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.local.yaml", "путь к файлу конфигурации")
+	users := flag.String("users", "", "ID пользователей через запятую (по умолчанию - все хранилище)")
+	dryRun := flag.Bool("dry-run", false, "только показать расхождения, ничего не исправляя")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, *configPath, *users, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, configPath, users string, dryRun bool) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	logBase, err := logger.New(cfg)
+	if err != nil {
+		return err
+	}
+	ctx = logger.CtxWWithLogger(ctx, logBase)
+
+	fileRepo, err := repository.NewFileRepository(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create file repository: %w", err)
+	}
+	storageRepo, err := repository.NewStorageRepository(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage repository: %w", err)
+	}
+	writeJournal, err := repository.NewWriteJournal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create write journal: %w", err)
+	}
+
+	parityStore, err := repository.NewParityStore(storageRepo, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create parity store: %w", err)
+	}
+
+	owners, err := parseOwners(users)
+	if err != nil {
+		return err
+	}
+
+	storageService := service.NewStorageService(fileRepo, storageRepo, repository.NewBlobStore(storageRepo), parityStore, writeJournal, nil, cfg)
+	report, err := storageService.CheckStorage(ctx, owners, dryRun)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	if len(report.Issues) > report.Fixed {
+		return fmt.Errorf("storage check found %d unresolved issues", len(report.Issues)-report.Fixed)
+	}
+	return nil
+}
+
+func parseOwners(users string) ([]uuid.UUID, error) {
+	var owners []uuid.UUID
+	for _, user := range strings.Split(users, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		ownerID, err := uuid.Parse(user)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id %q: %w", user, err)
+		}
+		owners = append(owners, ownerID)
+	}
+	return owners, nil
+}
@@ -0,0 +1,115 @@
+// migrate-storage однократно переносит файлы из раскладки по дереву папок
+// в раскладку по ID (<owner>/ab/cd/<id>) и обновляет storage_path в dbmanager.
+//
+//	go run ./cmd/migrate-storage -config config/config.local.yaml -dry-run
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/repository"
+	"homecloud-file-service/internal/service"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.local.yaml", "путь к файлу конфигурации")
+	users := flag.String("users", "", "ID пользователей через запятую (по умолчанию - все каталоги хранилища)")
+	dryRun := flag.Bool("dry-run", false, "только показать, что будет перенесено")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, *configPath, *users, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, configPath, users string, dryRun bool) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	logBase, err := logger.New(cfg)
+	if err != nil {
+		return err
+	}
+	ctx = logger.CtxWWithLogger(ctx, logBase)
+
+	fileRepo, err := repository.NewFileRepository(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create file repository: %w", err)
+	}
+	storageRepo, err := repository.NewStorageRepository(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create storage repository: %w", err)
+	}
+
+	migration := service.NewLayoutMigration(fileRepo, storageRepo, repository.NewBlobStore(storageRepo), cfg)
+	migration.DryRun = dryRun
+
+	owners, err := parseOwners(users)
+	if err != nil {
+		return err
+	}
+	if len(owners) == 0 {
+		if owners, err = migration.Owners(ctx); err != nil {
+			return err
+		}
+	}
+
+	var reports []*service.LayoutMigrationReport
+	failed := false
+	for _, ownerID := range owners {
+		if ctx.Err() != nil {
+			break
+		}
+		report, err := migration.MigrateOwner(ctx, ownerID)
+		if err != nil {
+			logBase.Error(ctx, "Failed to migrate user storage", zap.Error(err), zap.String("ownerID", ownerID.String()))
+			failed = true
+			continue
+		}
+		failed = failed || report.Failed > 0
+		reports = append(reports, report)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(reports); err != nil {
+		return err
+	}
+
+	if failed {
+		return fmt.Errorf("migration finished with errors")
+	}
+	return ctx.Err()
+}
+
+func parseOwners(users string) ([]uuid.UUID, error) {
+	var owners []uuid.UUID
+	for _, user := range strings.Split(users, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		ownerID, err := uuid.Parse(user)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id %q: %w", user, err)
+		}
+		owners = append(owners, ownerID)
+	}
+	return owners, nil
+}
@@ -101,16 +101,98 @@ func run(ctx context.Context, w io.Writer, args []string) (*http.Server, *logger
 	}
 	logBase.Info(ctx, "Storage repository initialized successfully")
 
+	// Контентно-адресуемое хранилище блобов (используется при storage.content_addressed)
+	blobStore := repository.NewBlobStore(storageRepo)
+
+	// Блоки четности для восстановления поврежденных участков файлов (storage.parity)
+	parityStore, err := repository.NewParityStore(storageRepo, cfg)
+	if err != nil {
+		logBase.Error(ctx, "Failed to create parity store", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
+	// Журнал намерений записи для восстановления после сбоя
+	writeJournal, err := repository.NewWriteJournal(cfg)
+	if err != nil {
+		logBase.Error(ctx, "Failed to create write journal", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
+	// Итоги фоновой проверки контрольных сумм по пользователям
+	scrubStatus, err := repository.NewScrubStatusStore(cfg)
+	if err != nil {
+		logBase.Error(ctx, "Failed to create scrub status store", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
+	// Сессии возобновляемой загрузки переживают перезапуск
+	uploadSessions, err := repository.NewUploadSessionStore(cfg)
+	if err != nil {
+		logBase.Error(ctx, "Failed to create upload session store", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
+	// Ответы на запросы с Idempotency-Key для повторов с мобильных клиентов
+	idempotency, err := repository.NewIdempotencyStore(cfg)
+	if err != nil {
+		logBase.Error(ctx, "Failed to create idempotency store", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
+	// Сессии возобновляемого скачивания и ключ подписи ссылок на них
+	downloadSessions, err := repository.NewDownloadSessionStore(cfg)
+	if err != nil {
+		logBase.Error(ctx, "Failed to create download session store", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
+	// Запросы на удаление данных пользователей ждут конца отсрочки и после перезапуска
+	userDeletions, err := repository.NewUserDeletionStore(cfg)
+	if err != nil {
+		logBase.Error(ctx, "Failed to create user deletion store", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
 	// Инициализируем сервисы
-	fileService := service.NewFileService(fileRepo, storageRepo, cfg)
-	storageService := service.NewStorageService(storageRepo, cfg)
+	fileService := service.NewFileService(fileRepo, storageRepo, cfg, service.FileServiceDeps{
+		BlobStore:        blobStore,
+		ParityStore:      parityStore,
+		Journal:          writeJournal,
+		DownloadSessions: downloadSessions,
+		UserDeletions:    userDeletions,
+	})
+	storageService := service.NewStorageService(fileRepo, storageRepo, blobStore, parityStore, writeJournal, scrubStatus, cfg)
 	logBase.Info(ctx, "FileService and StorageService initialized successfully")
 
+	// До приема запросов доводим до конца или откатываем операции, прерванные сбоем
+	if err := fileService.RecoverPendingWrites(ctx); err != nil {
+		logBase.Error(ctx, "Failed to recover pending writes", zap.Error(err))
+		return nil, nil, nil, err
+	}
+
+	// Фоновая проверка контрольных сумм останавливается вместе с сервером по отмене ctx
+	if cfg.Storage.Scrub.Enabled {
+		scrubber := service.NewScrubber(fileRepo, storageRepo, scrubStatus, cfg)
+		go scrubber.Run(ctx)
+	}
+
+	// Истекшие сессии загрузки с брошенными частями, сессии скачивания, ответы идемпотентности,
+	// брошенные временные файлы хранилища и данные пользователей после отсрочки удаляются в фоне
+	go service.NewJanitor(cfg, uploadSessions, downloadSessions, idempotency, storageRepo, service.NewUserDataPurge(fileService)).Run(ctx)
+
+	// Папки и файлы, которые получает новый пользователь (user_template.path)
+	userTemplate, err := config.LoadUserTemplate(cfg.UserTemplate.Path)
+	if err != nil {
+		logBase.Error(ctx, "Failed to load user template", zap.Error(err))
+		return nil, nil, nil, err
+	}
+	templateApplier := service.NewUserTemplateApplier(fileService, service.StorageOwners(storageRepo), userTemplate, cfg)
+
 	// Инициализируем gRPC сервер
-	fileGRPCServer := grpcserver.NewFileServiceServer(storageService, fileService, cfg)
+	fileGRPCServer := grpcserver.NewFileServiceServer(storageService, fileService, uploadSessions, templateApplier, cfg)
 
 	// Инициализируем HTTP хэндлеры
-	handler := api.NewHandler(fileService, storageService, authClient)
+	handler := api.NewHandler(fileService, storageService, uploadSessions, idempotency, authClient)
 
 	// Настраиваем маршруты
 	router := api.SetupRoutes(handler, logBase)
@@ -131,6 +213,25 @@ func run(ctx context.Context, w io.Writer, args []string) (*http.Server, *logger
 		}
 	}()
 
+	// Метрики отдаются только на внутреннем адресе
+	if cfg.Server.DebugAddr != "" {
+		debugServer := &http.Server{
+			Addr:              cfg.Server.DebugAddr,
+			Handler:           api.DebugRoutes(),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		logBase.Info(ctx, "Starting debug HTTP server", zap.String("address", debugServer.Addr))
+		go func() {
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logBase.Error(ctx, "Failed to start debug HTTP server", zap.Error(err))
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			debugServer.Close()
+		}()
+	}
+
 	// Создаем gRPC сервер
 	grpcListener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Grpc.Host, cfg.Grpc.Port))
 	if err != nil {
@@ -138,8 +239,11 @@ func run(ctx context.Context, w io.Writer, args []string) (*http.Server, *logger
 		return nil, nil, nil, err
 	}
 
+	// Вызовы выполняются от имени переданного user_id, поэтому без токена сервиса
+	// обслуживается только создание папок нового пользователя
 	grpcGRPCServer := grpc.NewServer(
-		grpc.UnaryInterceptor(grpcserver.LoggerInterceptor(logBase)),
+		grpc.ChainUnaryInterceptor(grpcserver.LoggerInterceptor(logBase), grpcserver.ServiceAuthInterceptor(cfg.Grpc.ServiceToken)),
+		grpc.ChainStreamInterceptor(grpcserver.LoggerStreamInterceptor(logBase), grpcserver.ServiceAuthStreamInterceptor(cfg.Grpc.ServiceToken)),
 	)
 	pb.RegisterFileServiceServer(grpcGRPCServer, fileGRPCServer)
 
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
@@ -21,6 +22,11 @@ func (lc *LoggerConfig) Build() (*zap.Logger, error) {
 type ServerConfig struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
+	// Сколько хранится ответ на запрос с Idempotency-Key (по умолчанию 24h)
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+	// Адрес внутреннего listener-а для /debug/vars, например 127.0.0.1:6060.
+	// Пусто - метрики не публикуются. На публичном порту их нет
+	DebugAddr string `yaml:"debug_addr"`
 }
 
 // DatabaseConfig - конфигурация базы данных
@@ -35,17 +41,95 @@ type DatabaseConfig struct {
 
 // StorageConfig - конфигурация файлового хранилища
 type StorageConfig struct {
-	BasePath    string `yaml:"base_path"`     // Базовая директория для хранения файлов
-	MaxSize     int64  `yaml:"max_size"`      // Максимальный размер файла в байтах
-	ChunkSize   int64  `yaml:"chunk_size"`    // Размер чанка для возобновляемых загрузок
-	TempPath    string `yaml:"temp_path"`     // Временная директория для загрузок
-	UserDirName string `yaml:"user_dir_name"` // Имя директории для пользователей (по умолчанию "users")
+	BasePath    string   `yaml:"base_path"`     // Базовая директория для хранения файлов
+	MaxSize     int64    `yaml:"max_size"`      // Максимальный размер файла в байтах
+	ChunkSize   int64    `yaml:"chunk_size"`    // Размер чанка для возобновляемых загрузок
+	TempPath    string   `yaml:"temp_path"`     // Временная директория для загрузок
+	UserDirName string   `yaml:"user_dir_name"` // Имя директории для пользователей (по умолчанию "users")
+	Driver      string   `yaml:"driver"`        // Драйвер хранилища блобов: local | s3 | mirror (по умолчанию local)
+	S3          S3Config `yaml:"s3"`            // Параметры S3-совместимого хранилища для драйвера s3
+	// Дополнительные корни для драйвера mirror: копия каждого объекта хранится в base_path и в каждом из них
+	MirrorPaths []string `yaml:"mirror_paths"`
+	// Сколько копий должно надежно записаться, чтобы запись считалась успешной (по умолчанию большинство)
+	WriteQuorum int `yaml:"write_quorum"`
+	// Хранить содержимое по SHA-256 с подсчетом ссылок (дедупликация между пользователями)
+	ContentAddressed bool        `yaml:"content_addressed"`
+	Scrub            ScrubConfig `yaml:"scrub"` // Фоновая проверка контрольных сумм
+	// Блоки четности Рида-Соломона для восстановления поврежденных участков файлов
+	Parity ParityConfig `yaml:"parity"`
+	// Сессии возобновляемой загрузки в temp_path
+	Uploads UploadsConfig `yaml:"uploads"`
+	// Сессии возобновляемого скачивания в temp_path и подписанные ссылки на них
+	Downloads DownloadsConfig `yaml:"downloads"`
+	// Удаление данных пользователя вместе с учетной записью
+	UserData UserDataConfig `yaml:"user_data"`
+}
+
+// ScrubConfig - фоновая проверка контрольных сумм файлов
+type ScrubConfig struct {
+	Enabled        bool          `yaml:"enabled"`          // Запускать проверку вместе с сервером
+	Interval       time.Duration `yaml:"interval"`         // Пауза между полными проходами (по умолчанию 24h)
+	BytesPerSecond int64         `yaml:"bytes_per_second"` // Ограничение скорости чтения (по умолчанию 16MB/s)
+}
+
+// UploadsConfig - сессии возобновляемой загрузки
+type UploadsConfig struct {
+	SessionTTL      time.Duration `yaml:"session_ttl"`      // Сколько живет сессия без новых данных (по умолчанию 24h)
+	JanitorInterval time.Duration `yaml:"janitor_interval"` // Пауза между очистками истекших сессий и ответов идемпотентности (по умолчанию 1h)
+}
+
+// DownloadsConfig - сессии возобновляемого скачивания
+type DownloadsConfig struct {
+	SessionTTL time.Duration `yaml:"session_ttl"` // Сколько живет сессия скачивания (по умолчанию 24h)
+	TokenTTL   time.Duration `yaml:"token_ttl"`   // Сколько действует подписанная ссылка, но не дольше сессии (по умолчанию 6h)
+	// Ключ HMAC для подписи ссылок. Если не задан, ключ создается при первом запуске
+	// и хранится рядом с сессиями, так что ссылки переживают перезапуск
+	TokenSecret string `yaml:"token_secret"`
+}
+
+// UserDataConfig - удаление данных пользователя
+type UserDataConfig struct {
+	// Сколько файлы удаленного пользователя лежат в корзине до безвозвратного удаления (по умолчанию 720h)
+	DeletionGracePeriod time.Duration `yaml:"deletion_grace_period"`
+	// Разрешить DeleteUserData с immediate: данные удаляются сразу, без отсрочки (по умолчанию выключено)
+	AllowImmediate bool `yaml:"allow_immediate"`
+}
+
+// ParityConfig - блоки четности для файлов. Четность пишется для всех файлов при enabled,
+// иначе - только для файлов перечисленных пользователей и папок (включая вложенные)
+type ParityConfig struct {
+	Enabled      bool     `yaml:"enabled"`       // Четность для всех файлов
+	Users        []string `yaml:"users"`         // ID пользователей, файлы которых защищаются
+	Folders      []string `yaml:"folders"`       // ID папок, файлы которых защищаются
+	DataShards   int      `yaml:"data_shards"`   // Блоков данных в группе (по умолчанию 10)
+	ParityShards int      `yaml:"parity_shards"` // Блоков четности на группу (по умолчанию 2)
+	ShardSize    int      `yaml:"shard_size"`    // Размер блока в байтах (по умолчанию 64KB)
+}
+
+// S3Config - конфигурация S3-совместимого хранилища
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`   // Адрес сервиса, например http://localhost:9000
+	Region    string `yaml:"region"`     // Регион для подписи запросов (по умолчанию us-east-1)
+	Bucket    string `yaml:"bucket"`     // Имя бакета
+	AccessKey string `yaml:"access_key"` // Ключ доступа
+	SecretKey string `yaml:"secret_key"` // Секретный ключ
+	Prefix    string `yaml:"prefix"`     // Префикс ключей объектов внутри бакета
+	PathStyle bool   `yaml:"path_style"` // Адресация бакета в пути URL вместо поддомена (MinIO, Ceph)
+	PartSize  int64  `yaml:"part_size"`  // Размер части multipart загрузки в байтах (по умолчанию 8MB)
+	// Таймауты соединения с S3. Тело ответа ограничением не покрывается: большие файлы
+	// читаются дольше, зависшее соединение обрывает сервер или отмена запроса
+	DialTimeout           time.Duration `yaml:"dial_timeout"`            // Установка TCP соединения (по умолчанию 10s)
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`   // TLS рукопожатие (по умолчанию 10s)
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"` // Ожидание заголовков ответа (по умолчанию 60s)
 }
 
 // GrpcConfig - конфигурация gRPC клиента для БД
 type GrpcConfig struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
+	// Общий токен сервисов homecloud: клиенты передают его в метаданных "authorization: Bearer <token>".
+	// Пусто - доступен только CreateUserDirectory, остальные вызовы отклоняются
+	ServiceToken string `yaml:"service_token"`
 }
 
 // DbManagerConfig - конфигурация gRPC клиента для DBManager
@@ -69,6 +153,8 @@ type Config struct {
 	Grpc      GrpcConfig      `yaml:"grpc"`
 	DbManager DbManagerConfig `yaml:"dbmanager"`
 	Auth      AuthConfig      `yaml:"auth"`
+	// Папки и файлы, которые получает новый пользователь
+	UserTemplate UserTemplateConfig `yaml:"user_template"`
 }
 
 func LoadConfig(filename string) (*Config, error) {
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UserTemplateConfig - папки и файлы, которые получает новый пользователь
+type UserTemplateConfig struct {
+	// YAML файл шаблона. Пусто - стандартные папки documents, photos, videos, music, downloads
+	Path string `yaml:"path"`
+	// Локаль имен, если сервис авторизации ее не передал (по умолчанию en)
+	DefaultLocale string `yaml:"default_locale"`
+}
+
+// UserTemplate дерево папок и файлов в корне пользователя
+type UserTemplate struct {
+	Folders []TemplateFolder `yaml:"folders"`
+	Files   []TemplateFile   `yaml:"files"`
+}
+
+// TemplateFolder папка шаблона
+type TemplateFolder struct {
+	Name string `yaml:"name"`
+	// Имена по локалям, например ru: Документы. Для остальных локалей - Name
+	Names   map[string]string `yaml:"names"`
+	Folders []TemplateFolder  `yaml:"folders"`
+	Files   []TemplateFile    `yaml:"files"`
+}
+
+// TemplateFile файл шаблона. В содержимом {{username}} заменяется именем пользователя
+type TemplateFile struct {
+	Name     string            `yaml:"name"`
+	Names    map[string]string `yaml:"names"`
+	Content  string            `yaml:"content"`
+	Contents map[string]string `yaml:"contents"` // Содержимое по локалям
+}
+
+// DefaultUserTemplate шаблон, который действовал до настраиваемых шаблонов
+func DefaultUserTemplate() *UserTemplate {
+	template := &UserTemplate{}
+	for _, name := range []string{"documents", "photos", "videos", "music", "downloads"} {
+		template.Folders = append(template.Folders, TemplateFolder{Name: name})
+	}
+	return template
+}
+
+// LoadUserTemplate читает шаблон из YAML файла. Пустой путь - DefaultUserTemplate
+func LoadUserTemplate(path string) (*UserTemplate, error) {
+	if path == "" {
+		return DefaultUserTemplate(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read user template: %v", err)
+	}
+	template := &UserTemplate{}
+	if err := yaml.UnmarshalStrict(data, template); err != nil {
+		return nil, fmt.Errorf("could not decode user template: %v", err)
+	}
+	if err := template.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid user template %s: %w", path, err)
+	}
+	return template, nil
+}
+
+// Validate проверяет имена: они должны быть непустыми, без разделителей пути
+// и не повторяться внутри одной папки
+func (t *UserTemplate) Validate() error {
+	return validateTemplateLevel("/", t.Folders, t.Files)
+}
+
+func validateTemplateLevel(path string, folders []TemplateFolder, files []TemplateFile) error {
+	seen := make(map[string]bool)
+	check := func(name string, names map[string]string) error {
+		own := make(map[string]bool)
+		for _, n := range append([]string{name}, mapValues(names)...) {
+			switch {
+			case strings.TrimSpace(n) == "", n == ".", n == "..":
+				return fmt.Errorf("invalid name %q in %s", n, path)
+			case strings.ContainsAny(n, "/\\"):
+				return fmt.Errorf("name %q in %s cannot contain path separators", n, path)
+			}
+			own[n] = true
+		}
+		// Имя в любой локали не должно совпадать с именами соседей: иначе при
+		// повторном применении нельзя понять, какая запись уже создана
+		for n := range own {
+			if seen[n] {
+				return fmt.Errorf("duplicate name %q in %s", n, path)
+			}
+			seen[n] = true
+		}
+		return nil
+	}
+
+	for _, folder := range folders {
+		if err := check(folder.Name, folder.Names); err != nil {
+			return err
+		}
+		if err := validateTemplateLevel(path+folder.Name+"/", folder.Folders, folder.Files); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		if err := check(file.Name, file.Names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LocalName имя папки для локали
+func (f *TemplateFolder) LocalName(locale string) string {
+	return localized(f.Names, f.Name, locale)
+}
+
+// AllNames имя папки во всех локалях: по любому из них папка считается уже созданной
+func (f *TemplateFolder) AllNames() []string {
+	return append([]string{f.Name}, mapValues(f.Names)...)
+}
+
+// LocalName имя файла для локали
+func (f *TemplateFile) LocalName(locale string) string {
+	return localized(f.Names, f.Name, locale)
+}
+
+// AllNames имя файла во всех локалях
+func (f *TemplateFile) AllNames() []string {
+	return append([]string{f.Name}, mapValues(f.Names)...)
+}
+
+// LocalContent содержимое файла для локали с подставленным именем пользователя
+func (f *TemplateFile) LocalContent(locale, username string) string {
+	return strings.ReplaceAll(localized(f.Contents, f.Content, locale), "{{username}}", username)
+}
+
+// localized ищет значение для локали целиком (pt-BR), затем для языка (pt)
+func localized(values map[string]string, fallback, locale string) string {
+	locale = strings.ReplaceAll(strings.ToLower(locale), "_", "-")
+	if value, ok := lookupLocale(values, locale); ok {
+		return value
+	}
+	if language, _, found := strings.Cut(locale, "-"); found {
+		if value, ok := lookupLocale(values, language); ok {
+			return value
+		}
+	}
+	return fallback
+}
+
+func lookupLocale(values map[string]string, locale string) (string, bool) {
+	for key, value := range values {
+		if strings.ReplaceAll(strings.ToLower(key), "_", "-") == locale {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func mapValues(values map[string]string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
@@ -3,6 +3,8 @@ package errdefs
 import (
 	"errors"
 	"fmt"
+
+	"github.com/google/uuid"
 )
 
 var (
@@ -25,6 +27,12 @@ var (
 	ErrQuotaExceeded    = errors.New("quota exceeded")
 	ErrFileInUse        = errors.New("file is in use")
 	ErrRevisionNotFound = errors.New("revision not found")
+	// ErrPreconditionFailed файл изменился с версии, которую ожидал клиент
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrSessionExpired срок сессии возобновляемой операции истек
+	ErrSessionExpired = errors.New("session expired")
+	// ErrInvalidToken подпись ссылки не сошлась или срок ее действия истек
+	ErrInvalidToken = errors.New("invalid token")
 
 	// Ошибки на уровне БД (repository)
 	ErrDB = errors.New("database error")
@@ -34,6 +42,24 @@ var (
 	ErrChecksumMismatch    = errors.New("checksum mismatch")
 )
 
+// FileExistsError имя в целевой папке уже занято. errors.Is(err, ErrFileExists) для нее истинно
+type FileExistsError struct {
+	// Name занятое имя
+	Name string
+	// ExistingID файл, который уже носит это имя
+	ExistingID uuid.UUID
+	// Suggestion свободное имя, под которым операция прошла бы
+	Suggestion string
+}
+
+func (e *FileExistsError) Error() string {
+	return fmt.Sprintf("%s: %q", ErrFileExists, e.Name)
+}
+
+func (e *FileExistsError) Unwrap() error {
+	return ErrFileExists
+}
+
 // New создает новую ошибку
 func New(text string) error {
 	return errors.New(text)
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"io"
 
 	"homecloud-file-service/internal/models"
 
@@ -18,7 +19,6 @@ type FileRepository interface {
 	DeleteFile(ctx context.Context, id uuid.UUID) error
 	SoftDeleteFile(ctx context.Context, id uuid.UUID) error
 	RestoreFile(ctx context.Context, id uuid.UUID) error
-	CreateFileFromFS(ctx context.Context, file *models.File) error
 
 	// Операции со списками файлов
 	ListFiles(ctx context.Context, req *models.FileListRequest) (*models.FileListResponse, error)
@@ -64,8 +64,19 @@ type FileRepository interface {
 // StorageRepository интерфейс для работы с файловым хранилищем
 type StorageRepository interface {
 	// Операции с файлами в хранилище
-	SaveFile(ctx context.Context, path string, content []byte) error
-	GetFile(ctx context.Context, path string) ([]byte, error)
+	// SaveFile потоково записывает content и возвращает размер и контрольные суммы,
+	// вычисленные в процессе записи
+	SaveFile(ctx context.Context, path string, content io.Reader) (*FileInfo, error)
+	// NewWriter открывает файл хранилища на запись
+	NewWriter(ctx context.Context, path string) (BlobWriter, error)
+	// GetFile открывает файл хранилища на чтение без загрузки в память
+	GetFile(ctx context.Context, path string) (BlobReader, error)
+	// GetVerifiedFile открывает файл, сверяя содержимое с sha256, если драйвер хранит несколько
+	// копий: небольшой файл сверяется до отдачи, большой - по ходу чтения, и при расхождении
+	// чтение продолжается с той же позиции из целой копии. Без целой копии возвращается
+	// ErrFileCorrupted. Поврежденные и пропавшие копии чинятся в фоне.
+	// Для драйверов с одной копией и пустого sha256 равносилен GetFile
+	GetVerifiedFile(ctx context.Context, path string, sha256 string) (BlobReader, error)
 	DeleteFile(ctx context.Context, path string) error
 	MoveFile(ctx context.Context, oldPath, newPath string) error
 	CopyFile(ctx context.Context, srcPath, dstPath string) error
@@ -78,10 +89,190 @@ type StorageRepository interface {
 	// Информация о файлах
 	GetFileInfo(ctx context.Context, path string) (*FileInfo, error)
 	GetDirectorySize(ctx context.Context, path string) (int64, error)
+	// Walk рекурсивно обходит все файлы под path, FileInfo.Path - ключ хранилища
+	Walk(ctx context.Context, path string, fn func(info *FileInfo) error) error
 
 	// Проверка целостности
 	CalculateChecksum(ctx context.Context, path string, algorithm string) (string, error)
 	VerifyChecksum(ctx context.Context, path string, expectedChecksum string, algorithm string) (bool, error)
+
+	// ExpiringStore удаляет временные файлы записей, прерванных без Close и Abort
+	ExpiringStore
+}
+
+// StorageBackend драйвер хранилища блобов (локальная ФС, S3 и т.д.).
+// Ключи - относительные пути внутри директории пользователей, разделитель "/"
+type StorageBackend interface {
+	// Name возвращает имя драйвера из конфигурации
+	Name() string
+
+	// Операции с объектами
+	NewWriter(ctx context.Context, key string) (BlobWriter, error)
+	Open(ctx context.Context, key string) (BlobReader, error)
+	Stat(ctx context.Context, key string) (*FileInfo, error)
+	Delete(ctx context.Context, key string) error
+	Move(ctx context.Context, srcKey, dstKey string) error
+	Copy(ctx context.Context, srcKey, dstKey string) error
+
+	// Операции с "директориями" (префиксами ключей)
+	MakeDir(ctx context.Context, key string) error
+	DeletePrefix(ctx context.Context, key string) error
+	// List возвращает имена непосредственных потомков ключа
+	List(ctx context.Context, key string) ([]string, error)
+	// Walk рекурсивно обходит все объекты под ключом
+	Walk(ctx context.Context, key string, fn func(info *FileInfo) error) error
+}
+
+// MirroredBackend драйвер, хранящий каждый объект в нескольких независимых копиях
+type MirroredBackend interface {
+	StorageBackend
+	// Mirrors возвращает драйверы отдельных копий в порядке приоритета чтения
+	Mirrors() []StorageBackend
+}
+
+// KeyLocker драйвер, умеющий блокировать ключ между процессами, работающими с одним
+// хранилищем (сервис и служебные утилиты). Драйверы без него (S3) блокировок не дают
+type KeyLocker interface {
+	// LockKey ждет монопольную блокировку ключа и возвращает функцию ее снятия
+	LockKey(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// BlobStore контентно-адресуемое хранилище: блобы лежат по SHA-256 содержимого,
+// на каждый блоб ведется счетчик ссылок (строки файлов и ревизий)
+type BlobStore interface {
+	// Put потоково записывает content и добавляет ссылку на блоб. Если блоб с таким
+	// SHA-256 уже есть, повторно данные не хранятся. FileInfo.Path - ключ блоба
+	Put(ctx context.Context, content io.Reader) (*FileInfo, error)
+	// Acquire добавляет ссылку на существующий блоб
+	Acquire(ctx context.Context, sha256 string) error
+	// Release убирает ссылку на блоб, последняя ссылка удаляет данные. Возвращает true, если блоб удален
+	Release(ctx context.Context, sha256 string) (bool, error)
+	// RefCount возвращает число ссылок на блоб
+	RefCount(ctx context.Context, sha256 string) (int64, error)
+	// SetRefCount заменяет счетчик ссылок значением, пересчитанным по БД. Ноль удаляет блоб
+	SetRefCount(ctx context.Context, sha256 string, refs int64) error
+	// Key возвращает ключ блоба в хранилище
+	Key(sha256 string) string
+	// ParseKey извлекает SHA-256 из ключа хранилища, ok == false для ключей вне хранилища блобов
+	ParseKey(key string) (sha256 string, ok bool)
+}
+
+// ParityStore блоки четности Рида-Соломона для содержимого. Четность хранится рядом с данными
+// по SHA-256 содержимого и позволяет восстановить поврежденные участки без второй копии
+type ParityStore interface {
+	// Protect сохраняет четность для содержимого path, сверив его с sha256. Если четность
+	// для этого содержимого уже есть, ничего не делает
+	Protect(ctx context.Context, path string, sha256 string) error
+	// Repair проверяет содержимое по блокам и восстанавливает поврежденные участки на месте.
+	// Если четности для содержимого нет, возвращает ParityRepair с Protected == false
+	Repair(ctx context.Context, path string, sha256 string) (*models.ParityRepair, error)
+	// Key возвращает ключ четности в хранилище
+	Key(sha256 string) string
+	// ParseKey извлекает SHA-256 из ключа четности, ok == false для прочих ключей
+	ParseKey(key string) (sha256 string, ok bool)
+}
+
+// WriteJournal журнал намерений записи. Запись журнала создается до изменения содержимого
+// и удаляется после обновления БД, поэтому после сбоя по оставшимся записям видно,
+// какие операции нужно довести до конца или откатить
+type WriteJournal interface {
+	// Begin надежно сохраняет новое намерение, Save - обновленное (например, смену стадии)
+	Begin(ctx context.Context, intent *models.WriteIntent) error
+	Save(ctx context.Context, intent *models.WriteIntent) error
+	// Commit удаляет намерение завершенной операции
+	Commit(ctx context.Context, id uuid.UUID) error
+	// Pending возвращает незавершенные намерения в порядке создания
+	Pending(ctx context.Context) ([]*models.WriteIntent, error)
+}
+
+// ScrubStatusStore хранит итоги фоновой проверки контрольных сумм по пользователям
+type ScrubStatusStore interface {
+	Save(ctx context.Context, status *models.ScrubStatus) error
+	// Get возвращает nil, если файлы пользователя еще не проверялись
+	Get(ctx context.Context, ownerID uuid.UUID) (*models.ScrubStatus, error)
+}
+
+// ExpiringStore хранилище с записями ограниченного срока жизни, которые периодически удаляются
+type ExpiringStore interface {
+	// RemoveExpired удаляет истекшие записи и возвращает их число
+	RemoveExpired(ctx context.Context) (int, error)
+}
+
+// UserDeletionStore хранит запросы на удаление данных пользователей до безвозвратного удаления
+type UserDeletionStore interface {
+	Save(ctx context.Context, deletion *models.UserDeletion) error
+	// Get возвращает nil, если удаление данных пользователя не запрошено
+	Get(ctx context.Context, userID uuid.UUID) (*models.UserDeletion, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+	// List возвращает все запросы, раньше запрошенные первыми
+	List(ctx context.Context) ([]models.UserDeletion, error)
+}
+
+// IdempotencyStore хранит ответы на запросы с Idempotency-Key, чтобы повтор запроса
+// получил исходный ответ, а не выполнил операцию второй раз
+type IdempotencyStore interface {
+	ExpiringStore
+	// Get возвращает nil, если ответа для ключа нет или его срок истек
+	Get(ctx context.Context, key string) (*models.IdempotentResponse, error)
+	// Save сохраняет ответ на срок хранения от текущего момента
+	Save(ctx context.Context, response *models.IdempotentResponse) error
+}
+
+// UploadSessionStore хранит сессии возобновляемой загрузки и принятые данные так,
+// чтобы незавершенные загрузки переживали перезапуск сервиса
+type UploadSessionStore interface {
+	// Save сохраняет сессию и продлевает ее срок на TTL от текущего момента
+	Save(ctx context.Context, session *models.ResumableSession) error
+	// Get возвращает nil, если сессии нет
+	Get(ctx context.Context, id string) (*models.ResumableSession, error)
+	// ListByUser возвращает незавершенные и не истекшие сессии пользователя, старые первыми
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.ResumableSession, error)
+	// Delete удаляет сессию вместе с принятыми данными
+	Delete(ctx context.Context, id string) error
+	// PartPath путь к файлу с принятыми данными сессии
+	PartPath(id string) string
+	// WriteChunk сохраняет часть [start, end] отдельным файлом, части одной сессии можно писать
+	// параллельно. Часть становится видна, только если r прочитан без ошибок и дал ровно
+	// end-start+1 байт; иначе она отбрасывается
+	WriteChunk(ctx context.Context, id string, start, end int64, r io.Reader) error
+	// ComposeChunks потоково собирает части по порядку в файл PartPath и удаляет их
+	ComposeChunks(ctx context.Context, id string, size int64) error
+	// RemoveExpired удаляет истекшие сессии и файлы данных без сессии. Возвращает число удаленных сессий и файлов
+	RemoveExpired(ctx context.Context) (int, error)
+}
+
+// DownloadSessionStore хранит сессии возобновляемого скачивания так, чтобы они переживали
+// перезапуск сервиса
+type DownloadSessionStore interface {
+	ExpiringStore
+	// Save сохраняет сессию со сроком TTL от текущего момента
+	Save(ctx context.Context, session *models.ResumableDownloadSession) error
+	// Get возвращает nil, если сессии нет
+	Get(ctx context.Context, id string) (*models.ResumableDownloadSession, error)
+	Delete(ctx context.Context, id string) error
+	// SigningKey ключ подписи ссылок на скачивание, созданный при первом обращении
+	SigningKey() ([]byte, error)
+}
+
+// BlobReader потоковый доступ к содержимому файла в хранилище
+type BlobReader interface {
+	io.ReadCloser
+	io.ReaderAt
+	// Seek переставляет позицию Read. Для последовательной отдачи файла или диапазона
+	// это дешевле ReadAt: в S3 ReadAt - отдельный запрос на каждый вызов
+	io.Seeker
+	// Size возвращает размер содержимого в байтах
+	Size() int64
+}
+
+// BlobWriter потоковая запись файла в хранилище с подсчетом контрольных сумм
+type BlobWriter interface {
+	io.WriteCloser
+	// Abort прерывает запись и удаляет частично записанные данные. После успешного Close
+	// ничего не делает, поэтому его можно безусловно вызывать в defer
+	Abort() error
+	// Info возвращает размер и контрольные суммы записанных данных, доступно после Close
+	Info() *FileInfo
 }
 
 // FileInfo информация о файле в хранилище
@@ -3,6 +3,7 @@ package interfaces
 import (
 	"context"
 	"io"
+	"time"
 
 	"homecloud-file-service/internal/models"
 
@@ -14,21 +15,30 @@ type FileService interface {
 	// Основные операции с файлами
 	CreateFile(ctx context.Context, req *models.CreateFileRequest, ownerID uuid.UUID) (*models.File, error)
 	GetFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (*models.File, error)
-	UpdateFile(ctx context.Context, fileID uuid.UUID, req *models.UpdateFileRequest, userID uuid.UUID) (*models.File, error)
-	DeleteFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) error
-	DeleteFileRecursive(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) error
+	// Изменяющие операции принимают models.WriteOptions: при несовпадении ожидаемой версии
+	// или содержимого они возвращают errdefs.ErrPreconditionFailed и ничего не меняют
+	UpdateFile(ctx context.Context, fileID uuid.UUID, req *models.UpdateFileRequest, userID uuid.UUID, opts models.WriteOptions) (*models.File, error)
+	DeleteFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID, opts models.WriteOptions) error
+	DeleteFileRecursive(ctx context.Context, fileID uuid.UUID, userID uuid.UUID, opts models.WriteOptions) error
 	RestoreFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) error
 
 	// Операции с контентом файлов
-	UploadFile(ctx context.Context, fileID uuid.UUID, content io.Reader, userID uuid.UUID) error
-	DownloadFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (io.ReadCloser, string, error)
-	GetFileContent(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) ([]byte, error)
+	UploadFile(ctx context.Context, fileID uuid.UUID, content io.Reader, userID uuid.UUID, opts models.WriteOptions) (*models.UploadResult, error)
+	DownloadFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (BlobReader, string, error)
+	GetFileContent(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (BlobReader, error)
 
 	// Возобновляемое скачивание
 	InitResumableDownload(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (*models.ResumableDownloadSession, error)
 	GetResumableDownloadSession(ctx context.Context, sessionID string) (*models.ResumableDownloadSession, error)
 	DownloadFileChunk(ctx context.Context, sessionID string, start, end uint64) (io.ReadCloser, error)
+	// OpenResumableDownload открывает содержимое файла сессии целиком. Если файл изменился
+	// после начала скачивания, возвращает errdefs.ErrPreconditionFailed
+	OpenResumableDownload(ctx context.Context, sessionID string) (BlobReader, error)
 	DeleteResumableDownloadSession(ctx context.Context, sessionID string) error
+	// SignDownloadToken выдает подписанную ссылку на сессию, по которой скачивают без авторизации
+	SignDownloadToken(ctx context.Context, sessionID string, userID uuid.UUID) (string, time.Time, error)
+	// ResolveDownloadToken проверяет подпись и срок ссылки и возвращает ее сессию
+	ResolveDownloadToken(ctx context.Context, token string) (*models.ResumableDownloadSession, error)
 
 	// Операции со списками файлов
 	ListFiles(ctx context.Context, req *models.FileListRequest) (*models.FileListResponse, error)
@@ -37,7 +47,7 @@ type FileService interface {
 	SearchFiles(ctx context.Context, userID uuid.UUID, query string) ([]models.File, error)
 
 	// Операции с папками
-	CreateFolder(ctx context.Context, name string, parentID *uuid.UUID, ownerID uuid.UUID) (*models.File, error)
+	CreateFolder(ctx context.Context, name string, parentID *uuid.UUID, ownerID uuid.UUID, conflict models.ConflictPolicy) (*models.File, error)
 	ListFolderContents(ctx context.Context, folderID *uuid.UUID, userID uuid.UUID) ([]models.File, error)
 	GetFileTree(ctx context.Context, rootID *uuid.UUID, userID uuid.UUID) ([]models.File, error)
 
@@ -45,7 +55,7 @@ type FileService interface {
 	CreateRevision(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (*models.FileRevision, error)
 	ListRevisions(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) ([]models.FileRevision, error)
 	GetRevision(ctx context.Context, fileID uuid.UUID, revisionID int64, userID uuid.UUID) (*models.FileRevision, error)
-	RestoreRevision(ctx context.Context, fileID uuid.UUID, revisionID int64, userID uuid.UUID) error
+	RestoreRevision(ctx context.Context, fileID uuid.UUID, revisionID int64, userID uuid.UUID, opts models.WriteOptions) error
 
 	// Операции с правами доступа
 	GrantPermission(ctx context.Context, fileID uuid.UUID, permission *models.FilePermission, userID uuid.UUID) error
@@ -56,12 +66,12 @@ type FileService interface {
 	// Специальные операции
 	StarFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) error
 	UnstarFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) error
-	MoveFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, userID uuid.UUID) error
-	CopyFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, newName string, userID uuid.UUID) (*models.File, error)
-	RenameFile(ctx context.Context, fileID uuid.UUID, newName string, userID uuid.UUID) error
+	MoveFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, userID uuid.UUID, opts models.WriteOptions) error
+	CopyFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, newName string, userID uuid.UUID, opts models.WriteOptions) (*models.File, error)
+	RenameFile(ctx context.Context, fileID uuid.UUID, newName string, userID uuid.UUID, opts models.WriteOptions) error
 
 	// Операции с метаданными
-	UpdateFileMetadata(ctx context.Context, fileID uuid.UUID, metadata map[string]interface{}, userID uuid.UUID) error
+	UpdateFileMetadata(ctx context.Context, fileID uuid.UUID, metadata map[string]interface{}, userID uuid.UUID, opts models.WriteOptions) error
 	GetFileMetadata(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (map[string]interface{}, error)
 
 	// Проверка целостности
@@ -70,13 +80,27 @@ type FileService interface {
 
 	// Операции с детальной информацией
 	GetFileDetails(ctx context.Context, userID uuid.UUID, filePath string) (*models.File, error)
+
+	// RecoverPendingWrites проигрывает журнал намерений записи после перезапуска
+	RecoverPendingWrites(ctx context.Context) error
+
+	// Данные пользователя целиком
+	// DeleteUserData переносит все файлы пользователя в корзину и назначает их безвозвратное
+	// удаление после срока отсрочки, с immediate - удаляет сразу
+	DeleteUserData(ctx context.Context, userID uuid.UUID, immediate bool) (*models.UserDeletion, error)
+	// CancelUserDataDeletion отменяет удаление до конца отсрочки, errdefs.ErrNotFound - если его не было
+	CancelUserDataDeletion(ctx context.Context, userID uuid.UUID) error
+	// PurgeDeletedUsers удаляет данные пользователей с истекшей отсрочкой и возвращает их число
+	PurgeDeletedUsers(ctx context.Context) (int, error)
+	GetUserStorageUsage(ctx context.Context, userID uuid.UUID) (*models.StorageUsage, error)
 }
 
 // StorageService интерфейс для работы с файловым хранилищем
 type StorageService interface {
 	// Основные операции
-	SaveFile(ctx context.Context, path string, content []byte) error
-	GetFile(ctx context.Context, path string) ([]byte, error)
+	SaveFile(ctx context.Context, path string, content io.Reader) (*FileInfo, error)
+	NewWriter(ctx context.Context, path string) (BlobWriter, error)
+	GetFile(ctx context.Context, path string) (BlobReader, error)
 	DeleteFile(ctx context.Context, path string) error
 	MoveFile(ctx context.Context, oldPath, newPath string) error
 	CopyFile(ctx context.Context, srcPath, dstPath string) error
@@ -98,4 +122,16 @@ type StorageService interface {
 	// Очистка и обслуживание
 	CleanupOrphanedFiles(ctx context.Context) error
 	OptimizeStorage(ctx context.Context) error
+	// CheckStorage сверяет хранилище с dbmanager и исправляет расхождения, если dryRun == false.
+	// Пустой owners - проверка всего хранилища
+	CheckStorage(ctx context.Context, owners []uuid.UUID, dryRun bool) (*models.StorageCheckReport, error)
+	// GetScrubStatus возвращает итог последней фоновой проверки контрольных сумм пользователя
+	GetScrubStatus(ctx context.Context, ownerID uuid.UUID) (*models.ScrubStatus, error)
+}
+
+// UserTemplateApplier создает папки и файлы шаблона в корне пользователя
+type UserTemplateApplier interface {
+	// Apply дописывает недостающее из шаблона, уже созданное не трогает. Пустая локаль - локаль
+	// по умолчанию. С dryRun ничего не создает, только возвращает отчет
+	Apply(ctx context.Context, ownerID uuid.UUID, username, locale string, dryRun bool) (*models.UserTemplateReport, error)
 }
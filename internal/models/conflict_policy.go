@@ -0,0 +1,30 @@
+package models
+
+import "fmt"
+
+// ConflictPolicy что делать, если в целевой папке уже есть файл с таким именем
+type ConflictPolicy string
+
+const (
+	// ConflictFail операция отклоняется с errdefs.ErrFileExists. Поведение по умолчанию
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictRename новый файл получает свободное имя вида "name (2).ext"
+	ConflictRename ConflictPolicy = "rename"
+	// ConflictReplace существующий файл удаляется безвозвратно, новый занимает его место
+	ConflictReplace ConflictPolicy = "replace"
+	// ConflictNewVersion содержимое записывается новой версией существующего файла с сохранением
+	// истории. Для папок - используется уже существующая папка
+	ConflictNewVersion ConflictPolicy = "new_version"
+)
+
+// ParseConflictPolicy разбирает политику из запроса. Пустая строка - ConflictFail
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(s); policy {
+	case "":
+		return ConflictFail, nil
+	case ConflictFail, ConflictRename, ConflictReplace, ConflictNewVersion:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q: expected fail, rename, replace or new_version", s)
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -67,6 +68,10 @@ type CreateFileRequest struct {
 	MimeType string     `json:"mime_type,omitempty"`
 	Size     int64      `json:"size,omitempty"`
 	Content  []byte     `json:"content,omitempty"`
+	// ContentReader потоковый источник содержимого, имеет приоритет над Content
+	ContentReader io.Reader `json:"-"`
+	// Conflict что делать, если имя в папке уже занято. Пустое значение - ConflictFail
+	Conflict ConflictPolicy `json:"conflict,omitempty"`
 }
 
 // UpdateFileRequest запрос на обновление файла
@@ -77,6 +82,38 @@ type UpdateFileRequest struct {
 	Content  []byte     `json:"content,omitempty"`
 }
 
+// WriteOptions условия изменяющей операции над файлом (оптимистичная блокировка).
+// Нулевое значение - без условий
+type WriteOptions struct {
+	// ExpectedVersion операция выполняется, только если File.Version равен ему. 0 - без проверки
+	ExpectedVersion int64
+	// ExpectedSHA256 операция выполняется, только если содержимое файла имеет эту контрольную сумму
+	ExpectedSHA256 string
+	// ConflictCopy при устаревшей базовой версии загрузка не отклоняется, а сохраняется рядом
+	// копией "name (conflicted copy from <device> <date>).ext". Действует для UploadFile
+	ConflictCopy bool
+	// Device имя устройства клиента для имени конфликтной копии
+	Device string
+	// Conflict что делать, если имя в целевой папке уже занято. Действует для MoveFile и CopyFile
+	Conflict ConflictPolicy
+}
+
+// UploadResult итог загрузки содержимого файла
+type UploadResult struct {
+	// FileID файл, в который была адресована загрузка
+	FileID uuid.UUID `json:"file_id"`
+	// Version версия файла после загрузки
+	Version int64 `json:"version"`
+	// ConflictCopyID копия с загруженным содержимым, если базовая версия устарела.
+	// Сам файл в этом случае не меняется
+	ConflictCopyID *uuid.UUID `json:"conflict_copy_id,omitempty"`
+}
+
+// Conditional есть ли у операции условия
+func (o WriteOptions) Conditional() bool {
+	return o.ExpectedVersion != 0 || o.ExpectedSHA256 != ""
+}
+
 // FileListRequest запрос на получение списка файлов
 type FileListRequest struct {
 	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
@@ -123,6 +160,20 @@ type ResumableSession struct {
 	FilePath    string     `json:"file_path" db:"file_path"`
 	Size        int64      `json:"size" db:"size"`
 	Checksum    string     `json:"checksum" db:"checksum"`
+	// ParentID папка, в которой будет создан файл
+	ParentID    *uuid.UUID `json:"parent_id,omitempty" db:"parent_id"`
+	// Offset сколько байт принято подряд с начала файла
+	Offset      int64      `json:"offset" db:"offset"`
+	// Metadata исходный заголовок Upload-Metadata загрузки tus
+	Metadata    string     `json:"metadata,omitempty" db:"metadata"`
+	// Received принятые диапазоны байт, упорядоченные и без пересечений
+	Received    []ByteRange `json:"received,omitempty" db:"received"`
+	// Conflict что делать при завершении, если имя в папке уже занято
+	Conflict    ConflictPolicy `json:"conflict,omitempty" db:"conflict"`
+	// FileID файл, новой версией которого станет загрузка. Пусто - создается новый файл
+	FileID      *uuid.UUID `json:"file_id,omitempty" db:"file_id"`
+	// ExpectedVersion версия FileID, от которой начата загрузка, 0 - без проверки
+	ExpectedVersion int64  `json:"expected_version,omitempty" db:"expected_version"`
 	UploadedAt  *time.Time `json:"uploaded_at,omitempty" db:"uploaded_at"`
 	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
 	IsCompleted bool       `json:"is_completed" db:"is_completed"`
@@ -130,6 +181,24 @@ type ResumableSession struct {
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// Expired истек ли срок сессии
+func (s *ResumableSession) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && now.After(s.ExpiresAt)
+}
+
+// IdempotentResponse сохраненный ответ на запрос с Idempotency-Key
+type IdempotentResponse struct {
+	// Key ключ запроса в пределах пользователя
+	Key string `json:"key"`
+	// Fingerprint метод и адрес исходного запроса: тот же ключ для другого запроса - ошибка клиента
+	Fingerprint string              `json:"fingerprint"`
+	StatusCode  int                 `json:"status_code"`
+	Header      map[string][]string `json:"header"`
+	Body        []byte              `json:"body"`
+	CreatedAt   time.Time           `json:"created_at"`
+	ExpiresAt   time.Time           `json:"expires_at"`
+}
+
 // ResumableDownloadSession представляет сессию для возобновляемого скачивания
 type ResumableDownloadSession struct {
 	ID          string     `json:"id"`
@@ -140,6 +209,207 @@ type ResumableDownloadSession struct {
 	Size        int64      `json:"size"`
 	Checksum    string     `json:"checksum"`
 	MimeType    string     `json:"mime_type"`
+	// Version версия файла на момент начала: если файл изменится, докачка остановится,
+	// а не склеит части разных версий
+	Version     int64      `json:"version"`
 	ExpiresAt   time.Time  `json:"expires_at"`
 	CreatedAt   time.Time  `json:"created_at"`
 }
+
+// Expired истек ли срок сессии
+func (s *ResumableDownloadSession) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && now.After(s.ExpiresAt)
+}
+
+// Операции, которые фиксируются в журнале намерений записи
+const (
+	WriteOpCreate  = "create"
+	WriteOpUpload  = "upload"
+	WriteOpRestore = "restore"
+	// WriteOpCopy копирование файла: FileID - исходный файл, копия ищется по ParentID и Name
+	WriteOpCopy = "copy"
+	// WriteOpDelete удаление файла: StoragePath - место в .deleting, куда отложено содержимое,
+	// PreviousPath - прежнее место содержимого
+	WriteOpDelete = "delete"
+)
+
+// Стадии записи в журнале намерений
+const (
+	// WriteStagePending содержимое еще пишется, после сбоя операция откатывается
+	WriteStagePending = "pending"
+	// WriteStageWritten содержимое надежно записано, после сбоя в БД дописываются метаданные
+	WriteStageWritten = "written"
+)
+
+// WriteIntent запись журнала намерений: операция над содержимым файла, которая
+// должна либо завершиться целиком, либо быть откачена при следующем запуске
+type WriteIntent struct {
+	ID             uuid.UUID  `json:"id"`
+	Op             string     `json:"op"`
+	Stage          string     `json:"stage"`
+	FileID         uuid.UUID  `json:"file_id"`
+	OwnerID        uuid.UUID  `json:"owner_id"`
+	StoragePath    string     `json:"storage_path,omitempty"`
+	PreviousPath   string     `json:"previous_path,omitempty"`
+	Size           int64      `json:"size,omitempty"`
+	MD5Checksum    string     `json:"md5_checksum,omitempty"`
+	SHA256Checksum string     `json:"sha256_checksum,omitempty"`
+	Version        int64      `json:"version,omitempty"`
+	ParentID       *uuid.UUID `json:"parent_id,omitempty"`
+	Name           string     `json:"name,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Виды расхождений между хранилищем и dbmanager, которые находит проверка хранилища
+const (
+	// StorageIssueOrphanObject объект в хранилище, на который не ссылается ни одна запись
+	StorageIssueOrphanObject = "orphan_object"
+	// StorageIssueMissingObject запись ссылается на отсутствующий объект
+	StorageIssueMissingObject = "missing_object"
+	// StorageIssueSizeMismatch размер объекта не совпадает с размером в БД
+	StorageIssueSizeMismatch = "size_mismatch"
+	// StorageIssueChecksumMismatch содержимое объекта не совпадает с контрольной суммой в БД
+	StorageIssueChecksumMismatch = "checksum_mismatch"
+	// StorageIssueRefCountMismatch счетчик ссылок блоба не совпадает с числом записей на него
+	StorageIssueRefCountMismatch = "refcount_mismatch"
+	// StorageIssueStaleTemp временный файл, оставшийся после прерванной операции
+	StorageIssueStaleTemp = "stale_temp"
+)
+
+// StorageIssue расхождение между хранилищем и dbmanager
+type StorageIssue struct {
+	Kind string `json:"kind"`
+	// Path ключ объекта в хранилище
+	Path       string     `json:"path"`
+	OwnerID    *uuid.UUID `json:"owner_id,omitempty"`
+	FileID     *uuid.UUID `json:"file_id,omitempty"`
+	RevisionID int64      `json:"revision_id,omitempty"`
+	Expected   string     `json:"expected,omitempty"`
+	Actual     string     `json:"actual,omitempty"`
+	// Fixed расхождение исправлено, Error - почему исправить не удалось
+	Fixed bool   `json:"fixed"`
+	Error string `json:"error,omitempty"`
+}
+
+// StorageCheckReport итог проверки хранилища
+type StorageCheckReport struct {
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Owners     int       `json:"owners"`
+	// OwnersIncomplete драйвер хранилища (S3) не хранит каталоги пользователей, по которым
+	// собирается их список. Пользователи без каталога могли не попасть в проверку, поэтому
+	// общие блобы и четность не удаляются, а их счетчики ссылок не меняются
+	OwnersIncomplete bool `json:"owners_incomplete,omitempty"`
+	Rows             int  `json:"rows"`
+	Objects          int  `json:"objects"`
+	// Skipped объекты, измененные недавно: они могут принадлежать незавершенной операции
+	Skipped int            `json:"skipped"`
+	Fixed   int            `json:"fixed"`
+	Issues  []StorageIssue `json:"issues"`
+}
+
+// Состояния фоновой проверки контрольных сумм пользователя
+const (
+	ScrubStateNeverRun  = "never_run"
+	ScrubStateRunning   = "running"
+	ScrubStateCompleted = "completed"
+	ScrubStateFailed    = "failed"
+)
+
+// Отметка о целостности файла в его метаданных, которую ставит фоновая проверка
+const (
+	MetadataIntegrity          = "integrity"
+	MetadataIntegrityCheckedAt = "integrity_checked_at"
+
+	FileIntegrityOK        = "ok"
+	FileIntegrityCorrupted = "corrupted"
+	FileIntegrityMissing   = "missing"
+)
+
+// ScrubStatus итог последней фоновой проверки контрольных сумм файлов пользователя
+type ScrubStatus struct {
+	OwnerID      uuid.UUID  `json:"owner_id"`
+	State        string     `json:"state"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	FilesChecked int        `json:"files_checked"`
+	BytesChecked int64      `json:"bytes_checked"`
+	// Unverified файлы без SHA-256 в БД: сверять их не с чем
+	Unverified int         `json:"unverified"`
+	Corrupted  []uuid.UUID `json:"corrupted"`
+	Missing    []uuid.UUID `json:"missing"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// ContentRange участок содержимого файла
+type ContentRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// ParityRepair итог проверки содержимого по блокам четности
+type ParityRepair struct {
+	// Protected для содержимого есть блоки четности, без них проверка не выполнялась
+	Protected bool `json:"protected"`
+	// Damaged поврежденные участки содержимого
+	Damaged []ContentRange `json:"damaged,omitempty"`
+	// Repaired поврежденные участки восстановлены и записаны на место
+	Repaired bool `json:"repaired"`
+	// ParityRebuilt блоки четности были повреждены или устарели и записаны заново
+	ParityRebuilt bool `json:"parity_rebuilt"`
+}
+
+// UserDeletion запрос на удаление всех данных пользователя. До PurgeAt файлы лежат
+// в корзине и удаление можно отменить
+type UserDeletion struct {
+	UserID      uuid.UUID `json:"user_id"`
+	RequestedAt time.Time `json:"requested_at"`
+	// PurgeAt когда данные будут удалены безвозвратно
+	PurgeAt time.Time `json:"purge_at"`
+	// Trashed файлы, которые в корзину перенесло само удаление. Отмена восстанавливает
+	// только их, а удаленное пользователем раньше остается в корзине
+	Trashed  []uuid.UUID `json:"trashed,omitempty"`
+	PurgedAt *time.Time  `json:"purged_at,omitempty"`
+}
+
+// Категории файлов в статистике использования хранилища
+const (
+	UsageCategoryDocuments = "documents"
+	UsageCategoryImages    = "images"
+	UsageCategoryVideos    = "videos"
+	UsageCategoryAudio     = "audio"
+	UsageCategoryArchives  = "archives"
+	UsageCategoryOther     = "other"
+)
+
+// CategoryUsage занятое место и число файлов одной категории
+type CategoryUsage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// StorageUsage использование хранилища пользователем. Файлы в корзине учитываются
+// только в Trash*, категории считаются без них
+type StorageUsage struct {
+	UserID         uuid.UUID                `json:"user_id"`
+	TotalBytes     int64                    `json:"total_bytes"`
+	FileCount      int64                    `json:"file_count"`
+	FolderCount    int64                    `json:"folder_count"`
+	TrashBytes     int64                    `json:"trash_bytes"`
+	TrashFileCount int64                    `json:"trash_file_count"`
+	Categories     map[string]CategoryUsage `json:"categories"`
+	// DeletionPurgeAt когда данные будут удалены, если удаление запрошено
+	DeletionPurgeAt *time.Time `json:"deletion_purge_at,omitempty"`
+}
+
+// UserTemplateReport итог применения шаблона к одному пользователю
+type UserTemplateReport struct {
+	OwnerID uuid.UUID `json:"owner_id"`
+	Locale  string    `json:"locale"`
+	// Created пути созданных папок и файлов от корня пользователя
+	Created  []string `json:"created,omitempty"`
+	Existing int      `json:"existing"`
+	// Skipped записи, которые лежат в корзине или заняты записью другого типа
+	Skipped []string `json:"skipped,omitempty"`
+}
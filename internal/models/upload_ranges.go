@@ -0,0 +1,57 @@
+package models
+
+import "sort"
+
+// ByteRange диапазон байт, оба конца включительно
+type ByteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// AddReceived отмечает принятый диапазон, объединяя его с пересекающимися и соседними,
+// и продвигает Offset до первого пропуска. Повторно присланные части ничего не меняют
+func (s *ResumableSession) AddReceived(start, end int64) {
+	if end < start {
+		return
+	}
+	ranges := append(s.Received, ByteRange{Start: start, End: end})
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End+1 {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	s.Received = merged
+
+	if merged[0].Start == 0 && merged[0].End+1 > s.Offset {
+		s.Offset = merged[0].End + 1
+	}
+}
+
+// Missing диапазоны, которые еще не приняты
+func (s *ResumableSession) Missing() []ByteRange {
+	missing := []ByteRange{}
+	next := int64(0)
+	for _, r := range s.Received {
+		if r.Start > next {
+			missing = append(missing, ByteRange{Start: next, End: r.Start - 1})
+		}
+		next = r.End + 1
+	}
+	if next < s.Size {
+		missing = append(missing, ByteRange{Start: next, End: s.Size - 1})
+	}
+	return missing
+}
+
+// Completed приняты ли все байты файла
+func (s *ResumableSession) Completed() bool {
+	return s.Offset >= s.Size
+}
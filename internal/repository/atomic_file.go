@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// partialDir каталог незавершенных записей внутри корня локального хранилища.
+	// Он на той же файловой системе, что и данные, поэтому rename в целевой путь атомарен
+	partialDir = ".partial"
+	// partialFilePrefix префикс временных файлов, которые переименовываются в целевой после fsync
+	partialFilePrefix = ".partial-"
+	// partialFileTTL после этого времени без записи временный файл считается брошенным
+	partialFileTTL = time.Hour
+)
+
+// createPartialFile создает временный файл в каталоге dir
+func createPartialFile(dir string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, partialFilePrefix+uuid.New().String()), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	return file, nil
+}
+
+// commitPartialFile сбрасывает временный файл на диск и атомарно заменяет им целевой.
+// После сбоя на месте target остается либо прежнее, либо новое содержимое целиком
+func commitPartialFile(file *os.File, target string) error {
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(file.Name(), target); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return syncDir(filepath.Dir(target))
+}
+
+// writeFileAtomic записывает небольшой файл целиком через временный файл и rename
+func writeFileAtomic(target string, data []byte) error {
+	file, err := createPartialFile(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return commitPartialFile(file, target)
+}
+
+// syncDir сбрасывает на диск запись каталога, иначе rename может не пережить сбой питания
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// removeStalePartialFiles удаляет брошенные временные файлы, оставшиеся после сбоя.
+// Активная запись обновляет mtime, поэтому долгие загрузки не затрагиваются
+func removeStalePartialFiles(dir string, olderThan time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), partialFilePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < olderThan {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// blobStorePrefix каталог блобов внутри директории пользователей.
+	// Имена пользовательских каталогов - UUID, поэтому пересечений нет
+	blobStorePrefix = ".cas"
+	// blobTempPrefix каталог для записи блобов, пока их SHA-256 еще не известен
+	blobTempPrefix = ".cas/tmp"
+	// blobRefsSuffix суффикс файла со счетчиком ссылок рядом с блобом
+	blobRefsSuffix = ".refs"
+)
+
+type blobStore struct {
+	storage interfaces.StorageRepository
+	// Счетчики ссылок меняются по схеме read-modify-write, поэтому изменения сериализуются
+	// мьютексом внутри процесса и блокировкой хранилища между процессами: служебные
+	// утилиты (fsck, apply-user-template) работают с блобами рядом с сервисом
+	mu     sync.Mutex
+	locker interfaces.KeyLocker
+}
+
+// NewBlobStore создает контентно-адресуемое хранилище поверх файлового хранилища
+func NewBlobStore(storage interfaces.StorageRepository) interfaces.BlobStore {
+	locker, _ := storage.(interfaces.KeyLocker)
+	return &blobStore{storage: storage, locker: locker}
+}
+
+// Key раскладывает блобы по каталогам ab/cd/<sha256>, чтобы не держать миллионы файлов в одной директории
+func (b *blobStore) Key(sha256 string) string {
+	return path.Join(blobStorePrefix, sha256[:2], sha256[2:4], sha256)
+}
+
+func (b *blobStore) ParseKey(key string) (string, bool) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return "", false
+	}
+
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != blobStorePrefix {
+		return "", false
+	}
+
+	sha256 := parts[3]
+	if !isSHA256(sha256) || parts[1] != sha256[:2] || parts[2] != sha256[2:4] {
+		return "", false
+	}
+	return sha256, true
+}
+
+func (b *blobStore) Put(ctx context.Context, content io.Reader) (*interfaces.FileInfo, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	// Пишем во временный ключ: хэш содержимого известен только после записи
+	tempKey := path.Join(blobTempPrefix, uuid.New().String())
+	info, err := b.storage.SaveFile(ctx, tempKey, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	sha256 := info.SHA256Checksum
+	key := b.Key(sha256)
+
+	unlock, err := b.lockRefs(ctx, sha256)
+	if err != nil {
+		b.storage.DeleteFile(ctx, tempKey)
+		return nil, err
+	}
+	defer unlock()
+
+	refs, err := b.readRefs(ctx, sha256)
+	if err != nil {
+		b.storage.DeleteFile(ctx, tempKey)
+		return nil, err
+	}
+
+	if refs > 0 {
+		// Такое содержимое уже хранится - копия не нужна
+		if err := b.storage.DeleteFile(ctx, tempKey); err != nil {
+			lg.Error(ctx, "Failed to delete duplicate blob", zap.Error(err), zap.String("key", tempKey))
+		}
+		lg.Info(ctx, "Blob deduplicated", zap.String("sha256", sha256), zap.Int64("refs", refs+1))
+	} else {
+		if err := b.storage.MoveFile(ctx, tempKey, key); err != nil {
+			b.storage.DeleteFile(ctx, tempKey)
+			return nil, fmt.Errorf("failed to store blob: %w", err)
+		}
+	}
+
+	if err := b.writeRefs(ctx, sha256, refs+1); err != nil {
+		if refs == 0 {
+			// Блоб без счетчика считался бы свободным, хотя его только что положили
+			if err := b.storage.DeleteFile(ctx, key); err != nil {
+				lg.Error(ctx, "Failed to delete unreferenced blob", zap.Error(err), zap.String("key", key))
+			}
+		}
+		return nil, err
+	}
+
+	info.Path = key
+	return info, nil
+}
+
+func (b *blobStore) Acquire(ctx context.Context, sha256 string) error {
+	if !isSHA256(sha256) {
+		return fmt.Errorf("%w: invalid sha256 %q", errdefs.ErrInvalidInput, sha256)
+	}
+
+	unlock, err := b.lockRefs(ctx, sha256)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	refs, err := b.readRefs(ctx, sha256)
+	if err != nil {
+		return err
+	}
+	if refs == 0 {
+		return fmt.Errorf("%w: blob %s", errdefs.ErrFileNotFound, sha256)
+	}
+
+	return b.writeRefs(ctx, sha256, refs+1)
+}
+
+func (b *blobStore) Release(ctx context.Context, sha256 string) (bool, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if !isSHA256(sha256) {
+		return false, fmt.Errorf("%w: invalid sha256 %q", errdefs.ErrInvalidInput, sha256)
+	}
+
+	unlock, err := b.lockRefs(ctx, sha256)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	refs, err := b.readRefs(ctx, sha256)
+	if err != nil {
+		return false, err
+	}
+
+	if refs > 1 {
+		return false, b.writeRefs(ctx, sha256, refs-1)
+	}
+
+	// Последняя ссылка - освобождаем место
+	if err := b.storage.DeleteFile(ctx, b.Key(sha256)); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return false, fmt.Errorf("failed to delete blob: %w", err)
+	}
+	if err := b.storage.DeleteFile(ctx, b.refsKey(sha256)); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return false, fmt.Errorf("failed to delete blob refs: %w", err)
+	}
+
+	lg.Info(ctx, "Blob released", zap.String("sha256", sha256))
+	return true, nil
+}
+
+func (b *blobStore) RefCount(ctx context.Context, sha256 string) (int64, error) {
+	if !isSHA256(sha256) {
+		return 0, fmt.Errorf("%w: invalid sha256 %q", errdefs.ErrInvalidInput, sha256)
+	}
+
+	unlock, err := b.lockRefs(ctx, sha256)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return b.readRefs(ctx, sha256)
+}
+
+func (b *blobStore) SetRefCount(ctx context.Context, sha256 string, refs int64) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if !isSHA256(sha256) {
+		return fmt.Errorf("%w: invalid sha256 %q", errdefs.ErrInvalidInput, sha256)
+	}
+
+	unlock, err := b.lockRefs(ctx, sha256)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if refs > 0 {
+		return b.writeRefs(ctx, sha256, refs)
+	}
+
+	if err := b.storage.DeleteFile(ctx, b.Key(sha256)); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	if err := b.storage.DeleteFile(ctx, b.refsKey(sha256)); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return fmt.Errorf("failed to delete blob refs: %w", err)
+	}
+
+	lg.Info(ctx, "Unreferenced blob deleted", zap.String("sha256", sha256))
+	return nil
+}
+
+// lockRefs блокирует счетчик ссылок блоба. Блокировка хранилища берется на каталог
+// блоба ab/cd, а не на сам счетчик: файл счетчика заменяется через rename
+func (b *blobStore) lockRefs(ctx context.Context, sha256 string) (func(), error) {
+	b.mu.Lock()
+	if b.locker == nil {
+		return b.mu.Unlock, nil
+	}
+	unlock, err := b.locker.LockKey(ctx, path.Dir(b.Key(sha256)))
+	if err != nil {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("failed to lock blob refs: %w", err)
+	}
+	return func() {
+		unlock()
+		b.mu.Unlock()
+	}, nil
+}
+
+func (b *blobStore) refsKey(sha256 string) string {
+	return b.Key(sha256) + blobRefsSuffix
+}
+
+// readRefs читает счетчик ссылок, отсутствие файла означает ноль ссылок
+func (b *blobStore) readRefs(ctx context.Context, sha256 string) (int64, error) {
+	reader, err := b.storage.GetFile(ctx, b.refsKey(sha256))
+	if errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read blob refs: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read blob refs: %w", err)
+	}
+
+	refs, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("corrupted blob refs for %s: %w", sha256, err)
+	}
+	return refs, nil
+}
+
+func (b *blobStore) writeRefs(ctx context.Context, sha256 string, refs int64) error {
+	if _, err := b.storage.SaveFile(ctx, b.refsKey(sha256), strings.NewReader(strconv.FormatInt(refs, 10))); err != nil {
+		return fmt.Errorf("failed to write blob refs: %w", err)
+	}
+	return nil
+}
+
+// isSHA256 проверяет, что строка - SHA-256 в hex
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestContext возвращает контекст с логгером, без которого репозитории не работают
+func newTestContext(t *testing.T) context.Context {
+	cfg := &config.Config{}
+	cfg.Logger.Config = zap.NewDevelopmentConfig()
+	cfg.Logger.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+
+	lg, err := logger.New(cfg)
+	require.NoError(t, err)
+	return logger.CtxWWithLogger(context.Background(), lg)
+}
+
+func TestBlobStoreDeduplicatesAndCountsReferences(t *testing.T) {
+	ctx := newTestContext(t)
+	root := t.TempDir()
+	store := NewBlobStore(NewStorageRepositoryWithBackend(NewLocalBackend(root), &config.Config{}))
+
+	// Одинаковое содержимое от двух пользователей хранится один раз
+	first, err := store.Put(ctx, strings.NewReader("family photo"))
+	require.NoError(t, err)
+	second, err := store.Put(ctx, strings.NewReader("family photo"))
+	require.NoError(t, err)
+	assert.Equal(t, first.Path, second.Path)
+
+	sha256 := first.SHA256Checksum
+	parsed, ok := store.ParseKey(first.Path)
+	require.True(t, ok)
+	assert.Equal(t, sha256, parsed)
+
+	// Копия файла добавляет ссылку
+	require.NoError(t, store.Acquire(ctx, sha256))
+	refs, err := store.RefCount(ctx, sha256)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), refs)
+
+	// Временные файлы после записи не остаются
+	tmp, err := os.ReadDir(filepath.Join(root, blobTempPrefix))
+	require.NoError(t, err)
+	assert.Empty(t, tmp)
+
+	// Место освобождает только последняя ссылка
+	for i := 0; i < 2; i++ {
+		freed, err := store.Release(ctx, sha256)
+		require.NoError(t, err)
+		assert.False(t, freed)
+	}
+	reader, err := NewLocalBackend(root).Open(ctx, first.Path)
+	require.NoError(t, err)
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "family photo", string(content))
+
+	freed, err := store.Release(ctx, sha256)
+	require.NoError(t, err)
+	assert.True(t, freed)
+	_, err = NewLocalBackend(root).Stat(ctx, first.Path)
+	assert.True(t, errdefs.Is(err, errdefs.ErrFileNotFound))
+
+	// После освобождения ссылки на блоб взять нельзя
+	assert.True(t, errdefs.Is(store.Acquire(ctx, sha256), errdefs.ErrFileNotFound))
+}
+
+// refsFailingStorage хранилище, в котором не записываются счетчики ссылок
+type refsFailingStorage struct {
+	interfaces.StorageRepository
+}
+
+func (s *refsFailingStorage) SaveFile(ctx context.Context, path string, content io.Reader) (*interfaces.FileInfo, error) {
+	if strings.HasSuffix(path, blobRefsSuffix) {
+		return nil, errors.New("disk full")
+	}
+	return s.StorageRepository.SaveFile(ctx, path, content)
+}
+
+func TestBlobStoreRefsAcrossStores(t *testing.T) {
+	ctx := newTestContext(t)
+	root := t.TempDir()
+	newStore := func() interfaces.BlobStore {
+		return NewBlobStore(NewStorageRepositoryWithBackend(NewLocalBackend(root), &config.Config{}))
+	}
+
+	// Два хранилища поверх одного корня - как сервис и утилита в разных процессах:
+	// ни одно изменение счетчика не теряется
+	blob, err := newStore().Put(ctx, strings.NewReader("shared"))
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for _, store := range []interfaces.BlobStore{newStore(), newStore()} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				assert.NoError(t, store.Acquire(ctx, blob.SHA256Checksum))
+			}
+		}()
+	}
+	wg.Wait()
+	refs, err := newStore().RefCount(ctx, blob.SHA256Checksum)
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), refs)
+
+	// Новый блоб без записанного счетчика не остается в хранилище
+	failing := NewBlobStore(&refsFailingStorage{NewStorageRepositoryWithBackend(NewLocalBackend(root), &config.Config{})})
+	_, err = failing.Put(ctx, strings.NewReader("orphan"))
+	require.Error(t, err)
+	var blobs []string
+	require.NoError(t, NewLocalBackend(root).Walk(ctx, blobStorePrefix, func(info *interfaces.FileInfo) error {
+		blobs = append(blobs, info.Path)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{blob.Path, blob.Path + blobRefsSuffix}, blobs)
+}
+
+func TestBlobStoreParseKeyRejectsForeignPaths(t *testing.T) {
+	store := NewBlobStore(nil)
+
+	for _, key := range []string{
+		"550e8400-e29b-41d4-a716-446655440000/photo.jpg",
+		".cas/tmp/550e8400-e29b-41d4-a716-446655440000",
+		".cas/00/00/" + strings.Repeat("ab", 32),
+	} {
+		_, ok := store.ParseKey(key)
+		assert.False(t, ok, key)
+	}
+}
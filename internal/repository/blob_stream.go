@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"homecloud-file-service/internal/interfaces"
+)
+
+// checksumWriter подсчитывает размер и контрольные суммы данных, проходящих через него
+type checksumWriter struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	size   int64
+}
+
+func newChecksumWriter() *checksumWriter {
+	return &checksumWriter{
+		md5:    md5.New(),
+		sha256: sha256.New(),
+	}
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	c.md5.Write(p)
+	c.sha256.Write(p)
+	c.size += int64(len(p))
+	return len(p), nil
+}
+
+// info возвращает накопленные размер и контрольные суммы
+func (c *checksumWriter) info(path string) *interfaces.FileInfo {
+	return &interfaces.FileInfo{
+		Path:           path,
+		Size:           c.size,
+		MD5Checksum:    fmt.Sprintf("%x", c.md5.Sum(nil)),
+		SHA256Checksum: fmt.Sprintf("%x", c.sha256.Sum(nil)),
+	}
+}
+
+// fileWriter реализация interfaces.BlobWriter поверх файла локальной ФС.
+// Данные пишутся во временный файл, Close делает fsync и атомарно переименовывает его в target
+type fileWriter struct {
+	file   *os.File
+	target string
+	path   string
+	sums   *checksumWriter
+	w      io.Writer
+	closed bool
+	info   *interfaces.FileInfo
+}
+
+func newFileWriter(file *os.File, target, path string) *fileWriter {
+	sums := newChecksumWriter()
+	return &fileWriter{
+		file:   file,
+		target: target,
+		path:   path,
+		sums:   sums,
+		w:      io.MultiWriter(file, sums),
+	}
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("write to closed blob writer")
+	}
+	return w.w.Write(p)
+}
+
+func (w *fileWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := commitPartialFile(w.file, w.target); err != nil {
+		return err
+	}
+	w.info = w.sums.info(w.path)
+	return nil
+}
+
+// Abort удаляет временный файл, прежнее содержимое target не затрагивается.
+// После успешного Close ничего не делает
+func (w *fileWriter) Abort() error {
+	if w.info != nil {
+		return nil
+	}
+	if !w.closed {
+		w.closed = true
+		w.file.Close()
+	}
+	if err := os.Remove(w.file.Name()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove partial file: %w", err)
+	}
+	return nil
+}
+
+func (w *fileWriter) Info() *interfaces.FileInfo {
+	return w.info
+}
+
+// fileReader реализация interfaces.BlobReader поверх файла локальной ФС
+type fileReader struct {
+	*os.File
+	size int64
+}
+
+func (r *fileReader) Size() int64 {
+	return r.size
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/models"
+)
+
+const (
+	// downloadSessionDir каталог сессий возобновляемого скачивания внутри временной директории
+	downloadSessionDir = "downloads"
+	// defaultDownloadSessionTTL сколько живет сессия скачивания
+	defaultDownloadSessionTTL = 24 * time.Hour
+	// signingKeyFile ключ подписи ссылок, если он не задан в конфигурации
+	signingKeyFile = "signing.key"
+	signingKeySize = 32
+)
+
+// downloadSessionStore хранит сессию в файле <id>.json. Содержимое файла не копируется:
+// скачивание читает его из хранилища по ID файла
+type downloadSessionStore struct {
+	records *jsonStore[models.ResumableDownloadSession]
+	ttl     time.Duration
+
+	keyOnce sync.Once
+	key     []byte
+	keyErr  error
+}
+
+// NewDownloadSessionStore создает хранилище сессий возобновляемого скачивания в Storage.TempPath
+func NewDownloadSessionStore(cfg *config.Config) (interfaces.DownloadSessionStore, error) {
+	records, err := newJSONStore[models.ResumableDownloadSession](cfg, downloadSessionDir, "download session")
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := cfg.Storage.Downloads.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultDownloadSessionTTL
+	}
+	return &downloadSessionStore{records: records, ttl: ttl}, nil
+}
+
+func (s *downloadSessionStore) Save(ctx context.Context, session *models.ResumableDownloadSession) error {
+	if !validSessionID(session.ID) {
+		return fmt.Errorf("invalid download session id %q", session.ID)
+	}
+
+	now := time.Now().UTC()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.ExpiresAt = now.Add(s.ttl)
+	return s.records.save(session.ID, session)
+}
+
+func (s *downloadSessionStore) Get(ctx context.Context, id string) (*models.ResumableDownloadSession, error) {
+	if !validSessionID(id) {
+		return nil, nil
+	}
+	return s.records.get(id)
+}
+
+func (s *downloadSessionStore) Delete(ctx context.Context, id string) error {
+	if !validSessionID(id) {
+		return nil
+	}
+	return s.records.delete(id)
+}
+
+func (s *downloadSessionStore) RemoveExpired(ctx context.Context) (int, error) {
+	ids, err := s.records.names()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, id := range ids {
+		session, err := s.Get(ctx, id)
+		if err != nil || session == nil || !session.Expired(now) {
+			continue
+		}
+		if err := s.Delete(ctx, id); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	stale, err := s.records.removeStale()
+	return removed + stale, err
+}
+
+// SigningKey читает ключ из signing.key, а при первом запуске создает его
+func (s *downloadSessionStore) SigningKey() ([]byte, error) {
+	s.keyOnce.Do(func() {
+		s.key, s.keyErr = loadOrCreateKey(filepath.Join(s.records.dir, signingKeyFile))
+	})
+	return s.key, s.keyErr
+}
+
+// loadOrCreateKey читает случайный ключ из файла или создает его, доступным только владельцу
+func loadOrCreateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) == signingKeySize {
+		return key, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	key = make([]byte, signingKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	file, err := createPartialFile(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signing key: %w", err)
+	}
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to protect signing key: %w", err)
+	}
+	if _, err := file.Write(key); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	if err := commitPartialFile(file, path); err != nil {
+		return nil, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package repository
+
+// lockFile без flock блокировки между процессами нет: сервис должен работать с хранилищем один
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -284,7 +284,6 @@ func (r *fileRepository) ListTrashedFiles(ctx context.Context, ownerID uuid.UUID
 	return files, nil
 }
 
-// Операции с ревизиями
 func (r *fileRepository) CreateRevision(ctx context.Context, revision *models.FileRevision) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "CreateRevision (repo) called", zap.String("revisionID", revision.ID.String()))
@@ -609,8 +608,3 @@ func (r *fileRepository) CalculateFileChecksums(ctx context.Context, fileID uuid
 	lg.Info(ctx, "File checksums calculated successfully in dbmanager", zap.Int("checksumCount", len(checksums)))
 	return checksums, nil
 }
-
-// CreateFileFromFS добавляет файл в БД по данным из файловой системы
-func (r *fileRepository) CreateFileFromFS(ctx context.Context, file *models.File) error {
-	return r.CreateFile(ctx, file)
-}
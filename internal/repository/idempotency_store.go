@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/models"
+)
+
+const (
+	// idempotencyDir каталог сохраненных ответов внутри временной директории
+	idempotencyDir = "idempotency"
+	// defaultIdempotencyTTL сколько хранится ответ на запрос с Idempotency-Key
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+// idempotencyStore хранит ответ в файле <sha256(key)>.json: ключ задает клиент,
+// поэтому в имя файла он попадает только хешем
+type idempotencyStore struct {
+	records *jsonStore[models.IdempotentResponse]
+	ttl     time.Duration
+}
+
+// NewIdempotencyStore создает хранилище ответов для Idempotency-Key в Storage.TempPath
+func NewIdempotencyStore(cfg *config.Config) (interfaces.IdempotencyStore, error) {
+	records, err := newJSONStore[models.IdempotentResponse](cfg, idempotencyDir, "idempotent response")
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := cfg.Server.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return &idempotencyStore{records: records, ttl: ttl}, nil
+}
+
+func (s *idempotencyStore) Get(ctx context.Context, key string) (*models.IdempotentResponse, error) {
+	response, err := s.records.get(recordName(key))
+	if err != nil || response == nil {
+		return nil, err
+	}
+	if time.Now().After(response.ExpiresAt) {
+		return nil, nil
+	}
+	return response, nil
+}
+
+func (s *idempotencyStore) Save(ctx context.Context, response *models.IdempotentResponse) error {
+	now := time.Now().UTC()
+	response.CreatedAt = now
+	response.ExpiresAt = now.Add(s.ttl)
+	return s.records.save(recordName(response.Key), response)
+}
+
+func (s *idempotencyStore) RemoveExpired(ctx context.Context) (int, error) {
+	names, err := s.records.names()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, name := range names {
+		response, err := s.records.get(name)
+		// Нечитаемая запись бесполезна так же, как истекшая
+		if err == nil && response != nil && !now.After(response.ExpiresAt) {
+			continue
+		}
+		if err := s.records.delete(name); err == nil {
+			removed++
+		}
+	}
+
+	stale, err := s.records.removeStale()
+	return removed + stale, err
+}
+
+// recordName имя файла ответа по ключу клиента
+func recordName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
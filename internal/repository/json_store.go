@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+)
+
+// jsonStoreExt расширение файлов записей
+const jsonStoreExt = ".json"
+
+// jsonStore хранит служебные записи сервиса (сессии, журнал, статусы) в каталоге внутри
+// временной директории, каждую в файле <name>.json. Файлы пишутся через временный файл,
+// fsync и rename, поэтому запись переживает перезапуск и не бывает записана наполовину
+type jsonStore[T any] struct {
+	dir string
+	// kind название записи в сообщениях об ошибках
+	kind string
+}
+
+// newJSONStore создает каталог subdir в Storage.TempPath и убирает временные файлы,
+// брошенные до перезапуска
+func newJSONStore[T any](cfg *config.Config, subdir, kind string) (*jsonStore[T], error) {
+	dir := filepath.Join(cfg.Storage.TempPath, subdir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s directory: %w", kind, err)
+	}
+	if _, err := removeStalePartialFiles(dir, 0); err != nil {
+		return nil, err
+	}
+	return &jsonStore[T]{dir: dir, kind: kind}, nil
+}
+
+func (s *jsonStore[T]) save(name string, record *T) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", s.kind, err)
+	}
+	if err := writeFileAtomic(s.path(name), data); err != nil {
+		return fmt.Errorf("failed to save %s: %w", s.kind, err)
+	}
+	return nil
+}
+
+// get возвращает nil, если записи нет. Нечитаемая запись - ErrFileCorrupted
+func (s *jsonStore[T]) get(name string) (*T, error) {
+	data, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.kind, err)
+	}
+
+	record := new(T)
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal %s: %v", errdefs.ErrFileCorrupted, s.kind, err)
+	}
+	return record, nil
+}
+
+func (s *jsonStore[T]) delete(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", s.kind, err)
+	}
+	return nil
+}
+
+// names перечисляет имена всех записей
+func (s *jsonStore[T]) names() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s directory: %w", s.kind, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), jsonStoreExt); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// removeStale удаляет временные файлы, брошенные работающим сервисом
+func (s *jsonStore[T]) removeStale() (int, error) {
+	return removeStalePartialFiles(s.dir, partialFileTTL)
+}
+
+func (s *jsonStore[T]) path(name string) string {
+	return filepath.Join(s.dir, name+jsonStoreExt)
+}
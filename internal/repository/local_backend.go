@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+)
+
+// localBackend драйвер хранилища поверх локальной файловой системы.
+// Ключи отображаются на файлы внутри BasePath/UserDirName
+type localBackend struct {
+	root string
+}
+
+func newLocalBackend(cfg *config.Config) (interfaces.StorageBackend, error) {
+	return openLocalRoot(cfg.Storage.BasePath, cfg.Storage.UserDirName)
+}
+
+// openLocalRoot готовит директорию пользователей внутри basePath и создает драйвер поверх нее
+func openLocalRoot(basePath, userDirName string) (interfaces.StorageBackend, error) {
+	// Создаем базовую директорию, если её нет
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create base storage directory: %w", err)
+	}
+
+	// Создаем директорию для пользователей
+	userPath := filepath.Join(basePath, userDirName)
+	if err := os.MkdirAll(userPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create user directory: %w", err)
+	}
+
+	// Временные файлы прерванных сбоем записей больше никому не нужны
+	if _, err := removeStalePartialFiles(filepath.Join(userPath, partialDir), partialFileTTL); err != nil {
+		return nil, fmt.Errorf("failed to clean partial files: %w", err)
+	}
+
+	return NewLocalBackend(userPath), nil
+}
+
+// lockDir каталог файлов блокировок внутри корня локального хранилища
+const lockDir = ".locks"
+
+// NewLocalBackend создает драйвер локальной ФС с корнем root
+func NewLocalBackend(root string) interfaces.StorageBackend {
+	return &localBackend{root: filepath.Clean(root)}
+}
+
+func (b *localBackend) Name() string {
+	return StorageDriverLocal
+}
+
+// resolve переводит ключ в абсолютный путь и проверяет, что он не выходит за пределы корня
+func (b *localBackend) resolve(key string) (string, error) {
+	fullPath := filepath.Clean(filepath.Join(b.root, filepath.FromSlash(key)))
+	if fullPath != b.root && !filepath.HasPrefix(fullPath, b.root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w: path traversal attempt", errdefs.ErrInvalidPath)
+	}
+	return fullPath, nil
+}
+
+func (b *localBackend) NewWriter(ctx context.Context, key string) (interfaces.BlobWriter, error) {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пишем во временный файл: до Close читатели видят прежнее содержимое,
+	// а сбой посреди записи не оставляет обрезанный файл
+	file, err := createPartialFile(filepath.Join(b.root, partialDir))
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileWriter(file, fullPath, key), nil
+}
+
+func (b *localBackend) Open(ctx context.Context, key string) (interfaces.BlobReader, error) {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrFileNotFound, key)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to access file: %w", err)
+	}
+
+	// Проверяем, что это файл, а не директория
+	if fileInfo.IsDir() {
+		return nil, fmt.Errorf("path is a directory, not a file: %s", key)
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return &fileReader{File: file, size: fileInfo.Size()}, nil
+}
+
+func (b *localBackend) Stat(ctx context.Context, key string) (*interfaces.FileInfo, error) {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrFileNotFound, key)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	return &interfaces.FileInfo{
+		Path:        key,
+		Size:        fileInfo.Size(),
+		IsDirectory: fileInfo.IsDir(),
+		ModifiedAt:  fileInfo.ModTime().Unix(),
+	}, nil
+}
+
+func (b *localBackend) Delete(ctx context.Context, key string) error {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	// Проверяем существование файла перед удалением
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", errdefs.ErrFileNotFound, key)
+	}
+
+	if err := os.Remove(fullPath); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+func (b *localBackend) Move(ctx context.Context, srcKey, dstKey string) error {
+	srcPath, err := b.resolve(srcKey)
+	if err != nil {
+		return err
+	}
+	dstPath, err := b.resolve(dstKey)
+	if err != nil {
+		return err
+	}
+
+	// Проверяем существование исходного файла
+	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", errdefs.ErrFileNotFound, srcKey)
+	}
+
+	// Создаем директорию для нового пути
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	if err := syncDir(filepath.Dir(dstPath)); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(srcPath))
+}
+
+func (b *localBackend) Copy(ctx context.Context, srcKey, dstKey string) error {
+	src, err := b.Open(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := b.NewWriter(ctx, dstKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Abort()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return dst.Close()
+}
+
+func (b *localBackend) MakeDir(ctx context.Context, key string) error {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return nil
+}
+
+func (b *localBackend) DeletePrefix(ctx context.Context, key string) error {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+	if fullPath == b.root {
+		return fmt.Errorf("%w: refusing to delete storage root", errdefs.ErrInvalidPath)
+	}
+
+	if err := os.RemoveAll(fullPath); err != nil {
+		return fmt.Errorf("failed to delete directory: %w", err)
+	}
+	return nil
+}
+
+func (b *localBackend) List(ctx context.Context, key string) ([]string, error) {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		// Незавершенные записи - не содержимое хранилища
+		if fullPath == b.root && (entry.Name() == partialDir || entry.Name() == lockDir) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// LockKey блокирует ключ через flock на файле .locks/<key>.lock: объекты заменяются
+// через rename, и блокировка на самом объекте не удержалась бы
+func (b *localBackend) LockKey(ctx context.Context, key string) (func(), error) {
+	if _, err := b.resolve(key); err != nil {
+		return nil, err
+	}
+	lockPath, err := b.resolve(path.Join(lockDir, key) + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	return lockFile(lockPath)
+}
+
+// RemoveExpired удаляет временные файлы записей, брошенных без Close и Abort
+func (b *localBackend) RemoveExpired(ctx context.Context) (int, error) {
+	return removeStalePartialFiles(filepath.Join(b.root, partialDir), partialFileTTL)
+}
+
+func (b *localBackend) Walk(ctx context.Context, key string, fn func(info *interfaces.FileInfo) error) error {
+	fullPath, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(fullPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// Незавершенные записи - не содержимое хранилища
+			if p == filepath.Join(b.root, partialDir) || p == filepath.Join(b.root, lockDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		return fn(&interfaces.FileInfo{
+			Path:       filepath.ToSlash(rel),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime().Unix(),
+		})
+	})
+}
@@ -0,0 +1,375 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// mirrorBackend драйвер, хранящий каждый объект в нескольких корнях (RAID1 поверх каталогов).
+// Запись идет во все копии и считается успешной, если надежно записано не меньше quorum копий.
+// Чтение идет из первой доступной копии, сверку содержимого при чтении и фоновую
+// починку делает storageRepository.GetVerifiedFile
+type mirrorBackend struct {
+	mirrors []interfaces.StorageBackend
+	quorum  int
+}
+
+func newMirrorBackend(cfg *config.Config) (interfaces.StorageBackend, error) {
+	if len(cfg.Storage.MirrorPaths) == 0 {
+		return nil, fmt.Errorf("mirror_paths is required for mirror driver")
+	}
+
+	roots := append([]string{cfg.Storage.BasePath}, cfg.Storage.MirrorPaths...)
+	mirrors := make([]interfaces.StorageBackend, 0, len(roots))
+	for _, root := range roots {
+		mirror, err := openLocalRoot(root, cfg.Storage.UserDirName)
+		if err != nil {
+			return nil, fmt.Errorf("mirror %s: %w", root, err)
+		}
+		mirrors = append(mirrors, mirror)
+	}
+
+	quorum := cfg.Storage.WriteQuorum
+	if quorum > len(mirrors) {
+		return nil, fmt.Errorf("write_quorum %d exceeds number of mirrors %d", quorum, len(mirrors))
+	}
+	return NewMirrorBackend(mirrors, quorum), nil
+}
+
+// NewMirrorBackend создает зеркало поверх готовых драйверов. quorum <= 0 - большинство копий
+func NewMirrorBackend(mirrors []interfaces.StorageBackend, quorum int) interfaces.StorageBackend {
+	if quorum <= 0 {
+		quorum = len(mirrors)/2 + 1
+	}
+	if quorum > len(mirrors) {
+		quorum = len(mirrors)
+	}
+	return &mirrorBackend{mirrors: mirrors, quorum: quorum}
+}
+
+func (b *mirrorBackend) Name() string {
+	return StorageDriverMirror
+}
+
+func (b *mirrorBackend) Mirrors() []interfaces.StorageBackend {
+	return b.mirrors
+}
+
+func (b *mirrorBackend) NewWriter(ctx context.Context, key string) (interfaces.BlobWriter, error) {
+	w := &mirrorWriter{ctx: ctx, key: key, quorum: b.quorum}
+	var lastErr error
+	for i, mirror := range b.mirrors {
+		writer, err := mirror.NewWriter(ctx, key)
+		if err != nil {
+			logMirrorFailure(ctx, "open writer", key, i, err)
+			lastErr = err
+			continue
+		}
+		w.writers = append(w.writers, writer)
+		w.mirrors = append(w.mirrors, i)
+	}
+
+	if len(w.writers) < b.quorum {
+		w.Abort()
+		return nil, fmt.Errorf("write quorum not reached: %d of %d mirrors available: %w", len(w.writers), b.quorum, lastErr)
+	}
+	return w, nil
+}
+
+// Open читает из первой копии, которая открывается
+func (b *mirrorBackend) Open(ctx context.Context, key string) (interfaces.BlobReader, error) {
+	var lastErr error
+	for i, mirror := range b.mirrors {
+		reader, err := mirror.Open(ctx, key)
+		if err == nil {
+			return reader, nil
+		}
+		if !errdefs.Is(err, errdefs.ErrFileNotFound) {
+			logMirrorFailure(ctx, "open", key, i, err)
+		}
+		lastErr = preferNotFound(lastErr, err)
+	}
+	return nil, lastErr
+}
+
+func (b *mirrorBackend) Stat(ctx context.Context, key string) (*interfaces.FileInfo, error) {
+	var lastErr error
+	for _, mirror := range b.mirrors {
+		info, err := mirror.Stat(ctx, key)
+		if err == nil {
+			return info, nil
+		}
+		lastErr = preferNotFound(lastErr, err)
+	}
+	return nil, lastErr
+}
+
+func (b *mirrorBackend) Delete(ctx context.Context, key string) error {
+	return b.fanOut(ctx, "delete", key, func(mirror interfaces.StorageBackend) error {
+		return mirror.Delete(ctx, key)
+	})
+}
+
+func (b *mirrorBackend) Move(ctx context.Context, srcKey, dstKey string) error {
+	return b.fanOut(ctx, "move", srcKey, func(mirror interfaces.StorageBackend) error {
+		return mirror.Move(ctx, srcKey, dstKey)
+	})
+}
+
+func (b *mirrorBackend) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return b.fanOut(ctx, "copy", srcKey, func(mirror interfaces.StorageBackend) error {
+		return mirror.Copy(ctx, srcKey, dstKey)
+	})
+}
+
+func (b *mirrorBackend) MakeDir(ctx context.Context, key string) error {
+	return b.fanOut(ctx, "make dir", key, func(mirror interfaces.StorageBackend) error {
+		return mirror.MakeDir(ctx, key)
+	})
+}
+
+func (b *mirrorBackend) DeletePrefix(ctx context.Context, key string) error {
+	return b.fanOut(ctx, "delete prefix", key, func(mirror interfaces.StorageBackend) error {
+		return mirror.DeletePrefix(ctx, key)
+	})
+}
+
+// LockKey блокирует ключ на каждой доступной копии, всегда в одном порядке, чтобы два
+// процесса не взяли блокировки навстречу друг другу. Копия, на которой блокировку взять
+// не удалось, пропускается: ее пропускают и другие процессы
+func (b *mirrorBackend) LockKey(ctx context.Context, key string) (func(), error) {
+	var unlocks []func()
+	var lastErr error
+	for i, mirror := range b.mirrors {
+		locker, ok := mirror.(interfaces.KeyLocker)
+		if !ok {
+			continue
+		}
+		unlock, err := locker.LockKey(ctx, key)
+		if err != nil {
+			logMirrorFailure(ctx, "lock", key, i, err)
+			lastErr = err
+			continue
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	if len(unlocks) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}, nil
+}
+
+// RemoveExpired удаляет просроченные объекты на каждой копии, которая их хранит
+func (b *mirrorBackend) RemoveExpired(ctx context.Context) (int, error) {
+	removed := 0
+	var lastErr error
+	for _, mirror := range b.mirrors {
+		store, ok := mirror.(interfaces.ExpiringStore)
+		if !ok {
+			continue
+		}
+		n, err := store.RemoveExpired(ctx)
+		if err != nil {
+			lastErr = err
+		}
+		removed += n
+	}
+	return removed, lastErr
+}
+
+// List объединяет содержимое всех доступных копий: объект, потерянный одной из них, остается виден
+func (b *mirrorBackend) List(ctx context.Context, key string) ([]string, error) {
+	seen := make(map[string]bool)
+	var lastErr error
+	listed := false
+	for _, mirror := range b.mirrors {
+		names, err := mirror.List(ctx, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		listed = true
+		for _, name := range names {
+			seen[name] = true
+		}
+	}
+	if !listed {
+		return nil, lastErr
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Walk обходит все копии и сообщает о каждом объекте один раз
+func (b *mirrorBackend) Walk(ctx context.Context, key string, fn func(info *interfaces.FileInfo) error) error {
+	seen := make(map[string]bool)
+	var lastErr error
+	walked := false
+	for _, mirror := range b.mirrors {
+		err := mirror.Walk(ctx, key, func(info *interfaces.FileInfo) error {
+			if seen[info.Path] {
+				return nil
+			}
+			seen[info.Path] = true
+			return fn(info)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			lastErr = err
+			continue
+		}
+		walked = true
+	}
+	if !walked {
+		return lastErr
+	}
+	return nil
+}
+
+// fanOut выполняет операцию на всех копиях. Отсутствие объекта на отдельной копии не считается
+// ее сбоем (копия могла не записаться раньше), но если объекта нет нигде - это ErrFileNotFound.
+// Операция не удалась, если исправных копий осталось меньше quorum
+func (b *mirrorBackend) fanOut(ctx context.Context, op, key string, fn func(mirror interfaces.StorageBackend) error) error {
+	var succeeded, failed int
+	var lastErr, notFound error
+	for i, mirror := range b.mirrors {
+		err := fn(mirror)
+		switch {
+		case err == nil:
+			succeeded++
+		case errdefs.Is(err, errdefs.ErrFileNotFound):
+			notFound = err
+		default:
+			logMirrorFailure(ctx, op, key, i, err)
+			failed++
+			lastErr = err
+		}
+	}
+
+	if len(b.mirrors)-failed < b.quorum {
+		return fmt.Errorf("%s failed on %d of %d mirrors: %w", op, failed, len(b.mirrors), lastErr)
+	}
+	if succeeded == 0 {
+		if notFound != nil {
+			return notFound
+		}
+		return lastErr
+	}
+	return nil
+}
+
+// preferNotFound выбирает ошибку для результата чтения: "не найдено" только если объекта нет ни в одной копии
+func preferNotFound(prev, err error) error {
+	if prev != nil && !errdefs.Is(prev, errdefs.ErrFileNotFound) {
+		return prev
+	}
+	if prev != nil && errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return prev
+	}
+	return err
+}
+
+// mirrorWriter пишет одни и те же данные во все копии. Копия, на которой запись сломалась,
+// отбрасывается; запись не удалась, если целых копий осталось меньше quorum
+type mirrorWriter struct {
+	ctx     context.Context
+	key     string
+	quorum  int
+	writers []interfaces.BlobWriter
+	mirrors []int
+	info    *interfaces.FileInfo
+}
+
+func (w *mirrorWriter) Write(p []byte) (int, error) {
+	var lastErr error
+	for i := 0; i < len(w.writers); {
+		if _, err := w.writers[i].Write(p); err != nil {
+			logMirrorFailure(w.ctx, "write", w.key, w.mirrors[i], err)
+			w.writers[i].Abort()
+			w.drop(i)
+			lastErr = err
+			continue
+		}
+		i++
+	}
+
+	if len(w.writers) < w.quorum {
+		return 0, fmt.Errorf("write quorum not reached: %d of %d mirrors left: %w", len(w.writers), w.quorum, lastErr)
+	}
+	return len(p), nil
+}
+
+// Close фиксирует копии. Данные надежны, если зафиксировано не меньше quorum копий
+func (w *mirrorWriter) Close() error {
+	var lastErr error
+	committed := 0
+	for i, writer := range w.writers {
+		if err := writer.Close(); err != nil {
+			logMirrorFailure(w.ctx, "commit", w.key, w.mirrors[i], err)
+			writer.Abort()
+			lastErr = err
+			continue
+		}
+		committed++
+		if w.info == nil {
+			w.info = writer.Info()
+		}
+	}
+	w.writers = nil
+
+	if committed < w.quorum {
+		return fmt.Errorf("write quorum not reached: %d of %d mirrors committed: %w", committed, w.quorum, lastErr)
+	}
+	return nil
+}
+
+func (w *mirrorWriter) Abort() error {
+	var firstErr error
+	for _, writer := range w.writers {
+		if err := writer.Abort(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.writers = nil
+	return firstErr
+}
+
+func (w *mirrorWriter) Info() *interfaces.FileInfo {
+	return w.info
+}
+
+func (w *mirrorWriter) drop(i int) {
+	w.writers = append(w.writers[:i], w.writers[i+1:]...)
+	w.mirrors = append(w.mirrors[:i], w.mirrors[i+1:]...)
+}
+
+// logMirrorFailure сообщает о сбое отдельной копии: операция может пройти за счет остальных,
+// но зеркало при этом деградирует
+func logMirrorFailure(ctx context.Context, op, key string, mirror int, err error) {
+	if lg := logger.GetLoggerFromCtxSafe(ctx); lg != nil {
+		lg.Error(ctx, "Storage mirror operation failed",
+			zap.Error(err),
+			zap.String("op", op),
+			zap.String("key", key),
+			zap.Int("mirror", mirror))
+	}
+}
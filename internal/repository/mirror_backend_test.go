@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenBackend копия, на которую нельзя записать
+type brokenBackend struct {
+	interfaces.StorageBackend
+}
+
+func (b *brokenBackend) NewWriter(ctx context.Context, key string) (interfaces.BlobWriter, error) {
+	return nil, errors.New("disk failure")
+}
+
+func TestMirrorBackendQuorumAndSelfHealingReads(t *testing.T) {
+	ctx := newTestContext(t)
+	roots := []string{t.TempDir(), t.TempDir()}
+	cfg := &config.Config{}
+	cfg.Storage.BasePath = roots[0]
+	cfg.Storage.MirrorPaths = roots[1:]
+	cfg.Storage.UserDirName = "users"
+	cfg.Storage.TempPath = t.TempDir()
+	cfg.Storage.Driver = StorageDriverMirror
+
+	repo, err := NewStorageRepository(cfg)
+	require.NoError(t, err)
+
+	info, err := repo.SaveFile(ctx, "owner/a.txt", strings.NewReader("mirrored content"))
+	require.NoError(t, err)
+	copies := []string{
+		filepath.Join(roots[0], "users", "owner", "a.txt"),
+		filepath.Join(roots[1], "users", "owner", "a.txt"),
+	}
+	for _, copy := range copies {
+		assert.FileExists(t, copy)
+	}
+
+	// Порча первой копии обнаруживается до отдачи: клиент получает целую, копия чинится в фоне
+	require.NoError(t, os.WriteFile(copies[0], []byte("bit rot"), 0644))
+	reader, err := repo.GetVerifiedFile(ctx, "owner/a.txt", info.SHA256Checksum)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "mirrored content", string(data))
+	assert.Eventually(t, func() bool {
+		repaired, err := os.ReadFile(copies[0])
+		return err == nil && string(repaired) == "mirrored content"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Большой файл сверяется с другой копией по ходу чтения и дочитывается из целой
+	repo.(*storageRepository).verifyBeforeServeSize = 4
+	require.NoError(t, os.WriteFile(copies[1], []byte("mirrored CONTENT"), 0644))
+	reader, err = repo.GetVerifiedFile(ctx, "owner/a.txt", info.SHA256Checksum)
+	require.NoError(t, err)
+	head := make([]byte, 3)
+	_, err = io.ReadFull(reader, head)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(copies[0], []byte("mirrored c0ntent"), 0644))
+	require.NoError(t, os.WriteFile(copies[1], []byte("mirrored content"), 0644))
+	tail, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "mirrored content", string(head)+string(tail))
+	assert.Eventually(t, func() bool {
+		repaired, err := os.ReadFile(copies[0])
+		return err == nil && string(repaired) == "mirrored content"
+	}, 5*time.Second, 10*time.Millisecond)
+	repo.(*storageRepository).verifyBeforeServeSize = defaultVerifyBeforeServeSize
+
+	// Пропавшая копия не мешает чтению и восстанавливается так же
+	require.NoError(t, os.Remove(copies[0]))
+	reader, err = repo.GetVerifiedFile(ctx, "owner/a.txt", info.SHA256Checksum)
+	require.NoError(t, err)
+	data, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "mirrored content", string(data))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(copies[0])
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Без единой целой копии файл не отдается
+	for _, copy := range copies {
+		require.NoError(t, os.WriteFile(copy, []byte("bit rot"), 0644))
+	}
+	_, err = repo.GetVerifiedFile(ctx, "owner/a.txt", info.SHA256Checksum)
+	assert.True(t, errdefs.Is(err, errdefs.ErrFileCorrupted))
+
+	// Запись проходит, пока исправных копий не меньше кворума
+	healthy := NewLocalBackend(t.TempDir())
+	broken := &brokenBackend{StorageBackend: NewLocalBackend(t.TempDir())}
+	for quorum, wantErr := range map[int]bool{1: false, 2: true} {
+		mirror := NewMirrorBackend([]interfaces.StorageBackend{broken, healthy}, quorum)
+		writer, err := mirror.NewWriter(ctx, "owner/b.txt")
+		if wantErr {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("degraded"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		// Чтение обходит копию, на которой объекта нет
+		reader, err := mirror.Open(ctx, "owner/b.txt")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len("degraded")), reader.Size())
+		require.NoError(t, reader.Close())
+	}
+}
@@ -0,0 +1,463 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// parityStorePrefix каталог четности внутри директории пользователей, раскладка как у блобов
+	parityStorePrefix = ".parity"
+
+	defaultParityDataShards   = 10
+	defaultParityShards       = 2
+	defaultParityShardSize    = 64 * 1024
+	parityMagic               = "HCPARITY"
+	parityVersion             = 1
+	parityHeaderSize          = 64
+	parityHeaderChecksumStart = 60
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// errParityDamaged файл четности не читается: его нужно построить заново
+var errParityDamaged = errors.New("parity file is damaged")
+
+// parityStore хранит для содержимого файл четности. Содержимое делится на блоки по shardSize,
+// каждые dataShards блоков образуют группу, к которой пишется parityShards блоков четности.
+// Формат файла: заголовок (parityHeaderSize байт), затем по каждой группе таблица CRC-32C
+// всех ее блоков и блоки четности. По CRC находятся поврежденные блоки, по четности они восстанавливаются
+type parityStore struct {
+	storage      interfaces.StorageRepository
+	dataShards   int
+	parityShards int
+	shardSize    int
+}
+
+// NewParityStore создает хранилище четности поверх файлового хранилища
+func NewParityStore(storage interfaces.StorageRepository, cfg *config.Config) (interfaces.ParityStore, error) {
+	p := &parityStore{
+		storage:      storage,
+		dataShards:   cfg.Storage.Parity.DataShards,
+		parityShards: cfg.Storage.Parity.ParityShards,
+		shardSize:    cfg.Storage.Parity.ShardSize,
+	}
+	if p.dataShards <= 0 {
+		p.dataShards = defaultParityDataShards
+	}
+	if p.parityShards <= 0 {
+		p.parityShards = defaultParityShards
+	}
+	if p.shardSize <= 0 {
+		p.shardSize = defaultParityShardSize
+	}
+
+	if _, err := newReedSolomon(p.dataShards, p.parityShards); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parityStore) Key(sha256 string) string {
+	return path.Join(parityStorePrefix, sha256[:2], sha256[2:4], sha256)
+}
+
+func (p *parityStore) ParseKey(key string) (string, bool) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return "", false
+	}
+
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != parityStorePrefix {
+		return "", false
+	}
+
+	sha256 := parts[3]
+	if !isSHA256(sha256) || parts[1] != sha256[:2] || parts[2] != sha256[2:4] {
+		return "", false
+	}
+	return sha256, true
+}
+
+func (p *parityStore) Protect(ctx context.Context, path string, sha256 string) error {
+	if !isSHA256(sha256) {
+		return fmt.Errorf("%w: invalid sha256 %q", errdefs.ErrInvalidInput, sha256)
+	}
+
+	// Одинаковое содержимое разных файлов защищается одним файлом четности
+	if reader, err := p.storage.GetFile(ctx, p.Key(sha256)); err == nil {
+		reader.Close()
+		return nil
+	}
+	return p.protect(ctx, path, sha256)
+}
+
+// protect строит файл четности по содержимому path и записывает его, только если содержимое совпало с sha256
+func (p *parityStore) protect(ctx context.Context, path string, expectedSHA256 string) error {
+	src, err := p.storage.GetFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	header := parityHeader{
+		dataShards:   p.dataShards,
+		parityShards: p.parityShards,
+		shardSize:    p.shardSize,
+		size:         src.Size(),
+		sha256:       expectedSHA256,
+	}
+	rs, err := newReedSolomon(header.dataShards, header.parityShards)
+	if err != nil {
+		return err
+	}
+
+	w, err := p.storage.NewWriter(ctx, p.Key(expectedSHA256))
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(header.marshal()); err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to write parity: %w", err)
+	}
+
+	hasher := sha256.New()
+	content := io.TeeReader(src, hasher)
+	shards := header.newShards()
+	remaining := header.size
+	for remaining > 0 {
+		for j := 0; j < header.dataShards; j++ {
+			clear(shards[j])
+			n := min(int64(header.shardSize), remaining)
+			if _, err := io.ReadFull(content, shards[j][:n]); err != nil {
+				w.Abort()
+				return fmt.Errorf("failed to read content: %w", err)
+			}
+			remaining -= n
+		}
+
+		rs.Encode(shards)
+		if err := header.writeGroup(w, shards); err != nil {
+			w.Abort()
+			return fmt.Errorf("failed to write parity: %w", err)
+		}
+	}
+
+	// Четность от уже поврежденных данных закрепила бы порчу
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expectedSHA256 {
+		w.Abort()
+		return fmt.Errorf("%w: content %s, expected %s", errdefs.ErrChecksumMismatch, actual, expectedSHA256)
+	}
+	return w.Close()
+}
+
+func (p *parityStore) Repair(ctx context.Context, path string, sha256 string) (*models.ParityRepair, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if !isSHA256(sha256) {
+		return nil, fmt.Errorf("%w: invalid sha256 %q", errdefs.ErrInvalidInput, sha256)
+	}
+	result := &models.ParityRepair{}
+
+	sidecar, err := p.storage.GetFile(ctx, p.Key(sha256))
+	if errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer sidecar.Close()
+	result.Protected = true
+
+	data, err := p.storage.GetFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	header, err := readParityHeader(sidecar)
+	if err == nil && header.sha256 != sha256 {
+		err = fmt.Errorf("%w: parity built for %s", errParityDamaged, header.sha256)
+	}
+	if err == nil {
+		err = p.repair(ctx, path, header, data, sidecar, result)
+	}
+	if err == nil {
+		return result, nil
+	} else if !errors.Is(err, errParityDamaged) {
+		return nil, err
+	}
+
+	// Без четности восстановить нечего, но если данные целы, четность строится заново
+	lg.Error(ctx, "Parity file is damaged, rebuilding", zap.Error(err), zap.String("path", path))
+	if err := p.protect(ctx, path, sha256); err != nil {
+		if errdefs.Is(err, errdefs.ErrChecksumMismatch) {
+			result.Damaged = []models.ContentRange{{Offset: 0, Length: data.Size()}}
+			return result, nil
+		}
+		return nil, err
+	}
+	result.ParityRebuilt = true
+	return result, nil
+}
+
+// repair проверяет блоки содержимого и, если нашлись поврежденные, переписывает содержимое
+// с восстановленными блоками. Итог записывается в result
+func (p *parityStore) repair(ctx context.Context, path string, header *parityHeader, data, sidecar interfaces.BlobReader, result *models.ParityRepair) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	rs, err := newReedSolomon(header.dataShards, header.parityShards)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errParityDamaged, err)
+	}
+	groups := header.groups()
+	shards := header.newShards()
+
+	// Первый проход: ищем поврежденные блоки, пока данные целы - только читаем
+	damagedGroups := make(map[int64]bool)
+	parityDamaged := false
+	hasher := sha256.New()
+	for g := int64(0); g < groups; g++ {
+		present, err := header.readGroup(data, sidecar, g, shards)
+		if err != nil {
+			return err
+		}
+		for i, ok := range present {
+			if ok {
+				continue
+			}
+			if i >= header.dataShards {
+				parityDamaged = true
+				continue
+			}
+			damagedGroups[g] = true
+			offset := (g*int64(header.dataShards) + int64(i)) * int64(header.shardSize)
+			result.Damaged = appendRange(result.Damaged, offset, min(int64(header.shardSize), header.size-offset))
+		}
+		header.writeData(hasher, g, shards)
+	}
+	if data.Size() > header.size {
+		result.Damaged = appendRange(result.Damaged, header.size, data.Size()-header.size)
+	}
+
+	if len(result.Damaged) == 0 {
+		if hex.EncodeToString(hasher.Sum(nil)) != header.sha256 {
+			// Все блоки сходятся с CRC, а содержимое нет - место порчи не определить
+			result.Damaged = []models.ContentRange{{Offset: 0, Length: header.size}}
+			lg.Error(ctx, "Content does not match checksum, damaged blocks not found", zap.String("path", path))
+			return nil
+		}
+		return p.rebuildParity(ctx, path, header.sha256, parityDamaged, result)
+	}
+
+	// Второй проход: переписываем содержимое, восстанавливая поврежденные группы
+	w, err := p.storage.NewWriter(ctx, path)
+	if err != nil {
+		return err
+	}
+	hasher.Reset()
+	out := io.MultiWriter(w, hasher)
+	for g := int64(0); g < groups; g++ {
+		present, err := header.readGroup(data, sidecar, g, shards)
+		if err != nil {
+			w.Abort()
+			return err
+		}
+		if damagedGroups[g] {
+			if err := rs.Reconstruct(shards, present); err != nil {
+				w.Abort()
+				lg.Error(ctx, "Content is damaged beyond parity repair",
+					zap.Error(err),
+					zap.String("path", path),
+					zap.Int64("group", g))
+				return nil
+			}
+		}
+		if err := header.writeData(out, g, shards); err != nil {
+			w.Abort()
+			return fmt.Errorf("failed to write repaired content: %w", err)
+		}
+	}
+
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != header.sha256 {
+		w.Abort()
+		lg.Error(ctx, "Repaired content does not match checksum",
+			zap.String("path", path),
+			zap.String("expected", header.sha256),
+			zap.String("actual", actual))
+		return nil
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write repaired content: %w", err)
+	}
+	result.Repaired = true
+
+	return p.rebuildParity(ctx, path, header.sha256, parityDamaged, result)
+}
+
+// rebuildParity перезаписывает поврежденные блоки четности. Сбой не отменяет восстановление данных
+func (p *parityStore) rebuildParity(ctx context.Context, path, sha256 string, damaged bool, result *models.ParityRepair) error {
+	if !damaged {
+		return nil
+	}
+	if err := p.protect(ctx, path, sha256); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "Failed to rebuild parity", zap.Error(err), zap.String("path", path))
+		return nil
+	}
+	result.ParityRebuilt = true
+	return nil
+}
+
+// parityHeader параметры файла четности
+type parityHeader struct {
+	dataShards   int
+	parityShards int
+	shardSize    int
+	size         int64
+	sha256       string
+}
+
+func readParityHeader(r io.ReaderAt) (*parityHeader, error) {
+	buf := make([]byte, parityHeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("%w: %v", errParityDamaged, err)
+	}
+	if string(buf[:8]) != parityMagic ||
+		binary.BigEndian.Uint16(buf[8:10]) != parityVersion ||
+		binary.BigEndian.Uint32(buf[parityHeaderChecksumStart:]) != crc32.Checksum(buf[:parityHeaderChecksumStart], crc32c) {
+		return nil, fmt.Errorf("%w: invalid header", errParityDamaged)
+	}
+
+	header := &parityHeader{
+		dataShards:   int(binary.BigEndian.Uint16(buf[10:12])),
+		parityShards: int(binary.BigEndian.Uint16(buf[12:14])),
+		shardSize:    int(binary.BigEndian.Uint32(buf[16:20])),
+		size:         int64(binary.BigEndian.Uint64(buf[20:28])),
+		sha256:       hex.EncodeToString(buf[28:60]),
+	}
+	if header.shardSize <= 0 || header.size < 0 {
+		return nil, fmt.Errorf("%w: invalid header", errParityDamaged)
+	}
+	return header, nil
+}
+
+func (h *parityHeader) marshal() []byte {
+	buf := make([]byte, parityHeaderSize)
+	copy(buf, parityMagic)
+	binary.BigEndian.PutUint16(buf[8:10], parityVersion)
+	binary.BigEndian.PutUint16(buf[10:12], uint16(h.dataShards))
+	binary.BigEndian.PutUint16(buf[12:14], uint16(h.parityShards))
+	binary.BigEndian.PutUint32(buf[16:20], uint32(h.shardSize))
+	binary.BigEndian.PutUint64(buf[20:28], uint64(h.size))
+	sum, _ := hex.DecodeString(h.sha256)
+	copy(buf[28:60], sum)
+	binary.BigEndian.PutUint32(buf[parityHeaderChecksumStart:], crc32.Checksum(buf[:parityHeaderChecksumStart], crc32c))
+	return buf
+}
+
+// groups число групп блоков, последняя группа дополняется нулевыми блоками
+func (h *parityHeader) groups() int64 {
+	groupBytes := int64(h.dataShards) * int64(h.shardSize)
+	return (h.size + groupBytes - 1) / groupBytes
+}
+
+// groupRecordSize размер записи группы в файле четности: таблица CRC и блоки четности
+func (h *parityHeader) groupRecordSize() int64 {
+	return int64(h.dataShards+h.parityShards)*4 + int64(h.parityShards)*int64(h.shardSize)
+}
+
+func (h *parityHeader) newShards() [][]byte {
+	shards := make([][]byte, h.dataShards+h.parityShards)
+	for i := range shards {
+		shards[i] = make([]byte, h.shardSize)
+	}
+	return shards
+}
+
+func (h *parityHeader) writeGroup(w io.Writer, shards [][]byte) error {
+	table := make([]byte, 4*len(shards))
+	for i, shard := range shards {
+		binary.BigEndian.PutUint32(table[4*i:], crc32.Checksum(shard, crc32c))
+	}
+	if _, err := w.Write(table); err != nil {
+		return err
+	}
+	for _, shard := range shards[h.dataShards:] {
+		if _, err := w.Write(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readGroup читает блоки группы g из данных и файла четности и отмечает целые по CRC.
+// Недочитанная часть блока данных заполняется нулями и не сойдется с CRC
+func (h *parityHeader) readGroup(data, sidecar io.ReaderAt, g int64, shards [][]byte) ([]bool, error) {
+	record := make([]byte, h.groupRecordSize())
+	if _, err := sidecar.ReadAt(record, parityHeaderSize+g*h.groupRecordSize()); err != nil {
+		return nil, fmt.Errorf("%w: group %d: %v", errParityDamaged, g, err)
+	}
+	table := record[:4*len(shards)]
+	parity := record[len(table):]
+
+	for j := 0; j < h.dataShards; j++ {
+		offset := (g*int64(h.dataShards) + int64(j)) * int64(h.shardSize)
+		clear(shards[j])
+		if offset >= h.size {
+			continue
+		}
+		n, err := data.ReadAt(shards[j][:min(int64(h.shardSize), h.size-offset)], offset)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read content: %w", err)
+		}
+		clear(shards[j][n:])
+	}
+	for i := 0; i < h.parityShards; i++ {
+		copy(shards[h.dataShards+i], parity[i*h.shardSize:(i+1)*h.shardSize])
+	}
+
+	present := make([]bool, len(shards))
+	for i, shard := range shards {
+		present[i] = crc32.Checksum(shard, crc32c) == binary.BigEndian.Uint32(table[4*i:])
+	}
+	return present, nil
+}
+
+// writeData пишет блоки данных группы g без дополнения нулями за концом содержимого
+func (h *parityHeader) writeData(w io.Writer, g int64, shards [][]byte) error {
+	for j := 0; j < h.dataShards; j++ {
+		offset := (g*int64(h.dataShards) + int64(j)) * int64(h.shardSize)
+		if offset >= h.size {
+			break
+		}
+		if _, err := w.Write(shards[j][:min(int64(h.shardSize), h.size-offset)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendRange добавляет участок, сливая его с предыдущим, если они соприкасаются
+func appendRange(ranges []models.ContentRange, offset, length int64) []models.ContentRange {
+	if n := len(ranges); n > 0 && ranges[n-1].Offset+ranges[n-1].Length == offset {
+		ranges[n-1].Length += length
+		return ranges
+	}
+	return append(ranges, models.ContentRange{Offset: offset, Length: length})
+}
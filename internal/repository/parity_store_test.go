@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParityStoreRepairsDamagedBlocks(t *testing.T) {
+	ctx := newTestContext(t)
+	root := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Storage.Parity.DataShards = 4
+	cfg.Storage.Parity.ParityShards = 2
+	cfg.Storage.Parity.ShardSize = 16
+
+	storage := NewStorageRepositoryWithBackend(NewLocalBackend(root), cfg)
+	store, err := NewParityStore(storage, cfg)
+	require.NoError(t, err)
+
+	// 150 байт: две полные группы по 64 байта и неполная третья
+	content := make([]byte, 150)
+	_, err = rand.Read(content)
+	require.NoError(t, err)
+	info, err := storage.SaveFile(ctx, "owner/file.bin", bytes.NewReader(content))
+	require.NoError(t, err)
+	sha := info.SHA256Checksum
+
+	result, err := store.Repair(ctx, "owner/file.bin", sha)
+	require.NoError(t, err)
+	assert.False(t, result.Protected)
+
+	require.NoError(t, store.Protect(ctx, "owner/file.bin", sha))
+	assert.FileExists(t, filepath.Join(root, store.Key(sha)))
+
+	damage := func(offsets ...int) {
+		damaged := append([]byte(nil), content...)
+		for _, offset := range offsets {
+			damaged[offset] ^= 0xff
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(root, "owner", "file.bin"), damaged, 0644))
+	}
+
+	// Два соседних блока первой группы и неполный последний блок
+	damage(20, 40, 145)
+	result, err = store.Repair(ctx, "owner/file.bin", sha)
+	require.NoError(t, err)
+	assert.True(t, result.Repaired)
+	assert.Equal(t, []models.ContentRange{{Offset: 16, Length: 32}, {Offset: 144, Length: 6}}, result.Damaged)
+	repaired, err := os.ReadFile(filepath.Join(root, "owner", "file.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, content, repaired)
+
+	// Три блока одной группы при двух блоках четности не восстановить
+	damage(0, 20, 40)
+	result, err = store.Repair(ctx, "owner/file.bin", sha)
+	require.NoError(t, err)
+	assert.False(t, result.Repaired)
+	assert.Len(t, result.Damaged, 1)
+
+	// Поврежденная четность при целых данных строится заново
+	damage()
+	sidecar := filepath.Join(root, store.Key(sha))
+	require.NoError(t, os.WriteFile(sidecar, []byte("garbage"), 0644))
+	result, err = store.Repair(ctx, "owner/file.bin", sha)
+	require.NoError(t, err)
+	assert.Empty(t, result.Damaged)
+	assert.True(t, result.ParityRebuilt)
+
+	damage(100)
+	result, err = store.Repair(ctx, "owner/file.bin", sha)
+	require.NoError(t, err)
+	assert.True(t, result.Repaired)
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"fmt"
+)
+
+// Арифметика поля GF(2^8) с порождающим многочленом x^8+x^4+x^3+x^2+1 (0x11d)
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	// Дублируем таблицу, чтобы сумма логарифмов не требовала взятия по модулю
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfInv обратный элемент, a != 0
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// gfMulAdd прибавляет к dst вектор src, умноженный на c
+func gfMulAdd(dst, src []byte, c byte) {
+	switch c {
+	case 0:
+		return
+	case 1:
+		for i, v := range src {
+			dst[i] ^= v
+		}
+		return
+	}
+
+	var table [256]byte
+	for v := 1; v < 256; v++ {
+		table[v] = gfMul(c, byte(v))
+	}
+	for i, v := range src {
+		dst[i] ^= table[v]
+	}
+}
+
+// reedSolomon систематический код Рида-Соломона: dataShards блоков данных хранятся как есть,
+// parityShards блоков четности - их линейные комбинации. Любые dataShards целых блоков
+// из dataShards+parityShards восстанавливают все остальные
+type reedSolomon struct {
+	dataShards   int
+	parityShards int
+	// matrix кодирующая матрица: единичная для данных и матрица Коши для четности.
+	// Любая квадратная подматрица матрицы Коши невырождена, поэтому любые dataShards строк обратимы
+	matrix [][]byte
+}
+
+func newReedSolomon(dataShards, parityShards int) (*reedSolomon, error) {
+	if dataShards <= 0 || parityShards <= 0 || dataShards+parityShards > 256 {
+		return nil, fmt.Errorf("invalid reed-solomon parameters: %d data + %d parity shards", dataShards, parityShards)
+	}
+
+	matrix := make([][]byte, dataShards+parityShards)
+	for i := 0; i < dataShards; i++ {
+		matrix[i] = make([]byte, dataShards)
+		matrix[i][i] = 1
+	}
+	for i := 0; i < parityShards; i++ {
+		row := make([]byte, dataShards)
+		for j := range row {
+			row[j] = gfInv(byte(dataShards+i) ^ byte(j))
+		}
+		matrix[dataShards+i] = row
+	}
+
+	return &reedSolomon{dataShards: dataShards, parityShards: parityShards, matrix: matrix}, nil
+}
+
+// Encode заполняет блоки четности shards[dataShards:] по блокам данных. Все блоки одного размера
+func (rs *reedSolomon) Encode(shards [][]byte) {
+	for i := 0; i < rs.parityShards; i++ {
+		parity := shards[rs.dataShards+i]
+		clear(parity)
+		for j := 0; j < rs.dataShards; j++ {
+			gfMulAdd(parity, shards[j], rs.matrix[rs.dataShards+i][j])
+		}
+	}
+}
+
+// Reconstruct восстанавливает блоки, для которых present[i] == false, на месте
+func (rs *reedSolomon) Reconstruct(shards [][]byte, present []bool) error {
+	var rows []int
+	for i := range shards {
+		if present[i] {
+			rows = append(rows, i)
+			if len(rows) == rs.dataShards {
+				break
+			}
+		}
+	}
+	if len(rows) < rs.dataShards {
+		return fmt.Errorf("too many damaged shards: %d intact, %d required", len(rows), rs.dataShards)
+	}
+
+	sub := make([][]byte, rs.dataShards)
+	for i, row := range rows {
+		sub[i] = rs.matrix[row]
+	}
+	inverse, err := gfInvertMatrix(sub)
+	if err != nil {
+		return err
+	}
+
+	// Данные - произведение обратной матрицы на выбранные целые блоки
+	for j := 0; j < rs.dataShards; j++ {
+		if present[j] {
+			continue
+		}
+		clear(shards[j])
+		for i, row := range rows {
+			gfMulAdd(shards[j], shards[row], inverse[j][i])
+		}
+	}
+
+	// Четность после восстановления данных просто пересчитывается
+	for i := 0; i < rs.parityShards; i++ {
+		if present[rs.dataShards+i] {
+			continue
+		}
+		parity := shards[rs.dataShards+i]
+		clear(parity)
+		for j := 0; j < rs.dataShards; j++ {
+			gfMulAdd(parity, shards[j], rs.matrix[rs.dataShards+i][j])
+		}
+	}
+	return nil
+}
+
+// gfInvertMatrix обращает квадратную матрицу методом Гаусса-Жордана
+func gfInvertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	work := make([][]byte, n)
+	for i := range m {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if work[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, fmt.Errorf("singular matrix")
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		if c := work[col][col]; c != 1 {
+			inv := gfInv(c)
+			for k := range work[col] {
+				work[col][k] = gfMul(work[col][k], inv)
+			}
+		}
+		for row := 0; row < n; row++ {
+			if row != col && work[row][col] != 0 {
+				gfMulAdd(work[row], work[col], work[row][col])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+	return inverse, nil
+}
@@ -0,0 +1,837 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+)
+
+const (
+	// defaultS3PartSize размер части multipart загрузки по умолчанию
+	defaultS3PartSize = 8 << 20
+	// minS3PartSize минимальный размер части (кроме последней), допускаемый S3
+	minS3PartSize = 5 << 20
+	// s3ListPageSize максимальное число ключей в одной странице листинга
+	s3ListPageSize = 1000
+	// maxS3CopyObjectSize предел одного CopyObject, объекты больше копируются по частям
+	maxS3CopyObjectSize = 5 << 30
+	// defaultS3CopyPartSize размер части UploadPartCopy
+	defaultS3CopyPartSize = 512 << 20
+	// maxS3Parts максимальное число частей multipart загрузки
+	maxS3Parts = 10000
+
+	defaultS3DialTimeout           = 10 * time.Second
+	defaultS3TLSHandshakeTimeout   = 10 * time.Second
+	defaultS3ResponseHeaderTimeout = 60 * time.Second
+)
+
+// s3Backend драйвер хранилища поверх S3-совместимого API (AWS S3, MinIO, Ceph RGW).
+// Ключи хранилища отображаются на объекты Prefix/UserDirName/<key> в бакете
+type s3Backend struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	pathStyle bool
+	partSize  int
+	signer    *s3Signer
+	now       func() time.Time
+	// copyThreshold и copyPartSize управляют копированием по частям (UploadPartCopy)
+	copyThreshold int64
+	copyPartSize  int64
+}
+
+func newS3Backend(cfg *config.Config) (interfaces.StorageBackend, error) {
+	return NewS3Backend(cfg.Storage.S3, cfg.Storage.UserDirName, newS3HTTPClient(cfg.Storage.S3))
+}
+
+// newS3HTTPClient создает http клиент с таймаутами соединения: у http.DefaultClient их нет,
+// и недоступный S3 подвешивал бы запросы навсегда. Общего Timeout нет - он оборвал бы
+// передачу больших файлов
+func newS3HTTPClient(s3cfg config.S3Config) *http.Client {
+	dialTimeout := s3cfg.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultS3DialTimeout
+	}
+	tlsHandshakeTimeout := s3cfg.TLSHandshakeTimeout
+	if tlsHandshakeTimeout <= 0 {
+		tlsHandshakeTimeout = defaultS3TLSHandshakeTimeout
+	}
+	responseHeaderTimeout := s3cfg.ResponseHeaderTimeout
+	if responseHeaderTimeout <= 0 {
+		responseHeaderTimeout = defaultS3ResponseHeaderTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = tlsHandshakeTimeout
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	return &http.Client{Transport: transport}
+}
+
+// NewS3Backend создает драйвер S3 с указанным http клиентом
+func NewS3Backend(s3cfg config.S3Config, userDirName string, client *http.Client) (interfaces.StorageBackend, error) {
+	if s3cfg.Endpoint == "" {
+		return nil, fmt.Errorf("s3 endpoint is required")
+	}
+	if s3cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+
+	endpoint, err := url.Parse(s3cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %q", s3cfg.Endpoint)
+	}
+
+	region := s3cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	partSize := s3cfg.PartSize
+	if partSize == 0 {
+		partSize = defaultS3PartSize
+	}
+	if partSize < minS3PartSize {
+		return nil, fmt.Errorf("s3 part size must be at least %d bytes", minS3PartSize)
+	}
+
+	return &s3Backend{
+		client:        client,
+		endpoint:      endpoint,
+		bucket:        s3cfg.Bucket,
+		prefix:        strings.Trim(path.Join(s3cfg.Prefix, userDirName), "/"),
+		pathStyle:     s3cfg.PathStyle,
+		partSize:      int(partSize),
+		copyThreshold: maxS3CopyObjectSize,
+		copyPartSize:  defaultS3CopyPartSize,
+		signer: &s3Signer{
+			accessKey: s3cfg.AccessKey,
+			secretKey: s3cfg.SecretKey,
+			region:    region,
+			service:   "s3",
+		},
+		now: time.Now,
+	}, nil
+}
+
+func (b *s3Backend) Name() string {
+	return StorageDriverS3
+}
+
+// objectKey переводит ключ хранилища в ключ объекта в бакете
+func (b *s3Backend) objectKey(key string) string {
+	return strings.TrimPrefix(path.Join(b.prefix, key), "/")
+}
+
+// dirPrefix возвращает префикс объектов, лежащих "внутри" ключа
+func (b *s3Backend) dirPrefix(key string) string {
+	p := b.objectKey(key)
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
+
+// storageKey переводит ключ объекта обратно в ключ хранилища
+func (b *s3Backend) storageKey(objectKey string) string {
+	if b.prefix == "" {
+		return objectKey
+	}
+	return strings.TrimPrefix(objectKey, b.prefix+"/")
+}
+
+// s3Error ошибка, возвращенная S3 API
+type s3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("s3 error %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *s3Error) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound {
+		return errdefs.ErrFileNotFound
+	}
+	return nil
+}
+
+// do выполняет подписанный запрос к объекту objectKey (или к бакету, если ключ пустой)
+func (b *s3Backend) do(ctx context.Context, method, objectKey string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *b.endpoint
+	urlPath := "/" + objectKey
+	if b.pathStyle {
+		urlPath = "/" + b.bucket + urlPath
+	} else {
+		u.Host = b.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(b.endpoint.Path, "/") + urlPath
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build s3 request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.ContentLength = int64(len(body))
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+	}
+	b.signer.sign(req, payloadHash, b.now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 request failed: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &s3Error{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if len(data) > 0 {
+			xml.Unmarshal(data, apiErr)
+		}
+		if apiErr.Code == "" {
+			apiErr.Code = http.StatusText(resp.StatusCode)
+		}
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+// doDiscard выполняет запрос и отбрасывает тело ответа
+func (b *s3Backend) doDiscard(ctx context.Context, method, objectKey string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	resp, err := b.do(ctx, method, objectKey, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp, nil
+}
+
+// doXML выполняет запрос и декодирует XML ответ в out
+func (b *s3Backend) doXML(ctx context.Context, method, objectKey string, query url.Values, header http.Header, body []byte, out interface{}) error {
+	resp, err := b.do(ctx, method, objectKey, query, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode s3 response: %w", err)
+	}
+	return nil
+}
+
+func (b *s3Backend) NewWriter(ctx context.Context, key string) (interfaces.BlobWriter, error) {
+	return &s3Writer{
+		backend: b,
+		ctx:     ctx,
+		key:     key,
+		sums:    newChecksumWriter(),
+	}, nil
+}
+
+func (b *s3Backend) Open(ctx context.Context, key string) (interfaces.BlobReader, error) {
+	info, err := b.headObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Reader{
+		backend: b,
+		ctx:     ctx,
+		key:     key,
+		size:    info.Size,
+	}, nil
+}
+
+// headObject возвращает размер и время изменения объекта
+func (b *s3Backend) headObject(ctx context.Context, key string) (*interfaces.FileInfo, error) {
+	resp, err := b.doDiscard(ctx, http.MethodHead, b.objectKey(key), nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+
+	info := &interfaces.FileInfo{
+		Path: key,
+		Size: resp.ContentLength,
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModifiedAt = modified.Unix()
+	}
+	return info, nil
+}
+
+func (b *s3Backend) Stat(ctx context.Context, key string) (*interfaces.FileInfo, error) {
+	info, err := b.headObject(ctx, key)
+	if err == nil {
+		return info, nil
+	}
+	if !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return nil, err
+	}
+
+	// Объекта нет - проверяем, не является ли ключ "директорией"
+	page, listErr := b.listObjects(ctx, b.dirPrefix(key), "", "", 1)
+	if listErr != nil {
+		return nil, listErr
+	}
+	if len(page.Contents) == 0 && len(page.CommonPrefixes) == 0 {
+		return nil, err
+	}
+	return &interfaces.FileInfo{Path: key, IsDirectory: true}, nil
+}
+
+func (b *s3Backend) Delete(ctx context.Context, key string) error {
+	// DELETE в S3 идемпотентен, поэтому отсутствие объекта проверяем явно
+	if _, err := b.headObject(ctx, key); err != nil {
+		return err
+	}
+	if _, err := b.doDiscard(ctx, http.MethodDelete, b.objectKey(key), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *s3Backend) Copy(ctx context.Context, srcKey, dstKey string) error {
+	info, err := b.headObject(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	return b.copyObject(ctx, srcKey, dstKey, info.Size)
+}
+
+// copyObject копирует объект размером size. Один CopyObject принимает не больше 5GB,
+// объекты крупнее copyThreshold копируются multipart загрузкой из частей исходного объекта
+func (b *s3Backend) copyObject(ctx context.Context, srcKey, dstKey string, size int64) error {
+	copySource := s3Escape("/"+b.bucket+"/"+b.objectKey(srcKey), false)
+	if size > b.copyThreshold {
+		if err := b.multipartCopy(ctx, copySource, dstKey, size); err != nil {
+			return fmt.Errorf("failed to copy object %s to %s: %w", srcKey, dstKey, err)
+		}
+		return nil
+	}
+
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", copySource)
+
+	var result struct {
+		XMLName xml.Name `xml:"CopyObjectResult"`
+	}
+	if err := b.doXML(ctx, http.MethodPut, b.objectKey(dstKey), nil, header, nil, &result); err != nil {
+		return fmt.Errorf("failed to copy object %s to %s: %w", srcKey, dstKey, err)
+	}
+	return nil
+}
+
+func (b *s3Backend) multipartCopy(ctx context.Context, copySource, dstKey string, size int64) error {
+	partSize := b.copyPartSize
+	if minPart := (size + maxS3Parts - 1) / maxS3Parts; partSize < minPart {
+		partSize = minPart
+	}
+
+	uploadID, err := b.createMultipartUpload(ctx, dstKey)
+	if err != nil {
+		return err
+	}
+
+	var parts []s3CompletedPart
+	for offset := int64(0); offset < size; offset += partSize {
+		end := min(offset+partSize, size) - 1
+		etag, err := b.uploadPartCopy(ctx, copySource, dstKey, uploadID, len(parts)+1, offset, end)
+		if err != nil {
+			b.abortMultipartUpload(context.WithoutCancel(ctx), dstKey, uploadID)
+			return err
+		}
+		parts = append(parts, s3CompletedPart{PartNumber: len(parts) + 1, ETag: etag})
+	}
+
+	if err := b.completeMultipartUpload(ctx, dstKey, uploadID, parts); err != nil {
+		b.abortMultipartUpload(context.WithoutCancel(ctx), dstKey, uploadID)
+		return err
+	}
+	return nil
+}
+
+func (b *s3Backend) Move(ctx context.Context, srcKey, dstKey string) error {
+	// В S3 нет переименования: копируем и удаляем исходный объект
+	info, err := b.headObject(ctx, srcKey)
+	if err == nil {
+		if err := b.copyObject(ctx, srcKey, dstKey, info.Size); err != nil {
+			return err
+		}
+		return b.Delete(ctx, srcKey)
+	}
+	if !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return err
+	}
+
+	// Перемещение "директории" - переносим все объекты под префиксом
+	moved := 0
+	err = b.Walk(ctx, srcKey, func(info *interfaces.FileInfo) error {
+		rel := strings.TrimPrefix(info.Path, strings.TrimSuffix(srcKey, "/")+"/")
+		if err := b.copyObject(ctx, info.Path, path.Join(dstKey, rel), info.Size); err != nil {
+			return err
+		}
+		moved++
+		return b.Delete(ctx, info.Path)
+	})
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		return fmt.Errorf("%w: %s", errdefs.ErrFileNotFound, srcKey)
+	}
+	return nil
+}
+
+func (b *s3Backend) MakeDir(ctx context.Context, key string) error {
+	// В S3 директорий нет, префикс появляется вместе с первым объектом
+	return nil
+}
+
+func (b *s3Backend) DeletePrefix(ctx context.Context, key string) error {
+	if b.dirPrefix(key) == "" || key == "" {
+		return fmt.Errorf("%w: refusing to delete storage root", errdefs.ErrInvalidPath)
+	}
+
+	err := b.Walk(ctx, key, func(info *interfaces.FileInfo) error {
+		_, err := b.doDiscard(ctx, http.MethodDelete, b.objectKey(info.Path), nil, nil, nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete directory: %w", err)
+	}
+
+	// Ключ мог быть и самим объектом
+	if _, err := b.doDiscard(ctx, http.MethodDelete, b.objectKey(key), nil, nil, nil); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *s3Backend) List(ctx context.Context, key string) ([]string, error) {
+	prefix := b.dirPrefix(key)
+
+	var names []string
+	token := ""
+	for {
+		page, err := b.listObjects(ctx, prefix, "/", token, s3ListPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+		for _, obj := range page.Contents {
+			names = append(names, strings.TrimPrefix(obj.Key, prefix))
+		}
+		for _, p := range page.CommonPrefixes {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/"))
+		}
+		if !page.IsTruncated {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	return names, nil
+}
+
+func (b *s3Backend) Walk(ctx context.Context, key string, fn func(info *interfaces.FileInfo) error) error {
+	prefix := b.dirPrefix(key)
+
+	token := ""
+	for {
+		page, err := b.listObjects(ctx, prefix, "", token, s3ListPageSize)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			info := &interfaces.FileInfo{
+				Path: b.storageKey(obj.Key),
+				Size: obj.Size,
+			}
+			if modified, err := time.Parse(time.RFC3339, obj.LastModified); err == nil {
+				info.ModifiedAt = modified.Unix()
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// s3ListResult ответ ListObjectsV2
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+func (b *s3Backend) listObjects(ctx context.Context, prefix, delimiter, token string, maxKeys int) (*s3ListResult, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("max-keys", strconv.Itoa(maxKeys))
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	var result s3ListResult
+	if err := b.doXML(ctx, http.MethodGet, "", query, nil, nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	return &result, nil
+}
+
+// Multipart загрузка
+func (b *s3Backend) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := b.doXML(ctx, http.MethodPost, b.objectKey(key), url.Values{"uploads": {""}}, nil, nil, &result); err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return result.UploadID, nil
+}
+
+func (b *s3Backend) uploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (string, error) {
+	query := url.Values{}
+	query.Set("partNumber", strconv.Itoa(partNumber))
+	query.Set("uploadId", uploadID)
+
+	resp, err := b.doDiscard(ctx, http.MethodPut, b.objectKey(key), query, nil, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (b *s3Backend) completeMultipartUpload(ctx context.Context, key, uploadID string, parts []s3CompletedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("failed to encode multipart completion: %w", err)
+	}
+
+	// S3 может вернуть ошибку внутри ответа 200 OK, поэтому разбираем тело
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := b.doXML(ctx, http.MethodPost, b.objectKey(key), url.Values{"uploadId": {uploadID}}, nil, body, &result); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload: %w", &s3Error{StatusCode: http.StatusOK, Code: result.Code, Message: result.Message})
+	}
+	return nil
+}
+
+func (b *s3Backend) uploadPartCopy(ctx context.Context, copySource, key, uploadID string, partNumber int, first, last int64) (string, error) {
+	query := url.Values{}
+	query.Set("partNumber", strconv.Itoa(partNumber))
+	query.Set("uploadId", uploadID)
+
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", copySource)
+	header.Set("X-Amz-Copy-Source-Range", fmt.Sprintf("bytes=%d-%d", first, last))
+
+	// Как и при завершении загрузки, ошибка может прийти в теле ответа 200 OK
+	var result struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := b.doXML(ctx, http.MethodPut, b.objectKey(key), query, header, nil, &result); err != nil {
+		return "", fmt.Errorf("failed to copy part %d: %w", partNumber, err)
+	}
+	if result.XMLName.Local == "Error" {
+		return "", fmt.Errorf("failed to copy part %d: %w", partNumber, &s3Error{StatusCode: http.StatusOK, Code: result.Code, Message: result.Message})
+	}
+	return result.ETag, nil
+}
+
+func (b *s3Backend) abortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	if _, err := b.doDiscard(ctx, http.MethodDelete, b.objectKey(key), url.Values{"uploadId": {uploadID}}, nil, nil); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// s3Writer реализация interfaces.BlobWriter: небольшие объекты отправляются одним PUT,
+// большие - multipart загрузкой частями по partSize, в памяти держится не больше одной части
+type s3Writer struct {
+	backend  *s3Backend
+	ctx      context.Context
+	key      string
+	sums     *checksumWriter
+	buf      []byte
+	uploadID string
+	parts    []s3CompletedPart
+	err      error
+	closed   bool
+	info     *interfaces.FileInfo
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("write to closed blob writer")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.sums.Write(p)
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= w.backend.partSize {
+		if err := w.flushPart(w.buf[:w.backend.partSize]); err != nil {
+			w.err = err
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[w.backend.partSize:]...)
+	}
+	return len(p), nil
+}
+
+// flushPart отправляет очередную часть, при первом вызове начинает multipart загрузку
+func (w *s3Writer) flushPart(data []byte) error {
+	if w.uploadID == "" {
+		uploadID, err := w.backend.createMultipartUpload(w.ctx, w.key)
+		if err != nil {
+			return err
+		}
+		w.uploadID = uploadID
+	}
+
+	partNumber := len(w.parts) + 1
+	etag, err := w.backend.uploadPart(w.ctx, w.key, w.uploadID, partNumber, data)
+	if err != nil {
+		return err
+	}
+	w.parts = append(w.parts, s3CompletedPart{PartNumber: partNumber, ETag: etag})
+	return nil
+}
+
+func (w *s3Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if w.err != nil {
+		return w.err
+	}
+	w.closed = true
+
+	if w.uploadID == "" {
+		// Объект поместился в одну часть - обычный PUT
+		if _, err := w.backend.doDiscard(w.ctx, http.MethodPut, w.backend.objectKey(w.key), nil, nil, w.buf); err != nil {
+			return fmt.Errorf("failed to put object %s: %w", w.key, err)
+		}
+	} else {
+		if len(w.buf) > 0 {
+			if err := w.flushPart(w.buf); err != nil {
+				return err
+			}
+		}
+		if err := w.backend.completeMultipartUpload(w.ctx, w.key, w.uploadID, w.parts); err != nil {
+			return err
+		}
+		w.uploadID = ""
+	}
+
+	w.buf = nil
+	w.info = w.sums.info(w.key)
+	return nil
+}
+
+// Abort после успешного Close ничего не делает: объект уже заменил прежний
+func (w *s3Writer) Abort() error {
+	if w.info != nil {
+		return nil
+	}
+	w.closed = true
+	w.buf = nil
+
+	// Незавершенная multipart загрузка занимает место в бакете, пока её не отменят
+	if w.uploadID != "" {
+		uploadID := w.uploadID
+		w.uploadID = ""
+		return w.backend.abortMultipartUpload(context.WithoutCancel(w.ctx), w.key, uploadID)
+	}
+	return nil
+}
+
+func (w *s3Writer) Info() *interfaces.FileInfo {
+	return w.info
+}
+
+// s3Reader реализация interfaces.BlobReader: последовательное чтение одним GET
+// с текущей позиции, ReadAt - ranged GET на каждый вызов
+type s3Reader struct {
+	backend *s3Backend
+	ctx     context.Context
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (r *s3Reader) Size() int64 {
+	return r.size
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		body, err := r.getRange(r.offset, r.size-1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *s3Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := off + int64(len(p)) - 1
+	if end >= r.size {
+		end = r.size - 1
+	}
+
+	body, err := r.getRange(off, end)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p[:end-off+1])
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek меняет позицию следующего Read. Открытый GET закрывается, следующий Read
+// откроет новый с нужного смещения
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// getRange выполняет GET с заголовком Range: bytes=start-end
+func (r *s3Reader) getRange(start, end int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := r.backend.do(r.ctx, http.MethodGet, r.backend.objectKey(r.key), nil, header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", r.key, err)
+	}
+
+	// Сервер без поддержки Range вернет весь объект - пропускаем лишнее
+	if resp.StatusCode == http.StatusOK && start > 0 {
+		if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to skip to offset %d: %w", start, err)
+		}
+	}
+	return resp.Body, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body != nil {
+		err := r.body.Close()
+		r.body = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// scrubStatusDir каталог итогов фоновой проверки внутри временной директории
+const scrubStatusDir = "scrub"
+
+// scrubStatusStore хранит итог проверки каждого пользователя в файле <owner>.json,
+// чтобы статус переживал перезапуск сервиса
+type scrubStatusStore struct {
+	records *jsonStore[models.ScrubStatus]
+}
+
+// NewScrubStatusStore создает хранилище итогов фоновой проверки в Storage.TempPath
+func NewScrubStatusStore(cfg *config.Config) (interfaces.ScrubStatusStore, error) {
+	records, err := newJSONStore[models.ScrubStatus](cfg, scrubStatusDir, "scrub status")
+	if err != nil {
+		return nil, err
+	}
+	return &scrubStatusStore{records: records}, nil
+}
+
+func (s *scrubStatusStore) Save(ctx context.Context, status *models.ScrubStatus) error {
+	return s.records.save(status.OwnerID.String(), status)
+}
+
+func (s *scrubStatusStore) Get(ctx context.Context, ownerID uuid.UUID) (*models.ScrubStatus, error) {
+	return s.records.get(ownerID.String())
+}
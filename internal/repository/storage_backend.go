@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+)
+
+// Имена встроенных драйверов хранилища
+const (
+	StorageDriverLocal  = "local"
+	StorageDriverS3     = "s3"
+	StorageDriverMirror = "mirror"
+)
+
+// StorageDriverFactory создает драйвер хранилища по конфигурации
+type StorageDriverFactory func(cfg *config.Config) (interfaces.StorageBackend, error)
+
+var (
+	storageDriversMu sync.RWMutex
+	storageDrivers   = make(map[string]StorageDriverFactory)
+)
+
+func init() {
+	RegisterStorageDriver(StorageDriverLocal, newLocalBackend)
+	RegisterStorageDriver(StorageDriverS3, newS3Backend)
+	RegisterStorageDriver(StorageDriverMirror, newMirrorBackend)
+}
+
+// RegisterStorageDriver регистрирует драйвер хранилища под именем name
+func RegisterStorageDriver(name string, factory StorageDriverFactory) {
+	storageDriversMu.Lock()
+	defer storageDriversMu.Unlock()
+
+	if factory == nil {
+		panic("storage driver factory is nil: " + name)
+	}
+	if _, exists := storageDrivers[name]; exists {
+		panic("storage driver already registered: " + name)
+	}
+	storageDrivers[name] = factory
+}
+
+// StorageDrivers возвращает отсортированный список зарегистрированных драйверов
+func StorageDrivers() []string {
+	storageDriversMu.RLock()
+	defer storageDriversMu.RUnlock()
+
+	names := make([]string, 0, len(storageDrivers))
+	for name := range storageDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStorageBackend создает драйвер хранилища, выбранный в cfg.Storage.Driver
+func NewStorageBackend(cfg *config.Config) (interfaces.StorageBackend, error) {
+	name := cfg.Storage.Driver
+	if name == "" {
+		name = StorageDriverLocal
+	}
+
+	storageDriversMu.RLock()
+	factory, ok := storageDrivers[name]
+	storageDriversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage driver %q (available: %s)", name, strings.Join(StorageDrivers(), ", "))
+	}
+
+	backend, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init storage driver %q: %w", name, err)
+	}
+	return backend, nil
+}
+
+// normalizeKey приводит путь к ключу хранилища и запрещает выход за пределы директории пользователей
+func normalizeKey(p string) (string, error) {
+	key := path.Clean(strings.TrimLeft(strings.ReplaceAll(p, "\\", "/"), "/"))
+	if key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("%w: path traversal attempt", errdefs.ErrInvalidPath)
+	}
+	if key == "." {
+		return "", nil
+	}
+	return key, nil
+}
@@ -1,15 +1,18 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/sha256"
 	"fmt"
+	"hash"
 	"io"
 	"os"
-	"path/filepath"
 	"sync"
 
 	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
 	"homecloud-file-service/internal/interfaces"
 	"homecloud-file-service/internal/logger"
 
@@ -18,14 +21,21 @@ import (
 )
 
 type storageRepository struct {
-	cfg      *config.Config
-	basePath string
-	userPath string
+	cfg     *config.Config
+	backend interfaces.StorageBackend
 	// Сессии для возобновляемых загрузок
 	sessions     map[string]*uploadSession
 	sessionMutex sync.RWMutex
+	// repairing ключи зеркальных копий, починка которых уже идет в фоне
+	repairing sync.Map
+	// verifyBeforeServeSize файлы не больше этого размера GetVerifiedFile сверяет до отдачи
+	verifyBeforeServeSize int64
 }
 
+// defaultVerifyBeforeServeSize лишнее чтение небольшого файла целиком дешевле, чем отдать
+// испорченные байты. Большие файлы сверяются с другой копией по ходу чтения
+const defaultVerifyBeforeServeSize = 32 << 20
+
 type uploadSession struct {
 	FilePath  string
 	CountByte int64
@@ -34,166 +44,401 @@ type uploadSession struct {
 }
 
 func NewStorageRepository(cfg *config.Config) (interfaces.StorageRepository, error) {
-	// Создаем базовую директорию, если её нет
-	if err := os.MkdirAll(cfg.Storage.BasePath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create base storage directory: %w", err)
-	}
-
-	// Создаем директорию для пользователей
-	userPath := filepath.Join(cfg.Storage.BasePath, cfg.Storage.UserDirName)
-	if err := os.MkdirAll(userPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create user directory: %w", err)
-	}
-
-	// Создаем временную директорию
+	// Создаем временную директорию, она всегда локальная независимо от драйвера
 	if err := os.MkdirAll(cfg.Storage.TempPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 
-	return &storageRepository{
-		cfg:      cfg,
-		basePath: cfg.Storage.BasePath,
-		userPath: userPath,
-		sessions: make(map[string]*uploadSession),
-	}, nil
+	// Выбираем драйвер хранилища блобов по конфигурации
+	backend, err := NewStorageBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStorageRepositoryWithBackend(backend, cfg), nil
 }
 
-// getHomeDirHandle возвращает абсолютный путь к директории хранения файлов пользователей
-func (r *storageRepository) getHomeDirHandle() string {
-	return r.userPath
+// NewStorageRepositoryWithBackend создает репозиторий поверх уже созданного драйвера хранилища
+func NewStorageRepositoryWithBackend(backend interfaces.StorageBackend, cfg *config.Config) interfaces.StorageRepository {
+	return &storageRepository{
+		cfg:                   cfg,
+		backend:               backend,
+		sessions:              make(map[string]*uploadSession),
+		verifyBeforeServeSize: defaultVerifyBeforeServeSize,
+	}
 }
 
-// validateFilePath проверяет, что путь файла корректен и не выходит за пределы разрешенной директории
+// validateFilePath проверяет, что путь файла корректен и не выходит за пределы разрешенной директории,
+// и возвращает ключ хранилища
 func (r *storageRepository) validateFilePath(filePath string) (string, error) {
-	// Объединяем корневую директорию и путь к файлу
-	uploadFilePath := filepath.Join(r.userPath, filePath)
-
-	// Нормализуем путь
-	uploadFilePath = filepath.Clean(uploadFilePath)
-
-	// Проверяем, не пытается ли пользователь выйти за пределы разрешенной директории
-	if !filepath.HasPrefix(uploadFilePath, r.userPath) {
-		return "", fmt.Errorf("invalid file path: path traversal attempt")
+	key, err := normalizeKey(filePath)
+	if err != nil {
+		return "", fmt.Errorf("invalid file path: %w", err)
 	}
-
-	return uploadFilePath, nil
+	return key, nil
 }
 
 // createUserDirectory создает директорию для пользователя, если её нет
-func (r *storageRepository) createUserDirectory(userID uuid.UUID) error {
-	userDir := filepath.Join(r.userPath, userID.String())
-	return os.MkdirAll(userDir, 0755)
+func (r *storageRepository) createUserDirectory(ctx context.Context, userID uuid.UUID) error {
+	return r.backend.MakeDir(ctx, userID.String())
 }
 
 // Операции с файлами в хранилище
-func (r *storageRepository) SaveFile(ctx context.Context, path string, content []byte) error {
+func (r *storageRepository) SaveFile(ctx context.Context, path string, content io.Reader) (*interfaces.FileInfo, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
-	lg.Info(ctx, "SaveFile (repo) called",
+	lg.Info(ctx, "SaveFile (repo) called", zap.String("path", path))
+
+	writer, err := r.NewWriter(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Копируем содержимое потоково, контрольные суммы считаются по ходу записи
+	bytesWritten, err := io.Copy(writer, content)
+	if err != nil {
+		lg.Error(ctx, "Failed to write file content",
+			zap.Error(err),
+			zap.String("path", path),
+			zap.Int64("bytesWritten", bytesWritten))
+		writer.Abort()
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		lg.Error(ctx, "Failed to close file",
+			zap.Error(err),
+			zap.String("path", path))
+		writer.Abort()
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	info := writer.Info()
+	lg.Info(ctx, "File saved successfully",
 		zap.String("path", path),
-		zap.Int("contentSize", len(content)))
+		zap.String("driver", r.backend.Name()),
+		zap.Int64("bytesWritten", info.Size),
+		zap.String("sha256", info.SHA256Checksum))
+
+	return info, nil
+}
+
+func (r *storageRepository) NewWriter(ctx context.Context, path string) (interfaces.BlobWriter, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
 
 	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
 		lg.Error(ctx, "Path validation failed",
 			zap.Error(err),
 			zap.String("path", path))
-		return fmt.Errorf("path validation failed: %w", err)
+		return nil, fmt.Errorf("path validation failed: %w", err)
 	}
 
 	lg.Debug(ctx, "Path validated successfully",
 		zap.String("originalPath", path),
-		zap.String("validPath", validPath))
+		zap.String("key", key))
 
-	// Создаем директорию, если она не существует
-	dir := filepath.Dir(validPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		lg.Error(ctx, "Failed to create directory",
+	writer, err := r.backend.NewWriter(ctx, key)
+	if err != nil {
+		lg.Error(ctx, "Failed to create file",
 			zap.Error(err),
-			zap.String("directory", dir))
-		return fmt.Errorf("failed to create directory: %w", err)
+			zap.String("key", key))
+		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
 
-	// Создаем и открываем файл для записи
-	file, err := os.Create(validPath)
+	return writer, nil
+}
+
+func (r *storageRepository) GetFile(ctx context.Context, path string) (interfaces.BlobReader, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "GetFile (repo) called", zap.String("path", path))
+
+	// Валидация пути
+	key, err := r.validateFilePath(path)
 	if err != nil {
-		lg.Error(ctx, "Failed to create file",
+		lg.Error(ctx, "Path validation failed",
 			zap.Error(err),
-			zap.String("path", validPath))
-		return fmt.Errorf("failed to create file: %w", err)
+			zap.String("path", path))
+		return nil, fmt.Errorf("path validation failed: %w", err)
 	}
-	defer file.Close()
 
-	// Записываем содержимое
-	bytesWritten, err := file.Write(content)
+	// Открываем файл, содержимое читается вызывающей стороной по мере необходимости
+	reader, err := r.backend.Open(ctx, key)
 	if err != nil {
-		lg.Error(ctx, "Failed to write file content",
+		lg.Error(ctx, "Failed to open file",
 			zap.Error(err),
-			zap.String("path", validPath),
-			zap.Int("expectedSize", len(content)))
-		return fmt.Errorf("failed to write file: %w", err)
+			zap.String("key", key))
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
-	lg.Info(ctx, "File saved successfully",
-		zap.String("path", validPath),
-		zap.Int("bytesWritten", bytesWritten),
-		zap.Int("contentSize", len(content)))
+	lg.Debug(ctx, "File opened",
+		zap.String("key", key),
+		zap.Int64("size", reader.Size()))
 
-	return nil
+	return reader, nil
 }
 
-func (r *storageRepository) GetFile(ctx context.Context, path string) ([]byte, error) {
+func (r *storageRepository) GetVerifiedFile(ctx context.Context, path string, expectedSHA256 string) (interfaces.BlobReader, error) {
+	mirrored, ok := r.backend.(interfaces.MirroredBackend)
+	if !ok || expectedSHA256 == "" {
+		// Единственную копию проверять незачем: восстановить ее все равно не из чего
+		return r.GetFile(ctx, path)
+	}
+
 	lg := logger.GetLoggerFromCtx(ctx)
-	lg.Info(ctx, "GetFile (repo) called", zap.String("path", path))
 
-	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
-		lg.Error(ctx, "Path validation failed",
-			zap.Error(err),
-			zap.String("path", path))
 		return nil, fmt.Errorf("path validation failed: %w", err)
 	}
 
-	// Проверка существования файла
-	fileInfo, err := os.Stat(validPath)
-	if os.IsNotExist(err) {
-		lg.Info(ctx, "File not found", zap.String("path", validPath))
-		return nil, fmt.Errorf("file not found: %s", path)
-	} else if err != nil {
-		lg.Error(ctx, "Failed to access file",
-			zap.Error(err),
-			zap.String("path", validPath))
-		return nil, fmt.Errorf("failed to access file: %w", err)
+	mirrors := mirrored.Mirrors()
+	var readers []interfaces.BlobReader
+	var lastErr error
+	missing := 0
+	for i, mirror := range mirrors {
+		reader, err := mirror.Open(ctx, key)
+		if err != nil {
+			if errdefs.Is(err, errdefs.ErrFileNotFound) {
+				missing++
+				lg.Error(ctx, "Storage mirror copy is missing", zap.String("key", key), zap.Int("mirror", i))
+			} else {
+				lg.Error(ctx, "Failed to read storage mirror copy", zap.Error(err), zap.String("key", key), zap.Int("mirror", i))
+			}
+			lastErr = err
+			continue
+		}
+		readers = append(readers, reader)
+	}
+	if len(readers) == 0 {
+		if missing == len(mirrors) {
+			return nil, fmt.Errorf("%w: %s", errdefs.ErrFileNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", key, lastErr)
+	}
+	if len(readers) < len(mirrors) {
+		r.queueRepair(ctx, key, expectedSHA256, mirrors)
+	}
+
+	onCorrupted := func() {
+		lg.Error(ctx, "Storage mirror copy is corrupted", zap.String("key", key), zap.String("expected", expectedSHA256))
+		r.queueRepair(ctx, key, expectedSHA256, mirrors)
+	}
+
+	// Небольшой файл сверяется до отдачи: клиент получает только целую копию
+	if readers[0].Size() <= r.verifyBeforeServeSize {
+		healthy, err := findHealthyCopy(readers, expectedSHA256, onCorrupted)
+		for _, reader := range readers {
+			if reader != healthy {
+				reader.Close()
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return healthy, nil
+	}
+
+	// Хешировать большой файл до первого байта ответа - это лишнее чтение всего файла
+	// на каждом скачивании. Копия сравнивается с другой по ходу чтения, а при расхождении
+	// чтение продолжается с той же позиции из копии, совпавшей с sha256
+	return &verifyingReader{
+		BlobReader:  readers[0],
+		readers:     readers,
+		hasher:      sha256.New(),
+		expected:    expectedSHA256,
+		onCorrupted: onCorrupted,
+	}, nil
+}
+
+// findHealthyCopy возвращает первую копию, содержимое которой совпадает с expectedSHA256.
+// Позиция Read копий не меняется
+func findHealthyCopy(readers []interfaces.BlobReader, expectedSHA256 string, onCorrupted func()) (interfaces.BlobReader, error) {
+	corrupted := false
+	for _, reader := range readers {
+		hasher := sha256.New()
+		_, err := io.Copy(hasher, io.NewSectionReader(reader, 0, reader.Size()))
+		if err == nil && fmt.Sprintf("%x", hasher.Sum(nil)) == expectedSHA256 {
+			if corrupted {
+				onCorrupted()
+			}
+			return reader, nil
+		}
+		corrupted = true
+	}
+	onCorrupted()
+	return nil, fmt.Errorf("%w: no mirror copy matches checksum %s", errdefs.ErrFileCorrupted, expectedSHA256)
+}
+
+// queueRepair запускает в фоне сверку всех копий объекта и починку поврежденных.
+// Повторный вызов, пока починка идет, ничего не делает
+func (r *storageRepository) queueRepair(ctx context.Context, key, expectedSHA256 string, mirrors []interfaces.StorageBackend) {
+	if _, running := r.repairing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer r.repairing.Delete(key)
+		r.repairObject(ctx, key, expectedSHA256, mirrors)
+	}()
+}
+
+// repairObject ищет целую копию объекта и перезаписывает ей остальные
+func (r *storageRepository) repairObject(ctx context.Context, key, expectedSHA256 string, mirrors []interfaces.StorageBackend) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	var healthy interfaces.StorageBackend
+	var damaged []int
+	for i, mirror := range mirrors {
+		actual, err := hashObject(ctx, mirror, key)
+		if err == nil && actual == expectedSHA256 {
+			if healthy == nil {
+				healthy = mirror
+			}
+			continue
+		}
+		damaged = append(damaged, i)
+	}
+
+	if healthy == nil {
+		lg.Error(ctx, "No healthy storage mirror copy to repair from", zap.String("key", key))
+		return
+	}
+	r.repairMirrors(ctx, key, expectedSHA256, healthy, mirrors, damaged)
+}
+
+// repairMirrors перезаписывает поврежденные копии содержимым целой. Сбой починки не мешает
+// отдать файл: копия остается поврежденной до следующего чтения
+func (r *storageRepository) repairMirrors(ctx context.Context, key, expectedSHA256 string, healthy interfaces.StorageBackend, mirrors []interfaces.StorageBackend, damaged []int) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	for _, i := range damaged {
+		if err := copyObject(ctx, healthy, mirrors[i], key, expectedSHA256); err != nil {
+			lg.Error(ctx, "Failed to repair storage mirror copy", zap.Error(err), zap.String("key", key), zap.Int("mirror", i))
+			continue
+		}
+		lg.Info(ctx, "Storage mirror copy repaired", zap.String("key", key), zap.Int("mirror", i))
+	}
+}
+
+// verifyingReader отдает большой файл из первой копии, сравнивая каждый прочитанный
+// участок с тем же участком второй. Если участки расходятся или копия не читается,
+// все копии сверяются с sha256 целиком, и чтение продолжается с той же позиции из целой:
+// испорченные байты клиенту не уходят. Когда сравнивать не с чем (открылась одна копия),
+// SHA-256 считается по ходу последовательного чтения, и при расхождении последний Read
+// возвращает ErrFileCorrupted, чтобы испорченный файл не сошел за целый.
+// ReadAt отдает данные текущей копии без сверки
+type verifyingReader struct {
+	interfaces.BlobReader
+	// readers все открытые копии, BlobReader - та, из которой идет чтение
+	readers     []interfaces.BlobReader
+	hasher      hash.Hash
+	expected    string
+	onCorrupted func()
+	// verified текущая копия сверена с sha256 целиком
+	verified bool
+	// offset позиция Read, hashed - сколько байт с начала вошло в hasher
+	offset int64
+	hashed int64
+	buf    []byte
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	if v.verified {
+		n, err := v.BlobReader.Read(p)
+		v.offset += int64(n)
+		return n, err
 	}
 
-	// Проверяем, что это файл, а не директория
-	if fileInfo.IsDir() {
-		lg.Error(ctx, "Path is a directory, not a file",
-			zap.String("path", validPath),
-			zap.Int64("size", fileInfo.Size()))
-		return nil, fmt.Errorf("path is a directory, not a file: %s", path)
+	n, err := v.BlobReader.Read(p)
+	if reference := v.reference(); reference != nil {
+		if err != nil && err != io.EOF {
+			return v.failover(p)
+		}
+		if n > 0 {
+			if cap(v.buf) < n {
+				v.buf = make([]byte, n)
+			}
+			m, _ := reference.ReadAt(v.buf[:n], v.offset)
+			if m < n || !bytes.Equal(p[:n], v.buf[:n]) {
+				return v.failover(p)
+			}
+		}
+		v.offset += int64(n)
+		return n, err
 	}
 
-	lg.Debug(ctx, "File info retrieved",
-		zap.String("path", validPath),
-		zap.Int64("size", fileInfo.Size()),
-		zap.Time("modTime", fileInfo.ModTime()))
+	if v.offset == v.hashed {
+		v.hasher.Write(p[:n])
+		v.hashed += int64(n)
+	}
+	v.offset += int64(n)
+	if n > 0 && v.hashed == v.Size() && v.offset == v.hashed {
+		if actual := fmt.Sprintf("%x", v.hasher.Sum(nil)); actual != v.expected {
+			v.onCorrupted()
+			return n, fmt.Errorf("%w: checksum %s, expected %s", errdefs.ErrFileCorrupted, actual, v.expected)
+		}
+	}
+	return n, err
+}
 
-	// Читаем файл
-	content, err := os.ReadFile(validPath)
+// reference копия, с которой сравнивается текущая
+func (v *verifyingReader) reference() interfaces.BlobReader {
+	for _, reader := range v.readers {
+		if reader != v.BlobReader {
+			return reader
+		}
+	}
+	return nil
+}
+
+// failover находит целую копию и продолжает чтение из нее с текущей позиции
+func (v *verifyingReader) failover(p []byte) (int, error) {
+	healthy, err := findHealthyCopy(v.readers, v.expected, v.onCorrupted)
 	if err != nil {
-		lg.Error(ctx, "Failed to read file",
-			zap.Error(err),
-			zap.String("path", validPath))
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return 0, err
+	}
+	if _, err := healthy.Seek(v.offset, io.SeekStart); err != nil {
+		return 0, err
 	}
+	v.BlobReader = healthy
+	v.verified = true
 
-	lg.Info(ctx, "File retrieved successfully",
-		zap.String("path", validPath),
-		zap.Int("contentSize", len(content)))
+	n, err := healthy.Read(p)
+	v.offset += int64(n)
+	return n, err
+}
+
+func (v *verifyingReader) Seek(offset int64, whence int) (int64, error) {
+	position, err := v.BlobReader.Seek(offset, whence)
+	if err == nil {
+		v.offset = position
+	}
+	return position, err
+}
+
+func (v *verifyingReader) Close() error {
+	var firstErr error
+	for _, reader := range v.readers {
+		if err := reader.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
 
-	return content, nil
+// LockKey блокирует ключ средствами драйвера. Драйвер без блокировок между процессами
+// (S3) ничего не блокирует
+func (r *storageRepository) LockKey(ctx context.Context, path string) (func(), error) {
+	key, err := r.validateFilePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("path validation failed: %w", err)
+	}
+	locker, ok := r.backend.(interfaces.KeyLocker)
+	if !ok {
+		return func() {}, nil
+	}
+	return locker.LockKey(ctx, key)
 }
 
 func (r *storageRepository) DeleteFile(ctx context.Context, path string) error {
@@ -201,7 +446,7 @@ func (r *storageRepository) DeleteFile(ctx context.Context, path string) error {
 	lg.Info(ctx, "DeleteFile (repo) called", zap.String("path", path))
 
 	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
 		lg.Error(ctx, "Path validation failed",
 			zap.Error(err),
@@ -209,21 +454,15 @@ func (r *storageRepository) DeleteFile(ctx context.Context, path string) error {
 		return fmt.Errorf("path validation failed: %w", err)
 	}
 
-	// Проверяем существование файла перед удалением
-	if _, err := os.Stat(validPath); os.IsNotExist(err) {
-		lg.Info(ctx, "File not found for deletion", zap.String("path", validPath))
-		return fmt.Errorf("file not found: %s", path)
-	}
-
 	// Удаляем файл
-	if err := os.Remove(validPath); err != nil {
+	if err := r.backend.Delete(ctx, key); err != nil {
 		lg.Error(ctx, "Failed to delete file",
 			zap.Error(err),
-			zap.String("path", validPath))
+			zap.String("key", key))
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
-	lg.Info(ctx, "File deleted successfully", zap.String("path", validPath))
+	lg.Info(ctx, "File deleted successfully", zap.String("key", key))
 	return nil
 }
 
@@ -234,7 +473,7 @@ func (r *storageRepository) MoveFile(ctx context.Context, oldPath, newPath strin
 		zap.String("newPath", newPath))
 
 	// Валидация путей
-	validOldPath, err := r.validateFilePath(oldPath)
+	oldKey, err := r.validateFilePath(oldPath)
 	if err != nil {
 		lg.Error(ctx, "Old path validation failed",
 			zap.Error(err),
@@ -242,7 +481,7 @@ func (r *storageRepository) MoveFile(ctx context.Context, oldPath, newPath strin
 		return fmt.Errorf("old path validation failed: %w", err)
 	}
 
-	validNewPath, err := r.validateFilePath(newPath)
+	newKey, err := r.validateFilePath(newPath)
 	if err != nil {
 		lg.Error(ctx, "New path validation failed",
 			zap.Error(err),
@@ -250,33 +489,18 @@ func (r *storageRepository) MoveFile(ctx context.Context, oldPath, newPath strin
 		return fmt.Errorf("new path validation failed: %w", err)
 	}
 
-	// Проверяем существование исходного файла
-	if _, err := os.Stat(validOldPath); os.IsNotExist(err) {
-		lg.Error(ctx, "Source file not found", zap.String("oldPath", validOldPath))
-		return fmt.Errorf("source file not found: %s", oldPath)
-	}
-
-	// Создаем директорию для нового пути
-	newDir := filepath.Dir(validNewPath)
-	if err := os.MkdirAll(newDir, 0755); err != nil {
-		lg.Error(ctx, "Failed to create directory",
-			zap.Error(err),
-			zap.String("directory", newDir))
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
 	// Перемещаем файл
-	if err := os.Rename(validOldPath, validNewPath); err != nil {
+	if err := r.backend.Move(ctx, oldKey, newKey); err != nil {
 		lg.Error(ctx, "Failed to move file",
 			zap.Error(err),
-			zap.String("oldPath", validOldPath),
-			zap.String("newPath", validNewPath))
+			zap.String("oldKey", oldKey),
+			zap.String("newKey", newKey))
 		return fmt.Errorf("failed to move file: %w", err)
 	}
 
 	lg.Info(ctx, "File moved successfully",
-		zap.String("oldPath", validOldPath),
-		zap.String("newPath", validNewPath))
+		zap.String("oldKey", oldKey),
+		zap.String("newKey", newKey))
 
 	return nil
 }
@@ -288,7 +512,7 @@ func (r *storageRepository) CopyFile(ctx context.Context, srcPath, dstPath strin
 		zap.String("dstPath", dstPath))
 
 	// Валидация путей
-	validSrcPath, err := r.validateFilePath(srcPath)
+	srcKey, err := r.validateFilePath(srcPath)
 	if err != nil {
 		lg.Error(ctx, "Source path validation failed",
 			zap.Error(err),
@@ -296,7 +520,7 @@ func (r *storageRepository) CopyFile(ctx context.Context, srcPath, dstPath strin
 		return fmt.Errorf("source path validation failed: %w", err)
 	}
 
-	validDstPath, err := r.validateFilePath(dstPath)
+	dstKey, err := r.validateFilePath(dstPath)
 	if err != nil {
 		lg.Error(ctx, "Destination path validation failed",
 			zap.Error(err),
@@ -304,48 +528,12 @@ func (r *storageRepository) CopyFile(ctx context.Context, srcPath, dstPath strin
 		return fmt.Errorf("destination path validation failed: %w", err)
 	}
 
-	// Проверяем существование исходного файла
-	srcInfo, err := os.Stat(validSrcPath)
-	if os.IsNotExist(err) {
-		lg.Error(ctx, "Source file not found", zap.String("srcPath", validSrcPath))
-		return fmt.Errorf("source file not found: %s", srcPath)
-	} else if err != nil {
-		lg.Error(ctx, "Failed to access source file",
-			zap.Error(err),
-			zap.String("srcPath", validSrcPath))
-		return fmt.Errorf("failed to access source file: %w", err)
-	}
-
-	lg.Debug(ctx, "Source file info",
-		zap.String("srcPath", validSrcPath),
-		zap.Int64("size", srcInfo.Size()),
-		zap.Time("modTime", srcInfo.ModTime()))
-
-	// Создаем директорию для нового пути
-	dstDir := filepath.Dir(validDstPath)
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		lg.Error(ctx, "Failed to create destination directory",
+	// Копируем содержимое средствами драйвера (в S3 - без скачивания объекта)
+	if err := r.backend.Copy(ctx, srcKey, dstKey); err != nil {
+		lg.Error(ctx, "Failed to copy file",
 			zap.Error(err),
-			zap.String("directory", dstDir))
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	// Открываем исходный файл
-	srcFile, err := os.Open(validSrcPath)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer srcFile.Close()
-
-	// Создаем целевой файл
-	dstFile, err := os.Create(validDstPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
-	}
-	defer dstFile.Close()
-
-	// Копируем содержимое
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+			zap.String("srcKey", srcKey),
+			zap.String("dstKey", dstKey))
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
 
@@ -355,13 +543,13 @@ func (r *storageRepository) CopyFile(ctx context.Context, srcPath, dstPath strin
 // Операции с директориями
 func (r *storageRepository) CreateDirectory(ctx context.Context, path string) error {
 	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
 		return fmt.Errorf("path validation failed: %w", err)
 	}
 
 	// Создаем директорию
-	if err := os.MkdirAll(validPath, 0755); err != nil {
+	if err := r.backend.MakeDir(ctx, key); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
@@ -370,13 +558,13 @@ func (r *storageRepository) CreateDirectory(ctx context.Context, path string) er
 
 func (r *storageRepository) DeleteDirectory(ctx context.Context, path string) error {
 	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
 		return fmt.Errorf("path validation failed: %w", err)
 	}
 
 	// Удаляем директорию
-	if err := os.RemoveAll(validPath); err != nil {
+	if err := r.backend.DeletePrefix(ctx, key); err != nil {
 		return fmt.Errorf("failed to delete directory: %w", err)
 	}
 
@@ -385,78 +573,61 @@ func (r *storageRepository) DeleteDirectory(ctx context.Context, path string) er
 
 func (r *storageRepository) ListDirectory(ctx context.Context, path string) ([]string, error) {
 	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
 		return nil, fmt.Errorf("path validation failed: %w", err)
 	}
 
 	// Читаем содержимое директории
-	entries, err := os.ReadDir(validPath)
+	files, err := r.backend.List(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	var files []string
-	for _, entry := range entries {
-		files = append(files, entry.Name())
-	}
-
 	return files, nil
 }
 
 // Информация о файлах
 func (r *storageRepository) GetFileInfo(ctx context.Context, path string) (*interfaces.FileInfo, error) {
 	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
 		return nil, fmt.Errorf("path validation failed: %w", err)
 	}
 
 	// Получаем информацию о файле
-	fileInfo, err := os.Stat(validPath)
+	info, err := r.backend.Stat(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
+	info.Path = path
 
 	// Вычисляем контрольные суммы только для файлов
-	var md5Checksum, sha256Checksum string
-	if !fileInfo.IsDir() {
-		md5Checksum, err = r.calculateChecksum(validPath, "md5")
+	if !info.IsDirectory {
+		info.MD5Checksum, err = r.calculateChecksum(ctx, key, "md5")
 		if err != nil {
 			return nil, fmt.Errorf("failed to calculate MD5: %w", err)
 		}
 
-		sha256Checksum, err = r.calculateChecksum(validPath, "sha256")
+		info.SHA256Checksum, err = r.calculateChecksum(ctx, key, "sha256")
 		if err != nil {
 			return nil, fmt.Errorf("failed to calculate SHA256: %w", err)
 		}
 	}
 
-	return &interfaces.FileInfo{
-		Path:           path,
-		Size:           fileInfo.Size(),
-		IsDirectory:    fileInfo.IsDir(),
-		ModifiedAt:     fileInfo.ModTime().Unix(),
-		MD5Checksum:    md5Checksum,
-		SHA256Checksum: sha256Checksum,
-	}, nil
+	return info, nil
 }
 
 func (r *storageRepository) GetDirectorySize(ctx context.Context, path string) (int64, error) {
 	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+	key, err := r.validateFilePath(path)
 	if err != nil {
 		return 0, fmt.Errorf("path validation failed: %w", err)
 	}
 
 	var totalSize int64
-	err = filepath.Walk(validPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			totalSize += info.Size()
-		}
+	err = r.backend.Walk(ctx, key, func(info *interfaces.FileInfo) error {
+		totalSize += info.Size
 		return nil
 	})
 
@@ -467,13 +638,39 @@ func (r *storageRepository) GetDirectorySize(ctx context.Context, path string) (
 	return totalSize, nil
 }
 
+func (r *storageRepository) Walk(ctx context.Context, path string, fn func(info *interfaces.FileInfo) error) error {
+	// Валидация пути
+	key, err := r.validateFilePath(path)
+	if err != nil {
+		return fmt.Errorf("path validation failed: %w", err)
+	}
+
+	if err := r.backend.Walk(ctx, key, fn); err != nil {
+		return fmt.Errorf("failed to walk directory: %w", err)
+	}
+	return nil
+}
+
+// RemoveExpired удаляет брошенные временные файлы драйвера, если он их создает
+func (r *storageRepository) RemoveExpired(ctx context.Context) (int, error) {
+	store, ok := r.backend.(interfaces.ExpiringStore)
+	if !ok {
+		return 0, nil
+	}
+	removed, err := store.RemoveExpired(ctx)
+	if err != nil {
+		return removed, fmt.Errorf("failed to remove partial files: %w", err)
+	}
+	return removed, nil
+}
+
 // Проверка целостности
 func (r *storageRepository) CalculateChecksum(ctx context.Context, path string, algorithm string) (string, error) {
-	return r.calculateChecksum(path, algorithm)
+	return r.calculateChecksum(ctx, path, algorithm)
 }
 
 func (r *storageRepository) VerifyChecksum(ctx context.Context, path string, expectedChecksum string, algorithm string) (bool, error) {
-	actualChecksum, err := r.calculateChecksum(path, algorithm)
+	actualChecksum, err := r.calculateChecksum(ctx, path, algorithm)
 	if err != nil {
 		return false, err
 	}
@@ -482,39 +679,76 @@ func (r *storageRepository) VerifyChecksum(ctx context.Context, path string, exp
 }
 
 // Вспомогательные методы
-func (r *storageRepository) calculateChecksum(path string, algorithm string) (string, error) {
-	// Валидация пути
-	validPath, err := r.validateFilePath(path)
+
+// hashObject считает SHA-256 объекта в конкретном драйвере
+func hashObject(ctx context.Context, backend interfaces.StorageBackend, key string) (string, error) {
+	reader, err := backend.Open(ctx, key)
 	if err != nil {
-		return "", fmt.Errorf("path validation failed: %w", err)
+		return "", err
 	}
+	defer reader.Close()
 
-	// Открываем файл
-	file, err := os.Open(validPath)
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// copyObject переписывает объект из одного драйвера в другой и проверяет записанное по SHA-256
+func copyObject(ctx context.Context, from, to interfaces.StorageBackend, key, expectedSHA256 string) error {
+	src, err := from.Open(ctx, key)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+		return err
 	}
-	defer file.Close()
+	defer src.Close()
 
-	// Вычисляем контрольную сумму
+	dst, err := to.NewWriter(ctx, key)
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(dst, io.TeeReader(src, hasher)); err != nil {
+		dst.Abort()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	// Данные, испорченные при чтении, не должны заменить прежнюю копию
+	if actual := fmt.Sprintf("%x", hasher.Sum(nil)); actual != expectedSHA256 {
+		dst.Abort()
+		return fmt.Errorf("%w: copied %s, expected %s", errdefs.ErrChecksumMismatch, actual, expectedSHA256)
+	}
+	return dst.Close()
+}
+
+func (r *storageRepository) calculateChecksum(ctx context.Context, path string, algorithm string) (string, error) {
+	// Валидация пути
+	key, err := r.validateFilePath(path)
+	if err != nil {
+		return "", fmt.Errorf("path validation failed: %w", err)
+	}
+
+	var hasher hash.Hash
 	switch algorithm {
 	case "sha256":
-		hasher := sha256.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return "", fmt.Errorf("failed to calculate SHA256: %w", err)
-		}
-		return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+		hasher = sha256.New()
 	case "md5":
-		// Для MD5 можно использовать crypto/md5
-		// Но пока возвращаем SHA256 как fallback
-		hasher := sha256.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return "", fmt.Errorf("failed to calculate checksum: %w", err)
-		}
-		return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+		hasher = md5.New()
 	default:
 		return "", fmt.Errorf("unsupported algorithm: %s", algorithm)
 	}
+
+	// Открываем файл
+	file, err := r.backend.Open(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Вычисляем контрольную сумму потоково
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to calculate %s: %w", algorithm, err)
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
 
 // Методы для работы с сессиями загрузки
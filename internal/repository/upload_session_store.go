@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// uploadSessionDir каталог сессий возобновляемой загрузки внутри временной директории
+	uploadSessionDir = "uploads"
+	// defaultUploadSessionTTL сколько живет сессия без новых данных
+	defaultUploadSessionTTL = 24 * time.Hour
+
+	uploadPartExt  = ".part"
+	uploadChunkExt = ".chunk"
+)
+
+// uploadSessionStore хранит сессию в файле <id>.json, а принятые данные - рядом в <id>.part.
+// Части, загружаемые параллельно, лежат отдельно в <id>.<start>-<end>.chunk до сборки
+type uploadSessionStore struct {
+	records *jsonStore[models.ResumableSession]
+	// dir каталог сессий, данные загрузок лежат рядом с ними
+	dir string
+	ttl time.Duration
+}
+
+// NewUploadSessionStore создает хранилище сессий возобновляемой загрузки в Storage.TempPath
+func NewUploadSessionStore(cfg *config.Config) (interfaces.UploadSessionStore, error) {
+	records, err := newJSONStore[models.ResumableSession](cfg, uploadSessionDir, "upload session")
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := cfg.Storage.Uploads.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultUploadSessionTTL
+	}
+	return &uploadSessionStore{records: records, dir: records.dir, ttl: ttl}, nil
+}
+
+func (s *uploadSessionStore) Save(ctx context.Context, session *models.ResumableSession) error {
+	if !validSessionID(session.ID) {
+		return fmt.Errorf("invalid upload session id %q", session.ID)
+	}
+
+	now := time.Now().UTC()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.UpdatedAt = now
+	session.ExpiresAt = now.Add(s.ttl)
+	return s.records.save(session.ID, session)
+}
+
+func (s *uploadSessionStore) Get(ctx context.Context, id string) (*models.ResumableSession, error) {
+	if !validSessionID(id) {
+		return nil, nil
+	}
+	return s.records.get(id)
+}
+
+func (s *uploadSessionStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.ResumableSession, error) {
+	ids, err := s.records.names()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := []models.ResumableSession{}
+	for _, id := range ids {
+		session, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil || session.UserID != userID || session.Expired(now) {
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (s *uploadSessionStore) Delete(ctx context.Context, id string) error {
+	if !validSessionID(id) {
+		return nil
+	}
+	// Сначала данные: файл части без сессии подберет очистка, а сессия без данных выглядела бы живой
+	if err := os.Remove(s.PartPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove upload data: %w", err)
+	}
+	if err := s.removeChunks(id); err != nil {
+		return err
+	}
+	return s.records.delete(id)
+}
+
+func (s *uploadSessionStore) PartPath(id string) string {
+	return filepath.Join(s.dir, id+uploadPartExt)
+}
+
+func (s *uploadSessionStore) RemoveExpired(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read upload session directory: %w", err)
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case strings.HasSuffix(name, jsonStoreExt):
+			id := strings.TrimSuffix(name, jsonStoreExt)
+			session, err := s.Get(ctx, id)
+			if err != nil || session == nil || !session.Expired(now) {
+				continue
+			}
+			if err := s.Delete(ctx, id); err != nil {
+				return removed, err
+			}
+			removed++
+		case strings.HasSuffix(name, uploadPartExt), strings.HasSuffix(name, uploadChunkExt):
+			// Данные без сессии остаются после сбоя между удалением данных и сессии
+			// или от частей, пришедших после завершения загрузки
+			id, _, _ := strings.Cut(name, ".")
+			if _, err := os.Stat(s.records.path(id)); !os.IsNotExist(err) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || now.Sub(info.ModTime()) < partialFileTTL {
+				continue
+			}
+			if err := os.Remove(filepath.Join(s.dir, name)); err == nil {
+				removed++
+			}
+		}
+	}
+
+	stale, err := s.records.removeStale()
+	return removed + stale, err
+}
+
+func (s *uploadSessionStore) WriteChunk(ctx context.Context, id string, start, end int64, r io.Reader) error {
+	if !validSessionID(id) {
+		return fmt.Errorf("invalid upload session id %q", id)
+	}
+	if start < 0 || end < start {
+		return fmt.Errorf("%w: invalid chunk range %d-%d", errdefs.ErrInvalidInput, start, end)
+	}
+
+	file, err := createPartialFile(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to create chunk file: %w", err)
+	}
+	written, err := io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if written != end-start+1 {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("%w: chunk %d-%d has %d bytes", errdefs.ErrInvalidInput, start, end, written)
+	}
+	// Повтор той же части заменяет прежнюю целиком
+	if err := commitPartialFile(file, s.chunkPath(id, start, end)); err != nil {
+		return fmt.Errorf("failed to save chunk: %w", err)
+	}
+	return nil
+}
+
+func (s *uploadSessionStore) ComposeChunks(ctx context.Context, id string, size int64) error {
+	chunks, err := s.listChunks(id)
+	if err != nil {
+		return err
+	}
+	// Для каждой позиции берется часть, которая начинается не позже и тянется дальше всех
+	sort.Slice(chunks, func(i, j int) bool {
+		if chunks[i].start != chunks[j].start {
+			return chunks[i].start < chunks[j].start
+		}
+		return chunks[i].end > chunks[j].end
+	})
+
+	file, err := createPartialFile(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to create upload file: %w", err)
+	}
+	fail := func(err error) error {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	var pos int64
+	for _, c := range chunks {
+		if pos >= size {
+			break
+		}
+		if c.end < pos {
+			continue
+		}
+		if c.start > pos {
+			return fail(fmt.Errorf("%w: bytes %d-%d are missing", errdefs.ErrInvalidInput, pos, c.start-1))
+		}
+		if err := copyChunk(file, c, pos, min(c.end, size-1)); err != nil {
+			return fail(err)
+		}
+		pos = min(c.end, size-1) + 1
+	}
+	if pos < size {
+		return fail(fmt.Errorf("%w: bytes %d-%d are missing", errdefs.ErrInvalidInput, pos, size-1))
+	}
+
+	if err := commitPartialFile(file, s.PartPath(id)); err != nil {
+		return fmt.Errorf("failed to save upload file: %w", err)
+	}
+	return s.removeChunks(id)
+}
+
+type uploadChunk struct {
+	path       string
+	start, end int64
+}
+
+// copyChunk дописывает байты [from, to] файла из части c
+func copyChunk(dst io.Writer, c uploadChunk, from, to int64) error {
+	src, err := os.Open(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to open chunk: %w", err)
+	}
+	defer src.Close()
+
+	if _, err := io.Copy(dst, io.NewSectionReader(src, from-c.start, to-from+1)); err != nil {
+		return fmt.Errorf("failed to copy chunk: %w", err)
+	}
+	return nil
+}
+
+// listChunks находит части сессии по именам файлов <id>.<start>-<end>.chunk
+func (s *uploadSessionStore) listChunks(id string) ([]uploadChunk, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, id+".*"+uploadChunkExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+
+	chunks := make([]uploadChunk, 0, len(paths))
+	for _, path := range paths {
+		bounds := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), id+"."), uploadChunkExt)
+		first, last, ok := strings.Cut(bounds, "-")
+		if !ok {
+			continue
+		}
+		start, err1 := strconv.ParseInt(first, 10, 64)
+		end, err2 := strconv.ParseInt(last, 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		chunks = append(chunks, uploadChunk{path: path, start: start, end: end})
+	}
+	return chunks, nil
+}
+
+func (s *uploadSessionStore) removeChunks(id string) error {
+	chunks, err := s.listChunks(id)
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove chunk: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *uploadSessionStore) chunkPath(id string, start, end int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.%d-%d%s", id, start, end, uploadChunkExt))
+}
+
+// validSessionID ID сессии приходит из URL и не должен выводить за пределы каталога сессий
+func validSessionID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\.*?[`)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSessionStoreSurvivesRestartAndExpires(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg := &config.Config{}
+	cfg.Storage.TempPath = t.TempDir()
+
+	store, err := NewUploadSessionStore(cfg)
+	require.NoError(t, err)
+
+	parentID := uuid.New()
+	session := &models.ResumableSession{ID: uuid.NewString(), UserID: uuid.New(), FilePath: "docs/a.txt", Size: 10, Offset: 4, ParentID: &parentID}
+	require.NoError(t, store.Save(ctx, session))
+	assert.WithinDuration(t, time.Now().Add(defaultUploadSessionTTL), session.ExpiresAt, time.Minute)
+	require.NoError(t, os.WriteFile(store.PartPath(session.ID), []byte("data"), 0644))
+
+	// После перезапуска сессия и принятые данные на месте
+	store, err = NewUploadSessionStore(cfg)
+	require.NoError(t, err)
+	loaded, err := store.Get(ctx, session.ID)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, int64(4), loaded.Offset)
+	assert.Equal(t, parentID, *loaded.ParentID)
+	assert.FileExists(t, store.PartPath(session.ID))
+
+	missing, err := store.Get(ctx, "../scrub")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// Живые сессии очистка не трогает
+	removed, err := store.RemoveExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	// Брошенные данные без сессии и истекшая сессия удаляются
+	orphan := store.PartPath(uuid.NewString())
+	require.NoError(t, os.WriteFile(orphan, []byte("orphan"), 0644))
+	old := time.Now().Add(-2 * partialFileTTL)
+	require.NoError(t, os.Chtimes(orphan, old, old))
+
+	cfg.Storage.Uploads.SessionTTL = time.Nanosecond
+	store, err = NewUploadSessionStore(cfg)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, loaded))
+	time.Sleep(time.Millisecond)
+
+	removed, err = store.RemoveExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.NoFileExists(t, orphan)
+	assert.NoFileExists(t, store.PartPath(session.ID))
+	expired, err := store.Get(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Nil(t, expired)
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// userDeletionDir каталог запросов на удаление данных пользователей внутри временной директории
+const userDeletionDir = "user_deletions"
+
+// userDeletionStore хранит запрос каждого пользователя в файле <user>.json: безвозвратное
+// удаление по истечении отсрочки должно состояться и после перезапуска сервиса
+type userDeletionStore struct {
+	records *jsonStore[models.UserDeletion]
+}
+
+// NewUserDeletionStore создает хранилище запросов на удаление данных пользователей в Storage.TempPath
+func NewUserDeletionStore(cfg *config.Config) (interfaces.UserDeletionStore, error) {
+	records, err := newJSONStore[models.UserDeletion](cfg, userDeletionDir, "user deletion")
+	if err != nil {
+		return nil, err
+	}
+	return &userDeletionStore{records: records}, nil
+}
+
+func (s *userDeletionStore) Save(ctx context.Context, deletion *models.UserDeletion) error {
+	return s.records.save(deletion.UserID.String(), deletion)
+}
+
+func (s *userDeletionStore) Get(ctx context.Context, userID uuid.UUID) (*models.UserDeletion, error) {
+	return s.records.get(userID.String())
+}
+
+func (s *userDeletionStore) Delete(ctx context.Context, userID uuid.UUID) error {
+	return s.records.delete(userID.String())
+}
+
+func (s *userDeletionStore) List(ctx context.Context) ([]models.UserDeletion, error) {
+	names, err := s.records.names()
+	if err != nil {
+		return nil, err
+	}
+
+	var deletions []models.UserDeletion
+	for _, name := range names {
+		if _, err := uuid.Parse(name); err != nil {
+			continue
+		}
+		deletion, err := s.records.get(name)
+		if err != nil {
+			return nil, err
+		}
+		if deletion != nil {
+			deletions = append(deletions, *deletion)
+		}
+	}
+	sort.Slice(deletions, func(i, j int) bool {
+		return deletions[i].RequestedAt.Before(deletions[j].RequestedAt)
+	})
+	return deletions, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// writeJournalDir каталог журнала внутри временной директории. Журнал всегда локальный:
+// он описывает операции этого экземпляра сервиса независимо от драйвера хранилища
+const writeJournalDir = "journal"
+
+// writeJournal хранит каждое намерение в отдельном JSON-файле <id>.json
+type writeJournal struct {
+	records *jsonStore[models.WriteIntent]
+}
+
+// NewWriteJournal создает журнал намерений записи в Storage.TempPath.
+// Недописанные записи журнала - это намерения, которые так и не начались, их убирает newJSONStore
+func NewWriteJournal(cfg *config.Config) (interfaces.WriteJournal, error) {
+	records, err := newJSONStore[models.WriteIntent](cfg, writeJournalDir, "write intent")
+	if err != nil {
+		return nil, err
+	}
+	return &writeJournal{records: records}, nil
+}
+
+func (j *writeJournal) Begin(ctx context.Context, intent *models.WriteIntent) error {
+	if intent.ID == uuid.Nil {
+		intent.ID = uuid.New()
+	}
+	if intent.CreatedAt.IsZero() {
+		intent.CreatedAt = time.Now()
+	}
+	if intent.Stage == "" {
+		intent.Stage = models.WriteStagePending
+	}
+	return j.Save(ctx, intent)
+}
+
+func (j *writeJournal) Save(ctx context.Context, intent *models.WriteIntent) error {
+	return j.records.save(intent.ID.String(), intent)
+}
+
+func (j *writeJournal) Commit(ctx context.Context, id uuid.UUID) error {
+	return j.records.delete(id.String())
+}
+
+func (j *writeJournal) Pending(ctx context.Context) ([]*models.WriteIntent, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	names, err := j.records.names()
+	if err != nil {
+		return nil, err
+	}
+
+	var intents []*models.WriteIntent
+	for _, name := range names {
+		intent, err := j.records.get(name)
+		if errdefs.Is(err, errdefs.ErrFileCorrupted) {
+			// Запись журнала пишется атомарно, испорченный файл восстановить нечем
+			lg.Error(ctx, "Skipping corrupted write intent", zap.Error(err), zap.String("name", name))
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if intent != nil {
+			intents = append(intents, intent)
+		}
+	}
+
+	sort.Slice(intents, func(a, b int) bool {
+		return intents[a].CreatedAt.Before(intents[b].CreatedAt)
+	})
+	return intents, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalWriterReplacesContentAtomically(t *testing.T) {
+	ctx := newTestContext(t)
+	root := t.TempDir()
+	backend := NewLocalBackend(root)
+
+	writer, err := backend.NewWriter(ctx, "owner/file")
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("first"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// Пока запись не завершена, читатели видят прежнее содержимое
+	writer, err = backend.NewWriter(ctx, "owner/file")
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("second, unfinished"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(root, "owner", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+
+	// Прерванная запись не портит файл и не оставляет временных файлов
+	require.NoError(t, writer.Abort())
+	content, err = os.ReadFile(filepath.Join(root, "owner", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+
+	partials, err := os.ReadDir(filepath.Join(root, partialDir))
+	require.NoError(t, err)
+	assert.Empty(t, partials)
+
+	names, err := backend.List(ctx, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file"}, names)
+
+	// Каталог временных файлов не виден в корне, а брошенную запись удаляет Janitor
+	abandoned, err := backend.NewWriter(ctx, "owner/abandoned")
+	require.NoError(t, err)
+	_, err = abandoned.Write([]byte("never closed"))
+	require.NoError(t, err)
+
+	names, err = backend.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"owner"}, names)
+
+	expiring := backend.(interfaces.ExpiringStore)
+	removed, err := expiring.RemoveExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	partials, err = os.ReadDir(filepath.Join(root, partialDir))
+	require.NoError(t, err)
+	require.Len(t, partials, 1)
+	stale := time.Now().Add(-2 * partialFileTTL)
+	require.NoError(t, os.Chtimes(filepath.Join(root, partialDir, partials[0].Name()), stale, stale))
+	removed, err = expiring.RemoveExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+}
+
+func TestWriteJournalKeepsPendingIntents(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg := &config.Config{}
+	cfg.Storage.TempPath = t.TempDir()
+
+	journal, err := NewWriteJournal(cfg)
+	require.NoError(t, err)
+
+	first := &models.WriteIntent{Op: models.WriteOpCreate, FileID: uuid.New()}
+	second := &models.WriteIntent{Op: models.WriteOpUpload, FileID: uuid.New()}
+	require.NoError(t, journal.Begin(ctx, first))
+	require.NoError(t, journal.Begin(ctx, second))
+
+	second.Stage = models.WriteStageWritten
+	second.SHA256Checksum = strings.Repeat("ab", 32)
+	require.NoError(t, journal.Save(ctx, second))
+	require.NoError(t, journal.Commit(ctx, first.ID))
+
+	// Журнал переживает перезапуск
+	journal, err = NewWriteJournal(cfg)
+	require.NoError(t, err)
+	pending, err := journal.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, second.ID, pending[0].ID)
+	assert.Equal(t, models.WriteStageWritten, pending[0].Stage)
+	assert.Equal(t, second.SHA256Checksum, pending[0].SHA256Checksum)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// unknownDevice подставляется в имя конфликтной копии, если клиент не назвал устройство
+	unknownDevice = "unknown device"
+	// maxDeviceNameLength ограничивает имя устройства в имени копии
+	maxDeviceNameLength = 64
+)
+
+// saveConflictCopy сохраняет загрузку с устаревшей базовой версией рядом с файлом, не трогая его:
+// клиент синхронизации ничего не теряет, а пользователь сам решает, какую правку оставить
+func (s *fileService) saveConflictCopy(ctx context.Context, file *models.File, content io.Reader, userID uuid.UUID, opts models.WriteOptions) (*models.UploadResult, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	siblings, err := s.fileRepo.ListFilesByParent(ctx, file.OwnerID, file.ParentID)
+	if err != nil {
+		lg.Error(ctx, "Failed to list siblings for conflict copy", zap.Error(err), zap.String("fileID", file.ID.String()))
+		return nil, fmt.Errorf("failed to list folder contents: %w", err)
+	}
+	taken := make(map[string]bool, len(siblings))
+	for _, sibling := range siblings {
+		taken[sibling.Name] = true
+	}
+
+	now := time.Now()
+	name := conflictCopyName(file.Name, opts.Device, now, 1)
+	for n := 2; taken[name]; n++ {
+		name = conflictCopyName(file.Name, opts.Device, now, n)
+	}
+
+	// Копия принадлежит владельцу файла и лежит в той же папке
+	conflictCopy, err := s.CreateFile(ctx, &models.CreateFileRequest{
+		Name:          name,
+		ParentID:      file.ParentID,
+		MimeType:      file.MimeType,
+		ContentReader: content,
+	}, file.OwnerID)
+	if err != nil {
+		lg.Error(ctx, "Failed to save conflict copy", zap.Error(err), zap.String("fileID", file.ID.String()))
+		return nil, fmt.Errorf("failed to save conflict copy: %w", err)
+	}
+
+	// Соавтор, загрузивший правку, должен видеть свою копию
+	if userID != file.OwnerID {
+		permission := &models.FilePermission{
+			ID:          uuid.New(),
+			FileID:      conflictCopy.ID,
+			GranteeID:   &userID,
+			GranteeType: models.GranteeTypeUser,
+			Role:        models.RoleWriter,
+		}
+		if err := s.fileRepo.CreatePermission(ctx, permission); err != nil {
+			lg.Error(ctx, "Failed to grant access to conflict copy", zap.Error(err), zap.String("fileID", conflictCopy.ID.String()))
+		}
+	}
+
+	lg.Info(ctx, "Upload saved as conflict copy",
+		zap.String("fileID", file.ID.String()),
+		zap.String("conflictCopyID", conflictCopy.ID.String()),
+		zap.String("name", name))
+	return &models.UploadResult{FileID: file.ID, Version: file.Version, ConflictCopyID: &conflictCopy.ID}, nil
+}
+
+// conflictCopyName строит имя "name (conflicted copy from <device> <date>).ext".
+// n > 1 различает несколько копий с одного устройства за день
+func conflictCopyName(name, device string, at time.Time, n int) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		// Файлы вида ".bashrc" целиком считаются именем
+		base, ext = name, ""
+	}
+
+	suffix := fmt.Sprintf("conflicted copy from %s %s", deviceName(device), at.Format("2006-01-02"))
+	if n > 1 {
+		suffix += fmt.Sprintf(" %d", n)
+	}
+	return fmt.Sprintf("%s (%s)%s", base, suffix, ext)
+}
+
+// deviceName очищает имя устройства от разделителей пути и управляющих символов
+func deviceName(device string) string {
+	device = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, device)
+	device = strings.TrimSpace(device)
+	if device == "" {
+		return unknownDevice
+	}
+	if runes := []rune(device); len(runes) > maxDeviceNameLength {
+		device = string(runes[:maxDeviceNameLength])
+	}
+	return device
+}
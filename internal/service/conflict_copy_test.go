@@ -0,0 +1,62 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadWithStaleVersionSavesConflictCopy(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo)})
+
+	ownerID := uuid.New()
+	parentID := uuid.New()
+	file := &models.File{ID: uuid.New(), OwnerID: ownerID, ParentID: &parentID, Name: "report.docx", Version: 1}
+	fileRepo.files[file.ID] = file
+	fileRepo.permissions = append(fileRepo.permissions, models.FilePermission{FileID: file.ID, GranteeID: &ownerID, Role: models.RoleOwner})
+
+	_, err := svc.UploadFile(ctx, file.ID, strings.NewReader("desktop edit"), ownerID, models.WriteOptions{ExpectedVersion: 1})
+	require.NoError(t, err)
+
+	// Без режима копий устаревшая загрузка отклоняется
+	_, err = svc.UploadFile(ctx, file.ID, strings.NewReader("laptop edit"), ownerID, models.WriteOptions{ExpectedVersion: 1})
+	require.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+
+	opts := models.WriteOptions{ExpectedVersion: 1, ConflictCopy: true, Device: "Laptop"}
+	result, err := svc.UploadFile(ctx, file.ID, strings.NewReader("laptop edit"), ownerID, opts)
+	require.NoError(t, err)
+	assert.Equal(t, file.ID, result.FileID)
+	assert.Equal(t, int64(2), result.Version)
+	require.NotNil(t, result.ConflictCopyID)
+
+	day := time.Now().Format("2006-01-02")
+	conflictCopy := fileRepo.files[*result.ConflictCopyID]
+	assert.Equal(t, "report (conflicted copy from Laptop "+day+").docx", conflictCopy.Name)
+	assert.Equal(t, &parentID, conflictCopy.ParentID)
+	assert.Equal(t, ownerID, conflictCopy.OwnerID)
+
+	// Исходный файл не тронут, копия хранит загруженное содержимое
+	assert.Equal(t, int64(2), fileRepo.files[file.ID].Version)
+	content, err := svc.GetFileContent(ctx, conflictCopy.ID, ownerID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.NoError(t, content.Close())
+	assert.Equal(t, "laptop edit", string(data))
+
+	// Вторая копия с того же устройства за день получает номер
+	result, err = svc.UploadFile(ctx, file.ID, strings.NewReader("another laptop edit"), ownerID, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "report (conflicted copy from Laptop "+day+" 2).docx", fileRepo.files[*result.ConflictCopyID].Name)
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// errContentOverwritten компенсация невозможна: содержимое перезаписано по тому же пути.
+// Такую операцию доводит до конца журнал намерений записи
+var errContentOverwritten = errors.New("content overwritten in place")
+
+// storeContent записывает содержимое файла и обновляет его storage_path, размер и контрольные суммы.
+// При включенном content_addressed содержимое попадает в хранилище блобов, иначе - по пути файла.
+// Запись - шаг операции tx: при откате новое содержимое удаляется. Ссылка на прежний блоб
+// освобождается только при Commit, чтобы сбой не оставил БД ссылаться на освобожденный блоб
+func (s *fileService) storeContent(ctx context.Context, tx *saga, file *models.File, content io.Reader, intent *models.WriteIntent) error {
+	previous := file.StoragePath
+	previousSize, previousMD5, previousSHA256 := file.Size, file.MD5Checksum, file.SHA256Checksum
+	_, previousIsBlob := s.blobSHA(previous)
+
+	undo := func(ctx context.Context) error {
+		written := file.StoragePath
+		file.StoragePath = previous
+		file.Size, file.MD5Checksum, file.SHA256Checksum = previousSize, previousMD5, previousSHA256
+
+		// У нового файла прежнего содержимого нет, его путь можно просто очистить
+		if _, isBlob := s.blobSHA(written); !isBlob && written == previous && previousSHA256 != nil {
+			return errContentOverwritten
+		}
+		s.discardContent(ctx, written, "")
+		return nil
+	}
+
+	err := tx.Step(ctx, "write_content", func(ctx context.Context) error {
+		var info *interfaces.FileInfo
+		var err error
+		if s.cfg.Storage.ContentAddressed {
+			info, err = s.blobStore.Put(ctx, content)
+			if err != nil {
+				return err
+			}
+			file.StoragePath = s.toAbsolutePath(info.Path)
+		} else {
+			// Общий блоб перезаписывать нельзя - файл получает собственный путь
+			if previousIsBlob || previous == "" {
+				file.StoragePath = s.generateStoragePath(file.OwnerID, file.ID)
+			}
+			info, err = s.storageRepo.SaveFile(ctx, s.toRelativePath(file.StoragePath), content)
+			if err != nil {
+				file.StoragePath = previous
+				return err
+			}
+		}
+
+		applyContentInfo(file, info)
+
+		if err := s.markWritten(ctx, intent, file); err != nil {
+			undo(ctx)
+			return err
+		}
+		return nil
+	}, undo)
+	if err != nil {
+		return err
+	}
+
+	if previousIsBlob {
+		tx.OnCommit(func(ctx context.Context) {
+			s.releaseContent(ctx, previous)
+		})
+	}
+	tx.OnCommit(func(ctx context.Context) {
+		s.protectContent(ctx, file)
+	})
+	return nil
+}
+
+// openContent открывает содержимое файла на чтение со сверкой по SHA-256 из БД:
+// на зеркальном хранилище поврежденная копия прозрачно заменяется целой
+func (s *fileService) openContent(ctx context.Context, file *models.File) (interfaces.BlobReader, error) {
+	var sha256 string
+	if file.SHA256Checksum != nil {
+		sha256 = *file.SHA256Checksum
+	}
+	return s.storageRepo.GetVerifiedFile(ctx, s.toRelativePath(file.StoragePath), sha256)
+}
+
+// blobSHA возвращает SHA-256 блоба, если storage_path указывает в хранилище блобов
+func (s *fileService) blobSHA(storagePath string) (string, bool) {
+	if s.blobStore == nil || storagePath == "" {
+		return "", false
+	}
+	return s.blobStore.ParseKey(s.toRelativePath(storagePath))
+}
+
+// acquireContent добавляет ссылку на блоб для новой записи (копии файла, ревизии)
+func (s *fileService) acquireContent(ctx context.Context, storagePath string) error {
+	if sha256, ok := s.blobSHA(storagePath); ok {
+		return s.blobStore.Acquire(ctx, sha256)
+	}
+	return nil
+}
+
+// releaseContent освобождает ссылку на блоб. Ошибки только логируются: висячий блоб
+// безопаснее потерянных данных, его подберет проверка хранилища
+func (s *fileService) releaseContent(ctx context.Context, storagePath string) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	sha256, ok := s.blobSHA(storagePath)
+	if !ok {
+		return
+	}
+
+	freed, err := s.blobStore.Release(ctx, sha256)
+	if err != nil {
+		lg.Error(ctx, "Failed to release blob", zap.Error(err), zap.String("sha256", sha256))
+		return
+	}
+	lg.Debug(ctx, "Blob reference released", zap.String("sha256", sha256), zap.Bool("freed", freed))
+}
+
+// collectBlobRefs собирает storage_path файла и его ревизий, которые ссылаются на блобы
+func (s *fileService) collectBlobRefs(ctx context.Context, file *models.File) []string {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if file.IsFolder || s.blobStore == nil {
+		return nil
+	}
+
+	var refs []string
+	if _, ok := s.blobSHA(file.StoragePath); ok {
+		refs = append(refs, file.StoragePath)
+	}
+
+	revisions, err := s.fileRepo.GetRevisions(ctx, file.ID)
+	if err != nil {
+		lg.Error(ctx, "Failed to get revisions for blob release", zap.Error(err), zap.String("fileID", file.ID.String()))
+		return refs
+	}
+	for _, revision := range revisions {
+		if _, ok := s.blobSHA(revision.StoragePath); ok {
+			refs = append(refs, revision.StoragePath)
+		}
+	}
+	return refs
+}
+
+// createRevisionRecord сохраняет ревизию, удерживая ссылку на её блоб
+func (s *fileService) createRevisionRecord(ctx context.Context, revision *models.FileRevision) error {
+	if err := s.acquireContent(ctx, revision.StoragePath); err != nil {
+		return fmt.Errorf("failed to acquire revision blob: %w", err)
+	}
+
+	if err := s.fileRepo.CreateRevision(ctx, revision); err != nil {
+		s.releaseContent(ctx, revision.StoragePath)
+		return err
+	}
+	return nil
+}
+
+// deleteRevisionRecord удаляет ревизию и освобождает ссылку на её блоб
+func (s *fileService) deleteRevisionRecord(ctx context.Context, revision *models.FileRevision) error {
+	if err := s.fileRepo.DeleteRevision(ctx, revision.ID); err != nil {
+		return err
+	}
+	s.releaseContent(ctx, revision.StoragePath)
+	return nil
+}
+
+// copyContent дает копии файла собственное содержимое. Блоб исходного файла не дублируется -
+// копия добавляет на него ссылку, иначе байты копируются по пути, выведенному из ID копии.
+// Записанное содержимое отмечается в журнале intent до обновления строки копии
+func (s *fileService) copyContent(ctx context.Context, tx *saga, sourceID uuid.UUID, copied *models.File, intent *models.WriteIntent) error {
+	source, err := s.fileRepo.GetFileByID(ctx, sourceID)
+	if err != nil {
+		tx.Rollback(ctx)
+		return fmt.Errorf("failed to get source file: %w", err)
+	}
+	if source.IsFolder || source.StoragePath == "" {
+		return nil
+	}
+
+	hasContent := true
+	if _, isBlob := s.blobSHA(source.StoragePath); isBlob {
+		err = tx.Step(ctx, "share_blob", func(ctx context.Context) error {
+			return s.acquireContent(ctx, source.StoragePath)
+		}, func(ctx context.Context) error {
+			s.releaseContent(ctx, source.StoragePath)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		copied.StoragePath = source.StoragePath
+	} else {
+		target := s.generateStoragePath(copied.OwnerID, copied.ID)
+		err = tx.Step(ctx, "copy_content", func(ctx context.Context) error {
+			err := s.storageRepo.CopyFile(ctx, s.toRelativePath(source.StoragePath), s.toRelativePath(target))
+			if errdefs.Is(err, errdefs.ErrFileNotFound) {
+				// У исходного файла еще нет содержимого - копировать нечего
+				hasContent = false
+				return nil
+			}
+			return err
+		}, func(ctx context.Context) error {
+			s.discardContent(ctx, target, "")
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy content: %w", err)
+		}
+		copied.StoragePath = target
+	}
+
+	if hasContent {
+		copied.Size = source.Size
+		copied.MD5Checksum = source.MD5Checksum
+		copied.SHA256Checksum = source.SHA256Checksum
+	}
+
+	err = tx.Step(ctx, "journal_copy", func(ctx context.Context) error {
+		return s.markWritten(ctx, intent, copied)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to journal copied content: %w", err)
+	}
+
+	err = tx.Step(ctx, "update_copy", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, copied)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update copied file: %w", err)
+	}
+	return nil
+}
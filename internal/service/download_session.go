@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// defaultDownloadTokenTTL сколько действует подписанная ссылка на скачивание
+const defaultDownloadTokenTTL = 6 * time.Hour
+
+// Возобновляемое скачивание
+func (s *fileService) InitResumableDownload(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (*models.ResumableDownloadSession, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "InitResumableDownload called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
+
+	if s.downloadSessions == nil {
+		return nil, fmt.Errorf("resumable downloads are not configured")
+	}
+
+	// Получаем файл из БД
+	file, err := s.fileRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		lg.Error(ctx, "Failed to get file from database", zap.Error(err))
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if file == nil {
+		lg.Error(ctx, "File not found", zap.String("fileID", fileID.String()))
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrFileNotFound, fileID)
+	}
+
+	// Проверяем права доступа (нужны права на чтение)
+	hasAccess, err := s.fileRepo.CheckPermission(ctx, fileID, userID, models.RoleReader)
+	if err != nil {
+		lg.Error(ctx, "Failed to check permission", zap.Error(err))
+		return nil, fmt.Errorf("failed to check permission: %w", err)
+	}
+
+	if !hasAccess {
+		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
+		return nil, fmt.Errorf("%w: cannot download %s", errdefs.ErrPermissionDenied, fileID)
+	}
+
+	// Проверяем, что это не папка
+	if file.IsFolder {
+		lg.Error(ctx, "Cannot download folder", zap.String("fileID", fileID.String()))
+		return nil, fmt.Errorf("%w: cannot download folder", errdefs.ErrInvalidInput)
+	}
+
+	// Берем контрольную сумму из метаданных, пересчитываем только если её нет
+	relativePath := s.toRelativePath(file.StoragePath)
+
+	var checksum string
+	if file.SHA256Checksum != nil && *file.SHA256Checksum != "" {
+		checksum = *file.SHA256Checksum
+	} else {
+		checksum, err = s.storageRepo.CalculateChecksum(ctx, relativePath, "sha256")
+		if err != nil {
+			lg.Error(ctx, "Failed to calculate checksum", zap.Error(err))
+			return nil, fmt.Errorf("failed to calculate checksum: %w", err)
+		}
+	}
+
+	// Срок жизни сессии выставляет хранилище
+	session := &models.ResumableDownloadSession{
+		ID:       uuid.New().String(),
+		FileID:   fileID,
+		UserID:   userID,
+		FileName: file.Name,
+		FilePath: relativePath,
+		Size:     file.Size,
+		Checksum: checksum,
+		MimeType: file.MimeType,
+		Version:  file.Version,
+	}
+	if err := s.downloadSessions.Save(ctx, session); err != nil {
+		lg.Error(ctx, "Failed to save download session", zap.Error(err))
+		return nil, err
+	}
+
+	lg.Info(ctx, "Resumable download session created", zap.String("sessionID", session.ID))
+	return session, nil
+}
+
+func (s *fileService) GetResumableDownloadSession(ctx context.Context, sessionID string) (*models.ResumableDownloadSession, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if s.downloadSessions == nil {
+		return nil, fmt.Errorf("%w: download session %s", errdefs.ErrNotFound, sessionID)
+	}
+
+	session, err := s.downloadSessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("%w: download session %s", errdefs.ErrNotFound, sessionID)
+	}
+
+	// Истекшую сессию удаляем сразу, не дожидаясь фоновой очистки
+	if session.Expired(time.Now()) {
+		lg.Info(ctx, "Download session expired", zap.String("sessionID", sessionID))
+		if err := s.downloadSessions.Delete(ctx, sessionID); err != nil {
+			lg.Error(ctx, "Failed to delete expired download session", zap.Error(err))
+		}
+		return nil, fmt.Errorf("%w: download session %s", errdefs.ErrSessionExpired, sessionID)
+	}
+
+	return session, nil
+}
+
+func (s *fileService) OpenResumableDownload(ctx context.Context, sessionID string) (interfaces.BlobReader, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	session, err := s.GetResumableDownloadSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Файл мог измениться, а доступ - быть отозван, пока клиент докачивал по частям
+	file, err := s.fileRepo.GetFileByID(ctx, session.FileID)
+	if err != nil || file == nil || file.IsTrashed {
+		return nil, fmt.Errorf("%w: file %s is no longer available", errdefs.ErrPreconditionFailed, session.FileID)
+	}
+	if file.Version != session.Version {
+		lg.Info(ctx, "File changed during resumable download",
+			zap.String("sessionID", sessionID), zap.Int64("sessionVersion", session.Version), zap.Int64("fileVersion", file.Version))
+		return nil, fmt.Errorf("%w: file changed since the download started", errdefs.ErrPreconditionFailed)
+	}
+
+	hasAccess, err := s.fileRepo.CheckPermission(ctx, session.FileID, session.UserID, models.RoleReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permission: %w", err)
+	}
+	if !hasAccess {
+		return nil, fmt.Errorf("%w: cannot download %s", errdefs.ErrPermissionDenied, session.FileID)
+	}
+
+	// Проверка контрольной суммы на каждом запросе части обошлась бы в чтение всего файла,
+	// клиент сверяет итог с checksum сессии сам
+	content, err := s.storageRepo.GetFile(ctx, session.FilePath)
+	if err != nil {
+		lg.Error(ctx, "Failed to get file from storage", zap.Error(err))
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	return content, nil
+}
+
+func (s *fileService) DownloadFileChunk(ctx context.Context, sessionID string, start, end uint64) (io.ReadCloser, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "DownloadFileChunk called", zap.String("sessionID", sessionID), zap.Uint64("start", start), zap.Uint64("end", end))
+
+	content, err := s.OpenResumableDownload(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Проверяем валидность диапазона
+	if start >= uint64(content.Size()) || end >= uint64(content.Size()) || start > end {
+		content.Close()
+		lg.Error(ctx, "Invalid range", zap.Uint64("start", start), zap.Uint64("end", end), zap.Int64("fileSize", content.Size()))
+		return nil, fmt.Errorf("%w: range %d-%d", errdefs.ErrInvalidInput, start, end)
+	}
+
+	return &sectionReadCloser{
+		SectionReader: io.NewSectionReader(content, int64(start), int64(end-start+1)),
+		closer:        content,
+	}, nil
+}
+
+func (s *fileService) DeleteResumableDownloadSession(ctx context.Context, sessionID string) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "DeleteResumableDownloadSession called", zap.String("sessionID", sessionID))
+
+	if s.downloadSessions == nil {
+		return nil
+	}
+	return s.downloadSessions.Delete(ctx, sessionID)
+}
+
+// SignDownloadToken выдает ссылку вида <sessionID>.<срок в unix>.<HMAC-SHA256>. Срок ссылки
+// не выходит за срок сессии, а отзывается ссылка удалением сессии
+func (s *fileService) SignDownloadToken(ctx context.Context, sessionID string, userID uuid.UUID) (string, time.Time, error) {
+	session, err := s.GetResumableDownloadSession(ctx, sessionID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if session.UserID != userID {
+		return "", time.Time{}, fmt.Errorf("%w: download session %s", errdefs.ErrNotFound, sessionID)
+	}
+
+	ttl := s.cfg.Storage.Downloads.TokenTTL
+	if ttl <= 0 {
+		ttl = defaultDownloadTokenTTL
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	if !session.ExpiresAt.IsZero() && session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt.Truncate(time.Second)
+	}
+
+	key, err := s.downloadSigningKey()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	payload := session.ID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + signDownloadPayload(key, payload), expiresAt.UTC(), nil
+}
+
+// ResolveDownloadToken проверяет ссылку из SignDownloadToken. Неверная подпись и истекший
+// срок неразличимы для клиента: обе дают errdefs.ErrInvalidToken
+func (s *fileService) ResolveDownloadToken(ctx context.Context, token string) (*models.ResumableDownloadSession, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", errdefs.ErrInvalidToken)
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", errdefs.ErrInvalidToken)
+	}
+
+	key, err := s.downloadSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	expected := signDownloadPayload(key, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, fmt.Errorf("%w: bad signature", errdefs.ErrInvalidToken)
+	}
+	if time.Now().After(time.Unix(expires, 0)) {
+		return nil, fmt.Errorf("%w: token expired", errdefs.ErrInvalidToken)
+	}
+
+	return s.GetResumableDownloadSession(ctx, parts[0])
+}
+
+// downloadSigningKey ключ из конфигурации, а без него - созданный хранилищем сессий
+func (s *fileService) downloadSigningKey() ([]byte, error) {
+	if secret := s.cfg.Storage.Downloads.TokenSecret; secret != "" {
+		return []byte(secret), nil
+	}
+	if s.downloadSessions == nil {
+		return nil, fmt.Errorf("resumable downloads are not configured")
+	}
+	key, err := s.downloadSessions.SigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing key: %w", err)
+	}
+	return key, nil
+}
+
+func signDownloadPayload(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
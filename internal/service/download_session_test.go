@@ -0,0 +1,70 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumableDownloadSurvivesRestartAndSignsTokens(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	newService := func() *fileService {
+		sessions, err := repository.NewDownloadSessionStore(cfg)
+		require.NoError(t, err)
+		return NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo), DownloadSessions: sessions}).(*fileService)
+	}
+	svc := newService()
+
+	ownerID := uuid.New()
+	file, err := svc.CreateFile(ctx, &models.CreateFileRequest{
+		Name:          "movie.bin",
+		ContentReader: strings.NewReader("0123456789"),
+	}, ownerID)
+	require.NoError(t, err)
+
+	session, err := svc.InitResumableDownload(ctx, file.ID, ownerID)
+	require.NoError(t, err)
+	token, _, err := svc.SignDownloadToken(ctx, session.ID, ownerID)
+	require.NoError(t, err)
+
+	// Чужой пользователь ссылку на сессию не получит
+	_, _, err = svc.SignDownloadToken(ctx, session.ID, uuid.New())
+	require.ErrorIs(t, err, errdefs.ErrNotFound)
+
+	// После перезапуска сессия и ключ подписи читаются с диска
+	svc = newService()
+	resolved, err := svc.ResolveDownloadToken(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, resolved.ID)
+
+	chunk, err := svc.DownloadFileChunk(ctx, resolved.ID, 3, 6)
+	require.NoError(t, err)
+	data, err := io.ReadAll(chunk)
+	chunk.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "3456", string(data))
+
+	tampered := strings.Replace(token, session.ID, uuid.New().String(), 1)
+	_, err = svc.ResolveDownloadToken(ctx, tampered)
+	require.ErrorIs(t, err, errdefs.ErrInvalidToken)
+
+	// Новая версия файла останавливает докачку старой
+	_, err = svc.UploadFile(ctx, file.ID, strings.NewReader("changed"), ownerID, models.WriteOptions{})
+	require.NoError(t, err)
+	_, err = svc.OpenResumableDownload(ctx, session.ID)
+	require.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+
+	// Удаленная сессия отзывает и ссылку
+	require.NoError(t, svc.DeleteResumableDownloadSession(ctx, session.ID))
+	_, err = svc.ResolveDownloadToken(ctx, token)
+	require.ErrorIs(t, err, errdefs.ErrNotFound)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memFileRepository хранит записи dbmanager в памяти. Методы, не нужные тестам,
+// паникуют через встроенный nil-интерфейс
+type memFileRepository struct {
+	interfaces.FileRepository
+	files       map[uuid.UUID]*models.File
+	revisions   map[uuid.UUID][]models.FileRevision
+	permissions []models.FilePermission
+	metadata    map[uuid.UUID]map[string]interface{}
+	// failures задает ошибки, которые вернут методы с указанными именами
+	failures map[string]error
+}
+
+func newMemFileRepository() *memFileRepository {
+	return &memFileRepository{
+		files:     make(map[uuid.UUID]*models.File),
+		revisions: make(map[uuid.UUID][]models.FileRevision),
+		metadata:  make(map[uuid.UUID]map[string]interface{}),
+		failures:  make(map[string]error),
+	}
+}
+
+func (m *memFileRepository) GetFileByID(ctx context.Context, id uuid.UUID) (*models.File, error) {
+	file, ok := m.files[id]
+	if !ok {
+		return nil, errdefs.ErrFileNotFound
+	}
+	copied := *file
+	return &copied, nil
+}
+
+func (m *memFileRepository) UpdateFile(ctx context.Context, file *models.File) error {
+	if _, ok := m.files[file.ID]; !ok {
+		return errdefs.ErrFileNotFound
+	}
+	copied := *file
+	m.files[file.ID] = &copied
+	return nil
+}
+
+func (m *memFileRepository) GetFileTree(ctx context.Context, ownerID uuid.UUID, rootID *uuid.UUID) ([]models.File, error) {
+	var files []models.File
+	for _, file := range m.files {
+		if file.OwnerID == ownerID && !file.IsTrashed {
+			files = append(files, *file)
+		}
+	}
+	return files, nil
+}
+
+func (m *memFileRepository) ListFilesByParent(ctx context.Context, ownerID uuid.UUID, parentID *uuid.UUID) ([]models.File, error) {
+	var files []models.File
+	for _, file := range m.files {
+		sameParent := (file.ParentID == nil && parentID == nil) ||
+			(file.ParentID != nil && parentID != nil && *file.ParentID == *parentID)
+		if file.OwnerID == ownerID && sameParent {
+			files = append(files, *file)
+		}
+	}
+	return files, nil
+}
+
+func (m *memFileRepository) MoveFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID) error {
+	file, ok := m.files[fileID]
+	if !ok {
+		return errdefs.ErrFileNotFound
+	}
+	file.ParentID = newParentID
+	return nil
+}
+
+func (m *memFileRepository) UpdateLastViewed(ctx context.Context, fileID uuid.UUID) error {
+	return nil
+}
+
+func (m *memFileRepository) ListTrashedFiles(ctx context.Context, ownerID uuid.UUID) ([]models.File, error) {
+	var files []models.File
+	for _, file := range m.files {
+		if file.OwnerID == ownerID && file.IsTrashed {
+			files = append(files, *file)
+		}
+	}
+	return files, nil
+}
+
+func (m *memFileRepository) GetRevisions(ctx context.Context, fileID uuid.UUID) ([]models.FileRevision, error) {
+	return m.revisions[fileID], nil
+}
+
+func (m *memFileRepository) GetRevision(ctx context.Context, fileID uuid.UUID, revisionID int64) (*models.FileRevision, error) {
+	for _, revision := range m.revisions[fileID] {
+		if revision.RevisionID == revisionID {
+			return &revision, nil
+		}
+	}
+	return nil, errdefs.ErrRevisionNotFound
+}
+
+func (m *memFileRepository) CreateRevision(ctx context.Context, revision *models.FileRevision) error {
+	if err := m.failures["CreateRevision"]; err != nil {
+		return err
+	}
+	m.revisions[revision.FileID] = append(m.revisions[revision.FileID], *revision)
+	return nil
+}
+
+func (m *memFileRepository) DeleteRevision(ctx context.Context, id uuid.UUID) error {
+	if err := m.failures["DeleteRevision"]; err != nil {
+		return err
+	}
+	for fileID, revisions := range m.revisions {
+		for i, revision := range revisions {
+			if revision.ID == id {
+				m.revisions[fileID] = append(revisions[:i:i], revisions[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return errdefs.ErrRevisionNotFound
+}
+
+func newTestContext(t *testing.T) context.Context {
+	cfg := &config.Config{}
+	cfg.Logger.Config = zap.NewDevelopmentConfig()
+	cfg.Logger.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+
+	lg, err := logger.New(cfg)
+	require.NoError(t, err)
+	return logger.CtxWWithLogger(context.Background(), lg)
+}
+
+// newTestStorage локальное хранилище во временном каталоге (пользователи в users,
+// служебные файлы в temp) и пустой репозиторий в памяти
+func newTestStorage(t *testing.T) (*config.Config, interfaces.StorageRepository, *memFileRepository) {
+	root := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Storage.BasePath = root
+	cfg.Storage.UserDirName = "users"
+	cfg.Storage.TempPath = filepath.Join(root, "temp")
+
+	storageRepo := repository.NewStorageRepositoryWithBackend(repository.NewLocalBackend(filepath.Join(root, "users")), cfg)
+	return cfg, storageRepo, newMemFileRepository()
+}
+
+func (m *memFileRepository) DeleteFile(ctx context.Context, id uuid.UUID) error {
+	if err := m.failures["DeleteFile"]; err != nil {
+		return err
+	}
+	if _, ok := m.files[id]; !ok {
+		return errdefs.ErrFileNotFound
+	}
+	delete(m.files, id)
+	return nil
+}
+
+func (m *memFileRepository) CreateFile(ctx context.Context, file *models.File) error {
+	if err := m.failures["CreateFile"]; err != nil {
+		return err
+	}
+	file.ID = uuid.New()
+	copied := *file
+	m.files[file.ID] = &copied
+	return nil
+}
+
+func (m *memFileRepository) CreatePermission(ctx context.Context, permission *models.FilePermission) error {
+	if err := m.failures["CreatePermission"]; err != nil {
+		return err
+	}
+	m.permissions = append(m.permissions, *permission)
+	return nil
+}
+
+func (m *memFileRepository) CheckPermission(ctx context.Context, fileID uuid.UUID, userID uuid.UUID, requiredRole string) (bool, error) {
+	for _, permission := range m.permissions {
+		if permission.FileID == fileID && permission.GranteeID != nil && *permission.GranteeID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memFileRepository) DeletePermission(ctx context.Context, id uuid.UUID) error {
+	for i, permission := range m.permissions {
+		if permission.ID == id {
+			m.permissions = append(m.permissions[:i:i], m.permissions[i+1:]...)
+			return nil
+		}
+	}
+	return errdefs.ErrNotFound
+}
+
+func (m *memFileRepository) GetFileMetadata(ctx context.Context, fileID uuid.UUID) (map[string]interface{}, error) {
+	return m.metadata[fileID], nil
+}
+
+func (m *memFileRepository) UpdateFileMetadata(ctx context.Context, fileID uuid.UUID, metadata map[string]interface{}) error {
+	m.metadata[fileID] = metadata
+	return nil
+}
+
+func (m *memFileRepository) VerifyFileIntegrity(ctx context.Context, fileID uuid.UUID) (bool, error) {
+	_, ok := m.files[fileID]
+	return ok, nil
+}
+
+func (m *memFileRepository) SoftDeleteFile(ctx context.Context, id uuid.UUID) error {
+	file, ok := m.files[id]
+	if !ok {
+		return errdefs.ErrFileNotFound
+	}
+	file.IsTrashed = true
+	return nil
+}
+
+func (m *memFileRepository) RestoreFile(ctx context.Context, id uuid.UUID) error {
+	file, ok := m.files[id]
+	if !ok {
+		return errdefs.ErrFileNotFound
+	}
+	file.IsTrashed = false
+	return nil
+}
+
+func (m *memFileRepository) GetPermissions(ctx context.Context, fileID uuid.UUID) ([]models.FilePermission, error) {
+	var permissions []models.FilePermission
+	for _, permission := range m.permissions {
+		if permission.FileID == fileID {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions, nil
+}
@@ -5,13 +5,12 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
-	"sync"
-	"time"
 
 	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
 	"homecloud-file-service/internal/interfaces"
 	"homecloud-file-service/internal/models"
 
@@ -109,18 +108,39 @@ func GetMimeTypeByExtension(filename string) string {
 type fileService struct {
 	fileRepo    interfaces.FileRepository
 	storageRepo interfaces.StorageRepository
+	blobStore   interfaces.BlobStore
+	parityStore interfaces.ParityStore
+	journal     interfaces.WriteJournal
 	cfg         *config.Config
-	// Добавляем map для хранения сессий в памяти
-	resumableSessions map[string]*models.ResumableDownloadSession
-	sessionMutex      sync.RWMutex
+	// downloadSessions сессии возобновляемого скачивания, переживающие перезапуск
+	downloadSessions interfaces.DownloadSessionStore
+	// userDeletions запросы на удаление данных пользователей, ждущие конца отсрочки
+	userDeletions interfaces.UserDeletionStore
+	// fileLocks сериализует изменения одного файла, чтобы проверка версии и запись шли подряд
+	fileLocks fileLocks
 }
 
-func NewFileService(fileRepo interfaces.FileRepository, storageRepo interfaces.StorageRepository, cfg *config.Config) interfaces.FileService {
+// FileServiceDeps необязательные зависимости файлового сервиса. Без BlobStore содержимое
+// пишется по storage_path файла, без ParityStore и Journal нет блоков четности и восстановления
+// записей после сбоя, без DownloadSessions и UserDeletions соответствующие вызовы недоступны
+type FileServiceDeps struct {
+	BlobStore        interfaces.BlobStore
+	ParityStore      interfaces.ParityStore
+	Journal          interfaces.WriteJournal
+	DownloadSessions interfaces.DownloadSessionStore
+	UserDeletions    interfaces.UserDeletionStore
+}
+
+func NewFileService(fileRepo interfaces.FileRepository, storageRepo interfaces.StorageRepository, cfg *config.Config, deps FileServiceDeps) interfaces.FileService {
 	return &fileService{
-		fileRepo:          fileRepo,
-		storageRepo:       storageRepo,
-		cfg:               cfg,
-		resumableSessions: make(map[string]*models.ResumableDownloadSession),
+		fileRepo:         fileRepo,
+		storageRepo:      storageRepo,
+		blobStore:        deps.BlobStore,
+		parityStore:      deps.ParityStore,
+		journal:          deps.Journal,
+		cfg:              cfg,
+		downloadSessions: deps.DownloadSessions,
+		userDeletions:    deps.UserDeletions,
 	}
 }
 
@@ -129,17 +149,27 @@ func (s *fileService) CreateFile(ctx context.Context, req *models.CreateFileRequ
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "CreateFile called", zap.Any("req", req), zap.String("ownerID", ownerID.String()))
 
+	// Имя в папке может быть уже занято: что делать, решает политика запроса
+	name, existing, err := s.resolveNameConflict(ctx, ownerID, req.ParentID, req.Name, req.IsFolder, nil, req.Conflict)
+	if err != nil {
+		lg.Info(ctx, "File name conflict", zap.Error(err), zap.String("name", req.Name))
+		return nil, err
+	}
+	if existing != nil && req.Conflict == models.ConflictNewVersion {
+		return s.createAsNewVersion(ctx, existing, req, ownerID)
+	}
+
 	// Определяем MIME тип
 	mimeType := req.MimeType
 	if mimeType == "" && !req.IsFolder {
-		mimeType = GetMimeTypeByExtension(req.Name)
+		mimeType = GetMimeTypeByExtension(name)
 	}
 
 	// Создаем объект файла (без ID - он будет сгенерирован БД)
 	file := &models.File{
 		OwnerID:    ownerID,
 		ParentID:   req.ParentID,
-		Name:       req.Name,
+		Name:       name,
 		MimeType:   mimeType,
 		Size:       req.Size,
 		IsFolder:   req.IsFolder,
@@ -150,176 +180,203 @@ func (s *fileService) CreateFile(ctx context.Context, req *models.CreateFileRequ
 	}
 
 	// Если это файл (не папка), добавляем расширение
-	if !req.IsFolder && req.Name != "" {
-		ext := filepath.Ext(req.Name)
+	if !req.IsFolder && name != "" {
+		ext := filepath.Ext(name)
 		if ext != "" {
 			file.FileExtension = &ext
 		}
 	}
 
+	// Каждый шаг создания откатывается, если следующий не удался: строка в БД,
+	// содержимое, права и ревизия либо появляются вместе, либо не появляются вовсе
+	tx := newSaga("create_file")
+
+	// Заменяемый файл убирается с дороги и удаляется, только если новый создан целиком
+	if existing != nil {
+		if err := s.setAsideExisting(ctx, tx, existing, ownerID); err != nil {
+			lg.Error(ctx, "Failed to replace existing file", zap.Error(err), zap.String("name", name))
+			return nil, err
+		}
+	}
+
 	// Сохраняем файл в БД и получаем сгенерированный ID
-	if err := s.fileRepo.CreateFile(ctx, file); err != nil {
+	err = tx.Step(ctx, "create_row", func(ctx context.Context) error {
+		return s.fileRepo.CreateFile(ctx, file)
+	}, func(ctx context.Context) error {
+		return s.fileRepo.DeleteFile(ctx, file.ID)
+	})
+	if isDuplicateName(err) {
+		// Имя заняли параллельным запросом уже после проверки
+		lg.Info(ctx, "File name taken concurrently", zap.Error(err), zap.String("name", name))
+		return nil, &errdefs.FileExistsError{Name: name}
+	}
+	if err != nil {
 		lg.Error(ctx, "Failed to create file in database", zap.Error(err))
 		return nil, fmt.Errorf("failed to create file in database: %w", err)
 	}
 
-	// Теперь у нас есть ID из БД, обновляем storage_path с правильным ID
-	relativeStoragePath := ""
-	absoluteStoragePath := ""
-	if req.ParentID != nil {
-		parent, err := s.fileRepo.GetFileByID(ctx, *req.ParentID)
-		if err == nil && parent != nil && parent.IsFolder {
-			relativeStoragePath = filepath.Join(parent.StoragePath[len(filepath.Join(s.cfg.Storage.BasePath, s.cfg.Storage.UserDirName))+1:], fmt.Sprintf("%s_%s", file.ID.String(), req.Name))
-			absoluteStoragePath = filepath.Join(parent.StoragePath, fmt.Sprintf("%s_%s", file.ID.String(), req.Name))
-		}
-	}
-	if relativeStoragePath == "" || absoluteStoragePath == "" {
-		relativeStoragePath = filepath.Join(ownerID.String(), fmt.Sprintf("%s_%s", file.ID.String(), req.Name))
-		absoluteStoragePath = filepath.Join(s.cfg.Storage.BasePath, s.cfg.Storage.UserDirName, relativeStoragePath)
-	}
-	file.StoragePath = absoluteStoragePath
+	// Теперь у нас есть ID из БД: путь в хранилище выводится из ID и не зависит от дерева папок,
+	// поэтому перемещение и переименование не трогают данные. Папкам физический каталог не нужен
+	file.StoragePath = s.generateStoragePath(ownerID, file.ID)
 
 	// Обновляем storage_path в БД
-	if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
+	err = tx.Step(ctx, "set_storage_path", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, file)
+	}, nil)
+	if err != nil {
 		lg.Error(ctx, "Failed to update storage path", zap.Error(err))
-		// Не возвращаем ошибку, так как файл уже создан
+		return nil, fmt.Errorf("failed to update storage path: %w", err)
 	}
 
-	// Если это папка, создаем директорию в файловой системе с относительным storage_path
-	if req.IsFolder {
-		if err := s.storageRepo.CreateDirectory(ctx, relativeStoragePath); err != nil {
-			lg.Error(ctx, "Failed to create directory", zap.Error(err))
-			return nil, fmt.Errorf("failed to create directory: %w", err)
+	// Если есть контент, сохраняем его потоково (по storage_path файла или в хранилище блобов)
+	var intent *models.WriteIntent
+	if content := requestContent(req); content != nil && !req.IsFolder {
+		intent, err = s.beginWrite(ctx, models.WriteOpCreate, file)
+		if err != nil {
+			lg.Error(ctx, "Failed to journal file creation", zap.Error(err))
+			tx.Rollback(ctx)
+			return nil, err
 		}
-		lg.Info(ctx, "Directory created successfully", zap.String("path", relativeStoragePath))
-	}
 
-	// Если есть контент, сохраняем его (тоже относительный путь)
-	if len(req.Content) > 0 && !req.IsFolder {
-		if err := s.storageRepo.SaveFile(ctx, relativeStoragePath, req.Content); err != nil {
+		if err := s.storeContent(ctx, tx, file, content, intent); err != nil {
 			lg.Error(ctx, "Failed to save file content", zap.Error(err))
+			s.abortWrite(ctx, tx, intent)
 			return nil, fmt.Errorf("failed to save file content: %w", err)
 		}
 
-		// Вычисляем контрольные суммы
-		md5Checksum, err := s.storageRepo.CalculateChecksum(ctx, relativeStoragePath, "md5")
+		// Обновляем storage_path и контрольные суммы в БД
+		err = tx.Step(ctx, "update_checksums", func(ctx context.Context) error {
+			return s.fileRepo.UpdateFile(ctx, file)
+		}, nil)
 		if err != nil {
-			lg.Error(ctx, "Failed to calculate MD5 checksum", zap.Error(err))
-		} else {
-			file.MD5Checksum = &md5Checksum
+			lg.Error(ctx, "Failed to update checksums", zap.Error(err))
+			s.abortWrite(ctx, tx, intent)
+			return nil, fmt.Errorf("failed to update checksums: %w", err)
 		}
+	}
 
-		sha256Checksum, err := s.storageRepo.CalculateChecksum(ctx, relativeStoragePath, "sha256")
-		if err != nil {
-			lg.Error(ctx, "Failed to calculate SHA256 checksum", zap.Error(err))
-		} else {
-			file.SHA256Checksum = &sha256Checksum
-		}
+	// Права владельца и первая ревизия
+	if err := s.createOwnerRecords(ctx, tx, file, false); err != nil {
+		s.abortWrite(ctx, tx, intent)
+		return nil, err
+	}
 
-		// Обновляем контрольные суммы в БД
-		if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
-			lg.Error(ctx, "Failed to update checksums", zap.Error(err))
-			// Не возвращаем ошибку, так как файл уже создан
-		}
+	s.finishWrite(ctx, intent)
+	tx.Commit(ctx)
+
+	lg.Info(ctx, "File created successfully", zap.String("fileID", file.ID.String()), zap.String("name", name))
+	return file, nil
+}
+
+// createAsNewVersion выполняет создание с ConflictNewVersion, когда имя уже занято:
+// папка используется существующая, содержимое файла записывается его новой версией
+func (s *fileService) createAsNewVersion(ctx context.Context, existing *models.File, req *models.CreateFileRequest, userID uuid.UUID) (*models.File, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if existing.IsFolder {
+		lg.Info(ctx, "Using existing folder", zap.String("folderID", existing.ID.String()), zap.String("name", existing.Name))
+		return existing, nil
 	}
 
-	// Создаем права доступа для владельца файла
-	ownerPermission := &models.FilePermission{
-		ID:          uuid.New(),
-		FileID:      file.ID,
-		GranteeID:   &ownerID,
-		GranteeType: models.GranteeTypeUser,
-		Role:        models.RoleOwner,
-		AllowShare:  true,
+	content := requestContent(req)
+	if content == nil {
+		content = strings.NewReader("")
+	}
+	if _, err := s.UploadFile(ctx, existing.ID, content, userID, models.WriteOptions{}); err != nil {
+		return nil, err
 	}
 
-	if err := s.fileRepo.CreatePermission(ctx, ownerPermission); err != nil {
-		lg.Error(ctx, "Failed to create owner permission", zap.Error(err))
-		// Не возвращаем ошибку, так как файл уже создан
-	} else {
-		lg.Info(ctx, "Owner permission created successfully", zap.String("fileID", file.ID.String()))
+	lg.Info(ctx, "File saved as new version", zap.String("fileID", existing.ID.String()), zap.String("name", existing.Name))
+	return s.fileRepo.GetFileByID(ctx, existing.ID)
+}
+
+// createOwnerRecords создает права владельца и первую ревизию нового файла как шаги операции tx.
+// При восстановлении после сбоя (ensure) уже созданные записи не дублируются
+func (s *fileService) createOwnerRecords(ctx context.Context, tx *saga, file *models.File, ensure bool) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+	ownerID := file.OwnerID
+
+	hasPermission := false
+	if ensure {
+		hasPermission, _ = s.fileRepo.CheckPermission(ctx, file.ID, ownerID, models.RoleOwner)
 	}
 
-	// Создаем ревизию файла (если это не папка)
-	if !req.IsFolder {
-		revision := &models.FileRevision{
+	if !hasPermission {
+		// Создаем права доступа для владельца файла
+		ownerPermission := &models.FilePermission{
 			ID:          uuid.New(),
 			FileID:      file.ID,
-			RevisionID:  1,
-			Size:        file.Size,
-			StoragePath: file.StoragePath,
-			UserID:      &ownerID,
+			GranteeID:   &ownerID,
+			GranteeType: models.GranteeTypeUser,
+			Role:        models.RoleOwner,
+			AllowShare:  true,
 		}
 
-		// Копируем MIME тип и контрольные суммы
-		if file.MimeType != "" {
-			revision.MimeType = &file.MimeType
-		}
-		if file.MD5Checksum != nil {
-			revision.MD5Checksum = file.MD5Checksum
+		err := tx.Step(ctx, "owner_permission", func(ctx context.Context) error {
+			return s.fileRepo.CreatePermission(ctx, ownerPermission)
+		}, func(ctx context.Context) error {
+			return s.fileRepo.DeletePermission(ctx, ownerPermission.ID)
+		})
+		if err != nil {
+			lg.Error(ctx, "Failed to create owner permission", zap.Error(err))
+			return fmt.Errorf("failed to create owner permission: %w", err)
 		}
+		lg.Info(ctx, "Owner permission created successfully", zap.String("fileID", file.ID.String()))
+	}
 
-		if err := s.fileRepo.CreateRevision(ctx, revision); err != nil {
-			lg.Error(ctx, "Failed to create file revision", zap.Error(err))
-			// Не возвращаем ошибку, так как файл уже создан
-		} else {
-			lg.Info(ctx, "File revision created successfully", zap.String("fileID", file.ID.String()), zap.Int64("revisionID", revision.RevisionID))
-		}
+	// Создаем ревизию файла (если это не папка)
+	hasRevision := false
+	if ensure && !file.IsFolder {
+		revisions, err := s.fileRepo.GetRevisions(ctx, file.ID)
+		hasRevision = err == nil && len(revisions) > 0
+	}
+	if file.IsFolder || hasRevision {
+		return nil
 	}
 
-	lg.Info(ctx, "File created successfully", zap.String("fileID", file.ID.String()), zap.String("name", req.Name))
-	return file, nil
+	revision := &models.FileRevision{
+		ID:          uuid.New(),
+		FileID:      file.ID,
+		RevisionID:  1,
+		Size:        file.Size,
+		StoragePath: file.StoragePath,
+		UserID:      &ownerID,
+	}
+
+	// Копируем MIME тип и контрольные суммы
+	if file.MimeType != "" {
+		revision.MimeType = &file.MimeType
+	}
+	if file.MD5Checksum != nil {
+		revision.MD5Checksum = file.MD5Checksum
+	}
+
+	err := tx.Step(ctx, "first_revision", func(ctx context.Context) error {
+		return s.createRevisionRecord(ctx, revision)
+	}, func(ctx context.Context) error {
+		return s.deleteRevisionRecord(ctx, revision)
+	})
+	if err != nil {
+		lg.Error(ctx, "Failed to create file revision", zap.Error(err))
+		return fmt.Errorf("failed to create file revision: %w", err)
+	}
+	lg.Info(ctx, "File revision created successfully", zap.String("fileID", file.ID.String()), zap.Int64("revisionID", revision.RevisionID))
+	return nil
 }
 
 func (s *fileService) GetFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (*models.File, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "GetFile called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
 
-	// Получаем файл из БД
+	// Получаем файл из БД. Восстанавливать запись по содержимому хранилища не из чего:
+	// объекты лежат по пути из ID (StorageKeyForFile), имени файла в нем нет
 	file, err := s.fileRepo.GetFileByID(ctx, fileID)
-	if err != nil || file == nil {
-		lg.Info(ctx, "File not found in DB, trying to find in filesystem", zap.String("fileID", fileID.String()), zap.Error(err))
-
-		// Рекурсивно ищем файл по ID во всех подпапках пользователя
-		foundPath, foundName, err := s.findFileRecursively(ctx, userID, fileID)
-		if err != nil {
-			lg.Error(ctx, "Failed to find file recursively", zap.Error(err))
-			return nil, fmt.Errorf("file not found")
-		}
-
-		if foundPath == "" {
-			return nil, fmt.Errorf("file not found")
-		}
-
-		// Получаем инфу о файле из ФС
-		info, err := s.storageRepo.GetFileInfo(ctx, foundPath)
-		if err != nil {
-			lg.Error(ctx, "Failed to get file info from FS", zap.Error(err))
-			return nil, fmt.Errorf("file not found")
-		}
-
-		// Собираем структуру файла
-		parts := strings.SplitN(foundName, "_", 2)
-		name := parts[1]
-		mimeType := GetMimeTypeByExtension(name)
-		file = &models.File{
-			ID:          fileID,
-			OwnerID:     userID,
-			Name:        name,
-			Size:        info.Size,
-			IsFolder:    info.IsDirectory,
-			MimeType:    mimeType,
-			StoragePath: foundPath,
-			CreatedAt:   time.Unix(info.ModifiedAt, 0),
-			UpdatedAt:   time.Unix(info.ModifiedAt, 0),
-		}
-
-		// Добавляем в БД
-		if err := s.fileRepo.CreateFileFromFS(ctx, file); err != nil {
-			lg.Error(ctx, "Failed to add file to DB from FS", zap.Error(err))
-			// Не возвращаем ошибку, а просто логируем
-		}
-		lg.Info(ctx, "File restored from FS and added to DB", zap.String("fileID", fileID.String()))
+	if err != nil {
+		lg.Error(ctx, "Failed to get file", zap.Error(err))
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	if file == nil {
+		return nil, errdefs.ErrFileNotFound
 	}
 
 	// Проверяем права доступа
@@ -331,7 +388,7 @@ func (s *fileService) GetFile(ctx context.Context, fileID uuid.UUID, userID uuid
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
 	}
 
 	// Обновляем время последнего просмотра
@@ -344,10 +401,13 @@ func (s *fileService) GetFile(ctx context.Context, fileID uuid.UUID, userID uuid
 	return file, nil
 }
 
-func (s *fileService) UpdateFile(ctx context.Context, fileID uuid.UUID, req *models.UpdateFileRequest, userID uuid.UUID) (*models.File, error) {
+func (s *fileService) UpdateFile(ctx context.Context, fileID uuid.UUID, req *models.UpdateFileRequest, userID uuid.UUID, opts models.WriteOptions) (*models.File, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "UpdateFile called", zap.String("fileID", fileID.String()), zap.Any("req", req), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Получаем файл из БД
 	file, err := s.fileRepo.GetFileByID(ctx, fileID)
 	if err != nil {
@@ -364,7 +424,12 @@ func (s *fileService) UpdateFile(ctx context.Context, fileID uuid.UUID, req *mod
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
+	}
+
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		return nil, err
 	}
 
 	// Обновляем поля файла
@@ -390,49 +455,49 @@ func (s *fileService) UpdateFile(ctx context.Context, fileID uuid.UUID, req *mod
 	}
 
 	// Если есть новый контент, обновляем его
+	tx := newSaga("update_file")
+	var intent *models.WriteIntent
 	if len(req.Content) > 0 && !file.IsFolder {
-		// Сохраняем новый контент
-		if err := s.storageRepo.SaveFile(ctx, file.StoragePath, req.Content); err != nil {
-			lg.Error(ctx, "Failed to save updated file content", zap.Error(err))
-			return nil, fmt.Errorf("failed to save file content: %w", err)
-		}
-
-		// Обновляем размер
-		file.Size = int64(len(req.Content))
-
-		// Пересчитываем контрольные суммы
-		md5Checksum, err := s.storageRepo.CalculateChecksum(ctx, file.StoragePath, "md5")
+		intent, err = s.beginWrite(ctx, models.WriteOpUpload, file)
 		if err != nil {
-			lg.Error(ctx, "Failed to calculate MD5 checksum", zap.Error(err))
-		} else {
-			file.MD5Checksum = &md5Checksum
+			lg.Error(ctx, "Failed to journal file update", zap.Error(err))
+			return nil, err
 		}
 
-		sha256Checksum, err := s.storageRepo.CalculateChecksum(ctx, file.StoragePath, "sha256")
-		if err != nil {
-			lg.Error(ctx, "Failed to calculate SHA256 checksum", zap.Error(err))
-		} else {
-			file.SHA256Checksum = &sha256Checksum
+		// Сохраняем новый контент, размер и контрольные суммы обновляются по ходу записи
+		if err := s.storeContent(ctx, tx, file, bytes.NewReader(req.Content), intent); err != nil {
+			lg.Error(ctx, "Failed to save updated file content", zap.Error(err))
+			s.abortWrite(ctx, tx, intent)
+			return nil, fmt.Errorf("failed to save file content: %w", err)
 		}
-
-		// Увеличиваем версию
-		file.Version++
 	}
 
+	// Любое изменение дает новую версию, иначе следующий запрос с тем же If-Match прошел бы
+	file.Version++
+
 	// Обновляем файл в БД
-	if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
+	err = tx.Step(ctx, "update_row", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, file)
+	}, nil)
+	if err != nil {
 		lg.Error(ctx, "Failed to update file in database", zap.Error(err))
+		s.abortWrite(ctx, tx, intent)
 		return nil, fmt.Errorf("failed to update file: %w", err)
 	}
+	s.finishWrite(ctx, intent)
+	tx.Commit(ctx)
 
 	lg.Info(ctx, "File updated successfully", zap.String("fileID", fileID.String()))
 	return file, nil
 }
 
-func (s *fileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) error {
+func (s *fileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID, opts models.WriteOptions) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "DeleteFile called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Получаем файл из БД
 	file, err := s.fileRepo.GetFileByID(ctx, fileID)
 	if err != nil {
@@ -442,7 +507,7 @@ func (s *fileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID u
 
 	if file == nil {
 		lg.Error(ctx, "File not found", zap.String("fileID", fileID.String()))
-		return fmt.Errorf("file not found")
+		return errdefs.ErrFileNotFound
 	}
 
 	// Проверяем права доступа (нужны права на запись)
@@ -454,7 +519,12 @@ func (s *fileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID u
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
+	}
+
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		return err
 	}
 
 	// Выполняем мягкое удаление (soft delete)
@@ -467,10 +537,13 @@ func (s *fileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID u
 	return nil
 }
 
-func (s *fileService) DeleteFileRecursive(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) error {
+func (s *fileService) DeleteFileRecursive(ctx context.Context, fileID uuid.UUID, userID uuid.UUID, opts models.WriteOptions) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "DeleteFileRecursive called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Получаем файл из БД
 	file, err := s.fileRepo.GetFileByID(ctx, fileID)
 	if err != nil {
@@ -480,7 +553,7 @@ func (s *fileService) DeleteFileRecursive(ctx context.Context, fileID uuid.UUID,
 
 	if file == nil {
 		lg.Error(ctx, "File not found", zap.String("fileID", fileID.String()))
-		return fmt.Errorf("file not found")
+		return errdefs.ErrFileNotFound
 	}
 
 	// Проверяем права доступа (нужны права на запись)
@@ -492,7 +565,12 @@ func (s *fileService) DeleteFileRecursive(ctx context.Context, fileID uuid.UUID,
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
+	}
+
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		return err
 	}
 
 	// Рекурсивно удаляем файл/папку
@@ -529,39 +607,82 @@ func (s *fileService) deleteFileRecursiveHelper(ctx context.Context, file *model
 		}
 	}
 
-	// Удаляем физический файл/папку из хранилища
-	relativePath := file.StoragePath
-	if strings.HasPrefix(relativePath, s.cfg.Storage.BasePath) {
-		relativePath = strings.TrimPrefix(relativePath, s.cfg.Storage.BasePath)
-		relativePath = strings.TrimPrefix(relativePath, "/")
-		relativePath = strings.TrimPrefix(relativePath, s.cfg.Storage.UserDirName)
-		relativePath = strings.TrimPrefix(relativePath, "/")
-	}
+	// Содержимое из хранилища блобов общее: запоминаем ссылки файла и его ревизий,
+	// чтобы освободить их после удаления записи
+	blobRefs := s.collectBlobRefs(ctx, file)
+	_, isBlob := s.blobSHA(file.StoragePath)
+	relativePath := s.toRelativePath(file.StoragePath)
+
+	// Удалить строку из БД и вернуть её нельзя, поэтому содержимое сначала откладывается
+	// в сторону: если строка не удалится, оно возвращается на место
+	tx := newSaga("delete_file")
+	var intent *models.WriteIntent
+	if relativePath != "" && !isBlob && !file.IsFolder {
+		stashKey := path.Join(deleteStashPrefix, file.ID.String())
+		var err error
+		intent, err = s.beginDelete(ctx, file, stashKey)
+		if err != nil {
+			lg.Error(ctx, "Failed to journal file deletion", zap.Error(err))
+			return err
+		}
 
-	if relativePath != "" {
-		if file.IsFolder {
-			if err := s.storageRepo.DeleteDirectory(ctx, relativePath); err != nil {
-				lg.Error(ctx, "Failed to delete directory from storage", zap.Error(err), zap.String("path", relativePath))
-				// Не возвращаем ошибку, продолжаем удаление из БД
-			} else {
-				lg.Debug(ctx, "Directory deleted from storage", zap.String("path", relativePath))
+		stashed := false
+		err = tx.Step(ctx, "stash_content", func(ctx context.Context) error {
+			err := s.storageRepo.MoveFile(ctx, relativePath, stashKey)
+			if errdefs.Is(err, errdefs.ErrFileNotFound) {
+				lg.Debug(ctx, "File content already missing", zap.String("path", relativePath))
+				return nil
 			}
-		} else {
-			if err := s.storageRepo.DeleteFile(ctx, relativePath); err != nil {
-				lg.Error(ctx, "Failed to delete file from storage", zap.Error(err), zap.String("path", relativePath))
-				// Не возвращаем ошибку, продолжаем удаление из БД
-			} else {
-				lg.Debug(ctx, "File deleted from storage", zap.String("path", relativePath))
+			stashed = err == nil
+			return err
+		}, func(ctx context.Context) error {
+			if !stashed {
+				return nil
 			}
+			return s.storageRepo.MoveFile(ctx, stashKey, relativePath)
+		})
+		if err != nil {
+			lg.Error(ctx, "Failed to move file content aside", zap.Error(err), zap.String("path", relativePath))
+			s.abortWrite(ctx, tx, intent)
+			return fmt.Errorf("failed to delete file from storage: %w", err)
 		}
+		tx.OnCommit(func(ctx context.Context) {
+			if stashed {
+				if err := s.storageRepo.DeleteFile(ctx, stashKey); err != nil {
+					// Намерение остается: отложенное содержимое удалится при перезапуске
+					lg.Error(ctx, "Failed to delete file from storage", zap.Error(err), zap.String("path", stashKey))
+					return
+				}
+			}
+			s.finishWrite(ctx, intent)
+		})
 	}
 
 	// Удаляем запись из БД
-	if err := s.fileRepo.DeleteFile(ctx, file.ID); err != nil {
+	err := tx.Step(ctx, "delete_row", func(ctx context.Context) error {
+		return s.fileRepo.DeleteFile(ctx, file.ID)
+	}, nil)
+	if err != nil {
 		lg.Error(ctx, "Failed to delete file from database", zap.Error(err))
+		s.abortWrite(ctx, tx, intent)
 		return fmt.Errorf("failed to delete file from database: %w", err)
 	}
 
+	// Каталог папки существует только в старой раскладке хранилища
+	if file.IsFolder && relativePath != "" {
+		tx.OnCommit(func(ctx context.Context) {
+			if err := s.storageRepo.DeleteDirectory(ctx, relativePath); err != nil {
+				lg.Error(ctx, "Failed to delete directory from storage", zap.Error(err), zap.String("path", relativePath))
+			}
+		})
+	}
+	for _, storagePath := range blobRefs {
+		tx.OnCommit(func(ctx context.Context) {
+			s.releaseContent(ctx, storagePath)
+		})
+	}
+	tx.Commit(ctx)
+
 	lg.Debug(ctx, "File deleted from database", zap.String("fileID", file.ID.String()), zap.String("fileName", file.Name))
 	return nil
 }
@@ -579,7 +700,7 @@ func (s *fileService) RestoreFile(ctx context.Context, fileID uuid.UUID, userID
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
 	// Восстанавливаем файл
@@ -593,80 +714,81 @@ func (s *fileService) RestoreFile(ctx context.Context, fileID uuid.UUID, userID
 }
 
 // Операции с контентом файлов
-func (s *fileService) UploadFile(ctx context.Context, fileID uuid.UUID, content io.Reader, userID uuid.UUID) error {
+func (s *fileService) UploadFile(ctx context.Context, fileID uuid.UUID, content io.Reader, userID uuid.UUID, opts models.WriteOptions) (*models.UploadResult, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "UploadFile called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Получаем файл из БД
 	file, err := s.fileRepo.GetFileByID(ctx, fileID)
 	if err != nil {
 		lg.Error(ctx, "Failed to get file from database", zap.Error(err))
-		return fmt.Errorf("failed to get file: %w", err)
+		return nil, fmt.Errorf("failed to get file: %w", err)
 	}
 
 	// Проверяем права доступа (нужны права на запись)
 	hasAccess, err := s.fileRepo.CheckPermission(ctx, fileID, userID, models.RoleWriter)
 	if err != nil {
 		lg.Error(ctx, "Failed to check permission", zap.Error(err))
-		return fmt.Errorf("failed to check permission: %w", err)
+		return nil, fmt.Errorf("failed to check permission: %w", err)
 	}
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
+	}
+
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		if opts.ConflictCopy && !file.IsFolder {
+			return s.saveConflictCopy(ctx, file, content, userID, opts)
+		}
+		return nil, err
 	}
 
 	// Проверяем, что это не папка
 	if file.IsFolder {
 		lg.Error(ctx, "Cannot upload content to folder", zap.String("fileID", fileID.String()))
-		return fmt.Errorf("cannot upload content to folder")
+		return nil, fmt.Errorf("%w: cannot upload content to folder", errdefs.ErrInvalidInput)
 	}
 
-	// Читаем весь контент
-	contentBytes, err := io.ReadAll(content)
+	intent, err := s.beginWrite(ctx, models.WriteOpUpload, file)
 	if err != nil {
-		lg.Error(ctx, "Failed to read content", zap.Error(err))
-		return fmt.Errorf("failed to read content: %w", err)
+		lg.Error(ctx, "Failed to journal file upload", zap.Error(err))
+		return nil, err
 	}
 
-	// Сохраняем контент в хранилище
-	if err := s.storageRepo.SaveFile(ctx, file.StoragePath, contentBytes); err != nil {
+	// Сохраняем контент в хранилище потоково, без буферизации всего файла в памяти.
+	// Размер файла и контрольные суммы считаются во время записи
+	tx := newSaga("upload_file")
+	if err := s.storeContent(ctx, tx, file, content, intent); err != nil {
 		lg.Error(ctx, "Failed to save file content", zap.Error(err))
-		return fmt.Errorf("failed to save file content: %w", err)
-	}
-
-	// Обновляем размер файла
-	file.Size = int64(len(contentBytes))
-
-	// Вычисляем контрольные суммы
-	md5Checksum, err := s.storageRepo.CalculateChecksum(ctx, file.StoragePath, "md5")
-	if err != nil {
-		lg.Error(ctx, "Failed to calculate MD5 checksum", zap.Error(err))
-	} else {
-		file.MD5Checksum = &md5Checksum
-	}
-
-	sha256Checksum, err := s.storageRepo.CalculateChecksum(ctx, file.StoragePath, "sha256")
-	if err != nil {
-		lg.Error(ctx, "Failed to calculate SHA256 checksum", zap.Error(err))
-	} else {
-		file.SHA256Checksum = &sha256Checksum
+		s.abortWrite(ctx, tx, intent)
+		return nil, fmt.Errorf("failed to save file content: %w", err)
 	}
 
 	// Увеличиваем версию
 	file.Version++
 
 	// Обновляем файл в БД
-	if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
+	err = tx.Step(ctx, "update_row", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, file)
+	}, nil)
+	if err != nil {
 		lg.Error(ctx, "Failed to update file in database", zap.Error(err))
-		return fmt.Errorf("failed to update file: %w", err)
+		s.abortWrite(ctx, tx, intent)
+		return nil, fmt.Errorf("failed to update file: %w", err)
 	}
+	s.finishWrite(ctx, intent)
+	tx.Commit(ctx)
 
 	lg.Info(ctx, "File uploaded successfully", zap.String("fileID", fileID.String()), zap.Int64("size", file.Size))
-	return nil
+	return &models.UploadResult{FileID: file.ID, Version: file.Version}, nil
 }
 
-func (s *fileService) DownloadFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (io.ReadCloser, string, error) {
+func (s *fileService) DownloadFile(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (interfaces.BlobReader, string, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "DownloadFile called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
 
@@ -686,23 +808,17 @@ func (s *fileService) DownloadFile(ctx context.Context, fileID uuid.UUID, userID
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, "", fmt.Errorf("access denied")
+		return nil, "", errdefs.ErrPermissionDenied
 	}
 
 	// Проверяем, что это не папка
 	if file.IsFolder {
 		lg.Error(ctx, "Cannot download folder content", zap.String("fileID", fileID.String()))
-		return nil, "", fmt.Errorf("cannot download folder content")
+		return nil, "", fmt.Errorf("%w: cannot download folder content", errdefs.ErrInvalidInput)
 	}
 
-	// Получаем относительный путь для storageRepo
-	relPath := file.StoragePath
-	userDirPrefix := filepath.Join(s.cfg.Storage.BasePath, s.cfg.Storage.UserDirName) + string(os.PathSeparator)
-	if strings.HasPrefix(relPath, userDirPrefix) {
-		relPath = strings.TrimPrefix(relPath, userDirPrefix)
-	}
-
-	content, err := s.storageRepo.GetFile(ctx, relPath)
+	// Открываем файл в хранилище, содержимое отдается потоково
+	content, err := s.openContent(ctx, file)
 	if err != nil {
 		lg.Error(ctx, "Failed to get file content from storage", zap.Error(err))
 		return nil, "", fmt.Errorf("failed to get file content: %w", err)
@@ -714,11 +830,11 @@ func (s *fileService) DownloadFile(ctx context.Context, fileID uuid.UUID, userID
 		// Не возвращаем ошибку, так как основная операция выполнена успешно
 	}
 
-	lg.Info(ctx, "File downloaded successfully", zap.String("fileID", fileID.String()))
-	return io.NopCloser(bytes.NewReader(content)), file.MimeType, nil
+	lg.Info(ctx, "File download started", zap.String("fileID", fileID.String()), zap.Int64("size", content.Size()))
+	return content, file.MimeType, nil
 }
 
-func (s *fileService) GetFileContent(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) ([]byte, error) {
+func (s *fileService) GetFileContent(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (interfaces.BlobReader, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "GetFileContent called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
 
@@ -738,17 +854,17 @@ func (s *fileService) GetFileContent(ctx context.Context, fileID uuid.UUID, user
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
 	}
 
 	// Проверяем, что это не папка
 	if file.IsFolder {
 		lg.Error(ctx, "Cannot get content of folder", zap.String("fileID", fileID.String()))
-		return nil, fmt.Errorf("cannot get content of folder")
+		return nil, fmt.Errorf("%w: cannot get content of folder", errdefs.ErrInvalidInput)
 	}
 
-	// Получаем контент из хранилища
-	content, err := s.storageRepo.GetFile(ctx, file.StoragePath)
+	// Открываем контент в хранилище
+	content, err := s.openContent(ctx, file)
 	if err != nil {
 		lg.Error(ctx, "Failed to get file content from storage", zap.Error(err))
 		return nil, fmt.Errorf("failed to get file content: %w", err)
@@ -760,7 +876,7 @@ func (s *fileService) GetFileContent(ctx context.Context, fileID uuid.UUID, user
 		// Не возвращаем ошибку, так как основная операция выполнена успешно
 	}
 
-	lg.Info(ctx, "File content retrieved successfully", zap.String("fileID", fileID.String()), zap.Int("size", len(content)))
+	lg.Info(ctx, "File content retrieved successfully", zap.String("fileID", fileID.String()), zap.Int64("size", content.Size()))
 	return content, nil
 }
 
@@ -790,7 +906,7 @@ func (s *fileService) ListFiles(ctx context.Context, req *models.FileListRequest
 		// Проверяем, что это папка
 		if !folder.IsFolder {
 			lg.Error(ctx, "Specified path is not a folder", zap.String("path", req.Path))
-			return nil, fmt.Errorf("specified path is not a folder")
+			return nil, fmt.Errorf("%w: specified path is not a folder", errdefs.ErrInvalidInput)
 		}
 
 		// Получаем содержимое папки
@@ -886,7 +1002,7 @@ func (s *fileService) SearchFiles(ctx context.Context, userID uuid.UUID, query s
 }
 
 // Операции с папками
-func (s *fileService) CreateFolder(ctx context.Context, name string, parentID *uuid.UUID, ownerID uuid.UUID) (*models.File, error) {
+func (s *fileService) CreateFolder(ctx context.Context, name string, parentID *uuid.UUID, ownerID uuid.UUID, conflict models.ConflictPolicy) (*models.File, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "CreateFolder called", zap.String("name", name), zap.Any("parentID", parentID), zap.String("ownerID", ownerID.String()))
 
@@ -897,6 +1013,7 @@ func (s *fileService) CreateFolder(ctx context.Context, name string, parentID *u
 		IsFolder: true,
 		MimeType: "application/x-directory",
 		Size:     0,
+		Conflict: conflict,
 	}
 
 	// Используем общий метод CreateFile
@@ -934,7 +1051,7 @@ func (s *fileService) ListFolderContents(ctx context.Context, folderID *uuid.UUI
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied to folder", zap.String("folderID", folderID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied to folder")
+		return nil, fmt.Errorf("%w to folder", errdefs.ErrPermissionDenied)
 	}
 
 	// Получаем содержимое папки
@@ -977,7 +1094,7 @@ func (s *fileService) CreateRevision(ctx context.Context, fileID uuid.UUID, user
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
 	}
 
 	// Получаем файл
@@ -1021,7 +1138,7 @@ func (s *fileService) CreateRevision(ctx context.Context, fileID uuid.UUID, user
 	}
 
 	// Сохраняем ревизию
-	if err := s.fileRepo.CreateRevision(ctx, revision); err != nil {
+	if err := s.createRevisionRecord(ctx, revision); err != nil {
 		lg.Error(ctx, "Failed to create revision", zap.Error(err))
 		return nil, fmt.Errorf("failed to create revision: %w", err)
 	}
@@ -1043,7 +1160,7 @@ func (s *fileService) ListRevisions(ctx context.Context, fileID uuid.UUID, userI
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
 	}
 
 	// Получаем ревизии
@@ -1070,7 +1187,7 @@ func (s *fileService) GetRevision(ctx context.Context, fileID uuid.UUID, revisio
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
 	}
 
 	// Получаем ревизию
@@ -1084,10 +1201,13 @@ func (s *fileService) GetRevision(ctx context.Context, fileID uuid.UUID, revisio
 	return revision, nil
 }
 
-func (s *fileService) RestoreRevision(ctx context.Context, fileID uuid.UUID, revisionID int64, userID uuid.UUID) error {
+func (s *fileService) RestoreRevision(ctx context.Context, fileID uuid.UUID, revisionID int64, userID uuid.UUID, opts models.WriteOptions) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "RestoreRevision called", zap.String("fileID", fileID.String()), zap.Int64("revisionID", revisionID), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Проверяем права доступа
 	hasAccess, err := s.fileRepo.CheckPermission(ctx, fileID, userID, models.RoleWriter)
 	if err != nil {
@@ -1097,7 +1217,7 @@ func (s *fileService) RestoreRevision(ctx context.Context, fileID uuid.UUID, rev
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
 	// Получаем ревизию
@@ -1114,37 +1234,87 @@ func (s *fileService) RestoreRevision(ctx context.Context, fileID uuid.UUID, rev
 		return fmt.Errorf("failed to get file: %w", err)
 	}
 
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		return err
+	}
+
+	intent, err := s.beginWrite(ctx, models.WriteOpRestore, file)
+	if err != nil {
+		lg.Error(ctx, "Failed to journal revision restore", zap.Error(err))
+		return err
+	}
+
 	// Восстанавливаем содержимое файла из ревизии
-	if revision.StoragePath != file.StoragePath {
-		// Копируем файл из ревизии
-		content, err := s.storageRepo.GetFile(ctx, revision.StoragePath)
+	tx := newSaga("restore_revision")
+	if sha256, isBlob := s.blobSHA(revision.StoragePath); isBlob && revision.StoragePath != file.StoragePath {
+		// Ревизия в хранилище блобов - файл просто начинает ссылаться на её блоб
+		previous := file.StoragePath
+		err := tx.Step(ctx, "share_revision_blob", func(ctx context.Context) error {
+			if err := s.blobStore.Acquire(ctx, sha256); err != nil {
+				return err
+			}
+			file.StoragePath = revision.StoragePath
+			file.Size = revision.Size
+			file.MD5Checksum = revision.MD5Checksum
+			file.SHA256Checksum = &sha256
+			if err := s.markWritten(ctx, intent, file); err != nil {
+				s.releaseContent(ctx, revision.StoragePath)
+				return err
+			}
+			return nil
+		}, func(ctx context.Context) error {
+			s.releaseContent(ctx, revision.StoragePath)
+			return nil
+		})
+		if err != nil {
+			lg.Error(ctx, "Failed to acquire revision blob", zap.Error(err))
+			s.finishWrite(ctx, intent)
+			return fmt.Errorf("failed to restore revision content: %w", err)
+		}
+		tx.OnCommit(func(ctx context.Context) {
+			s.releaseContent(ctx, previous)
+		})
+	} else if revision.StoragePath != file.StoragePath {
+		// Копируем файл из ревизии потоково
+		content, err := s.storageRepo.GetFile(ctx, s.toRelativePath(revision.StoragePath))
 		if err != nil {
 			lg.Error(ctx, "Failed to get revision content", zap.Error(err))
+			s.finishWrite(ctx, intent)
 			return fmt.Errorf("failed to get revision content: %w", err)
 		}
+		defer content.Close()
 
 		// Сохраняем в текущий путь файла
-		if err := s.storageRepo.SaveFile(ctx, file.StoragePath, content); err != nil {
+		if err := s.storeContent(ctx, tx, file, content, intent); err != nil {
 			lg.Error(ctx, "Failed to save restored content", zap.Error(err))
+			s.abortWrite(ctx, tx, intent)
 			return fmt.Errorf("failed to save restored content: %w", err)
 		}
 	}
 
-	// Обновляем метаданные файла
-	file.Size = revision.Size
-	if revision.MD5Checksum != nil {
-		file.MD5Checksum = revision.MD5Checksum
-	}
+	// Размер и контрольные суммы уже взяты из записанного содержимого. Если ревизия
+	// лежит по пути файла, содержимое не менялось и они остаются прежними
 	if revision.MimeType != nil {
 		file.MimeType = *revision.MimeType
 	}
 
+	// Увеличиваем версию
+	file.Version++
+
 	// Сохраняем обновленный файл
-	if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
+	err = tx.Step(ctx, "update_row", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, file)
+	}, nil)
+	if err != nil {
 		lg.Error(ctx, "Failed to update file", zap.Error(err))
+		s.abortWrite(ctx, tx, intent)
 		return fmt.Errorf("failed to update file: %w", err)
 	}
 
+	s.finishWrite(ctx, intent)
+	tx.Commit(ctx)
+
 	lg.Info(ctx, "Revision restored successfully", zap.String("fileID", fileID.String()), zap.Int64("revisionID", revisionID))
 	return nil
 }
@@ -1163,7 +1333,7 @@ func (s *fileService) GrantPermission(ctx context.Context, fileID uuid.UUID, per
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
 	// Устанавливаем ID файла
@@ -1192,7 +1362,7 @@ func (s *fileService) RevokePermission(ctx context.Context, fileID uuid.UUID, gr
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
 	// Получаем разрешения для файла
@@ -1213,7 +1383,7 @@ func (s *fileService) RevokePermission(ctx context.Context, fileID uuid.UUID, gr
 
 	if permissionToDelete == nil {
 		lg.Error(ctx, "Permission not found", zap.String("granteeID", granteeID.String()))
-		return fmt.Errorf("permission not found")
+		return fmt.Errorf("%w: permission not found", errdefs.ErrNotFound)
 	}
 
 	// Удаляем разрешение
@@ -1239,7 +1409,7 @@ func (s *fileService) ListPermissions(ctx context.Context, fileID uuid.UUID, use
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
 	}
 
 	// Получаем разрешения
@@ -1289,7 +1459,7 @@ func (s *fileService) StarFile(ctx context.Context, fileID uuid.UUID, userID uui
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
 	// Добавляем в избранное
@@ -1325,7 +1495,7 @@ func (s *fileService) UnstarFile(ctx context.Context, fileID uuid.UUID, userID u
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
 	// Удаляем из избранного
@@ -1341,10 +1511,13 @@ func (s *fileService) UnstarFile(ctx context.Context, fileID uuid.UUID, userID u
 	return nil
 }
 
-func (s *fileService) MoveFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, userID uuid.UUID) error {
+func (s *fileService) MoveFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, userID uuid.UUID, opts models.WriteOptions) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "MoveFile called", zap.String("fileID", fileID.String()), zap.Any("newParentID", newParentID), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Проверяем права доступа к файлу (нужны права на запись)
 	hasAccess, err := s.fileRepo.CheckPermission(ctx, fileID, userID, models.RoleWriter)
 	if err != nil {
@@ -1354,7 +1527,7 @@ func (s *fileService) MoveFile(ctx context.Context, fileID uuid.UUID, newParentI
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied to file", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied to file")
+		return fmt.Errorf("%w to file", errdefs.ErrPermissionDenied)
 	}
 
 	// Если указана новая родительская папка, проверяем права доступа к ней
@@ -1367,24 +1540,101 @@ func (s *fileService) MoveFile(ctx context.Context, fileID uuid.UUID, newParentI
 
 		if !hasParentAccess {
 			lg.Error(ctx, "Access denied to parent folder", zap.String("parentID", newParentID.String()), zap.String("userID", userID.String()))
-			return fmt.Errorf("access denied to parent folder")
+			return fmt.Errorf("%w to parent folder", errdefs.ErrPermissionDenied)
 		}
 	}
 
-	// Перемещаем файл
-	if err := s.fileRepo.MoveFile(ctx, fileID, newParentID); err != nil {
+	// Прежняя папка нужна, чтобы вернуть файл на место при откате
+	file, err := s.fileRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		lg.Error(ctx, "Failed to get file from database", zap.Error(err))
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		return err
+	}
+	previousParentID := file.ParentID
+
+	name, existing, err := s.resolveNameConflict(ctx, file.OwnerID, newParentID, file.Name, file.IsFolder, &file.ID, opts.Conflict)
+	if err != nil {
+		lg.Info(ctx, "File name conflict", zap.Error(err), zap.String("name", file.Name))
+		return err
+	}
+	if existing != nil && opts.Conflict == models.ConflictNewVersion {
+		// Содержимое становится новой версией файла в целевой папке, перемещаемый файл больше не нужен.
+		// Записанную версию не отменить: если исходный файл не удалился, он остается на месте,
+		// а перемещение завершается ошибкой
+		tx := newSaga("move_as_new_version")
+		err := tx.Step(ctx, "write_new_version", func(ctx context.Context) error {
+			_, err := s.writeNewVersionFrom(ctx, file, existing, userID)
+			return err
+		}, nil)
+		if err != nil {
+			lg.Error(ctx, "Failed to move file as new version", zap.Error(err))
+			return err
+		}
+		err = tx.Step(ctx, "delete_source", func(ctx context.Context) error {
+			return s.deleteFileRecursiveHelper(ctx, file, userID)
+		}, nil)
+		if err != nil {
+			lg.Error(ctx, "Failed to delete moved file", zap.Error(err), zap.String("fileID", fileID.String()))
+			return fmt.Errorf("failed to delete moved file: %w", err)
+		}
+		tx.Commit(ctx)
+		lg.Info(ctx, "File moved as new version", zap.String("fileID", fileID.String()), zap.String("targetID", existing.ID.String()))
+		return nil
+	}
+
+	// Перемещаем файл. Путь в хранилище выводится из ID, поэтому данные на диске не трогаются
+	tx := newSaga("move_file")
+	if existing != nil {
+		if err := s.setAsideExisting(ctx, tx, existing, userID); err != nil {
+			lg.Error(ctx, "Failed to replace existing file", zap.Error(err), zap.String("name", name))
+			return err
+		}
+	}
+	if name != file.Name {
+		if err := s.renameStep(ctx, tx, file, name); err != nil {
+			lg.Error(ctx, "Failed to rename moved file", zap.Error(err))
+			return err
+		}
+	}
+	err = tx.Step(ctx, "move_row", func(ctx context.Context) error {
+		return s.fileRepo.MoveFile(ctx, fileID, newParentID)
+	}, func(ctx context.Context) error {
+		return s.fileRepo.MoveFile(ctx, fileID, previousParentID)
+	})
+	if isDuplicateName(err) {
+		lg.Info(ctx, "File name taken concurrently", zap.Error(err), zap.String("name", name))
+		return &errdefs.FileExistsError{Name: name}
+	}
+	if err != nil {
 		lg.Error(ctx, "Failed to move file", zap.Error(err))
 		return fmt.Errorf("failed to move file: %w", err)
 	}
+	err = tx.Step(ctx, "bump_version", func(ctx context.Context) error {
+		return s.bumpVersion(ctx, fileID)
+	}, nil)
+	if err != nil {
+		lg.Error(ctx, "Failed to update moved file version", zap.Error(err))
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	tx.Commit(ctx)
 
 	lg.Info(ctx, "File moved successfully", zap.String("fileID", fileID.String()))
 	return nil
 }
 
-func (s *fileService) CopyFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, newName string, userID uuid.UUID) (*models.File, error) {
+func (s *fileService) CopyFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, newName string, userID uuid.UUID, opts models.WriteOptions) (*models.File, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "CopyFile called", zap.String("fileID", fileID.String()), zap.Any("newParentID", newParentID), zap.String("newName", newName), zap.String("userID", userID.String()))
 
+	// Пока копия создается, исходный файл не меняется
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Проверяем права доступа к исходному файлу (нужны права на чтение)
 	hasAccess, err := s.fileRepo.CheckPermission(ctx, fileID, userID, models.RoleReader)
 	if err != nil {
@@ -1394,7 +1644,7 @@ func (s *fileService) CopyFile(ctx context.Context, fileID uuid.UUID, newParentI
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied to source file", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied to source file")
+		return nil, fmt.Errorf("%w to source file", errdefs.ErrPermissionDenied)
 	}
 
 	// Если указана новая родительская папка, проверяем права доступа к ней
@@ -1407,25 +1657,94 @@ func (s *fileService) CopyFile(ctx context.Context, fileID uuid.UUID, newParentI
 
 		if !hasParentAccess {
 			lg.Error(ctx, "Access denied to parent folder", zap.String("parentID", newParentID.String()), zap.String("userID", userID.String()))
-			return nil, fmt.Errorf("access denied to parent folder")
+			return nil, fmt.Errorf("%w to parent folder", errdefs.ErrPermissionDenied)
 		}
 	}
 
-	// Копируем файл
-	copiedFile, err := s.fileRepo.CopyFile(ctx, fileID, newParentID, newName)
+	source, err := s.fileRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		lg.Error(ctx, "Failed to get source file", zap.Error(err))
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	if newName == "" {
+		newName = source.Name
+	}
+
+	name, existing, err := s.resolveNameConflict(ctx, source.OwnerID, newParentID, newName, source.IsFolder, nil, opts.Conflict)
+	if err != nil {
+		lg.Info(ctx, "File name conflict", zap.Error(err), zap.String("name", newName))
+		return nil, err
+	}
+	if existing != nil && opts.Conflict == models.ConflictNewVersion {
+		if existing.ID == source.ID {
+			// Копия на место самого файла: его содержимое уже текущая версия
+			lg.Info(ctx, "File copied onto itself", zap.String("fileID", fileID.String()))
+			return source, nil
+		}
+		copiedFile, err := s.writeNewVersionFrom(ctx, source, existing, userID)
+		if err != nil {
+			lg.Error(ctx, "Failed to copy file as new version", zap.Error(err))
+			return nil, err
+		}
+		lg.Info(ctx, "File copied as new version", zap.String("originalFileID", fileID.String()), zap.String("targetID", existing.ID.String()))
+		return copiedFile, nil
+	}
+
+	// Копируем файл: если содержимое скопировать не удалось, строка копии удаляется
+	tx := newSaga("copy_file")
+	if existing != nil {
+		if err := s.setAsideExisting(ctx, tx, existing, userID); err != nil {
+			lg.Error(ctx, "Failed to replace existing file", zap.Error(err), zap.String("name", name))
+			return nil, err
+		}
+	}
+
+	// Копия ссылается на содержимое исходного файла, пока не получит свое: журнал
+	// нужен с момента создания ее строки
+	intent, err := s.beginCopy(ctx, source, newParentID, name)
+	if err != nil {
+		lg.Error(ctx, "Failed to journal file copy", zap.Error(err))
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	var copiedFile *models.File
+	err = tx.Step(ctx, "copy_row", func(ctx context.Context) error {
+		copiedFile, err = s.fileRepo.CopyFile(ctx, fileID, newParentID, name)
+		return err
+	}, func(ctx context.Context) error {
+		return s.fileRepo.DeleteFile(ctx, copiedFile.ID)
+	})
+	if isDuplicateName(err) {
+		lg.Info(ctx, "File name taken concurrently", zap.Error(err), zap.String("name", name))
+		s.abortWrite(ctx, tx, intent)
+		return nil, &errdefs.FileExistsError{Name: name}
+	}
 	if err != nil {
 		lg.Error(ctx, "Failed to copy file", zap.Error(err))
+		s.abortWrite(ctx, tx, intent)
 		return nil, fmt.Errorf("failed to copy file: %w", err)
 	}
 
+	// Копия получает собственный путь в хранилище, содержимое из хранилища блобов не дублируется
+	if err := s.copyContent(ctx, tx, fileID, copiedFile, intent); err != nil {
+		lg.Error(ctx, "Failed to copy file content", zap.Error(err))
+		s.abortWrite(ctx, tx, intent)
+		return nil, fmt.Errorf("failed to copy file content: %w", err)
+	}
+	s.finishWrite(ctx, intent)
+	tx.Commit(ctx)
+
 	lg.Info(ctx, "File copied successfully", zap.String("originalFileID", fileID.String()), zap.String("newFileID", copiedFile.ID.String()))
 	return copiedFile, nil
 }
 
-func (s *fileService) RenameFile(ctx context.Context, fileID uuid.UUID, newName string, userID uuid.UUID) error {
+func (s *fileService) RenameFile(ctx context.Context, fileID uuid.UUID, newName string, userID uuid.UUID, opts models.WriteOptions) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "RenameFile called", zap.String("fileID", fileID.String()), zap.String("newName", newName), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Получаем файл из БД
 	file, err := s.fileRepo.GetFileByID(ctx, fileID)
 	if err != nil {
@@ -1442,11 +1761,17 @@ func (s *fileService) RenameFile(ctx context.Context, fileID uuid.UUID, newName
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
+	}
+
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		return err
 	}
 
 	// Обновляем имя файла
 	file.Name = newName
+	file.Version++
 
 	// Обновляем расширение если это файл
 	if !file.IsFolder {
@@ -1469,10 +1794,13 @@ func (s *fileService) RenameFile(ctx context.Context, fileID uuid.UUID, newName
 }
 
 // Операции с метаданными
-func (s *fileService) UpdateFileMetadata(ctx context.Context, fileID uuid.UUID, metadata map[string]interface{}, userID uuid.UUID) error {
+func (s *fileService) UpdateFileMetadata(ctx context.Context, fileID uuid.UUID, metadata map[string]interface{}, userID uuid.UUID, opts models.WriteOptions) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "UpdateFileMetadata called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
 
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
 	// Проверяем права доступа (нужны права на запись)
 	hasAccess, err := s.fileRepo.CheckPermission(ctx, fileID, userID, models.RoleWriter)
 	if err != nil {
@@ -1482,14 +1810,45 @@ func (s *fileService) UpdateFileMetadata(ctx context.Context, fileID uuid.UUID,
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
-	// Обновляем метаданные
-	if err := s.fileRepo.UpdateFileMetadata(ctx, fileID, metadata); err != nil {
+	file, err := s.fileRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		lg.Error(ctx, "Failed to get file from database", zap.Error(err))
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+	if err := checkPrecondition(file, opts); err != nil {
+		lg.Info(ctx, "Write precondition failed", zap.Error(err), zap.String("fileID", fileID.String()))
+		return err
+	}
+	previous, err := s.fileRepo.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		lg.Error(ctx, "Failed to get file metadata", zap.Error(err))
+		return fmt.Errorf("failed to get file metadata: %w", err)
+	}
+
+	// Метаданные хранятся отдельно от строки файла: если версия не увеличилась,
+	// прежние метаданные возвращаются, иначе второй запрос с тем же If-Match прошел бы проверку
+	tx := newSaga("update_metadata")
+	err = tx.Step(ctx, "update_metadata", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFileMetadata(ctx, fileID, metadata)
+	}, func(ctx context.Context) error {
+		return s.fileRepo.UpdateFileMetadata(ctx, fileID, previous)
+	})
+	if err != nil {
 		lg.Error(ctx, "Failed to update file metadata", zap.Error(err))
 		return fmt.Errorf("failed to update file metadata: %w", err)
 	}
+	file.Version++
+	err = tx.Step(ctx, "bump_version", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, file)
+	}, nil)
+	if err != nil {
+		lg.Error(ctx, "Failed to update file version", zap.Error(err))
+		return fmt.Errorf("failed to update file metadata: %w", err)
+	}
+	tx.Commit(ctx)
 
 	lg.Info(ctx, "File metadata updated successfully", zap.String("fileID", fileID.String()))
 	return nil
@@ -1508,7 +1867,7 @@ func (s *fileService) GetFileMetadata(ctx context.Context, fileID uuid.UUID, use
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
+		return nil, errdefs.ErrPermissionDenied
 	}
 
 	// Получаем метаданные
@@ -1536,7 +1895,23 @@ func (s *fileService) VerifyFileIntegrity(ctx context.Context, fileID uuid.UUID,
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return false, fmt.Errorf("access denied")
+		return false, errdefs.ErrPermissionDenied
+	}
+
+	file, err := s.fileRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		lg.Error(ctx, "Failed to get file", zap.Error(err))
+		return false, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	// Поврежденные участки содержимого восстанавливаем по четности до сверки с БД
+	repaired, err := s.repairContent(ctx, file)
+	if err != nil {
+		lg.Error(ctx, "Failed to check file content against parity", zap.Error(err))
+		return false, fmt.Errorf("failed to verify file integrity: %w", err)
+	}
+	if !repaired {
+		return false, nil
 	}
 
 	// Проверяем целостность файла
@@ -1563,7 +1938,7 @@ func (s *fileService) CalculateFileChecksums(ctx context.Context, fileID uuid.UU
 
 	if !hasAccess {
 		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return fmt.Errorf("access denied")
+		return errdefs.ErrPermissionDenied
 	}
 
 	// Вычисляем контрольные суммы
@@ -1577,15 +1952,68 @@ func (s *fileService) CalculateFileChecksums(ctx context.Context, fileID uuid.UU
 	return nil
 }
 
-// generateStoragePath генерирует путь для хранения файла
-func (s *fileService) generateStoragePath(ownerID uuid.UUID, fileID uuid.UUID, fileName string, parentID *uuid.UUID) string {
-	if parentID != nil {
-		parent, err := s.fileRepo.GetFileByID(context.Background(), *parentID)
-		if err == nil && parent != nil && parent.IsFolder {
-			return filepath.Join(parent.StoragePath, fmt.Sprintf("%s_%s", fileID.String(), fileName))
-		}
+// requestContent возвращает источник содержимого запроса на создание файла или nil, если контента нет
+func requestContent(req *models.CreateFileRequest) io.Reader {
+	if req.ContentReader != nil {
+		return req.ContentReader
+	}
+	if len(req.Content) > 0 {
+		return bytes.NewReader(req.Content)
+	}
+	return nil
+}
+
+// applyContentInfo переносит размер и контрольные суммы записанного содержимого в метаданные файла
+func applyContentInfo(file *models.File, info *interfaces.FileInfo) {
+	file.Size = info.Size
+	md5Checksum := info.MD5Checksum
+	sha256Checksum := info.SHA256Checksum
+	file.MD5Checksum = &md5Checksum
+	file.SHA256Checksum = &sha256Checksum
+}
+
+// toRelativePath приводит storage_path из БД к пути относительно директории пользователей хранилища
+func (s *fileService) toRelativePath(storagePath string) string {
+	relativePath := storagePath
+	if strings.HasPrefix(relativePath, s.cfg.Storage.BasePath) {
+		relativePath = strings.TrimPrefix(relativePath, s.cfg.Storage.BasePath)
+		relativePath = strings.TrimPrefix(relativePath, "/")
+		relativePath = strings.TrimPrefix(relativePath, s.cfg.Storage.UserDirName)
+		relativePath = strings.TrimPrefix(relativePath, "/")
 	}
-	return filepath.Join(s.cfg.Storage.BasePath, s.cfg.Storage.UserDirName, ownerID.String(), fmt.Sprintf("%s_%s", fileID.String(), fileName))
+	return relativePath
+}
+
+// toAbsolutePath переводит ключ хранилища в storage_path для БД
+func (s *fileService) toAbsolutePath(key string) string {
+	return filepath.Join(s.cfg.Storage.BasePath, s.cfg.Storage.UserDirName, key)
+}
+
+// sectionReadCloser отдает диапазон файла и закрывает исходный reader
+type sectionReadCloser struct {
+	*io.SectionReader
+	closer io.Closer
+}
+
+func (r *sectionReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+// generateStoragePath генерирует путь для хранения файла: <owner>/ab/cd/<id>.
+// Путь зависит только от ID, поэтому не меняется при перемещении и переименовании
+func (s *fileService) generateStoragePath(ownerID uuid.UUID, fileID uuid.UUID) string {
+	return s.toAbsolutePath(StorageKeyForFile(ownerID, fileID))
+}
+
+// deleteStashPrefix каталог, куда откладывается содержимое удаляемого файла до удаления строки в БД.
+// Остатки после сбоя подбирает проверка хранилища
+const deleteStashPrefix = ".deleting"
+
+// StorageKeyForFile возвращает ключ хранилища файла. Первые символы ID разбивают файлы
+// пользователя по подкаталогам, чтобы не держать все файлы в одной директории
+func StorageKeyForFile(ownerID uuid.UUID, fileID uuid.UUID) string {
+	id := fileID.String()
+	return path.Join(ownerID.String(), id[:2], id[2:4], id)
 }
 
 // GetFileDetails получает детальную информацию о файле из dbmanager по пути
@@ -1618,206 +2046,3 @@ func (s *fileService) GetFileDetails(ctx context.Context, userID uuid.UUID, file
 func (s *fileService) getUserDirPath(userID uuid.UUID) string {
 	return userID.String()
 }
-
-func (s *fileService) findFileRecursively(ctx context.Context, userID uuid.UUID, fileID uuid.UUID) (string, string, error) {
-	// Начинаем поиск с корневой папки пользователя
-	userDir := s.getUserDirPath(userID)
-	return s.findFileRecursivelyHelper(ctx, userID, fileID, userDir)
-}
-
-func (s *fileService) findFileRecursivelyHelper(ctx context.Context, userID uuid.UUID, fileID uuid.UUID, currentPath string) (string, string, error) {
-	lg := logger.GetLoggerFromCtx(ctx)
-
-	// Получаем список файлов в текущей папке
-	fileNames, err := s.storageRepo.ListDirectory(ctx, currentPath)
-	if err != nil {
-		lg.Error(ctx, "Failed to list directory", zap.String("path", currentPath), zap.Error(err))
-		return "", "", fmt.Errorf("failed to list directory: %w", err)
-	}
-
-	// Сначала проверяем файлы в текущей папке
-	for _, fileName := range fileNames {
-		parts := strings.SplitN(fileName, "_", 2)
-		if len(parts) == 2 && parts[0] == fileID.String() {
-			fullPath := filepath.Join(currentPath, fileName)
-			lg.Info(ctx, "File found", zap.String("path", fullPath), zap.String("name", fileName))
-			return fullPath, fileName, nil
-		}
-	}
-
-	// Затем рекурсивно ищем в подпапках
-	for _, fileName := range fileNames {
-		// Проверяем, является ли это папкой (у папок нет расширения или они заканчиваются на /)
-		ext := filepath.Ext(fileName)
-		if ext == "" || strings.HasSuffix(fileName, "/") {
-			subDirPath := filepath.Join(currentPath, fileName)
-			foundPath, foundName, err := s.findFileRecursivelyHelper(ctx, userID, fileID, subDirPath)
-			if err == nil && foundPath != "" {
-				return foundPath, foundName, nil
-			}
-		}
-	}
-
-	return "", "", fmt.Errorf("file not found")
-}
-
-// Возобновляемое скачивание
-func (s *fileService) InitResumableDownload(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (*models.ResumableDownloadSession, error) {
-	lg := logger.GetLoggerFromCtx(ctx)
-	lg.Info(ctx, "InitResumableDownload called", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-
-	// Получаем файл из БД
-	file, err := s.fileRepo.GetFileByID(ctx, fileID)
-	if err != nil {
-		lg.Error(ctx, "Failed to get file from database", zap.Error(err))
-		return nil, fmt.Errorf("failed to get file: %w", err)
-	}
-
-	if file == nil {
-		lg.Error(ctx, "File not found", zap.String("fileID", fileID.String()))
-		return nil, fmt.Errorf("file not found")
-	}
-
-	// Проверяем права доступа (нужны права на чтение)
-	hasAccess, err := s.fileRepo.CheckPermission(ctx, fileID, userID, models.RoleReader)
-	if err != nil {
-		lg.Error(ctx, "Failed to check permission", zap.Error(err))
-		return nil, fmt.Errorf("failed to check permission: %w", err)
-	}
-
-	if !hasAccess {
-		lg.Error(ctx, "Access denied", zap.String("fileID", fileID.String()), zap.String("userID", userID.String()))
-		return nil, fmt.Errorf("access denied")
-	}
-
-	// Проверяем, что это не папка
-	if file.IsFolder {
-		lg.Error(ctx, "Cannot download folder", zap.String("fileID", fileID.String()))
-		return nil, fmt.Errorf("cannot download folder")
-	}
-
-	// Вычисляем контрольную сумму файла
-	relativePath := file.StoragePath
-	if strings.HasPrefix(relativePath, s.cfg.Storage.BasePath) {
-		relativePath = strings.TrimPrefix(relativePath, s.cfg.Storage.BasePath)
-		relativePath = strings.TrimPrefix(relativePath, "/")
-		relativePath = strings.TrimPrefix(relativePath, s.cfg.Storage.UserDirName)
-		relativePath = strings.TrimPrefix(relativePath, "/")
-	}
-
-	checksum, err := s.storageRepo.CalculateChecksum(ctx, relativePath, "sha256")
-	if err != nil {
-		lg.Error(ctx, "Failed to calculate checksum", zap.Error(err))
-		return nil, fmt.Errorf("failed to calculate checksum: %w", err)
-	}
-
-	// Генерируем уникальный ID сессии
-	sessionID := uuid.New().String()
-
-	// Создаем сессию
-	session := &models.ResumableDownloadSession{
-		ID:        sessionID,
-		FileID:    fileID,
-		UserID:    userID,
-		FileName:  file.Name,
-		FilePath:  relativePath,
-		Size:      file.Size,
-		Checksum:  checksum,
-		MimeType:  file.MimeType,
-		ExpiresAt: time.Now().Add(24 * time.Hour), // Сессия действительна 24 часа
-		CreatedAt: time.Now(),
-	}
-
-	// Сохраняем сессию в памяти (в реальном приложении лучше использовать Redis или БД)
-	s.saveResumableDownloadSession(session)
-
-	lg.Info(ctx, "Resumable download session created", zap.String("sessionID", sessionID))
-	return session, nil
-}
-
-func (s *fileService) GetResumableDownloadSession(ctx context.Context, sessionID string) (*models.ResumableDownloadSession, error) {
-	lg := logger.GetLoggerFromCtx(ctx)
-	lg.Info(ctx, "GetResumableDownloadSession called", zap.String("sessionID", sessionID))
-
-	session := s.getResumableDownloadSession(sessionID)
-	if session == nil {
-		lg.Error(ctx, "Session not found", zap.String("sessionID", sessionID))
-		return nil, fmt.Errorf("session not found")
-	}
-
-	// Проверяем, не истекла ли сессия
-	if time.Now().After(session.ExpiresAt) {
-		lg.Error(ctx, "Session expired", zap.String("sessionID", sessionID))
-		s.deleteResumableDownloadSession(sessionID)
-		return nil, fmt.Errorf("session expired")
-	}
-
-	return session, nil
-}
-
-func (s *fileService) DownloadFileChunk(ctx context.Context, sessionID string, start, end uint64) (io.ReadCloser, error) {
-	lg := logger.GetLoggerFromCtx(ctx)
-	lg.Info(ctx, "DownloadFileChunk called", zap.String("sessionID", sessionID), zap.Uint64("start", start), zap.Uint64("end", end))
-
-	session := s.getResumableDownloadSession(sessionID)
-	if session == nil {
-		lg.Error(ctx, "Session not found", zap.String("sessionID", sessionID))
-		return nil, fmt.Errorf("session not found")
-	}
-
-	// Проверяем, не истекла ли сессия
-	if time.Now().After(session.ExpiresAt) {
-		lg.Error(ctx, "Session expired", zap.String("sessionID", sessionID))
-		s.deleteResumableDownloadSession(sessionID)
-		return nil, fmt.Errorf("session expired")
-	}
-
-	// Проверяем валидность диапазона
-	if start >= uint64(session.Size) || end >= uint64(session.Size) || start > end {
-		lg.Error(ctx, "Invalid range", zap.Uint64("start", start), zap.Uint64("end", end), zap.Int64("fileSize", session.Size))
-		return nil, fmt.Errorf("invalid range")
-	}
-
-	// Получаем файл из хранилища
-	content, err := s.storageRepo.GetFile(ctx, session.FilePath)
-	if err != nil {
-		lg.Error(ctx, "Failed to get file from storage", zap.Error(err))
-		return nil, fmt.Errorf("failed to get file: %w", err)
-	}
-
-	// Создаем reader для нужного диапазона
-	chunkSize := end - start + 1
-	if start+chunkSize > uint64(len(content)) {
-		chunkSize = uint64(len(content)) - start
-	}
-
-	chunk := content[start : start+chunkSize]
-	return io.NopCloser(bytes.NewReader(chunk)), nil
-}
-
-func (s *fileService) DeleteResumableDownloadSession(ctx context.Context, sessionID string) error {
-	lg := logger.GetLoggerFromCtx(ctx)
-	lg.Info(ctx, "DeleteResumableDownloadSession called", zap.String("sessionID", sessionID))
-
-	s.deleteResumableDownloadSession(sessionID)
-	return nil
-}
-
-// Вспомогательные методы для работы с сессиями в памяти
-func (s *fileService) saveResumableDownloadSession(session *models.ResumableDownloadSession) {
-	s.sessionMutex.Lock()
-	defer s.sessionMutex.Unlock()
-	s.resumableSessions[session.ID] = session
-}
-
-func (s *fileService) getResumableDownloadSession(sessionID string) *models.ResumableDownloadSession {
-	s.sessionMutex.RLock()
-	defer s.sessionMutex.RUnlock()
-	return s.resumableSessions[sessionID]
-}
-
-func (s *fileService) deleteResumableDownloadSession(sessionID string) {
-	s.sessionMutex.Lock()
-	defer s.sessionMutex.Unlock()
-	delete(s.resumableSessions, sessionID)
-}
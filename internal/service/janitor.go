@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+const defaultJanitorInterval = time.Hour
+
+// Janitor в фоне удаляет истекшие записи временных хранилищ: сессии возобновляемой загрузки
+// с брошенными частями, сохраненные ответы на запросы с Idempotency-Key и временные
+// файлы хранилища, оставшиеся от прерванных записей.
+// Иначе они копились бы во временной директории бесконечно
+type Janitor struct {
+	stores   []interfaces.ExpiringStore
+	interval time.Duration
+}
+
+// NewJanitor создает фоновую очистку перечисленных хранилищ
+func NewJanitor(cfg *config.Config, stores ...interfaces.ExpiringStore) *Janitor {
+	interval := cfg.Storage.Uploads.JanitorInterval
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+	return &Janitor{stores: stores, interval: interval}
+}
+
+// Run очищает хранилища сразу и затем каждые interval. Завершается с отменой ctx
+func (j *Janitor) Run(ctx context.Context) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "Janitor started", zap.Duration("interval", j.interval))
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.Cleanup(ctx)
+
+		select {
+		case <-ctx.Done():
+			lg.Info(ctx, "Janitor stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup выполняет один проход очистки
+func (j *Janitor) Cleanup(ctx context.Context) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	removed := 0
+	for _, store := range j.stores {
+		n, err := store.RemoveExpired(ctx)
+		if err != nil {
+			lg.Error(ctx, "Failed to remove expired records", zap.Error(err))
+		}
+		removed += n
+	}
+	if removed > 0 {
+		lg.Info(ctx, "Expired records removed", zap.Int("removed", removed))
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// LayoutMigration переносит файлы из старой раскладки, повторяющей дерево папок
+// (<owner>/<folderID>_<folder>/<fileID>_<name>), в раскладку по ID (<owner>/ab/cd/<id>)
+// и обновляет storage_path в dbmanager. Повторный запуск безопасен: уже перенесенные
+// файлы и блобы пропускаются
+type LayoutMigration struct {
+	fileRepo    interfaces.FileRepository
+	storageRepo interfaces.StorageRepository
+	files       *fileService
+	// DryRun только считает, что будет перенесено, ничего не меняя
+	DryRun bool
+}
+
+// LayoutMigrationReport итог миграции одного пользователя
+type LayoutMigrationReport struct {
+	OwnerID  uuid.UUID `json:"owner_id"`
+	Total    int       `json:"total"`
+	Migrated int       `json:"migrated"`
+	Skipped  int       `json:"skipped"`
+	Failed   int       `json:"failed"`
+	Errors   []string  `json:"errors,omitempty"`
+}
+
+// NewLayoutMigration создает миграцию раскладки хранилища
+func NewLayoutMigration(fileRepo interfaces.FileRepository, storageRepo interfaces.StorageRepository, blobStore interfaces.BlobStore, cfg *config.Config) *LayoutMigration {
+	return &LayoutMigration{
+		fileRepo:    fileRepo,
+		storageRepo: storageRepo,
+		files: &fileService{
+			fileRepo:    fileRepo,
+			storageRepo: storageRepo,
+			blobStore:   blobStore,
+			cfg:         cfg,
+		},
+	}
+}
+
+// Owners возвращает пользователей, у которых есть каталог в хранилище
+func (m *LayoutMigration) Owners(ctx context.Context) ([]uuid.UUID, error) {
+	return m.files.storageOwners(ctx)
+}
+
+// MigrateOwner переносит все файлы пользователя, включая файлы в корзине
+func (m *LayoutMigration) MigrateOwner(ctx context.Context, ownerID uuid.UUID) (*LayoutMigrationReport, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "Storage layout migration started", zap.String("ownerID", ownerID.String()), zap.Bool("dryRun", m.DryRun))
+
+	files, err := m.files.ownerFiles(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LayoutMigrationReport{OwnerID: ownerID, Total: len(files)}
+	var legacyDirs []string
+	for i := range files {
+		file := &files[i]
+		oldPath := file.StoragePath
+
+		migrated, err := m.migrateFile(ctx, file)
+		switch {
+		case err != nil:
+			report.Failed++
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", file.ID, err))
+			lg.Error(ctx, "Failed to migrate file", zap.Error(err), zap.String("fileID", file.ID.String()))
+		case migrated:
+			report.Migrated++
+			if file.IsFolder {
+				legacyDirs = append(legacyDirs, m.files.toRelativePath(oldPath))
+			}
+		default:
+			report.Skipped++
+		}
+	}
+
+	if !m.DryRun {
+		m.removeLegacyDirs(ctx, legacyDirs)
+	}
+
+	lg.Info(ctx, "Storage layout migration finished",
+		zap.String("ownerID", ownerID.String()),
+		zap.Int("migrated", report.Migrated),
+		zap.Int("skipped", report.Skipped),
+		zap.Int("failed", report.Failed))
+	return report, nil
+}
+
+// migrateFile переносит содержимое файла по пути из ID. Сначала перемещаются данные,
+// затем обновляется БД: при сбое между шагами повторный запуск найдет исходный путь
+// пустым и только допишет storage_path
+func (m *LayoutMigration) migrateFile(ctx context.Context, file *models.File) (bool, error) {
+	target := m.files.generateStoragePath(file.OwnerID, file.ID)
+	if file.StoragePath == target {
+		// Прошлый запуск мог прерваться, не перенеся ревизии
+		if file.IsFolder {
+			return false, nil
+		}
+		return m.migrateRevisions(ctx, file)
+	}
+	// Блобы уже не зависят от дерева папок
+	if _, ok := m.files.blobSHA(file.StoragePath); ok {
+		return false, nil
+	}
+	if m.DryRun {
+		return true, nil
+	}
+
+	oldPath := file.StoragePath
+	moved := false
+	if !file.IsFolder && oldPath != "" {
+		err := m.storageRepo.MoveFile(ctx, m.files.toRelativePath(oldPath), m.files.toRelativePath(target))
+		if err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+			return false, err
+		}
+		moved = err == nil
+	}
+
+	file.StoragePath = target
+	if err := m.fileRepo.UpdateFile(ctx, file); err != nil {
+		if moved {
+			m.storageRepo.MoveFile(ctx, m.files.toRelativePath(target), m.files.toRelativePath(oldPath))
+		}
+		return false, fmt.Errorf("failed to update storage path: %w", err)
+	}
+
+	if !file.IsFolder && oldPath != "" {
+		if _, err := m.migrateRevisions(ctx, file); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// migrateRevisions перенаправляет ревизии со старых путей файла на новый. Обновления
+// ревизий в dbmanager нет, поэтому сначала создается запись с новым ID на новом пути,
+// и только потом удаляется старая: при сбое между шагами ревизия не пропадает,
+// а повторный запуск найдет перенесенную копию и удалит лишнюю запись
+func (m *LayoutMigration) migrateRevisions(ctx context.Context, file *models.File) (bool, error) {
+	revisions, err := m.fileRepo.GetRevisions(ctx, file.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get revisions: %w", err)
+	}
+
+	migrated := make(map[int64]bool)
+	for _, revision := range revisions {
+		if revision.StoragePath == file.StoragePath {
+			migrated[revision.RevisionID] = true
+		}
+	}
+
+	changed := false
+	for _, revision := range revisions {
+		if !m.legacyPath(file, revision.StoragePath) {
+			continue
+		}
+		changed = true
+		if m.DryRun {
+			continue
+		}
+
+		if !migrated[revision.RevisionID] {
+			moved := revision
+			moved.ID = uuid.New()
+			moved.StoragePath = file.StoragePath
+			if err := m.fileRepo.CreateRevision(ctx, &moved); err != nil {
+				return true, fmt.Errorf("failed to recreate revision %d: %w", revision.RevisionID, err)
+			}
+			migrated[revision.RevisionID] = true
+		}
+		if err := m.fileRepo.DeleteRevision(ctx, revision.ID); err != nil && !errdefs.Is(err, errdefs.ErrRevisionNotFound) {
+			return true, fmt.Errorf("failed to delete revision %d: %w", revision.RevisionID, err)
+		}
+	}
+	return changed, nil
+}
+
+// legacyPath путь старой раскладки для файла: имя в ней начинается с "<fileID>_"
+func (m *LayoutMigration) legacyPath(file *models.File, storagePath string) bool {
+	if storagePath == "" || storagePath == file.StoragePath {
+		return false
+	}
+	if _, ok := m.files.blobSHA(storagePath); ok {
+		return false
+	}
+	return strings.HasPrefix(filepath.Base(storagePath), file.ID.String()+"_")
+}
+
+// removeLegacyDirs удаляет опустевшие каталоги старой раскладки, начиная с самых глубоких.
+// Каталоги, в которых что-то осталось, не трогаются
+func (m *LayoutMigration) removeLegacyDirs(ctx context.Context, dirs []string) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+	for _, dir := range dirs {
+		if dir == "" || path.Dir(dir) == "." {
+			continue
+		}
+		entries, err := m.storageRepo.ListDirectory(ctx, dir)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := m.storageRepo.DeleteDirectory(ctx, dir); err != nil {
+			lg.Error(ctx, "Failed to remove legacy directory", zap.Error(err), zap.String("path", dir))
+		}
+	}
+}
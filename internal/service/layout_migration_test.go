@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutMigrationMovesLegacyFiles(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	usersDir := filepath.Join(cfg.Storage.BasePath, "users")
+
+	ownerID := uuid.New()
+	folder := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "docs", IsFolder: true}
+	folder.StoragePath = filepath.Join(usersDir, ownerID.String(), folder.ID.String()+"_docs")
+	file := &models.File{ID: uuid.New(), OwnerID: ownerID, ParentID: &folder.ID, Name: "a.txt", IsTrashed: true}
+	file.StoragePath = filepath.Join(folder.StoragePath, file.ID.String()+"_a.txt")
+	fileRepo.files[folder.ID] = folder
+	fileRepo.files[file.ID] = file
+	fileRepo.revisions[file.ID] = []models.FileRevision{{ID: uuid.New(), FileID: file.ID, RevisionID: 1, StoragePath: file.StoragePath}}
+
+	require.NoError(t, os.MkdirAll(folder.StoragePath, 0755))
+	require.NoError(t, os.WriteFile(file.StoragePath, []byte("hello"), 0644))
+
+	migration := NewLayoutMigration(fileRepo, storageRepo, repository.NewBlobStore(storageRepo), cfg)
+
+	owners, err := migration.Owners(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ownerID}, owners)
+
+	// Dry-run ничего не меняет
+	migration.DryRun = true
+	report, err := migration.MigrateOwner(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Migrated)
+	assert.FileExists(t, file.StoragePath)
+
+	// Сбой после пересоздания ревизии: старая запись остается рядом с новой
+	migration.DryRun = false
+	fileRepo.failures["DeleteRevision"] = errors.New("dbmanager unavailable")
+	report, err = migration.MigrateOwner(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Migrated)
+	assert.Equal(t, 1, report.Failed)
+	assert.Len(t, fileRepo.revisions[file.ID], 2)
+
+	// Повторный запуск удаляет лишнюю запись и не создает новых
+	delete(fileRepo.failures, "DeleteRevision")
+	report, err = migration.MigrateOwner(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Migrated)
+	assert.Zero(t, report.Failed)
+	require.Len(t, fileRepo.revisions[file.ID], 1)
+	assert.Equal(t, int64(1), fileRepo.revisions[file.ID][0].RevisionID)
+
+	target := StorageKeyForFile(ownerID, file.ID)
+	content, err := os.ReadFile(filepath.Join(usersDir, target))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+	assert.Equal(t, filepath.Join(usersDir, target), fileRepo.files[file.ID].StoragePath)
+	assert.Equal(t, fileRepo.files[file.ID].StoragePath, fileRepo.revisions[file.ID][0].StoragePath)
+
+	// Опустевший каталог старой папки удален
+	assert.NoDirExists(t, folder.StoragePath)
+
+	// Повторный запуск ничего не переносит
+	report, err = migration.MigrateOwner(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Skipped)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// resolveNameConflict проверяет, свободно ли имя name в папке parentID, и применяет политику.
+// self - сам перемещаемый файл, он своему имени не мешает. Возвращает имя, под которым сохранять,
+// и файл, занявший имя, если политика ConflictReplace или ConflictNewVersion требует что-то с ним сделать.
+// Файл и папка не заменяют друг друга и не бывают новой версией друг друга: для них
+// ConflictReplace и ConflictNewVersion дают ErrFileExists. Иначе файл, записанный поверх
+// одноименной папки, удалил бы вместе с ней все ее содержимое
+func (s *fileService) resolveNameConflict(ctx context.Context, ownerID uuid.UUID, parentID *uuid.UUID, name string, isFolder bool, self *uuid.UUID, policy models.ConflictPolicy) (string, *models.File, error) {
+	siblings, err := s.fileRepo.ListFilesByParent(ctx, ownerID, parentID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list folder contents: %w", err)
+	}
+
+	taken := make(map[string]bool, len(siblings))
+	var existing *models.File
+	for i := range siblings {
+		if self != nil && siblings[i].ID == *self {
+			continue
+		}
+		taken[siblings[i].Name] = true
+		if siblings[i].Name == name {
+			existing = &siblings[i]
+		}
+	}
+	if existing == nil {
+		return name, nil, nil
+	}
+
+	switch {
+	case policy == models.ConflictRename:
+		return freeName(name, taken), nil, nil
+	case (policy == models.ConflictReplace || policy == models.ConflictNewVersion) && existing.IsFolder == isFolder:
+		return name, existing, nil
+	default:
+		return "", nil, &errdefs.FileExistsError{Name: name, ExistingID: existing.ID, Suggestion: freeName(name, taken)}
+	}
+}
+
+// freeName подбирает незанятое имя вида "name (2).ext"
+func freeName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		// Файлы вида ".bashrc" целиком считаются именем
+		base, ext = name, ""
+	}
+
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// setAsideExisting освобождает имя для ConflictReplace как шаг операции tx: файл, занявший имя,
+// переименовывается, а удаляется безвозвратно только после успешного завершения операции.
+// При откате файл получает свое имя обратно
+func (s *fileService) setAsideExisting(ctx context.Context, tx *saga, existing *models.File, userID uuid.UUID) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	hasAccess, err := s.fileRepo.CheckPermission(ctx, existing.ID, userID, models.RoleWriter)
+	if err != nil {
+		return fmt.Errorf("failed to check permission: %w", err)
+	}
+	if !hasAccess {
+		return fmt.Errorf("%w: cannot replace %q", errdefs.ErrPermissionDenied, existing.Name)
+	}
+
+	aside := *existing
+	aside.Name = ".replaced-" + existing.ID.String()
+	err = tx.Step(ctx, "set_aside_existing", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, &aside)
+	}, func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, existing)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set aside existing file: %w", err)
+	}
+
+	tx.OnCommit(func(ctx context.Context) {
+		if err := s.deleteFileRecursiveHelper(ctx, &aside, userID); err != nil {
+			lg.Error(ctx, "Failed to delete replaced file", zap.Error(err), zap.String("fileID", existing.ID.String()))
+			return
+		}
+		lg.Info(ctx, "Replaced file deleted", zap.String("fileID", existing.ID.String()), zap.String("name", existing.Name))
+	})
+	return nil
+}
+
+// renameStep переименовывает файл как шаг операции tx (ConflictRename при перемещении)
+func (s *fileService) renameStep(ctx context.Context, tx *saga, file *models.File, name string) error {
+	renamed := *file
+	renamed.Name = name
+	if !file.IsFolder {
+		renamed.FileExtension = nil
+		if ext := filepath.Ext(name); ext != "" {
+			renamed.FileExtension = &ext
+		}
+	}
+
+	err := tx.Step(ctx, "rename_row", func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, &renamed)
+	}, func(ctx context.Context) error {
+		return s.fileRepo.UpdateFile(ctx, file)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
+}
+
+// writeNewVersionFrom записывает содержимое source новой версией existing (ConflictNewVersion
+// при перемещении и копировании). Содержимое папок так не объединяется
+func (s *fileService) writeNewVersionFrom(ctx context.Context, source, existing *models.File, userID uuid.UUID) (*models.File, error) {
+	if source.IsFolder {
+		return nil, fmt.Errorf("%w: folders cannot be merged, use another conflict policy", errdefs.ErrInvalidInput)
+	}
+
+	content, err := s.openContent(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source content: %w", err)
+	}
+	defer content.Close()
+
+	if _, err := s.UploadFile(ctx, existing.ID, content, userID, models.WriteOptions{}); err != nil {
+		return nil, err
+	}
+	return s.fileRepo.GetFileByID(ctx, existing.ID)
+}
+
+// isDuplicateName ошибка уникального индекса dbmanager: имя заняли между проверкой и созданием
+func isDuplicateName(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
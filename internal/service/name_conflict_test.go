@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFileConflictPolicies(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo)})
+
+	ownerID := uuid.New()
+	parentID := uuid.New()
+	create := func(content string, policy models.ConflictPolicy) (*models.File, error) {
+		return svc.CreateFile(ctx, &models.CreateFileRequest{
+			Name:          "report.txt",
+			ParentID:      &parentID,
+			ContentReader: strings.NewReader(content),
+			Conflict:      policy,
+		}, ownerID)
+	}
+	readContent := func(fileID uuid.UUID) string {
+		content, err := svc.GetFileContent(ctx, fileID, ownerID)
+		require.NoError(t, err)
+		defer content.Close()
+		data, err := io.ReadAll(content)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	original, err := create("v1", "")
+	require.NoError(t, err)
+
+	// По умолчанию занятое имя - типизированная ошибка с подсказкой
+	_, err = create("v2", models.ConflictFail)
+	require.ErrorIs(t, err, errdefs.ErrFileExists)
+	var exists *errdefs.FileExistsError
+	require.ErrorAs(t, err, &exists)
+	assert.Equal(t, original.ID, exists.ExistingID)
+	assert.Equal(t, "report (2).txt", exists.Suggestion)
+
+	renamed, err := create("v2", models.ConflictRename)
+	require.NoError(t, err)
+	assert.Equal(t, "report (2).txt", renamed.Name)
+
+	// Новая версия того же файла: ID сохраняется, версия растет
+	versioned, err := create("v3", models.ConflictNewVersion)
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, versioned.ID)
+	assert.Equal(t, int64(2), versioned.Version)
+	assert.Equal(t, "v3", readContent(original.ID))
+
+	// Замена: прежний файл удаляется, имя занимает новый
+	replaced, err := create("v4", models.ConflictReplace)
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, replaced.ID)
+	assert.Equal(t, "report.txt", replaced.Name)
+	assert.NotContains(t, fileRepo.files, original.ID)
+	assert.Equal(t, "v4", readContent(replaced.ID))
+
+	// Папку нельзя сделать новой версией файла или заменить ей файл
+	_, err = svc.CreateFolder(ctx, "report.txt", &parentID, ownerID, models.ConflictNewVersion)
+	require.ErrorIs(t, err, errdefs.ErrFileExists)
+	_, err = svc.CreateFolder(ctx, "report.txt", &parentID, ownerID, models.ConflictReplace)
+	require.ErrorIs(t, err, errdefs.ErrFileExists)
+	assert.Contains(t, fileRepo.files, replaced.ID)
+
+	// Файл поверх папки не удаляет ее вместе с содержимым
+	folder, err := svc.CreateFolder(ctx, "archive", &parentID, ownerID, models.ConflictFail)
+	require.NoError(t, err)
+	_, err = svc.CreateFile(ctx, &models.CreateFileRequest{
+		Name:          "archive",
+		ParentID:      &parentID,
+		ContentReader: strings.NewReader("file"),
+		Conflict:      models.ConflictReplace,
+	}, ownerID)
+	require.ErrorIs(t, err, errdefs.ErrFileExists)
+	assert.Contains(t, fileRepo.files, folder.ID)
+}
+
+func TestMoveFileAsNewVersionFailsWhenSourceStays(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{})
+
+	ownerID := uuid.New()
+	sourceParent, err := svc.CreateFolder(ctx, "inbox", nil, ownerID, models.ConflictFail)
+	require.NoError(t, err)
+	targetParent, err := svc.CreateFolder(ctx, "archive", nil, ownerID, models.ConflictFail)
+	require.NoError(t, err)
+	create := func(content string, parentID *uuid.UUID) *models.File {
+		file, err := svc.CreateFile(ctx, &models.CreateFileRequest{
+			Name:          "report.txt",
+			ParentID:      parentID,
+			ContentReader: strings.NewReader(content),
+		}, ownerID)
+		require.NoError(t, err)
+		return file
+	}
+	source := create("moved", &sourceParent.ID)
+	target := create("old", &targetParent.ID)
+
+	// Исходный файл не удалился: перемещение не считается выполненным
+	fileRepo.failures["DeleteFile"] = errors.New("database is unavailable")
+	err = svc.MoveFile(ctx, source.ID, &targetParent.ID, ownerID, models.WriteOptions{Conflict: models.ConflictNewVersion})
+	require.ErrorContains(t, err, "failed to delete moved file")
+	assert.Contains(t, fileRepo.files, source.ID)
+
+	delete(fileRepo.failures, "DeleteFile")
+	require.NoError(t, svc.MoveFile(ctx, source.ID, &targetParent.ID, ownerID, models.WriteOptions{Conflict: models.ConflictNewVersion}))
+	assert.NotContains(t, fileRepo.files, source.ID)
+	assert.Equal(t, int64(3), fileRepo.files[target.ID].Version)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxParityFolderDepth ограничивает подъем по родительским папкам: цикл в данных не должен зациклить запись
+const maxParityFolderDepth = 64
+
+// parityEnabled определяет, нужна ли файлу четность: для всех файлов, для файлов перечисленных
+// пользователей или для файлов в перечисленных папках и их подпапках
+func (s *fileService) parityEnabled(ctx context.Context, file *models.File) bool {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if s.parityStore == nil {
+		return false
+	}
+	cfg := s.cfg.Storage.Parity
+	if cfg.Enabled || containsID(cfg.Users, file.OwnerID) {
+		return true
+	}
+	if len(cfg.Folders) == 0 {
+		return false
+	}
+
+	parentID := file.ParentID
+	for depth := 0; parentID != nil && depth < maxParityFolderDepth; depth++ {
+		if containsID(cfg.Folders, *parentID) {
+			return true
+		}
+		parent, err := s.fileRepo.GetFileByID(ctx, *parentID)
+		if err != nil || parent == nil {
+			lg.Error(ctx, "Failed to resolve parent folder for parity", zap.Error(err), zap.String("fileID", file.ID.String()))
+			return false
+		}
+		parentID = parent.ParentID
+	}
+	return false
+}
+
+// protectContent пишет четность для текущего содержимого файла. Ошибки только логируются:
+// содержимое уже сохранено, без четности оно лишь не сможет само восстановиться
+func (s *fileService) protectContent(ctx context.Context, file *models.File) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if file.IsFolder || file.StoragePath == "" || file.SHA256Checksum == nil || !s.parityEnabled(ctx, file) {
+		return
+	}
+	if err := s.parityStore.Protect(ctx, s.toRelativePath(file.StoragePath), *file.SHA256Checksum); err != nil {
+		lg.Error(ctx, "Failed to write parity", zap.Error(err), zap.String("fileID", file.ID.String()))
+		return
+	}
+	lg.Debug(ctx, "Parity written", zap.String("fileID", file.ID.String()), zap.String("sha256", *file.SHA256Checksum))
+}
+
+// repairContent проверяет содержимое файла по четности и восстанавливает поврежденные участки.
+// Возвращает false, если содержимое повреждено и восстановить его не удалось
+func (s *fileService) repairContent(ctx context.Context, file *models.File) (bool, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if s.parityStore == nil || file.IsFolder || file.StoragePath == "" || file.SHA256Checksum == nil {
+		return true, nil
+	}
+
+	result, err := s.parityStore.Repair(ctx, s.toRelativePath(file.StoragePath), *file.SHA256Checksum)
+	if err != nil {
+		return false, err
+	}
+	if !result.Protected {
+		return true, nil
+	}
+
+	fields := []zap.Field{
+		zap.String("fileID", file.ID.String()),
+		zap.Any("damaged", result.Damaged),
+		zap.Bool("parityRebuilt", result.ParityRebuilt),
+	}
+	switch {
+	case len(result.Damaged) == 0:
+		if result.ParityRebuilt {
+			lg.Info(ctx, "Damaged parity rebuilt", fields...)
+		}
+		return true, nil
+	case result.Repaired:
+		lg.Info(ctx, "File content repaired from parity", fields...)
+		return true, nil
+	default:
+		lg.Error(ctx, "File content is damaged beyond parity repair", fields...)
+		return false, nil
+	}
+}
+
+// containsID проверяет, есть ли id среди строковых ID из конфигурации
+func containsID(ids []string, id uuid.UUID) bool {
+	for _, s := range ids {
+		if parsed, err := uuid.Parse(s); err == nil && parsed == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyFileIntegrityRepairsFromFolderParity(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	cfg.Storage.Parity.ShardSize = 8
+
+	usersDir := filepath.Join(cfg.Storage.BasePath, "users")
+	parityStore, err := repository.NewParityStore(storageRepo, cfg)
+	require.NoError(t, err)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{ParityStore: parityStore})
+
+	ownerID := uuid.New()
+	create := func(req *models.CreateFileRequest) *models.File {
+		file, err := svc.CreateFile(ctx, req, ownerID)
+		require.NoError(t, err)
+		return file
+	}
+	protected := create(&models.CreateFileRequest{Name: "photos", IsFolder: true})
+	cfg.Storage.Parity.Folders = []string{protected.ID.String()}
+	nested := create(&models.CreateFileRequest{Name: "2024", IsFolder: true, ParentID: &protected.ID})
+
+	photo := create(&models.CreateFileRequest{Name: "beach.jpg", ParentID: &nested.ID, ContentReader: strings.NewReader("sand, sea and sun")})
+	plain := create(&models.CreateFileRequest{Name: "notes.txt", ContentReader: strings.NewReader("not protected")})
+	assert.FileExists(t, filepath.Join(usersDir, parityStore.Key(*photo.SHA256Checksum)))
+	assert.NoFileExists(t, filepath.Join(usersDir, parityStore.Key(*plain.SHA256Checksum)))
+
+	// Порча содержимого исправляется при проверке целостности
+	contentPath := filepath.Join(usersDir, StorageKeyForFile(ownerID, photo.ID))
+	require.NoError(t, os.WriteFile(contentPath, []byte("sand, SEA and sun"), 0644))
+	ok, err := svc.VerifyFileIntegrity(ctx, photo.ID, ownerID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	repaired, err := os.ReadFile(contentPath)
+	require.NoError(t, err)
+	assert.Equal(t, "sand, sea and sun", string(repaired))
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// fileLocks блокировки отдельных файлов внутри процесса. Без них между проверкой версии
+// и записью мог бы вклиниться другой запрос, и оба изменения прошли бы с одной версией
+type fileLocks struct {
+	mu    sync.Mutex
+	locks map[uuid.UUID]*fileLock
+}
+
+type fileLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock захватывает блокировку файла и возвращает функцию ее освобождения
+func (l *fileLocks) lock(id uuid.UUID) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[uuid.UUID]*fileLock)
+	}
+	fl, ok := l.locks[id]
+	if !ok {
+		fl = &fileLock{}
+		l.locks[id] = fl
+	}
+	fl.refs++
+	l.mu.Unlock()
+
+	fl.mu.Lock()
+	return func() {
+		fl.mu.Unlock()
+		l.mu.Lock()
+		fl.refs--
+		if fl.refs == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// checkPrecondition сверяет файл с условиями операции
+func checkPrecondition(file *models.File, opts models.WriteOptions) error {
+	if opts.ExpectedVersion != 0 && file.Version != opts.ExpectedVersion {
+		return fmt.Errorf("%w: expected version %d, current version %d", errdefs.ErrPreconditionFailed, opts.ExpectedVersion, file.Version)
+	}
+	if opts.ExpectedSHA256 != "" && (file.SHA256Checksum == nil || *file.SHA256Checksum != opts.ExpectedSHA256) {
+		return fmt.Errorf("%w: content has changed", errdefs.ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// bumpVersion увеличивает версию файла после изменения, которое не проходит через
+// UpdateFile (перемещение). Без этого второй запрос с тем же If-Match
+// тоже прошел бы проверку
+func (s *fileService) bumpVersion(ctx context.Context, fileID uuid.UUID) error {
+	file, err := s.fileRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+	file.Version++
+	if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
+		return fmt.Errorf("failed to update file version: %w", err)
+	}
+	return nil
+}
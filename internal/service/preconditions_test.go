@@ -0,0 +1,84 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalWritesRejectStaleVersion(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo)})
+
+	ownerID := uuid.New()
+	file := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "notes.txt", Version: 1}
+	fileRepo.files[file.ID] = file
+	fileRepo.permissions = append(fileRepo.permissions, models.FilePermission{FileID: file.ID, GranteeID: &ownerID, Role: models.RoleOwner})
+
+	// Оба устройства прочитали версию 1, первое сохраняет
+	result, err := svc.UploadFile(ctx, file.ID, strings.NewReader("from laptop"), ownerID, models.WriteOptions{ExpectedVersion: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Version)
+	saved := fileRepo.files[file.ID]
+	assert.Equal(t, int64(2), saved.Version)
+	laptopSHA := *saved.SHA256Checksum
+
+	// Второе устройство получает отказ, содержимое первого не затерто
+	_, err = svc.UploadFile(ctx, file.ID, strings.NewReader("from phone"), ownerID, models.WriteOptions{ExpectedVersion: 1})
+	assert.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+	assert.Equal(t, int64(2), fileRepo.files[file.ID].Version)
+	assert.Equal(t, laptopSHA, *fileRepo.files[file.ID].SHA256Checksum)
+
+	// Условие по содержимому
+	err = svc.RenameFile(ctx, file.ID, "phone.txt", ownerID, models.WriteOptions{ExpectedSHA256: "stale"})
+	assert.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+	require.NoError(t, svc.RenameFile(ctx, file.ID, "laptop.txt", ownerID, models.WriteOptions{ExpectedSHA256: laptopSHA}))
+	assert.Equal(t, "laptop.txt", fileRepo.files[file.ID].Name)
+
+	err = svc.UpdateFileMetadata(ctx, file.ID, map[string]interface{}{"color": "red"}, ownerID, models.WriteOptions{ExpectedVersion: 1})
+	assert.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+	assert.Nil(t, fileRepo.metadata[file.ID])
+
+	// Переименование, перемещение и метаданные тоже дают новую версию:
+	// второй запрос с тем же If-Match отклоняется
+	require.Equal(t, int64(3), fileRepo.files[file.ID].Version)
+	require.NoError(t, svc.UpdateFileMetadata(ctx, file.ID, map[string]interface{}{"color": "red"}, ownerID, models.WriteOptions{ExpectedVersion: 3}))
+	err = svc.RenameFile(ctx, file.ID, "phone.txt", ownerID, models.WriteOptions{ExpectedVersion: 3})
+	assert.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+
+	folderID := uuid.New()
+	fileRepo.files[folderID] = &models.File{ID: folderID, OwnerID: ownerID, Name: "docs", IsFolder: true}
+	fileRepo.permissions = append(fileRepo.permissions, models.FilePermission{FileID: folderID, GranteeID: &ownerID, Role: models.RoleOwner})
+	require.NoError(t, svc.MoveFile(ctx, file.ID, &folderID, ownerID, models.WriteOptions{ExpectedVersion: 4}))
+	err = svc.MoveFile(ctx, file.ID, nil, ownerID, models.WriteOptions{ExpectedVersion: 4})
+	assert.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+	assert.Equal(t, folderID, *fileRepo.files[file.ID].ParentID)
+
+	revision, err := svc.CreateRevision(ctx, file.ID, ownerID)
+	require.NoError(t, err)
+
+	// Без условий запись проходит как раньше
+	_, err = svc.UploadFile(ctx, file.ID, strings.NewReader("from phone"), ownerID, models.WriteOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), fileRepo.files[file.ID].Version)
+
+	// Восстановление ревизии - такая же запись: проверяет версию и увеличивает ее
+	revisionID := revision.RevisionID
+	err = svc.RestoreRevision(ctx, file.ID, revisionID, ownerID, models.WriteOptions{ExpectedVersion: 5})
+	assert.ErrorIs(t, err, errdefs.ErrPreconditionFailed)
+	require.NoError(t, svc.RestoreRevision(ctx, file.ID, revisionID, ownerID, models.WriteOptions{ExpectedVersion: 6}))
+	restored := fileRepo.files[file.ID]
+	assert.Equal(t, int64(7), restored.Version)
+	// Без content_addressed ревизия лежит по пути файла: размер и SHA-256 описывают
+	// то, что там сейчас, а не прежние значения из ревизии
+	assert.Equal(t, int64(len("from phone")), restored.Size)
+	assert.NotEqual(t, laptopSHA, *restored.SHA256Checksum)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+
+	"homecloud-file-service/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// saga выполняет операцию, затрагивающую и dbmanager, и хранилище, по шагам.
+// Для каждого выполненного шага запоминается компенсация; если следующий шаг
+// не удался, выполненные шаги откатываются в обратном порядке. Необратимые действия
+// (освобождение прежнего содержимого) откладываются до Commit
+type saga struct {
+	name       string
+	steps      []sagaStep
+	onCommit   []func(ctx context.Context)
+	rolledBack bool
+}
+
+type sagaStep struct {
+	name string
+	undo func(ctx context.Context) error
+}
+
+func newSaga(name string) *saga {
+	return &saga{name: name}
+}
+
+// Step выполняет шаг. undo может быть nil, если шаг нечего откатывать.
+// При ошибке шага все предыдущие шаги откатываются, ошибка возвращается как есть
+func (t *saga) Step(ctx context.Context, name string, action func(ctx context.Context) error, undo func(ctx context.Context) error) error {
+	if err := action(ctx); err != nil {
+		lg := logger.GetLoggerFromCtx(ctx)
+		lg.Error(ctx, "Saga step failed", zap.Error(err), zap.String("saga", t.name), zap.String("step", name))
+		t.Rollback(ctx)
+		return err
+	}
+	if undo != nil {
+		t.steps = append(t.steps, sagaStep{name: name, undo: undo})
+	}
+	return nil
+}
+
+// OnCommit откладывает действие до успешного завершения всей операции
+func (t *saga) OnCommit(fn func(ctx context.Context)) {
+	t.onCommit = append(t.onCommit, fn)
+}
+
+// Commit завершает операцию: компенсации больше не нужны, отложенные действия выполняются
+func (t *saga) Commit(ctx context.Context) {
+	t.steps = nil
+	for _, fn := range t.onCommit {
+		fn(ctx)
+	}
+	t.onCommit = nil
+}
+
+// Rollback откатывает выполненные шаги в обратном порядке. Возвращает false,
+// если какой-то шаг откатить не удалось - тогда состояние доводит журнал или проверка хранилища
+func (t *saga) Rollback(ctx context.Context) bool {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	t.rolledBack = true
+	for i := len(t.steps) - 1; i >= 0; i-- {
+		step := t.steps[i]
+		if err := step.undo(ctx); err != nil {
+			t.rolledBack = false
+			lg.Error(ctx, "Saga compensation failed", zap.Error(err), zap.String("saga", t.name), zap.String("step", step.name))
+			continue
+		}
+		lg.Debug(ctx, "Saga step compensated", zap.String("saga", t.name), zap.String("step", step.name))
+	}
+	t.steps = nil
+	t.onCommit = nil
+	return t.rolledBack
+}
+
+// RolledBack сообщает, что операция была полностью откачена
+func (t *saga) RolledBack() bool {
+	return t.rolledBack
+}
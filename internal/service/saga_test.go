@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSagaRollsBackCompletedSteps(t *testing.T) {
+	ctx := newTestContext(t)
+	var undone []string
+	committed := false
+
+	tx := newSaga("test")
+	for _, name := range []string{"first", "second"} {
+		require.NoError(t, tx.Step(ctx, name, func(ctx context.Context) error {
+			return nil
+		}, func(ctx context.Context) error {
+			undone = append(undone, name)
+			return nil
+		}))
+	}
+	tx.OnCommit(func(ctx context.Context) { committed = true })
+
+	failure := errors.New("boom")
+	err := tx.Step(ctx, "third", func(ctx context.Context) error {
+		return failure
+	}, nil)
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"second", "first"}, undone)
+	assert.True(t, tx.RolledBack())
+
+	// После отката отложенные действия не выполняются
+	tx.Commit(ctx)
+	assert.False(t, committed)
+}
+
+func TestCreateFileCompensatesOnPermissionFailure(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	usersDir := filepath.Join(cfg.Storage.BasePath, "users")
+	journal, err := repository.NewWriteJournal(cfg)
+	require.NoError(t, err)
+	fileRepo.failures["CreatePermission"] = errors.New("dbmanager unavailable")
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo), Journal: journal})
+
+	ownerID := uuid.New()
+	_, err = svc.CreateFile(ctx, &models.CreateFileRequest{
+		Name:          "report.txt",
+		ContentReader: strings.NewReader("quarterly numbers"),
+	}, ownerID)
+	require.Error(t, err)
+
+	// Ни строки, ни содержимого, ни незавершенного намерения
+	assert.Empty(t, fileRepo.files)
+	var stored []string
+	filepath.WalkDir(usersDir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			stored = append(stored, p)
+		}
+		return nil
+	})
+	assert.Empty(t, stored)
+	pending, err := journal.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
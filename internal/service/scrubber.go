@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"io"
+	"time"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultScrubInterval       = 24 * time.Hour
+	defaultScrubBytesPerSecond = 16 * 1024 * 1024
+	// scrubBufferSize порция чтения, после которой применяется ограничение скорости
+	scrubBufferSize = 256 * 1024
+)
+
+// scrubMetrics счетчики фоновой проверки, публикуются через /debug/vars
+var scrubMetrics = expvar.NewMap("scrubber")
+
+// Scrubber в фоне перечитывает файлы пользователей и сверяет их с SHA-256 из БД,
+// чтобы порча данных на дисках обнаруживалась до того, как файл понадобится.
+// Поврежденные и пропавшие файлы отмечаются в метаданных, итог прохода сохраняется по пользователю
+type Scrubber struct {
+	files    *fileService
+	status   interfaces.ScrubStatusStore
+	interval time.Duration
+	limiter  *byteRateLimiter
+}
+
+// NewScrubber создает фоновую проверку контрольных сумм
+func NewScrubber(fileRepo interfaces.FileRepository, storageRepo interfaces.StorageRepository, status interfaces.ScrubStatusStore, cfg *config.Config) *Scrubber {
+	interval := cfg.Storage.Scrub.Interval
+	if interval <= 0 {
+		interval = defaultScrubInterval
+	}
+	bytesPerSecond := cfg.Storage.Scrub.BytesPerSecond
+	if bytesPerSecond <= 0 {
+		bytesPerSecond = defaultScrubBytesPerSecond
+	}
+
+	return &Scrubber{
+		files: &fileService{
+			fileRepo:    fileRepo,
+			storageRepo: storageRepo,
+			cfg:         cfg,
+		},
+		status:   status,
+		interval: interval,
+		limiter:  &byteRateLimiter{bytesPerSecond: bytesPerSecond},
+	}
+}
+
+// Run проверяет всех пользователей, затем ждет interval и начинает заново. Завершается с отменой ctx
+func (s *Scrubber) Run(ctx context.Context) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "Scrubber started", zap.Duration("interval", s.interval), zap.Int64("bytesPerSecond", s.limiter.bytesPerSecond))
+
+	for {
+		s.scrubAll(ctx)
+
+		timer := time.NewTimer(s.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			lg.Info(ctx, "Scrubber stopped")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// scrubAll проходит по всем пользователям, у которых есть каталог в хранилище
+func (s *Scrubber) scrubAll(ctx context.Context) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	owners, err := s.files.storageOwners(ctx)
+	if err != nil {
+		lg.Error(ctx, "Failed to list storage owners for scrub", zap.Error(err))
+		scrubMetrics.Add("errors", 1)
+		return
+	}
+
+	for _, ownerID := range owners {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := s.ScrubOwner(ctx, ownerID); err != nil {
+			lg.Error(ctx, "Scrub failed", zap.Error(err), zap.String("ownerID", ownerID.String()))
+		}
+	}
+	scrubMetrics.Add("passes", 1)
+	scrubMetrics.Set("last_pass_finished", unixTimeVar(time.Now()))
+}
+
+// ScrubOwner проверяет все файлы пользователя, включая корзину, и сохраняет итог
+func (s *Scrubber) ScrubOwner(ctx context.Context, ownerID uuid.UUID) (*models.ScrubStatus, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	previous, err := s.status.Get(ctx, ownerID)
+	if err != nil {
+		lg.Error(ctx, "Failed to read previous scrub status", zap.Error(err), zap.String("ownerID", ownerID.String()))
+	}
+	wasDamaged := make(map[uuid.UUID]bool)
+	if previous != nil {
+		for _, id := range append(previous.Corrupted, previous.Missing...) {
+			wasDamaged[id] = true
+		}
+	}
+
+	startedAt := time.Now()
+	status := &models.ScrubStatus{
+		OwnerID:   ownerID,
+		State:     models.ScrubStateRunning,
+		StartedAt: &startedAt,
+		Corrupted: []uuid.UUID{},
+		Missing:   []uuid.UUID{},
+	}
+	s.save(ctx, status)
+	s.limiter.Reset()
+
+	files, err := s.files.ownerFiles(ctx, ownerID)
+	if err != nil {
+		return s.finish(ctx, status, err)
+	}
+
+	for i := range files {
+		file := &files[i]
+		if ctx.Err() != nil {
+			return s.finish(ctx, status, ctx.Err())
+		}
+		if file.IsFolder || file.StoragePath == "" {
+			continue
+		}
+		if file.SHA256Checksum == nil || *file.SHA256Checksum == "" {
+			status.Unverified++
+			continue
+		}
+
+		integrity, size, err := s.scrubFile(ctx, file)
+		if err != nil {
+			if ctx.Err() != nil {
+				return s.finish(ctx, status, ctx.Err())
+			}
+			lg.Error(ctx, "Failed to scrub file", zap.Error(err), zap.String("fileID", file.ID.String()))
+			scrubMetrics.Add("errors", 1)
+			continue
+		}
+
+		status.FilesChecked++
+		status.BytesChecked += size
+		scrubMetrics.Add("files_checked", 1)
+		scrubMetrics.Add("bytes_checked", size)
+
+		switch integrity {
+		case models.FileIntegrityCorrupted:
+			status.Corrupted = append(status.Corrupted, file.ID)
+			scrubMetrics.Add("corrupted", 1)
+		case models.FileIntegrityMissing:
+			status.Missing = append(status.Missing, file.ID)
+			scrubMetrics.Add("missing", 1)
+		default:
+			// Отметку снимаем только с файлов, которые были повреждены в прошлый раз
+			if !wasDamaged[file.ID] {
+				continue
+			}
+		}
+		s.markIntegrity(ctx, file.ID, integrity)
+	}
+
+	return s.finish(ctx, status, nil)
+}
+
+// scrubFile перечитывает содержимое файла и возвращает его состояние и прочитанный объем
+func (s *Scrubber) scrubFile(ctx context.Context, file *models.File) (string, int64, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	actual, size, err := s.hash(ctx, file.StoragePath)
+	if err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return "", 0, err
+	}
+	if err == nil && actual == *file.SHA256Checksum {
+		return models.FileIntegrityOK, size, nil
+	}
+
+	// Пока файл читался, его могли перезаписать: расхождение с устаревшей записью - не порча
+	current, err := s.files.fileRepo.GetFileByID(ctx, file.ID)
+	if err != nil || current == nil {
+		return models.FileIntegrityOK, size, nil
+	}
+	if current.StoragePath != file.StoragePath || current.SHA256Checksum == nil || *current.SHA256Checksum != *file.SHA256Checksum {
+		return models.FileIntegrityOK, size, nil
+	}
+
+	if actual == "" {
+		lg.Error(ctx, "Scrub found missing file content", zap.String("fileID", file.ID.String()), zap.String("path", file.StoragePath))
+		return models.FileIntegrityMissing, 0, nil
+	}
+	lg.Error(ctx, "Scrub found corrupted file",
+		zap.String("fileID", file.ID.String()),
+		zap.String("expected", *file.SHA256Checksum),
+		zap.String("actual", actual))
+	return models.FileIntegrityCorrupted, size, nil
+}
+
+// hash считает SHA-256 содержимого, соблюдая ограничение скорости чтения
+func (s *Scrubber) hash(ctx context.Context, storagePath string) (string, int64, error) {
+	reader, err := s.files.storageRepo.GetFile(ctx, s.files.toRelativePath(storagePath))
+	if err != nil {
+		return "", 0, err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	buf := make([]byte, scrubBufferSize)
+	var size int64
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			hasher.Write(buf[:n])
+			size += int64(n)
+			if err := s.limiter.Wait(ctx, n); err != nil {
+				return "", 0, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read file content: %w", err)
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// markIntegrity записывает состояние файла в его метаданные, сохраняя остальные ключи
+func (s *Scrubber) markIntegrity(ctx context.Context, fileID uuid.UUID, integrity string) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	metadata, err := s.files.fileRepo.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		// Без текущих метаданных запись затерла бы их
+		lg.Error(ctx, "Failed to get file metadata", zap.Error(err), zap.String("fileID", fileID.String()))
+		return
+	}
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata[models.MetadataIntegrity] = integrity
+	metadata[models.MetadataIntegrityCheckedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	if err := s.files.fileRepo.UpdateFileMetadata(ctx, fileID, metadata); err != nil {
+		lg.Error(ctx, "Failed to mark file integrity", zap.Error(err), zap.String("fileID", fileID.String()))
+	}
+}
+
+// finish сохраняет итог прохода по пользователю
+func (s *Scrubber) finish(ctx context.Context, status *models.ScrubStatus, err error) (*models.ScrubStatus, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	finishedAt := time.Now()
+	status.FinishedAt = &finishedAt
+	status.State = models.ScrubStateCompleted
+	if err != nil {
+		status.State = models.ScrubStateFailed
+		status.Error = err.Error()
+	}
+	// Отмена ctx не должна помешать сохранить итог
+	s.save(context.WithoutCancel(ctx), status)
+
+	lg.Info(ctx, "Scrub finished",
+		zap.String("ownerID", status.OwnerID.String()),
+		zap.String("state", status.State),
+		zap.Int("filesChecked", status.FilesChecked),
+		zap.Int("corrupted", len(status.Corrupted)),
+		zap.Int("missing", len(status.Missing)))
+	return status, err
+}
+
+func (s *Scrubber) save(ctx context.Context, status *models.ScrubStatus) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if err := s.status.Save(ctx, status); err != nil {
+		lg.Error(ctx, "Failed to save scrub status", zap.Error(err), zap.String("ownerID", status.OwnerID.String()))
+	}
+}
+
+// byteRateLimiter ограничивает среднюю скорость чтения с начала прохода:
+// после каждой порции ждет, пока прочитанный объем не уложится в лимит.
+// Не предназначен для одновременного использования из нескольких горутин
+type byteRateLimiter struct {
+	bytesPerSecond int64
+	start          time.Time
+	bytes          int64
+}
+
+// Reset начинает отсчет заново, чтобы пауза между проходами не превращалась в запас скорости
+func (l *byteRateLimiter) Reset() {
+	l.start = time.Now()
+	l.bytes = 0
+}
+
+func (l *byteRateLimiter) Wait(ctx context.Context, n int) error {
+	if l.bytesPerSecond <= 0 {
+		return nil
+	}
+	if l.start.IsZero() {
+		l.start = time.Now()
+	}
+
+	l.bytes += int64(n)
+	due := l.start.Add(time.Duration(float64(l.bytes) / float64(l.bytesPerSecond) * float64(time.Second)))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// unixTimeVar публикует время в expvar как Unix-время
+func unixTimeVar(t time.Time) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(t.Unix())
+	return v
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubberMarksCorruptedFiles(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	usersDir := filepath.Join(cfg.Storage.BasePath, "users")
+	statusStore, err := repository.NewScrubStatusStore(cfg)
+	require.NoError(t, err)
+	scrubber := NewScrubber(fileRepo, storageRepo, statusStore, cfg)
+
+	ownerID := uuid.New()
+	addFile := func(content string) *models.File {
+		file := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "file.txt"}
+		file.StoragePath = scrubber.files.generateStoragePath(ownerID, file.ID)
+		info, err := storageRepo.SaveFile(ctx, StorageKeyForFile(ownerID, file.ID), strings.NewReader(content))
+		require.NoError(t, err)
+		applyContentInfo(file, info)
+		fileRepo.files[file.ID] = file
+		return file
+	}
+
+	healthy := addFile("healthy")
+	corrupted := addFile("corrupted")
+	require.NoError(t, os.WriteFile(filepath.Join(usersDir, StorageKeyForFile(ownerID, corrupted.ID)), []byte("bit rot"), 0644))
+	missing := addFile("missing")
+	require.NoError(t, os.Remove(filepath.Join(usersDir, StorageKeyForFile(ownerID, missing.ID))))
+	unverified := addFile("legacy")
+	unverified.SHA256Checksum = nil
+	fileRepo.metadata[corrupted.ID] = map[string]interface{}{"color": "red"}
+
+	status, err := scrubber.ScrubOwner(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScrubStateCompleted, status.State)
+	assert.Equal(t, 3, status.FilesChecked)
+	assert.Equal(t, 1, status.Unverified)
+	assert.Equal(t, []uuid.UUID{corrupted.ID}, status.Corrupted)
+	assert.Equal(t, []uuid.UUID{missing.ID}, status.Missing)
+
+	// Отметка добавляется к метаданным, не затирая их
+	assert.Equal(t, models.FileIntegrityCorrupted, fileRepo.metadata[corrupted.ID][models.MetadataIntegrity])
+	assert.Equal(t, "red", fileRepo.metadata[corrupted.ID]["color"])
+	assert.Equal(t, models.FileIntegrityMissing, fileRepo.metadata[missing.ID][models.MetadataIntegrity])
+	assert.Nil(t, fileRepo.metadata[healthy.ID])
+
+	// Итог виден через сервис хранилища и после перезапуска
+	svc := NewStorageService(fileRepo, storageRepo, nil, nil, nil, statusStore, cfg)
+	saved, err := svc.GetScrubStatus(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, status.Corrupted, saved.Corrupted)
+
+	never, err := svc.GetScrubStatus(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Equal(t, models.ScrubStateNeverRun, never.State)
+
+	// Восстановленный файл снова отмечается как целый
+	require.NoError(t, os.WriteFile(filepath.Join(usersDir, StorageKeyForFile(ownerID, corrupted.ID)), []byte("corrupted"), 0644))
+	status, err = scrubber.ScrubOwner(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Empty(t, status.Corrupted)
+	assert.Equal(t, models.FileIntegrityOK, fileRepo.metadata[corrupted.ID][models.MetadataIntegrity])
+}
@@ -0,0 +1,654 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// errOwnersIncomplete причина, по которой общие блобы не исправляются
+const errOwnersIncomplete = "owner list may be incomplete, shared content is kept"
+
+// storageCheckGracePeriod объекты моложе этого срока могут принадлежать операции,
+// которая еще выполняется, поэтому сиротами они не считаются
+const storageCheckGracePeriod = time.Hour
+
+// storageCheck сверяет содержимое хранилища с записями dbmanager (fsck).
+// Сначала собираются записи файлов и ревизий, затем обходится хранилище: так объект,
+// записанный во время проверки, окажется моложе storageCheckGracePeriod и не будет удален
+type storageCheck struct {
+	files  *fileService
+	dryRun bool
+	// full проверка всего хранилища: только тогда известны все ссылки на общие блобы
+	// и можно судить об объектах вне каталогов пользователей
+	full bool
+	// ownersComplete у каждого пользователя есть каталог в хранилище. Иначе ссылки на общие
+	// блобы могли собраться не все, и освобождать их нельзя
+	ownersComplete bool
+	now            time.Time
+	report         *models.StorageCheckReport
+
+	// refs записи, ссылающиеся на объект, по ключу хранилища
+	refs map[string][]contentRef
+	// rows все записи файлов, найденные в БД
+	rows map[uuid.UUID]*models.File
+	// contents SHA-256 содержимого, на которое ссылаются записи: для него хранится четность
+	contents map[string]bool
+	// objects объекты хранилища по ключу
+	objects map[string]*interfaces.FileInfo
+	// relocated объекты, которые исправление возвращает записям без содержимого:
+	// сиротами они не считаются, даже если исправление не выполнялось (dry-run)
+	relocated map[string]bool
+	// inFlight ключи и файлы незавершенных операций из журнала намерений записи
+	inFlight      map[string]bool
+	inFlightFiles map[uuid.UUID]bool
+}
+
+// contentRef ссылка записи на объект хранилища
+type contentRef struct {
+	file *models.File
+	// revision nil, если это текущее содержимое файла
+	revision *models.FileRevision
+}
+
+func newStorageCheck(files *fileService, full, dryRun bool) *storageCheck {
+	now := time.Now()
+	return &storageCheck{
+		files:         files,
+		dryRun:        dryRun,
+		full:          full,
+		now:           now,
+		report:        &models.StorageCheckReport{DryRun: dryRun, StartedAt: now, Issues: []models.StorageIssue{}},
+		refs:          make(map[string][]contentRef),
+		rows:          make(map[uuid.UUID]*models.File),
+		contents:      make(map[string]bool),
+		objects:       make(map[string]*interfaces.FileInfo),
+		relocated:     make(map[string]bool),
+		inFlight:      make(map[string]bool),
+		inFlightFiles: make(map[uuid.UUID]bool),
+	}
+}
+
+// checkStorage проверяет хранилище пользователей owners, пустой owners - все хранилище
+func (s *fileService) checkStorage(ctx context.Context, owners []uuid.UUID, dryRun bool) (*models.StorageCheckReport, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	c := newStorageCheck(s, len(owners) == 0, dryRun)
+	if c.full {
+		var err error
+		if owners, c.ownersComplete, err = s.fileOwners(ctx); err != nil {
+			return nil, err
+		}
+		c.report.OwnersIncomplete = !c.ownersComplete
+	}
+	lg.Info(ctx, "Storage check started", zap.Int("owners", len(owners)), zap.Bool("full", c.full), zap.Bool("dryRun", dryRun))
+
+	if err := c.collectRows(ctx, owners); err != nil {
+		return nil, err
+	}
+	if err := c.collectInFlight(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.collectObjects(ctx, owners); err != nil {
+		return nil, err
+	}
+
+	// Сначала записи без содержимого: их исправление может найти содержимое
+	// среди объектов, которые иначе считались бы сиротами
+	c.checkRows(ctx)
+	c.checkObjects(ctx)
+
+	c.report.Owners = len(owners)
+	c.report.FinishedAt = time.Now()
+	lg.Info(ctx, "Storage check finished",
+		zap.Int("rows", c.report.Rows),
+		zap.Int("objects", c.report.Objects),
+		zap.Int("issues", len(c.report.Issues)),
+		zap.Int("fixed", c.report.Fixed),
+		zap.Int("skipped", c.report.Skipped))
+	return c.report, nil
+}
+
+// collectRows собирает записи файлов и ревизий и ключи, на которые они ссылаются
+func (c *storageCheck) collectRows(ctx context.Context, owners []uuid.UUID) error {
+	for _, ownerID := range owners {
+		files, err := c.files.ownerFiles(ctx, ownerID)
+		if err != nil {
+			return err
+		}
+
+		for i := range files {
+			file := &files[i]
+			c.rows[file.ID] = file
+			c.report.Rows++
+			if file.IsFolder {
+				continue
+			}
+
+			if file.StoragePath != "" {
+				key := c.files.toRelativePath(file.StoragePath)
+				c.refs[key] = append(c.refs[key], contentRef{file: file})
+			}
+			if file.SHA256Checksum != nil && *file.SHA256Checksum != "" {
+				c.contents[*file.SHA256Checksum] = true
+			}
+
+			revisions, err := c.files.fileRepo.GetRevisions(ctx, file.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get revisions of %s: %w", file.ID, err)
+			}
+			for j := range revisions {
+				revision := &revisions[j]
+				if revision.StoragePath == "" {
+					continue
+				}
+				key := c.files.toRelativePath(revision.StoragePath)
+				c.refs[key] = append(c.refs[key], contentRef{file: file, revision: revision})
+				// У ревизии нет SHA-256 в БД, но у ревизии в хранилище блобов он в ключе
+				if sha256, ok := c.files.blobSHA(revision.StoragePath); ok {
+					c.contents[sha256] = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// collectInFlight запоминает операции из журнала: их доводит до конца восстановление,
+// а не проверка хранилища
+func (c *storageCheck) collectInFlight(ctx context.Context) error {
+	if c.files.journal == nil {
+		return nil
+	}
+
+	intents, err := c.files.journal.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read write journal: %w", err)
+	}
+	for _, intent := range intents {
+		c.inFlightFiles[intent.FileID] = true
+		for _, storagePath := range []string{intent.StoragePath, intent.PreviousPath} {
+			if storagePath != "" {
+				c.inFlight[c.files.toRelativePath(storagePath)] = true
+			}
+		}
+		if intent.Op == models.WriteOpCreate {
+			c.inFlight[StorageKeyForFile(intent.OwnerID, intent.FileID)] = true
+		}
+	}
+	return nil
+}
+
+// collectObjects обходит хранилище. При частичной проверке обходятся только каталоги
+// пользователей и отложенные при удалении файлы, а блобы проверяются поштучно
+func (c *storageCheck) collectObjects(ctx context.Context, owners []uuid.UUID) error {
+	visit := func(info *interfaces.FileInfo) error {
+		// Маркеры "каталогов" объектных хранилищ
+		if strings.HasSuffix(info.Path, "/") {
+			return nil
+		}
+		c.objects[info.Path] = info
+		return nil
+	}
+
+	roots := []string{""}
+	if !c.full {
+		roots = []string{deleteStashPrefix}
+		for _, ownerID := range owners {
+			roots = append(roots, ownerID.String())
+		}
+	}
+	for _, root := range roots {
+		if err := c.files.storageRepo.Walk(ctx, root, visit); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to walk storage %q: %w", root, err)
+		}
+	}
+
+	if !c.full {
+		for key := range c.refs {
+			if _, ok := c.objects[key]; ok {
+				continue
+			}
+			if _, isBlob := c.parseBlobKey(key); !isBlob {
+				continue
+			}
+			if info := c.stat(ctx, key); info != nil {
+				c.objects[key] = info
+			}
+		}
+	}
+
+	c.report.Objects = len(c.objects)
+	return nil
+}
+
+// stat возвращает размер объекта без чтения содержимого, nil - если объекта нет
+func (c *storageCheck) stat(ctx context.Context, key string) *interfaces.FileInfo {
+	reader, err := c.files.storageRepo.GetFile(ctx, key)
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	return &interfaces.FileInfo{Path: key, Size: reader.Size()}
+}
+
+// checkRows ищет записи, содержимого которых нет в хранилище
+func (c *storageCheck) checkRows(ctx context.Context) {
+	for _, key := range sortedKeys(c.refs) {
+		if _, ok := c.objects[key]; ok {
+			continue
+		}
+		for _, ref := range c.refs[key] {
+			if c.inFlightFiles[ref.file.ID] {
+				continue
+			}
+
+			issue := c.newIssue(models.StorageIssueMissingObject, key, ref)
+			var fix func(ctx context.Context) error
+			// Ревизию восстановить неоткуда, текущему содержимому файла ищем замену
+			if ref.revision == nil {
+				fix = c.relocateFix(ctx, key, ref.file)
+			}
+			c.record(ctx, issue, fix)
+		}
+	}
+}
+
+// relocateFix ищет содержимое файла в другом месте хранилища: в каталоге отложенных
+// при удалении файлов, по пути из ID или в хранилище блобов по SHA-256
+func (c *storageCheck) relocateFix(ctx context.Context, key string, file *models.File) func(ctx context.Context) error {
+	stashKey := path.Join(deleteStashPrefix, file.ID.String())
+	if _, ok := c.objects[stashKey]; ok {
+		// Удаление файла прервалось после того, как содержимое было отложено
+		c.relocated[stashKey] = true
+		return func(ctx context.Context) error {
+			if err := c.files.storageRepo.MoveFile(ctx, stashKey, key); err != nil {
+				return err
+			}
+			c.claim(stashKey, key)
+			return nil
+		}
+	}
+
+	idKey := StorageKeyForFile(file.OwnerID, file.ID)
+	if _, ok := c.objects[idKey]; ok && idKey != key && len(c.refs[idKey]) == 0 {
+		c.relocated[idKey] = true
+		return func(ctx context.Context) error {
+			file.StoragePath = c.files.toAbsolutePath(idKey)
+			if err := c.files.fileRepo.UpdateFile(ctx, file); err != nil {
+				return err
+			}
+			c.refs[idKey] = append(c.refs[idKey], contentRef{file: file})
+			return nil
+		}
+	}
+
+	if file.SHA256Checksum == nil || c.files.blobStore == nil {
+		return nil
+	}
+	sha256 := *file.SHA256Checksum
+	blobKey := c.files.blobStore.Key(sha256)
+	if _, ok := c.objects[blobKey]; !ok || blobKey == key {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		if err := c.files.blobStore.Acquire(ctx, sha256); err != nil {
+			return err
+		}
+		file.StoragePath = c.files.toAbsolutePath(blobKey)
+		if err := c.files.fileRepo.UpdateFile(ctx, file); err != nil {
+			c.files.releaseContent(ctx, file.StoragePath)
+			return err
+		}
+		c.refs[blobKey] = append(c.refs[blobKey], contentRef{file: file})
+		return nil
+	}
+}
+
+// parseBlobKey возвращает SHA-256 блоба по ключу хранилища. Без хранилища блобов
+// (storage.content_addressed выключен) блобом не считается ни один объект
+func (c *storageCheck) parseBlobKey(key string) (string, bool) {
+	if c.files.blobStore == nil {
+		return "", false
+	}
+	return c.files.blobStore.ParseKey(key)
+}
+
+// claim переносит объект from на место to после того, как исправление вернуло его записи
+func (c *storageCheck) claim(from, to string) {
+	info := c.objects[from]
+	delete(c.objects, from)
+	info.Path = to
+	c.objects[to] = info
+}
+
+// checkObjects сверяет каждый объект хранилища с записями, которые на него ссылаются
+func (c *storageCheck) checkObjects(ctx context.Context) {
+	for _, key := range sortedKeys(c.objects) {
+		info := c.objects[key]
+		if c.relocated[key] {
+			continue
+		}
+
+		if sha256, ok := c.parseBlobKey(key); ok {
+			c.checkBlob(ctx, info, sha256)
+			continue
+		}
+		if c.files.parityStore != nil {
+			// Четность нужна, пока есть записи с таким содержимым
+			if sha256, ok := c.files.parityStore.ParseKey(key); ok {
+				if c.full && !c.contents[sha256] {
+					c.checkOrphan(ctx, info, models.StorageIssueOrphanObject)
+				}
+				continue
+			}
+		}
+		// Файл счетчика ссылок проверяется вместе с блобом
+		if _, ok := c.parseBlobKey(strings.TrimSuffix(key, path.Ext(key))); ok {
+			continue
+		}
+
+		top, _, nested := strings.Cut(key, "/")
+		if _, err := uuid.Parse(top); err == nil {
+			c.checkContent(ctx, info)
+			continue
+		}
+		if !c.full {
+			continue
+		}
+		if top == deleteStashPrefix || (nested && strings.HasPrefix(top, ".")) {
+			// Служебные каталоги хранят только временные данные операций
+			c.checkOrphan(ctx, info, models.StorageIssueStaleTemp)
+		} else if !nested {
+			c.checkOrphan(ctx, info, models.StorageIssueOrphanObject)
+		}
+	}
+}
+
+// checkContent проверяет файл в каталоге пользователя
+func (c *storageCheck) checkContent(ctx context.Context, info *interfaces.FileInfo) {
+	refs := c.refs[info.Path]
+	if len(refs) == 0 {
+		c.checkOrphan(ctx, info, models.StorageIssueOrphanObject)
+		return
+	}
+	if c.inFlight[info.Path] {
+		return
+	}
+
+	actual, err := c.files.storageRepo.GetFileInfo(ctx, info.Path)
+	if err != nil {
+		c.record(ctx, models.StorageIssue{Kind: models.StorageIssueMissingObject, Path: info.Path, Error: err.Error()}, nil)
+		return
+	}
+
+	// Старые ревизии при перезаписи на месте ссылаются на тот же путь, сверяем только текущее содержимое
+	for _, ref := range refs {
+		if ref.revision == nil {
+			c.checkRowContent(ctx, ref, actual)
+		}
+	}
+}
+
+// checkBlob проверяет блоб: что содержимое соответствует адресу, что на него есть ссылки
+// и что счетчик ссылок совпадает с их числом
+func (c *storageCheck) checkBlob(ctx context.Context, info *interfaces.FileInfo, sha256 string) {
+	refs := c.refs[info.Path]
+	if len(refs) == 0 {
+		if c.full && !c.inFlight[info.Path] {
+			c.checkOrphan(ctx, info, models.StorageIssueOrphanObject)
+		}
+		return
+	}
+
+	actual, err := c.files.storageRepo.GetFileInfo(ctx, info.Path)
+	if err != nil {
+		c.record(ctx, models.StorageIssue{Kind: models.StorageIssueMissingObject, Path: info.Path, Error: err.Error()}, nil)
+		return
+	}
+
+	if actual.SHA256Checksum != sha256 {
+		// Блоб поврежден: все записи на него получат расхождение ниже
+		c.record(ctx, models.StorageIssue{
+			Kind:     models.StorageIssueChecksumMismatch,
+			Path:     info.Path,
+			Expected: sha256,
+			Actual:   actual.SHA256Checksum,
+		}, nil)
+	} else {
+		for _, ref := range refs {
+			if ref.revision == nil {
+				c.checkRowContent(ctx, ref, actual)
+			}
+		}
+	}
+
+	if !c.full || c.inFlight[info.Path] {
+		return
+	}
+	count, err := c.files.blobStore.RefCount(ctx, sha256)
+	if err != nil {
+		c.record(ctx, models.StorageIssue{Kind: models.StorageIssueRefCountMismatch, Path: info.Path, Error: err.Error()}, nil)
+		return
+	}
+	if count != int64(len(refs)) {
+		expected := int64(len(refs))
+		issue := models.StorageIssue{
+			Kind:     models.StorageIssueRefCountMismatch,
+			Path:     info.Path,
+			Expected: fmt.Sprint(expected),
+			Actual:   fmt.Sprint(count),
+		}
+		fix := func(ctx context.Context) error {
+			return c.files.blobStore.SetRefCount(ctx, sha256, expected)
+		}
+		// Заниженный счетчик удалил бы блоб, пока на него ссылаются непроверенные записи
+		if !c.ownersComplete {
+			issue.Error = errOwnersIncomplete
+			fix = nil
+		}
+		c.record(ctx, issue, fix)
+	}
+}
+
+// checkRowContent сверяет размер и контрольную сумму записи с фактическим содержимым.
+// Расхождение размера при совпадающей (или неизвестной) сумме - устаревшие метаданные,
+// их можно дописать. Расхождение суммы может означать порчу данных и исправляется только вручную
+func (c *storageCheck) checkRowContent(ctx context.Context, ref contentRef, actual *interfaces.FileInfo) {
+	file := ref.file
+	if file.SHA256Checksum != nil && *file.SHA256Checksum != "" && *file.SHA256Checksum != actual.SHA256Checksum {
+		issue := c.newIssue(models.StorageIssueChecksumMismatch, actual.Path, ref)
+		issue.Expected = *file.SHA256Checksum
+		issue.Actual = actual.SHA256Checksum
+		c.record(ctx, issue, nil)
+		return
+	}
+	if file.Size == actual.Size {
+		return
+	}
+
+	issue := c.newIssue(models.StorageIssueSizeMismatch, actual.Path, ref)
+	issue.Expected = fmt.Sprint(file.Size)
+	issue.Actual = fmt.Sprint(actual.Size)
+	c.record(ctx, issue, func(ctx context.Context) error {
+		applyContentInfo(file, actual)
+		return c.files.fileRepo.UpdateFile(ctx, file)
+	})
+}
+
+// checkOrphan сообщает об объекте без записей. Недавние объекты пропускаются:
+// их запись в БД может появиться, когда завершится операция
+func (c *storageCheck) checkOrphan(ctx context.Context, info *interfaces.FileInfo, kind string) {
+	if c.inFlight[info.Path] {
+		return
+	}
+	if info.ModifiedAt != 0 && c.now.Sub(time.Unix(info.ModifiedAt, 0)) < storageCheckGracePeriod {
+		c.report.Skipped++
+		return
+	}
+
+	issue := models.StorageIssue{Kind: kind, Path: info.Path, Actual: fmt.Sprint(info.Size)}
+	fix := func(ctx context.Context) error {
+		if sha256, ok := c.parseBlobKey(info.Path); ok {
+			return c.files.blobStore.SetRefCount(ctx, sha256, 0)
+		}
+		err := c.files.storageRepo.DeleteFile(ctx, info.Path)
+		if errdefs.Is(err, errdefs.ErrFileNotFound) {
+			return nil
+		}
+		return err
+	}
+	if c.sharedContent(info.Path) && !c.ownersComplete {
+		issue.Error = errOwnersIncomplete
+		fix = nil
+	}
+	c.record(ctx, issue, fix)
+}
+
+// sharedContent объект может быть нужен записям любого пользователя: блоб или блок четности
+func (c *storageCheck) sharedContent(key string) bool {
+	if _, ok := c.parseBlobKey(key); ok {
+		return true
+	}
+	if c.files.parityStore != nil {
+		if _, ok := c.files.parityStore.ParseKey(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newIssue создает расхождение для записи, ссылающейся на объект
+func (c *storageCheck) newIssue(kind, key string, ref contentRef) models.StorageIssue {
+	fileID, ownerID := ref.file.ID, ref.file.OwnerID
+	issue := models.StorageIssue{Kind: kind, Path: key, FileID: &fileID, OwnerID: &ownerID}
+	if ref.revision != nil {
+		issue.RevisionID = ref.revision.RevisionID
+	}
+	return issue
+}
+
+// record добавляет расхождение в отчет и, если это не dry-run, исправляет его.
+// fix == nil - автоматического исправления нет
+func (c *storageCheck) record(ctx context.Context, issue models.StorageIssue, fix func(ctx context.Context) error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	switch {
+	case c.dryRun:
+	case fix == nil:
+		if issue.Error == "" {
+			issue.Error = "manual repair required"
+		}
+	default:
+		if err := fix(ctx); err != nil {
+			issue.Error = err.Error()
+		} else {
+			issue.Fixed = true
+			c.report.Fixed++
+		}
+	}
+
+	fields := []zap.Field{
+		zap.String("kind", issue.Kind),
+		zap.String("path", issue.Path),
+		zap.Bool("fixed", issue.Fixed),
+	}
+	if issue.FileID != nil {
+		fields = append(fields, zap.String("fileID", issue.FileID.String()))
+	}
+	if issue.Error != "" {
+		fields = append(fields, zap.String("error", issue.Error))
+	}
+	lg.Info(ctx, "Storage issue found", fields...)
+
+	c.report.Issues = append(c.report.Issues, issue)
+}
+
+// fileOwners возвращает пользователей по каталогам хранилища: перечислять владельцев
+// dbmanager не умеет. Каталог создается при регистрации (CreateUserDirectory), но полным
+// список считается только для драйверов поверх файловой системы: в S3 каталогов нет,
+// и пользователь, все файлы которого лежат в .cas, в список не попадет
+func (s *fileService) fileOwners(ctx context.Context) (owners []uuid.UUID, complete bool, err error) {
+	owners, err = s.storageOwners(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	switch s.cfg.Storage.Driver {
+	case "", "local", "mirror":
+		return owners, true, nil
+	}
+	return owners, false, nil
+}
+
+// storageOwners возвращает пользователей, у которых есть каталог в хранилище
+func (s *fileService) storageOwners(ctx context.Context) ([]uuid.UUID, error) {
+	return StorageOwners(s.storageRepo)(ctx)
+}
+
+// OwnersFunc перечисляет пользователей сервиса
+type OwnersFunc func(ctx context.Context) ([]uuid.UUID, error)
+
+// StorageOwners перечисляет пользователей, у которых есть каталог в хранилище.
+// Тот же список проходят проверка хранилища, скраббер и применение шаблона
+func StorageOwners(storageRepo interfaces.StorageRepository) OwnersFunc {
+	return func(ctx context.Context) ([]uuid.UUID, error) {
+		names, err := storageRepo.ListDirectory(ctx, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list storage root: %w", err)
+		}
+
+		var owners []uuid.UUID
+		for _, name := range names {
+			// Служебные каталоги (.cas и т.п.) - не UUID
+			if ownerID, err := uuid.Parse(name); err == nil {
+				owners = append(owners, ownerID)
+			}
+		}
+		return owners, nil
+	}
+}
+
+// ownerFiles собирает файлы пользователя из дерева и корзины без повторов
+func (s *fileService) ownerFiles(ctx context.Context, ownerID uuid.UUID) ([]models.File, error) {
+	tree, err := s.fileRepo.GetFileTree(ctx, ownerID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file tree: %w", err)
+	}
+	trashed, err := s.fileRepo.ListTrashedFiles(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trashed files: %w", err)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	var files []models.File
+	for _, file := range append(tree, trashed...) {
+		if seen[file.ID] {
+			continue
+		}
+		seen[file.ID] = true
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
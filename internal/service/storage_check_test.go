@@ -0,0 +1,168 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStorageFindsAndFixesIssues(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	usersDir := filepath.Join(cfg.Storage.BasePath, "users")
+	blobStore := repository.NewBlobStore(storageRepo)
+	svc := NewStorageService(fileRepo, storageRepo, blobStore, nil, nil, nil, cfg)
+	files := svc.(*storageService).files
+
+	ownerID := uuid.New()
+	write := func(key, content string) {
+		full := filepath.Join(usersDir, key)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	addFile := func(content string) *models.File {
+		file := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "file.txt"}
+		file.StoragePath = files.generateStoragePath(ownerID, file.ID)
+		write(StorageKeyForFile(ownerID, file.ID), content)
+		info, err := storageRepo.GetFileInfo(ctx, StorageKeyForFile(ownerID, file.ID))
+		require.NoError(t, err)
+		applyContentInfo(file, info)
+		fileRepo.files[file.ID] = file
+		return file
+	}
+
+	healthy := addFile("healthy")
+	resized := addFile("resized")
+	resized.Size = 1
+	resized.SHA256Checksum = nil
+	corrupted := addFile("corrupted")
+	write(StorageKeyForFile(ownerID, corrupted.ID), "bit rot")
+
+	// Удаление прервалось: содержимое отложено, а запись осталась
+	stashed := addFile("stashed")
+	require.NoError(t, storageRepo.MoveFile(ctx, StorageKeyForFile(ownerID, stashed.ID), ".deleting/"+stashed.ID.String()))
+
+	orphanKey := StorageKeyForFile(ownerID, uuid.New())
+	write(orphanKey, "orphan")
+
+	// Блоб с лишней ссылкой и блоб без ссылок
+	shared, err := blobStore.Put(ctx, strings.NewReader("shared"))
+	require.NoError(t, err)
+	require.NoError(t, blobStore.Acquire(ctx, shared.SHA256Checksum))
+	blobFile := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "blob.txt", StoragePath: files.toAbsolutePath(shared.Path)}
+	applyContentInfo(blobFile, shared)
+	fileRepo.files[blobFile.ID] = blobFile
+	unused, err := blobStore.Put(ctx, strings.NewReader("unused"))
+	require.NoError(t, err)
+
+	// Все, кроме свежего объекта, записано давно
+	old := time.Now().Add(-2 * storageCheckGracePeriod)
+	require.NoError(t, filepath.Walk(usersDir, func(p string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		return os.Chtimes(p, old, old)
+	}))
+	youngKey := StorageKeyForFile(ownerID, uuid.New())
+	write(youngKey, "upload in progress")
+
+	// Dry-run только сообщает
+	report, err := svc.CheckStorage(ctx, nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		models.StorageIssueMissingObject:    1,
+		models.StorageIssueOrphanObject:     2,
+		models.StorageIssueSizeMismatch:     1,
+		models.StorageIssueChecksumMismatch: 1,
+		models.StorageIssueRefCountMismatch: 1,
+	}, countIssues(report))
+	assert.Zero(t, report.Fixed)
+	assert.Equal(t, 1, report.Skipped)
+	assert.FileExists(t, filepath.Join(usersDir, orphanKey))
+
+	report, err = svc.CheckStorage(ctx, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.Fixed)
+
+	assert.NoFileExists(t, filepath.Join(usersDir, orphanKey))
+	assert.FileExists(t, filepath.Join(usersDir, youngKey))
+	assert.FileExists(t, filepath.Join(usersDir, StorageKeyForFile(ownerID, stashed.ID)))
+	assert.FileExists(t, filepath.Join(usersDir, StorageKeyForFile(ownerID, healthy.ID)))
+	assert.Equal(t, int64(len("resized")), fileRepo.files[resized.ID].Size)
+
+	refs, err := blobStore.RefCount(ctx, shared.SHA256Checksum)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), refs)
+	refs, err = blobStore.RefCount(ctx, unused.SHA256Checksum)
+	require.NoError(t, err)
+	assert.Zero(t, refs)
+
+	// Поврежденное содержимое автоматически не исправляется
+	report, err = svc.CheckStorage(ctx, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{models.StorageIssueChecksumMismatch: 1}, countIssues(report))
+	assert.Equal(t, corrupted.ID, *report.Issues[0].FileID)
+	assert.False(t, report.Issues[0].Fixed)
+
+	// Без хранилища блобов проверка работает и для записей, указывающих в .cas
+	withoutBlobs := NewStorageService(fileRepo, storageRepo, nil, nil, nil, nil, cfg)
+	_, err = withoutBlobs.CheckStorage(ctx, []uuid.UUID{ownerID}, true)
+	require.NoError(t, err)
+}
+
+func TestCheckStorageKeepsBlobsWhenOwnersIncomplete(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	usersDir := filepath.Join(cfg.Storage.BasePath, "users")
+	blobStore := repository.NewBlobStore(storageRepo)
+	svc := NewStorageService(fileRepo, storageRepo, blobStore, nil, nil, nil, cfg)
+	files := svc.(*storageService).files
+
+	// Все содержимое пользователя в хранилище блобов, каталога пользователя нет
+	blob, err := blobStore.Put(ctx, strings.NewReader("only in cas"))
+	require.NoError(t, err)
+	file := &models.File{ID: uuid.New(), OwnerID: uuid.New(), Name: "blob.txt", StoragePath: files.toAbsolutePath(blob.Path)}
+	applyContentInfo(file, blob)
+	fileRepo.files[file.ID] = file
+
+	old := time.Now().Add(-2 * storageCheckGracePeriod)
+	require.NoError(t, filepath.Walk(usersDir, func(p string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		return os.Chtimes(p, old, old)
+	}))
+
+	// Каталогов пользователей в S3 нет: блоб выглядит лишним, но не освобождается
+	cfg.Storage.Driver = "s3"
+	report, err := svc.CheckStorage(ctx, nil, false)
+	require.NoError(t, err)
+	assert.True(t, report.OwnersIncomplete)
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, models.StorageIssueOrphanObject, report.Issues[0].Kind)
+	assert.False(t, report.Issues[0].Fixed)
+	refs, err := blobStore.RefCount(ctx, blob.SHA256Checksum)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), refs)
+
+	// В локальном хранилище каталог пользователя создан при регистрации
+	cfg.Storage.Driver = ""
+	require.NoError(t, os.MkdirAll(filepath.Join(usersDir, file.OwnerID.String()), 0755))
+	report, err = svc.CheckStorage(ctx, nil, false)
+	require.NoError(t, err)
+	assert.False(t, report.OwnersIncomplete)
+	assert.Empty(t, report.Issues)
+}
+
+func countIssues(report *models.StorageCheckReport) map[string]int {
+	counts := make(map[string]int)
+	for _, issue := range report.Issues {
+		counts[issue.Kind]++
+	}
+	return counts
+}
@@ -3,35 +3,53 @@ package service
 import (
 	"context"
 	"fmt"
-	"path/filepath"
+	"io"
 
 	"homecloud-file-service/config"
 	"homecloud-file-service/internal/interfaces"
 	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 type storageService struct {
 	storageRepo interfaces.StorageRepository
 	cfg         *config.Config
+	// files сверяет хранилище с записями dbmanager
+	files       *fileService
+	scrubStatus interfaces.ScrubStatusStore
 }
 
-func NewStorageService(storageRepo interfaces.StorageRepository, cfg *config.Config) interfaces.StorageService {
+func NewStorageService(fileRepo interfaces.FileRepository, storageRepo interfaces.StorageRepository, blobStore interfaces.BlobStore, parityStore interfaces.ParityStore, journal interfaces.WriteJournal, scrubStatus interfaces.ScrubStatusStore, cfg *config.Config) interfaces.StorageService {
 	return &storageService{
 		storageRepo: storageRepo,
 		cfg:         cfg,
+		scrubStatus: scrubStatus,
+		files: &fileService{
+			fileRepo:    fileRepo,
+			storageRepo: storageRepo,
+			blobStore:   blobStore,
+			parityStore: parityStore,
+			journal:     journal,
+			cfg:         cfg,
+		},
 	}
 }
 
 // Основные операции
-func (s *storageService) SaveFile(ctx context.Context, path string, content []byte) error {
+func (s *storageService) SaveFile(ctx context.Context, path string, content io.Reader) (*interfaces.FileInfo, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "SaveFile called", zap.String("path", path))
 	return s.storageRepo.SaveFile(ctx, path, content)
 }
 
-func (s *storageService) GetFile(ctx context.Context, path string) ([]byte, error) {
+func (s *storageService) NewWriter(ctx context.Context, path string) (interfaces.BlobWriter, error) {
+	return s.storageRepo.NewWriter(ctx, path)
+}
+
+func (s *storageService) GetFile(ctx context.Context, path string) (interfaces.BlobReader, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "GetFile called", zap.String("path", path))
 	return s.storageRepo.GetFile(ctx, path)
@@ -103,56 +121,57 @@ func (s *storageService) VerifyChecksum(ctx context.Context, path string, expect
 }
 
 // Очистка и обслуживание
+
+// CleanupOrphanedFiles проверяет все хранилище и исправляет найденные расхождения
 func (s *storageService) CleanupOrphanedFiles(ctx context.Context) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "CleanupOrphanedFiles called")
 
-	// Получаем список всех файлов в хранилище
-	files, err := s.storageRepo.ListDirectory(ctx, s.cfg.Storage.BasePath)
+	report, err := s.CheckStorage(ctx, nil, false)
 	if err != nil {
-		lg.Error(ctx, "Failed to list directory", zap.Error(err))
-		return fmt.Errorf("failed to list directory: %w", err)
+		lg.Error(ctx, "Failed to check storage", zap.Error(err))
+		return err
 	}
 
-	// Простая логика очистки - удаляем временные файлы
-	cleanedCount := 0
-	for _, file := range files {
-		if len(file) > 4 && file[len(file)-4:] == ".tmp" {
-			err := s.storageRepo.DeleteFile(ctx, filepath.Join(s.cfg.Storage.BasePath, file))
-			if err != nil {
-				lg.Error(ctx, "Failed to delete orphaned file", zap.String("file", file), zap.Error(err))
-			} else {
-				cleanedCount++
-			}
-		}
-	}
-
-	lg.Info(ctx, "Cleanup completed", zap.Int("cleanedCount", cleanedCount))
+	lg.Info(ctx, "Cleanup completed", zap.Int("issues", len(report.Issues)), zap.Int("fixed", report.Fixed))
 	return nil
 }
 
+// OptimizeStorage сверяет содержимое и контрольные суммы всего хранилища, ничего не меняя
 func (s *storageService) OptimizeStorage(ctx context.Context) error {
 	lg := logger.GetLoggerFromCtx(ctx)
 	lg.Info(ctx, "OptimizeStorage called")
 
-	// Простая логика оптимизации - проверяем целостность файлов
-	files, err := s.storageRepo.ListDirectory(ctx, s.cfg.Storage.BasePath)
+	report, err := s.CheckStorage(ctx, nil, true)
 	if err != nil {
-		lg.Error(ctx, "Failed to list directory", zap.Error(err))
-		return fmt.Errorf("failed to list directory: %w", err)
+		lg.Error(ctx, "Failed to check storage", zap.Error(err))
+		return err
 	}
 
-	optimizedCount := 0
-	for _, file := range files {
-		// Проверяем MD5 контрольную сумму
-		_, err := s.storageRepo.CalculateChecksum(ctx, filepath.Join(s.cfg.Storage.BasePath, file), "md5")
-		if err != nil {
-			lg.Error(ctx, "Failed to calculate checksum", zap.String("file", file), zap.Error(err))
-		} else {
-			optimizedCount++
-		}
+	lg.Info(ctx, "Storage optimization completed", zap.Int("objects", report.Objects), zap.Int("issues", len(report.Issues)))
+	return nil
+}
+
+func (s *storageService) CheckStorage(ctx context.Context, owners []uuid.UUID, dryRun bool) (*models.StorageCheckReport, error) {
+	report, err := s.files.checkStorage(ctx, owners, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check storage: %w", err)
 	}
+	return report, nil
+}
 
-	lg.Info(ctx, "Storage optimization completed", zap.Int("optimizedCount", optimizedCount))
-	return nil
+func (s *storageService) GetScrubStatus(ctx context.Context, ownerID uuid.UUID) (*models.ScrubStatus, error) {
+	status, err := s.scrubStatus.Get(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scrub status: %w", err)
+	}
+	if status == nil {
+		status = &models.ScrubStatus{
+			OwnerID:   ownerID,
+			State:     models.ScrubStateNeverRun,
+			Corrupted: []uuid.UUID{},
+			Missing:   []uuid.UUID{},
+		}
+	}
+	return status, nil
 }
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// defaultDeletionGracePeriod сколько файлы удаленного пользователя ждут в корзине
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// DeleteUserData переносит в корзину все файлы пользователя и назначает их безвозвратное
+// удаление через срок отсрочки. Повторный вызов отсрочку не продлевает, а лишь переносит
+// в корзину то, что появилось после первого. С immediate данные удаляются сразу
+func (s *fileService) DeleteUserData(ctx context.Context, userID uuid.UUID, immediate bool) (*models.UserDeletion, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "DeleteUserData called", zap.String("userID", userID.String()), zap.Bool("immediate", immediate))
+
+	if s.userDeletions == nil {
+		return nil, errors.New("user data deletion is not configured")
+	}
+	// Удаление без отсрочки необратимо, поэтому включается в конфигурации явно
+	if immediate && !s.cfg.Storage.UserData.AllowImmediate {
+		return nil, fmt.Errorf("%w: immediate user data deletion is disabled", errdefs.ErrPermissionDenied)
+	}
+
+	unlock := s.fileLocks.lock(userID)
+	defer unlock()
+
+	deletion, err := s.userDeletions.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user deletion: %w", err)
+	}
+	if deletion == nil {
+		grace := s.cfg.Storage.UserData.DeletionGracePeriod
+		if grace <= 0 {
+			grace = defaultDeletionGracePeriod
+		}
+		now := time.Now().UTC()
+		deletion = &models.UserDeletion{UserID: userID, RequestedAt: now, PurgeAt: now.Add(grace)}
+	}
+	if immediate {
+		deletion.PurgeAt = time.Now().UTC()
+	}
+
+	// Запрос сохраняется до изменений: если перенос в корзину прервется, очистка
+	// все равно состоится, а повторный вызов его продолжит
+	if err := s.userDeletions.Save(ctx, deletion); err != nil {
+		return nil, fmt.Errorf("failed to save user deletion: %w", err)
+	}
+
+	roots, err := s.fileRepo.ListFilesByParent(ctx, userID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user files: %w", err)
+	}
+	var trashErr error
+	for _, file := range roots {
+		if file.IsTrashed {
+			continue
+		}
+		if err := s.fileRepo.SoftDeleteFile(ctx, file.ID); err != nil {
+			trashErr = fmt.Errorf("failed to move %s to trash: %w", file.ID, err)
+			break
+		}
+		deletion.Trashed = append(deletion.Trashed, file.ID)
+	}
+	if err := s.userDeletions.Save(ctx, deletion); err != nil {
+		return nil, fmt.Errorf("failed to save user deletion: %w", err)
+	}
+	if trashErr != nil {
+		lg.Error(ctx, "Failed to move user files to trash", zap.Error(trashErr))
+		return nil, trashErr
+	}
+
+	if immediate {
+		if err := s.purgeUserData(ctx, deletion); err != nil {
+			return nil, err
+		}
+	}
+
+	lg.Info(ctx, "User data deletion scheduled", zap.String("userID", userID.String()),
+		zap.Time("purgeAt", deletion.PurgeAt), zap.Int("trashed", len(deletion.Trashed)))
+	return deletion, nil
+}
+
+// CancelUserDataDeletion отменяет удаление до конца отсрочки и возвращает из корзины
+// то, что туда перенесло удаление
+func (s *fileService) CancelUserDataDeletion(ctx context.Context, userID uuid.UUID) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "CancelUserDataDeletion called", zap.String("userID", userID.String()))
+
+	if s.userDeletions == nil {
+		return fmt.Errorf("%w: user data deletion was not requested", errdefs.ErrNotFound)
+	}
+
+	unlock := s.fileLocks.lock(userID)
+	defer unlock()
+
+	deletion, err := s.userDeletions.Get(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user deletion: %w", err)
+	}
+	if deletion == nil {
+		return fmt.Errorf("%w: user data deletion was not requested", errdefs.ErrNotFound)
+	}
+
+	for _, fileID := range deletion.Trashed {
+		err := s.fileRepo.RestoreFile(ctx, fileID)
+		if err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+			return fmt.Errorf("failed to restore %s: %w", fileID, err)
+		}
+	}
+	if err := s.userDeletions.Delete(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user deletion: %w", err)
+	}
+
+	lg.Info(ctx, "User data deletion canceled", zap.String("userID", userID.String()), zap.Int("restored", len(deletion.Trashed)))
+	return nil
+}
+
+// PurgeDeletedUsers безвозвратно удаляет данные пользователей, отсрочка которых истекла.
+// Возвращает число пользователей, чьи данные удалены
+func (s *fileService) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	if s.userDeletions == nil {
+		return 0, nil
+	}
+	deletions, err := s.userDeletions.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	purged := 0
+	var errs []error
+	for i := range deletions {
+		deletion := &deletions[i]
+		if now.Before(deletion.PurgeAt) {
+			continue
+		}
+		unlock := s.fileLocks.lock(deletion.UserID)
+		// Пока ждали блокировку, удаление могли отменить
+		current, err := s.userDeletions.Get(ctx, deletion.UserID)
+		if err == nil && current != nil && !now.Before(current.PurgeAt) {
+			err = s.purgeUserData(ctx, current)
+			if err == nil {
+				purged++
+			}
+		}
+		unlock()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return purged, errors.Join(errs...)
+}
+
+// purgeUserData удаляет все файлы пользователя вместе с ревизиями, правами доступа
+// и ссылками на блобы, затем каталог пользователя в хранилище и сам запрос.
+// Права, выданные пользователю на чужие файлы, остаются: у dbmanager нет поиска прав
+// по получателю, а перебирать ради этого файлы всех пользователей слишком дорого.
+// Без учетной записи такими правами никто не воспользуется, убрать их может владелец файла
+func (s *fileService) purgeUserData(ctx context.Context, deletion *models.UserDeletion) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+	userID := deletion.UserID
+
+	files, err := s.ownerFiles(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list user files: %w", err)
+	}
+
+	// Вложенные файлы удаляются раньше папок, в которых лежат
+	depths := fileDepths(files)
+	sort.SliceStable(files, func(i, j int) bool {
+		return depths[files[i].ID] > depths[files[j].ID]
+	})
+	for i := range files {
+		if err := s.purgeFile(ctx, &files[i]); err != nil {
+			lg.Error(ctx, "Failed to purge user file", zap.Error(err), zap.String("fileID", files[i].ID.String()))
+			return fmt.Errorf("failed to purge file %s: %w", files[i].ID, err)
+		}
+	}
+
+	// Содержимое вне хранилища блобов, которое не нашлось по записям, лежит в каталоге пользователя
+	err = s.storageRepo.DeleteDirectory(ctx, s.getUserDirPath(userID))
+	if err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		lg.Error(ctx, "Failed to delete user directory", zap.Error(err), zap.String("userID", userID.String()))
+	}
+
+	now := time.Now().UTC()
+	deletion.PurgedAt = &now
+	if err := s.userDeletions.Delete(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user deletion: %w", err)
+	}
+
+	lg.Info(ctx, "User data purged", zap.String("userID", userID.String()), zap.Int("files", len(files)))
+	return nil
+}
+
+// purgeFile удаляет права доступа и ревизии файла, затем сам файл
+func (s *fileService) purgeFile(ctx context.Context, file *models.File) error {
+	permissions, err := s.fileRepo.GetPermissions(ctx, file.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get permissions: %w", err)
+	}
+	for _, permission := range permissions {
+		if err := s.fileRepo.DeletePermission(ctx, permission.ID); err != nil && !errdefs.Is(err, errdefs.ErrNotFound) {
+			return fmt.Errorf("failed to delete permission: %w", err)
+		}
+	}
+
+	if !file.IsFolder {
+		revisions, err := s.fileRepo.GetRevisions(ctx, file.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get revisions: %w", err)
+		}
+		for i := range revisions {
+			revision := &revisions[i]
+			if err := s.deleteRevisionRecord(ctx, revision); err != nil && !errdefs.Is(err, errdefs.ErrRevisionNotFound) {
+				return fmt.Errorf("failed to delete revision: %w", err)
+			}
+			// Содержимое текущей версии удалит deleteFileRecursiveHelper
+			if revision.StoragePath == "" || revision.StoragePath == file.StoragePath {
+				continue
+			}
+			if _, isBlob := s.blobSHA(revision.StoragePath); !isBlob {
+				err := s.storageRepo.DeleteFile(ctx, s.toRelativePath(revision.StoragePath))
+				if err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+					return fmt.Errorf("failed to delete revision content: %w", err)
+				}
+			}
+		}
+	}
+
+	return s.deleteFileRecursiveHelper(ctx, file, file.OwnerID)
+}
+
+// fileDepths глубина каждого файла от корня пользователя. Родитель, которого нет
+// среди files, считается корнем
+func fileDepths(files []models.File) map[uuid.UUID]int {
+	parents := make(map[uuid.UUID]*uuid.UUID, len(files))
+	for i := range files {
+		parents[files[i].ID] = files[i].ParentID
+	}
+
+	depths := make(map[uuid.UUID]int, len(files))
+	var depth func(id uuid.UUID, seen int) int
+	depth = func(id uuid.UUID, seen int) int {
+		if d, ok := depths[id]; ok {
+			return d
+		}
+		parentID := parents[id]
+		d := 0
+		// seen защищает от зацикленных ссылок на родителя в испорченных записях
+		if parentID != nil && seen < len(files) {
+			if _, ok := parents[*parentID]; ok {
+				d = depth(*parentID, seen+1) + 1
+			}
+		}
+		depths[id] = d
+		return d
+	}
+	for id := range parents {
+		depth(id, 0)
+	}
+	return depths
+}
+
+// GetUserStorageUsage считает место, занятое файлами пользователя, по категориям,
+// и отдельно - корзиной
+func (s *fileService) GetUserStorageUsage(ctx context.Context, userID uuid.UUID) (*models.StorageUsage, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Info(ctx, "GetUserStorageUsage called", zap.String("userID", userID.String()))
+
+	files, err := s.ownerFiles(ctx, userID)
+	if err != nil {
+		lg.Error(ctx, "Failed to list user files", zap.Error(err))
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*models.File, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+	}
+	// Файл в папке из корзины тоже в корзине, даже если сам не помечен
+	trashed := make(map[uuid.UUID]bool, len(files))
+	var inTrash func(file *models.File, seen int) bool
+	inTrash = func(file *models.File, seen int) bool {
+		if t, ok := trashed[file.ID]; ok {
+			return t
+		}
+		t := file.IsTrashed
+		if !t && file.ParentID != nil && seen < len(files) {
+			if parent, ok := byID[*file.ParentID]; ok {
+				t = inTrash(parent, seen+1)
+			}
+		}
+		trashed[file.ID] = t
+		return t
+	}
+
+	usage := &models.StorageUsage{UserID: userID, Categories: make(map[string]models.CategoryUsage)}
+	for i := range files {
+		file := &files[i]
+		switch {
+		case inTrash(file, 0):
+			if !file.IsFolder {
+				usage.TrashBytes += file.Size
+				usage.TrashFileCount++
+			}
+		case file.IsFolder:
+			usage.FolderCount++
+		default:
+			usage.TotalBytes += file.Size
+			usage.FileCount++
+			category := usageCategory(file.MimeType)
+			c := usage.Categories[category]
+			c.Bytes += file.Size
+			c.Files++
+			usage.Categories[category] = c
+		}
+	}
+
+	if s.userDeletions != nil {
+		deletion, err := s.userDeletions.Get(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user deletion: %w", err)
+		}
+		if deletion != nil {
+			usage.DeletionPurgeAt = &deletion.PurgeAt
+		}
+	}
+	return usage, nil
+}
+
+// usageCategory относит файл к категории статистики по MIME типу
+func usageCategory(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return models.UsageCategoryImages
+	case strings.HasPrefix(mimeType, "video/"):
+		return models.UsageCategoryVideos
+	case strings.HasPrefix(mimeType, "audio/"):
+		return models.UsageCategoryAudio
+	case strings.HasPrefix(mimeType, "text/"),
+		mimeType == "application/pdf",
+		mimeType == "application/json",
+		mimeType == "application/xml",
+		mimeType == "application/msword",
+		strings.HasPrefix(mimeType, "application/vnd.ms-"),
+		strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument."),
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument."):
+		return models.UsageCategoryDocuments
+	case mimeType == "application/zip",
+		mimeType == "application/vnd.rar",
+		mimeType == "application/x-7z-compressed",
+		mimeType == "application/x-tar",
+		mimeType == "application/gzip":
+		return models.UsageCategoryArchives
+	default:
+		return models.UsageCategoryOther
+	}
+}
+
+// userDataPurge подключает безвозвратное удаление данных пользователей к Janitor:
+// «истекшие записи» для него - запросы на удаление с прошедшей отсрочкой
+type userDataPurge struct {
+	files interfaces.FileService
+}
+
+// NewUserDataPurge возвращает задачу Janitor, удаляющую данные пользователей после отсрочки
+func NewUserDataPurge(files interfaces.FileService) interfaces.ExpiringStore {
+	return userDataPurge{files: files}
+}
+
+func (p userDataPurge) RemoveExpired(ctx context.Context) (int, error) {
+	return p.files.PurgeDeletedUsers(ctx)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteUserDataTrashesThenPurgesAndReportsUsage(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	deletions, err := repository.NewUserDeletionStore(cfg)
+	require.NoError(t, err)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo), UserDeletions: deletions})
+
+	ownerID := uuid.New()
+	otherID := uuid.New()
+	create := func(name string, parentID *uuid.UUID, content string, userID uuid.UUID) *models.File {
+		file, err := svc.CreateFile(ctx, &models.CreateFileRequest{
+			Name:          name,
+			ParentID:      parentID,
+			ContentReader: strings.NewReader(content),
+		}, userID)
+		require.NoError(t, err)
+		return file
+	}
+
+	docs, err := svc.CreateFolder(ctx, "docs", nil, ownerID, models.ConflictFail)
+	require.NoError(t, err)
+	create("notes.txt", &docs.ID, "hello", ownerID)
+	create("photo.jpg", nil, "0123456789", ownerID)
+	old := create("old.txt", nil, "old", ownerID)
+	require.NoError(t, svc.DeleteFile(ctx, old.ID, ownerID, models.WriteOptions{}))
+	foreign := create("foreign.txt", nil, "not mine", otherID)
+	require.NoError(t, svc.GrantPermission(ctx, foreign.ID, &models.FilePermission{
+		GranteeID:   &ownerID,
+		GranteeType: models.GranteeTypeUser,
+		Role:        models.RoleReader,
+	}, otherID))
+
+	usage, err := svc.GetUserStorageUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), usage.TotalBytes)
+	assert.Equal(t, int64(2), usage.FileCount)
+	assert.Equal(t, int64(1), usage.FolderCount)
+	assert.Equal(t, models.CategoryUsage{Bytes: 5, Files: 1}, usage.Categories[models.UsageCategoryDocuments])
+	assert.Equal(t, models.CategoryUsage{Bytes: 10, Files: 1}, usage.Categories[models.UsageCategoryImages])
+	assert.Equal(t, int64(3), usage.TrashBytes)
+	assert.Nil(t, usage.DeletionPurgeAt)
+
+	// Мягкое удаление: все в корзине, повторный вызов отсрочку не сдвигает
+	deletion, err := svc.DeleteUserData(ctx, ownerID, false)
+	require.NoError(t, err)
+	assert.Len(t, deletion.Trashed, 2)
+	again, err := svc.DeleteUserData(ctx, ownerID, false)
+	require.NoError(t, err)
+	assert.Equal(t, deletion.PurgeAt, again.PurgeAt)
+
+	usage, err = svc.GetUserStorageUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Zero(t, usage.TotalBytes)
+	assert.Equal(t, int64(18), usage.TrashBytes)
+	assert.Equal(t, int64(3), usage.TrashFileCount)
+	require.NotNil(t, usage.DeletionPurgeAt)
+
+	// До конца отсрочки фоновая очистка ничего не трогает
+	purged, err := svc.PurgeDeletedUsers(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	// Отмена возвращает только то, что перенесло удаление
+	require.NoError(t, svc.CancelUserDataDeletion(ctx, ownerID))
+	trashed, err := fileRepo.ListTrashedFiles(ctx, ownerID)
+	require.NoError(t, err)
+	require.Len(t, trashed, 1)
+	assert.Equal(t, old.ID, trashed[0].ID)
+	require.ErrorIs(t, svc.CancelUserDataDeletion(ctx, ownerID), errdefs.ErrNotFound)
+
+	// Удаление без отсрочки выключено, пока его не разрешили в конфигурации
+	_, err = svc.DeleteUserData(ctx, ownerID, true)
+	require.ErrorIs(t, err, errdefs.ErrPermissionDenied)
+	pending, err := deletions.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	cfg.Storage.UserData.AllowImmediate = true
+	deletion, err = svc.DeleteUserData(ctx, ownerID, true)
+	require.NoError(t, err)
+	assert.NotNil(t, deletion.PurgedAt)
+
+	for _, file := range fileRepo.files {
+		assert.Equal(t, otherID, file.OwnerID)
+	}
+	// Права на чужие файлы, выданные пользователю, остаются: искать их по получателю dbmanager не умеет
+	granted := false
+	for _, permission := range fileRepo.permissions {
+		assert.Equal(t, foreign.ID, permission.FileID)
+		if permission.GranteeID != nil && *permission.GranteeID == ownerID {
+			granted = true
+		}
+	}
+	assert.True(t, granted)
+	pending, err = deletions.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	content, err := svc.GetFileContent(ctx, foreign.ID, otherID)
+	require.NoError(t, err)
+	content.Close()
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// defaultTemplateLocale локаль имен, если ее не передали и не задали в конфигурации
+const defaultTemplateLocale = "en"
+
+// UserTemplateApplier создает в корне пользователя папки и файлы из шаблона.
+// Повторное применение безопасно: запись, которая уже есть под именем из любой
+// локали, не создается заново, а в существующие папки дописывается недостающее.
+// Записи в корзине тоже считаются существующими - пользователь удалил их сам
+type UserTemplateApplier struct {
+	files         interfaces.FileService
+	owners        OwnersFunc
+	template      *config.UserTemplate
+	defaultLocale string
+	// users сериализует применение для одного пользователя: повтор вызова от
+	// сервиса авторизации не должен создать вторую копию папки
+	users fileLocks
+}
+
+// NewUserTemplateApplier создает применение шаблона. owners перечисляет пользователей
+// для Owners, обычно StorageOwners. template == nil - DefaultUserTemplate
+func NewUserTemplateApplier(files interfaces.FileService, owners OwnersFunc, template *config.UserTemplate, cfg *config.Config) *UserTemplateApplier {
+	if template == nil {
+		template = config.DefaultUserTemplate()
+	}
+	defaultLocale := cfg.UserTemplate.DefaultLocale
+	if defaultLocale == "" {
+		defaultLocale = defaultTemplateLocale
+	}
+	return &UserTemplateApplier{
+		files:         files,
+		owners:        owners,
+		template:      template,
+		defaultLocale: defaultLocale,
+	}
+}
+
+// Owners возвращает пользователей, к которым применяется шаблон
+func (a *UserTemplateApplier) Owners(ctx context.Context) ([]uuid.UUID, error) {
+	return a.owners(ctx)
+}
+
+// Apply создает недостающие папки и файлы шаблона. Пустая локаль - локаль по умолчанию.
+// С dryRun только считает, что будет создано
+func (a *UserTemplateApplier) Apply(ctx context.Context, ownerID uuid.UUID, username, locale string, dryRun bool) (*models.UserTemplateReport, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+	if locale == "" {
+		locale = a.defaultLocale
+	}
+	lg.Info(ctx, "Applying user template", zap.String("ownerID", ownerID.String()),
+		zap.String("locale", locale), zap.Bool("dryRun", dryRun))
+
+	unlock := a.users.lock(ownerID)
+	defer unlock()
+
+	trashed, err := a.files.ListTrashedFiles(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	apply := &templateApply{
+		applier:  a,
+		ownerID:  ownerID,
+		username: username,
+		locale:   locale,
+		dryRun:   dryRun,
+		trashed:  trashed,
+		report:   &models.UserTemplateReport{OwnerID: ownerID, Locale: locale},
+	}
+	if err := apply.level(ctx, nil, "/", false, a.template.Folders, a.template.Files); err != nil {
+		return nil, err
+	}
+
+	lg.Info(ctx, "User template applied", zap.String("ownerID", ownerID.String()),
+		zap.Int("created", len(apply.report.Created)),
+		zap.Int("existing", apply.report.Existing),
+		zap.Int("skipped", len(apply.report.Skipped)))
+	return apply.report, nil
+}
+
+// templateApply состояние одного применения шаблона
+type templateApply struct {
+	applier  *UserTemplateApplier
+	ownerID  uuid.UUID
+	username string
+	locale   string
+	dryRun   bool
+	trashed  []models.File
+	report   *models.UserTemplateReport
+}
+
+// level обрабатывает одну папку шаблона. parentID == nil - корень пользователя.
+// missing - папки еще нет (только при dryRun): ее содержимое тогда создается целиком
+func (t *templateApply) level(ctx context.Context, parentID *uuid.UUID, dir string, missing bool, folders []config.TemplateFolder, files []config.TemplateFile) error {
+	var existing []models.File
+	if !missing {
+		var err error
+		if existing, err = t.children(ctx, parentID, dir); err != nil {
+			return err
+		}
+	}
+
+	for i := range folders {
+		folder := &folders[i]
+		name := folder.LocalName(t.locale)
+
+		found := findByNames(existing, folder.AllNames())
+		switch {
+		case found != nil && (found.IsTrashed || !found.IsFolder):
+			t.report.Skipped = append(t.report.Skipped, path.Join(dir, found.Name))
+			continue
+		case found != nil:
+			t.report.Existing++
+		case t.dryRun:
+			t.report.Created = append(t.report.Created, path.Join(dir, name))
+			if err := t.level(ctx, nil, path.Join(dir, name), true, folder.Folders, folder.Files); err != nil {
+				return err
+			}
+			continue
+		default:
+			created, isNew, err := t.createFolder(ctx, name, parentID)
+			if err != nil {
+				return fmt.Errorf("failed to create template folder %s: %w", path.Join(dir, name), err)
+			}
+			if isNew {
+				t.report.Created = append(t.report.Created, path.Join(dir, name))
+			} else {
+				t.report.Existing++
+			}
+			found = created
+		}
+
+		if err := t.level(ctx, &found.ID, path.Join(dir, found.Name), false, folder.Folders, folder.Files); err != nil {
+			return err
+		}
+	}
+
+	for i := range files {
+		file := &files[i]
+		name := file.LocalName(t.locale)
+
+		found := findByNames(existing, file.AllNames())
+		switch {
+		case found != nil && (found.IsTrashed || found.IsFolder):
+			t.report.Skipped = append(t.report.Skipped, path.Join(dir, found.Name))
+			continue
+		case found != nil:
+			t.report.Existing++
+			continue
+		}
+
+		if !t.dryRun {
+			_, err := t.applier.files.CreateFile(ctx, &models.CreateFileRequest{
+				Name:          name,
+				ParentID:      parentID,
+				MimeType:      "text/plain",
+				ContentReader: strings.NewReader(file.LocalContent(t.locale, t.username)),
+				Conflict:      models.ConflictFail,
+			}, t.ownerID)
+			if errors.Is(err, errdefs.ErrFileExists) {
+				// Имя заняли между списком и созданием - значит, файл уже есть
+				t.report.Existing++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create template file %s: %w", path.Join(dir, name), err)
+			}
+		}
+		t.report.Created = append(t.report.Created, path.Join(dir, name))
+	}
+	return nil
+}
+
+// children содержимое папки вместе с записями из корзины
+func (t *templateApply) children(ctx context.Context, parentID *uuid.UUID, dir string) ([]models.File, error) {
+	files, err := t.applier.files.ListFolderContents(ctx, parentID, t.ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	for _, file := range t.trashed {
+		if sameParent(file.ParentID, parentID) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// createFolder создает папку. Если имя успели занять папкой, возвращает ее с isNew == false
+func (t *templateApply) createFolder(ctx context.Context, name string, parentID *uuid.UUID) (*models.File, bool, error) {
+	folder, err := t.applier.files.CreateFolder(ctx, name, parentID, t.ownerID, models.ConflictFail)
+	if err == nil {
+		return folder, true, nil
+	}
+	if !errors.Is(err, errdefs.ErrFileExists) {
+		return nil, false, err
+	}
+
+	files, listErr := t.applier.files.ListFolderContents(ctx, parentID, t.ownerID)
+	if listErr != nil {
+		return nil, false, listErr
+	}
+	if existing := findByNames(files, []string{name}); existing != nil && existing.IsFolder {
+		return existing, false, nil
+	}
+	return nil, false, err
+}
+
+// findByNames ищет запись под любым из имен
+func findByNames(files []models.File, names []string) *models.File {
+	for i := range files {
+		for _, name := range names {
+			if files[i].Name == name {
+				return &files[i]
+			}
+		}
+	}
+	return nil
+}
+
+func sameParent(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
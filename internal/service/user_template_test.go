@@ -0,0 +1,84 @@
+package service
+
+import (
+	"io"
+	"testing"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserTemplateApplyIsIdempotentAndLocalized(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo)})
+
+	template := &config.UserTemplate{
+		Folders: []config.TemplateFolder{
+			{
+				Name:    "documents",
+				Names:   map[string]string{"ru": "Документы"},
+				Folders: []config.TemplateFolder{{Name: "work", Names: map[string]string{"ru": "Работа"}}},
+			},
+			{Name: "music", Names: map[string]string{"ru": "Музыка"}},
+		},
+		Files: []config.TemplateFile{{
+			Name:     "README.txt",
+			Content:  "Welcome, {{username}}!",
+			Contents: map[string]string{"ru": "Добро пожаловать, {{username}}!"},
+		}},
+	}
+	require.NoError(t, template.Validate())
+	applier := NewUserTemplateApplier(svc, StorageOwners(storageRepo), template, cfg)
+	ownerID := uuid.New()
+
+	preview, err := applier.Apply(ctx, ownerID, "alice", "ru_RU", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/Документы", "/Документы/Работа", "/Музыка", "/README.txt"}, preview.Created)
+	assert.Empty(t, fileRepo.files)
+
+	report, err := applier.Apply(ctx, ownerID, "alice", "ru_RU", false)
+	require.NoError(t, err)
+	assert.Equal(t, preview.Created, report.Created)
+
+	root1, err := svc.ListFolderContents(ctx, nil, ownerID)
+	require.NoError(t, err)
+	require.Len(t, root1, 3)
+	var readme, music *models.File
+	for i := range root1 {
+		switch root1[i].Name {
+		case "README.txt":
+			readme = &root1[i]
+		case "Музыка":
+			music = &root1[i]
+		}
+	}
+	require.NotNil(t, readme)
+	content, err := svc.GetFileContent(ctx, readme.ID, ownerID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	content.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "Добро пожаловать, alice!", string(data))
+
+	// Папка в корзине остается там, а повтор в другой локали ничего не дублирует
+	require.NotNil(t, music)
+	require.NoError(t, svc.DeleteFile(ctx, music.ID, ownerID, models.WriteOptions{}))
+	again, err := applier.Apply(ctx, ownerID, "alice", "", false)
+	require.NoError(t, err)
+	assert.Empty(t, again.Created)
+	assert.Equal(t, "en", again.Locale)
+	assert.Equal(t, 3, again.Existing)
+	assert.Equal(t, []string{"/Музыка"}, again.Skipped)
+
+	assert.Len(t, fileRepo.files, 4)
+
+	// Локальные имена не должны совпадать с именами соседей
+	template.Folders[1].Names["ru"] = "Документы"
+	assert.Error(t, template.Validate())
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// beginWrite фиксирует в журнале намерение изменить содержимое файла.
+// Без журнала (например, в служебных утилитах) возвращает nil
+func (s *fileService) beginWrite(ctx context.Context, op string, file *models.File) (*models.WriteIntent, error) {
+	if s.journal == nil {
+		return nil, nil
+	}
+
+	intent := &models.WriteIntent{
+		Op:           op,
+		FileID:       file.ID,
+		OwnerID:      file.OwnerID,
+		PreviousPath: file.StoragePath,
+	}
+	// Любая запись поверх существующего содержимого (загрузка, восстановление ревизии)
+	// меняет версию файла. Созданный файл получает версию вместе со строкой
+	if op != models.WriteOpCreate {
+		intent.Version = file.Version + 1
+	}
+	if err := s.journal.Begin(ctx, intent); err != nil {
+		return nil, fmt.Errorf("failed to journal %s: %w", op, err)
+	}
+	return intent, nil
+}
+
+// beginCopy фиксирует в журнале копирование файла еще до создания строки копии:
+// пока копия не получила собственный storage_path, она ссылается на содержимое
+// исходного файла. ID копии выдает dbmanager, поэтому при восстановлении копия
+// ищется по папке и имени. Папкам и файлам без содержимого журнал не нужен
+func (s *fileService) beginCopy(ctx context.Context, source *models.File, parentID *uuid.UUID, name string) (*models.WriteIntent, error) {
+	if s.journal == nil || source.IsFolder || source.StoragePath == "" {
+		return nil, nil
+	}
+
+	intent := &models.WriteIntent{
+		Op:           models.WriteOpCopy,
+		FileID:       source.ID,
+		OwnerID:      source.OwnerID,
+		PreviousPath: source.StoragePath,
+		ParentID:     parentID,
+		Name:         name,
+	}
+	if err := s.journal.Begin(ctx, intent); err != nil {
+		return nil, fmt.Errorf("failed to journal %s: %w", models.WriteOpCopy, err)
+	}
+	return intent, nil
+}
+
+// beginDelete фиксирует в журнале, что содержимое удаляемого файла откладывается в stashKey.
+// После сбоя оно удаляется, если строка файла успела удалиться, иначе возвращается на место
+func (s *fileService) beginDelete(ctx context.Context, file *models.File, stashKey string) (*models.WriteIntent, error) {
+	if s.journal == nil {
+		return nil, nil
+	}
+
+	intent := &models.WriteIntent{
+		Op:           models.WriteOpDelete,
+		FileID:       file.ID,
+		OwnerID:      file.OwnerID,
+		StoragePath:  s.toAbsolutePath(stashKey),
+		PreviousPath: file.StoragePath,
+	}
+	if err := s.journal.Begin(ctx, intent); err != nil {
+		return nil, fmt.Errorf("failed to journal %s: %w", models.WriteOpDelete, err)
+	}
+	return intent, nil
+}
+
+// markWritten отмечает, что новое содержимое надежно записано: после сбоя
+// операция будет доведена до конца, а не откачена
+func (s *fileService) markWritten(ctx context.Context, intent *models.WriteIntent, file *models.File) error {
+	if intent == nil {
+		return nil
+	}
+
+	intent.Stage = models.WriteStageWritten
+	intent.StoragePath = file.StoragePath
+	intent.Size = file.Size
+	if file.MD5Checksum != nil {
+		intent.MD5Checksum = *file.MD5Checksum
+	}
+	if file.SHA256Checksum != nil {
+		intent.SHA256Checksum = *file.SHA256Checksum
+	}
+	return s.journal.Save(ctx, intent)
+}
+
+// finishWrite удаляет намерение завершенной или откаченной операции
+func (s *fileService) finishWrite(ctx context.Context, intent *models.WriteIntent) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if intent == nil {
+		return
+	}
+	// Оставшееся намерение безопасно: при следующем запуске оно будет применено повторно
+	if err := s.journal.Commit(ctx, intent.ID); err != nil {
+		lg.Error(ctx, "Failed to commit write intent", zap.Error(err), zap.String("intentID", intent.ID.String()))
+	}
+}
+
+// abortWrite завершает намерение операции, откаченной сагой. Если откат был неполным
+// (например, содержимое перезаписано на месте), намерение остается и будет применено при перезапуске
+func (s *fileService) abortWrite(ctx context.Context, tx *saga, intent *models.WriteIntent) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if intent == nil {
+		return
+	}
+	if !tx.RolledBack() {
+		lg.Error(ctx, "Write left for journal recovery", zap.String("intentID", intent.ID.String()), zap.String("fileID", intent.FileID.String()))
+		return
+	}
+	s.finishWrite(ctx, intent)
+}
+
+// RecoverPendingWrites проигрывает журнал после перезапуска: записанные операции
+// доводятся до конца, недописанные откатываются
+func (s *fileService) RecoverPendingWrites(ctx context.Context) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if s.journal == nil {
+		return nil
+	}
+
+	intents, err := s.journal.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, intent := range intents {
+		var err error
+		switch {
+		case intent.Op == models.WriteOpCopy:
+			err = s.recoverCopy(ctx, intent)
+		case intent.Op == models.WriteOpDelete:
+			err = s.recoverDelete(ctx, intent)
+		case intent.Stage == models.WriteStageWritten:
+			err = s.rollForward(ctx, intent)
+		default:
+			err = s.rollBack(ctx, intent)
+		}
+		if err != nil {
+			// Намерение остается в журнале до следующего запуска
+			lg.Error(ctx, "Failed to recover write", zap.Error(err),
+				zap.String("intentID", intent.ID.String()),
+				zap.String("op", intent.Op),
+				zap.String("fileID", intent.FileID.String()))
+			continue
+		}
+
+		lg.Info(ctx, "Write recovered",
+			zap.String("op", intent.Op),
+			zap.String("stage", intent.Stage),
+			zap.String("fileID", intent.FileID.String()))
+		s.finishWrite(ctx, intent)
+	}
+	return nil
+}
+
+// rollForward дописывает в БД метаданные содержимого, которое успело записаться
+func (s *fileService) rollForward(ctx context.Context, intent *models.WriteIntent) error {
+	file, err := s.fileRepo.GetFileByID(ctx, intent.FileID)
+	if errdefs.Is(err, errdefs.ErrFileNotFound) {
+		// Файл удален - записанное содержимое никому не принадлежит
+		s.discardContent(ctx, intent.StoragePath, intent.PreviousPath)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	file.StoragePath = intent.StoragePath
+	file.Size = intent.Size
+	md5Checksum := intent.MD5Checksum
+	sha256Checksum := intent.SHA256Checksum
+	file.MD5Checksum = &md5Checksum
+	file.SHA256Checksum = &sha256Checksum
+	if intent.Version > file.Version {
+		file.Version = intent.Version
+	}
+
+	if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
+		return fmt.Errorf("failed to update file: %w", err)
+	}
+
+	if intent.Op == models.WriteOpCreate {
+		if err := s.createOwnerRecords(ctx, newSaga("recover_create"), file, true); err != nil {
+			return err
+		}
+	}
+
+	// Прежний блоб освобождается только после фиксации операции, до сбоя этого не произошло
+	if _, isBlob := s.blobSHA(intent.PreviousPath); isBlob {
+		s.releaseContent(ctx, intent.PreviousPath)
+	}
+	return nil
+}
+
+// rollBack откатывает операцию, содержимое которой не успело записаться
+func (s *fileService) rollBack(ctx context.Context, intent *models.WriteIntent) error {
+	file, err := s.fileRepo.GetFileByID(ctx, intent.FileID)
+	if errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if intent.Op == models.WriteOpCreate {
+		// Файл без содержимого так и не стал видимым клиенту - удаляем его целиком
+		s.discardContent(ctx, s.generateStoragePath(file.OwnerID, file.ID), "")
+		if err := s.fileRepo.DeleteFile(ctx, file.ID); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		return nil
+	}
+
+	// Запись по тому же пути атомарна: там лежит либо прежнее, либо новое содержимое целиком.
+	// Если rename успел произойти, прежних байтов уже нет - приводим БД к тому, что на диске
+	if _, isBlob := s.blobSHA(file.StoragePath); isBlob || file.StoragePath == "" {
+		return nil
+	}
+	info, err := s.storageRepo.GetFileInfo(ctx, s.toRelativePath(file.StoragePath))
+	if errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat file content: %w", err)
+	}
+	if file.SHA256Checksum != nil && *file.SHA256Checksum == info.SHA256Checksum {
+		return nil
+	}
+
+	applyContentInfo(file, info)
+	if err := s.fileRepo.UpdateFile(ctx, file); err != nil {
+		return fmt.Errorf("failed to update file: %w", err)
+	}
+	return nil
+}
+
+// recoverDelete доводит прерванное удаление: строка файла удалена - отложенное содержимое
+// больше не нужно, строка осталась - содержимое возвращается на место
+func (s *fileService) recoverDelete(ctx context.Context, intent *models.WriteIntent) error {
+	stashKey := s.toRelativePath(intent.StoragePath)
+
+	_, err := s.fileRepo.GetFileByID(ctx, intent.FileID)
+	if errdefs.Is(err, errdefs.ErrFileNotFound) {
+		if err := s.storageRepo.DeleteFile(ctx, stashKey); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete stashed content: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	// Содержимое не успели отложить или уже вернули - переносить нечего
+	err = s.storageRepo.MoveFile(ctx, stashKey, s.toRelativePath(intent.PreviousPath))
+	if err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		return fmt.Errorf("failed to restore stashed content: %w", err)
+	}
+	return nil
+}
+
+// recoverCopy доводит прерванное копирование: копия, содержимое которой успело записаться,
+// переводится на него, а копия без содержимого удаляется - иначе она так и ссылалась бы
+// на содержимое исходного файла, и удаление копии удалило бы его
+func (s *fileService) recoverCopy(ctx context.Context, intent *models.WriteIntent) error {
+	siblings, err := s.fileRepo.ListFilesByParent(ctx, intent.OwnerID, intent.ParentID)
+	if err != nil {
+		return fmt.Errorf("failed to list folder contents: %w", err)
+	}
+	var copied *models.File
+	for i := range siblings {
+		file := &siblings[i]
+		if file.ID != intent.FileID && file.Name == intent.Name &&
+			(file.StoragePath == intent.PreviousPath || file.StoragePath == intent.StoragePath) {
+			copied = file
+			break
+		}
+	}
+
+	written := intent.Stage == models.WriteStageWritten
+	switch {
+	case copied == nil:
+		// Строка копии не создана или уже удалена - записанное содержимое никому не принадлежит
+		if written {
+			s.discardContent(ctx, intent.StoragePath, intent.PreviousPath)
+		}
+		return nil
+	case written:
+		copied.StoragePath = intent.StoragePath
+		copied.Size = intent.Size
+		md5Checksum := intent.MD5Checksum
+		sha256Checksum := intent.SHA256Checksum
+		copied.MD5Checksum = &md5Checksum
+		copied.SHA256Checksum = &sha256Checksum
+		if err := s.fileRepo.UpdateFile(ctx, copied); err != nil {
+			return fmt.Errorf("failed to update copied file: %w", err)
+		}
+		return nil
+	default:
+		s.discardContent(ctx, s.generateStoragePath(copied.OwnerID, copied.ID), intent.PreviousPath)
+		if err := s.fileRepo.DeleteFile(ctx, copied.ID); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete copied file: %w", err)
+		}
+		return nil
+	}
+}
+
+// discardContent удаляет содержимое, на которое не ссылается ни одна запись.
+// keep - путь, который удалять нельзя (прежнее содержимое файла)
+func (s *fileService) discardContent(ctx context.Context, storagePath, keep string) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	if storagePath == "" || storagePath == keep {
+		return
+	}
+	if _, isBlob := s.blobSHA(storagePath); isBlob {
+		s.releaseContent(ctx, storagePath)
+		return
+	}
+	if err := s.storageRepo.DeleteFile(ctx, s.toRelativePath(storagePath)); err != nil && !errdefs.Is(err, errdefs.ErrFileNotFound) {
+		lg.Error(ctx, "Failed to discard content", zap.Error(err), zap.String("path", storagePath))
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverPendingWrites(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	journal, err := repository.NewWriteJournal(cfg)
+	require.NoError(t, err)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo), Journal: journal}).(*fileService)
+
+	ownerID := uuid.New()
+
+	// Создание прервано до записи содержимого - файл удаляется
+	abandoned := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "abandoned.txt"}
+	fileRepo.files[abandoned.ID] = abandoned
+	_, err = svc.beginWrite(ctx, models.WriteOpCreate, abandoned)
+	require.NoError(t, err)
+
+	// Содержимое записано, но БД не обновлена - метаданные дописываются
+	uploaded := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "uploaded.txt", Version: 1}
+	fileRepo.files[uploaded.ID] = uploaded
+	intent, err := svc.beginWrite(ctx, models.WriteOpCreate, uploaded)
+	require.NoError(t, err)
+	written := *uploaded
+	require.NoError(t, svc.storeContent(ctx, newSaga("test"), &written, strings.NewReader("new content"), intent))
+
+	// Восстановление ревизии записано, но БД не обновлена - версия увеличивается, как при загрузке
+	restored := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "restored.txt", Version: 2}
+	fileRepo.files[restored.ID] = restored
+	intent, err = svc.beginWrite(ctx, models.WriteOpRestore, restored)
+	require.NoError(t, err)
+	restoredContent := *restored
+	require.NoError(t, svc.storeContent(ctx, newSaga("test"), &restoredContent, strings.NewReader("revision content"), intent))
+
+	require.NoError(t, svc.RecoverPendingWrites(ctx))
+
+	assert.Equal(t, int64(3), fileRepo.files[restored.ID].Version)
+	assert.Equal(t, int64(len("revision content")), fileRepo.files[restored.ID].Size)
+
+	_, ok := fileRepo.files[abandoned.ID]
+	assert.False(t, ok)
+
+	recovered := fileRepo.files[uploaded.ID]
+	assert.Equal(t, svc.generateStoragePath(ownerID, uploaded.ID), recovered.StoragePath)
+	assert.Equal(t, int64(len("new content")), recovered.Size)
+	require.NotNil(t, recovered.SHA256Checksum)
+	assert.Equal(t, *written.SHA256Checksum, *recovered.SHA256Checksum)
+	assert.Len(t, fileRepo.revisions[uploaded.ID], 1)
+
+	ok, err = fileRepo.CheckPermission(ctx, uploaded.ID, ownerID, models.RoleOwner)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Журнал пуст, повторный запуск ничего не делает
+	pending, err := journal.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRecoverInterruptedCopy(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	journal, err := repository.NewWriteJournal(cfg)
+	require.NoError(t, err)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{BlobStore: repository.NewBlobStore(storageRepo), Journal: journal}).(*fileService)
+
+	ownerID := uuid.New()
+	source := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "source.txt", Version: 1}
+	source.StoragePath = svc.generateStoragePath(ownerID, source.ID)
+	_, err = storageRepo.SaveFile(ctx, StorageKeyForFile(ownerID, source.ID), strings.NewReader("source"))
+	require.NoError(t, err)
+	fileRepo.files[source.ID] = source
+
+	// Строка копии создана, но содержимое еще не скопировано: копия ссылается на исходный файл
+	_, err = svc.beginCopy(ctx, source, nil, "copy.txt")
+	require.NoError(t, err)
+	stuck := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "copy.txt", StoragePath: source.StoragePath}
+	fileRepo.files[stuck.ID] = stuck
+
+	// Содержимое второй копии записано, строка еще не обновлена - копия доводится до конца
+	written := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: "copy (2).txt", StoragePath: source.StoragePath}
+	fileRepo.files[written.ID] = written
+	writtenIntent, err := svc.beginCopy(ctx, source, nil, written.Name)
+	require.NoError(t, err)
+	target := *written
+	target.StoragePath = svc.generateStoragePath(ownerID, written.ID)
+	require.NoError(t, storageRepo.CopyFile(ctx, StorageKeyForFile(ownerID, source.ID), StorageKeyForFile(ownerID, written.ID)))
+	require.NoError(t, svc.markWritten(ctx, writtenIntent, &target))
+
+	require.NoError(t, svc.RecoverPendingWrites(ctx))
+
+	// Удаление копии без содержимого не трогает исходный файл
+	assert.NotContains(t, fileRepo.files, stuck.ID)
+	_, err = storageRepo.GetFileInfo(ctx, StorageKeyForFile(ownerID, source.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, target.StoragePath, fileRepo.files[written.ID].StoragePath)
+
+	pending, err := journal.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRecoverInterruptedDelete(t *testing.T) {
+	ctx := newTestContext(t)
+	cfg, storageRepo, fileRepo := newTestStorage(t)
+	journal, err := repository.NewWriteJournal(cfg)
+	require.NoError(t, err)
+	svc := NewFileService(fileRepo, storageRepo, cfg, FileServiceDeps{Journal: journal}).(*fileService)
+
+	ownerID := uuid.New()
+	stash := func(name string) *models.File {
+		file := &models.File{ID: uuid.New(), OwnerID: ownerID, Name: name}
+		file.StoragePath = svc.generateStoragePath(ownerID, file.ID)
+		_, err := storageRepo.SaveFile(ctx, StorageKeyForFile(ownerID, file.ID), strings.NewReader(name))
+		require.NoError(t, err)
+		fileRepo.files[file.ID] = file
+
+		stashKey := path.Join(deleteStashPrefix, file.ID.String())
+		_, err = svc.beginDelete(ctx, file, stashKey)
+		require.NoError(t, err)
+		require.NoError(t, storageRepo.MoveFile(ctx, StorageKeyForFile(ownerID, file.ID), stashKey))
+		return file
+	}
+
+	// Содержимое отложено, строка не удалена - содержимое возвращается на место
+	kept := stash("kept.txt")
+	// Строка удалена - отложенное содержимое удаляется
+	deleted := stash("deleted.txt")
+	delete(fileRepo.files, deleted.ID)
+
+	require.NoError(t, svc.RecoverPendingWrites(ctx))
+
+	_, err = storageRepo.GetFileInfo(ctx, StorageKeyForFile(ownerID, kept.ID))
+	assert.NoError(t, err)
+	for _, file := range []*models.File{kept, deleted} {
+		_, err = storageRepo.GetFileInfo(ctx, path.Join(deleteStashPrefix, file.ID.String()))
+		assert.True(t, errdefs.Is(err, errdefs.ErrFileNotFound))
+	}
+
+	pending, err := journal.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
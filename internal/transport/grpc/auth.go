@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	pb "homecloud-file-service/internal/transport/grpc/protos"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// serviceTokenMetadataKey заголовок запроса с токеном сервиса: "Bearer <grpc.service_token>"
+const serviceTokenMetadataKey = "authorization"
+
+// tokenlessMethods вызовы, доступные без токена, пока grpc.service_token не задан.
+// Сервис авторизации создает папки нового пользователя и без токена, остальные вызовы
+// выполняются от имени любого переданного user_id и без токена не обслуживаются
+var tokenlessMethods = map[string]bool{
+	pb.FileService_CreateUserDirectory_FullMethodName: true,
+}
+
+// ServiceAuthInterceptor пропускает только вызовы других сервисов homecloud с общим токеном
+func ServiceAuthInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorizeService(ctx, token, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ServiceAuthStreamInterceptor то же для потоковых вызовов
+func ServiceAuthStreamInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorizeService(ss.Context(), token, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorizeService сверяет токен из метаданных запроса с настроенным
+func authorizeService(ctx context.Context, token, method string) error {
+	if token == "" {
+		if tokenlessMethods[method] {
+			return nil
+		}
+		return status.Errorf(codes.Unauthenticated, "grpc.service_token is not configured, %s is disabled", method)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(serviceTokenMetadataKey) {
+		got, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid service token")
+}
@@ -0,0 +1,167 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	pb "homecloud-file-service/internal/transport/grpc/protos"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// parseID разбирает обязательный UUID из поля запроса
+func parseID(field, value string) (uuid.UUID, error) {
+	if value == "" {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "%s is required", field)
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s format", field)
+	}
+	return id, nil
+}
+
+// parseOptionalID разбирает ID папки: пустая строка - корень пользователя
+func parseOptionalID(field, value string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := parseID(field, value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func conflictFromProto(policy pb.ConflictPolicy) (models.ConflictPolicy, error) {
+	switch policy {
+	case pb.ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED, pb.ConflictPolicy_CONFLICT_POLICY_FAIL:
+		return models.ConflictFail, nil
+	case pb.ConflictPolicy_CONFLICT_POLICY_RENAME:
+		return models.ConflictRename, nil
+	case pb.ConflictPolicy_CONFLICT_POLICY_REPLACE:
+		return models.ConflictReplace, nil
+	case pb.ConflictPolicy_CONFLICT_POLICY_NEW_VERSION:
+		return models.ConflictNewVersion, nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "unknown conflict policy %d", policy)
+	}
+}
+
+// statusFromError переводит ошибки сервиса в коды gRPC. Текст внутренних ошибок
+// наружу не отдается, он остается в логе
+func statusFromError(ctx context.Context, err error, message string) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var exists *errdefs.FileExistsError
+	switch {
+	case errdefs.As(err, &exists):
+		msg := fmt.Sprintf("a file named %q already exists in this location", exists.Name)
+		if exists.Suggestion != "" {
+			msg += fmt.Sprintf(", try %q instead", exists.Suggestion)
+		}
+		return status.Error(codes.AlreadyExists, msg)
+	case errdefs.Is(err, errdefs.ErrFileNotFound), errdefs.Is(err, errdefs.ErrNotFound), errdefs.Is(err, errdefs.ErrRevisionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errdefs.Is(err, errdefs.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errdefs.Is(err, errdefs.ErrPreconditionFailed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errdefs.Is(err, errdefs.ErrInvalidInput), errdefs.Is(err, errdefs.ErrInvalidPath):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errdefs.Is(err, errdefs.ErrQuotaExceeded), errdefs.Is(err, errdefs.ErrStorageFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, message)
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, message)
+	}
+
+	lg := logger.GetLoggerFromCtx(ctx)
+	lg.Error(ctx, message, zap.Error(err))
+	return status.Error(codes.Internal, message)
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func idOrEmpty(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func fileToProto(file *models.File) *pb.FileInfo {
+	return &pb.FileInfo{
+		Id:             file.ID.String(),
+		OwnerId:        file.OwnerID.String(),
+		ParentId:       idOrEmpty(file.ParentID),
+		Name:           file.Name,
+		MimeType:       file.MimeType,
+		Size:           file.Size,
+		IsFolder:       file.IsFolder,
+		IsTrashed:      file.IsTrashed,
+		Starred:        file.Starred,
+		Version:        file.Version,
+		Sha256Checksum: stringOrEmpty(file.SHA256Checksum),
+		Md5Checksum:    stringOrEmpty(file.MD5Checksum),
+		CreatedAt:      timestampOrNil(&file.CreatedAt),
+		UpdatedAt:      timestampOrNil(&file.UpdatedAt),
+		TrashedAt:      timestampOrNil(file.TrashedAt),
+	}
+}
+
+func filesToProto(files []models.File) *pb.FileList {
+	list := &pb.FileList{Files: make([]*pb.FileInfo, 0, len(files))}
+	for i := range files {
+		list.Files = append(list.Files, fileToProto(&files[i]))
+	}
+	return list
+}
+
+func permissionToProto(permission *models.FilePermission) *pb.PermissionInfo {
+	return &pb.PermissionInfo{
+		Id:          permission.ID.String(),
+		FileId:      permission.FileID.String(),
+		GranteeId:   idOrEmpty(permission.GranteeID),
+		GranteeType: permission.GranteeType,
+		Role:        permission.Role,
+		AllowShare:  permission.AllowShare,
+		CreatedAt:   timestampOrNil(&permission.CreatedAt),
+	}
+}
+
+func revisionToProto(revision *models.FileRevision) *pb.RevisionInfo {
+	return &pb.RevisionInfo{
+		RevisionId:  revision.RevisionID,
+		FileId:      revision.FileID.String(),
+		Size:        revision.Size,
+		MimeType:    stringOrEmpty(revision.MimeType),
+		Md5Checksum: stringOrEmpty(revision.MD5Checksum),
+		UserId:      idOrEmpty(revision.UserID),
+		CreatedAt:   timestampOrNil(&revision.CreatedAt),
+	}
+}
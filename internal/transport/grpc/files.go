@@ -0,0 +1,363 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+	pb "homecloud-file-service/internal/transport/grpc/protos"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Вызовы ниже выполняются от имени user_id из запроса: сервисы homecloud, которые
+// ходят сюда по внутренней сети, передают пользователя, ради которого работают,
+// и получают ровно его права доступа
+
+// GetFile возвращает метаданные файла или папки
+func (s *FileServiceServer) GetFile(ctx context.Context, req *pb.GetFileRequest) (*pb.FileInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+	return s.fileInfo(ctx, fileID, userID)
+}
+
+// ListFolder возвращает содержимое папки, без folder_id - корня пользователя
+func (s *FileServiceServer) ListFolder(ctx context.Context, req *pb.ListFolderRequest) (*pb.FileList, error) {
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	folderID, err := parseOptionalID("folder_id", req.FolderId)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.fileService.ListFolderContents(ctx, folderID, userID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to list folder")
+	}
+	return filesToProto(files), nil
+}
+
+// CreateFolder создает папку. При занятом имени действует политика conflict
+func (s *FileServiceServer) CreateFolder(ctx context.Context, req *pb.CreateFolderRequest) (*pb.FileInfo, error) {
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	parentID, err := parseOptionalID("parent_id", req.ParentId)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateName(req.Name); err != nil {
+		return nil, err
+	}
+	conflict, err := conflictFromProto(req.Conflict)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := s.fileService.CreateFolder(ctx, req.Name, parentID, userID, conflict)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to create folder")
+	}
+	return fileToProto(folder), nil
+}
+
+// Move переносит файл в другую папку и возвращает его новое состояние
+func (s *FileServiceServer) Move(ctx context.Context, req *pb.MoveRequest) (*pb.FileInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+	newParentID, err := parseOptionalID("new_parent_id", req.NewParentId)
+	if err != nil {
+		return nil, err
+	}
+	conflict, err := conflictFromProto(req.Conflict)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := s.fileService.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to move file")
+	}
+
+	opts := models.WriteOptions{ExpectedVersion: req.ExpectedVersion, Conflict: conflict}
+	if err := s.fileService.MoveFile(ctx, fileID, newParentID, userID, opts); err != nil {
+		return nil, statusFromError(ctx, err, "failed to move file")
+	}
+
+	moved, err := s.fileService.GetFile(ctx, fileID, userID)
+	if errdefs.Is(err, errdefs.ErrFileNotFound) && conflict == models.ConflictNewVersion {
+		// Содержимое стало новой версией файла в целевой папке, а сам файл удален
+		return s.findByName(ctx, newParentID, source.Name, userID)
+	}
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to get moved file")
+	}
+	return fileToProto(moved), nil
+}
+
+// Copy копирует файл или папку целиком
+func (s *FileServiceServer) Copy(ctx context.Context, req *pb.CopyRequest) (*pb.FileInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+	newParentID, err := parseOptionalID("new_parent_id", req.NewParentId)
+	if err != nil {
+		return nil, err
+	}
+	if req.NewName != "" {
+		if err := validateName(req.NewName); err != nil {
+			return nil, err
+		}
+	}
+	conflict, err := conflictFromProto(req.Conflict)
+	if err != nil {
+		return nil, err
+	}
+
+	copied, err := s.fileService.CopyFile(ctx, fileID, newParentID, req.NewName, userID, models.WriteOptions{Conflict: conflict})
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to copy file")
+	}
+	return fileToProto(copied), nil
+}
+
+// Rename переименовывает файл в той же папке
+func (s *FileServiceServer) Rename(ctx context.Context, req *pb.RenameRequest) (*pb.FileInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateName(req.NewName); err != nil {
+		return nil, err
+	}
+
+	opts := models.WriteOptions{ExpectedVersion: req.ExpectedVersion}
+	if err := s.fileService.RenameFile(ctx, fileID, req.NewName, userID, opts); err != nil {
+		return nil, statusFromError(ctx, err, "failed to rename file")
+	}
+	return s.fileInfo(ctx, fileID, userID)
+}
+
+// Delete перемещает файл в корзину, а с permanent удаляет безвозвратно
+func (s *FileServiceServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := models.WriteOptions{ExpectedVersion: req.ExpectedVersion}
+	if req.Permanent {
+		err = s.fileService.DeleteFileRecursive(ctx, fileID, userID, opts)
+	} else {
+		err = s.fileService.DeleteFile(ctx, fileID, userID, opts)
+	}
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to delete file")
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+// Restore возвращает файл из корзины
+func (s *FileServiceServer) Restore(ctx context.Context, req *pb.RestoreRequest) (*pb.FileInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.fileService.RestoreFile(ctx, fileID, userID); err != nil {
+		return nil, statusFromError(ctx, err, "failed to restore file")
+	}
+	return s.fileInfo(ctx, fileID, userID)
+}
+
+// SetStarred добавляет файл в избранное или убирает из него
+func (s *FileServiceServer) SetStarred(ctx context.Context, req *pb.SetStarredRequest) (*pb.FileInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Starred {
+		err = s.fileService.StarFile(ctx, fileID, userID)
+	} else {
+		err = s.fileService.UnstarFile(ctx, fileID, userID)
+	}
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to update starred")
+	}
+	return s.fileInfo(ctx, fileID, userID)
+}
+
+// ListPermissions возвращает права доступа к файлу
+func (s *FileServiceServer) ListPermissions(ctx context.Context, req *pb.ListPermissionsRequest) (*pb.PermissionList, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.fileService.ListPermissions(ctx, fileID, userID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to list permissions")
+	}
+
+	list := &pb.PermissionList{Permissions: make([]*pb.PermissionInfo, 0, len(permissions))}
+	for i := range permissions {
+		list.Permissions = append(list.Permissions, permissionToProto(&permissions[i]))
+	}
+	return list, nil
+}
+
+// GrantPermission выдает права на файл. Выдавать права может только владелец
+func (s *FileServiceServer) GrantPermission(ctx context.Context, req *pb.GrantPermissionRequest) (*pb.PermissionInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+
+	granteeType := req.GranteeType
+	if granteeType == "" {
+		granteeType = models.GranteeTypeUser
+	}
+	switch granteeType {
+	case models.GranteeTypeUser, models.GranteeTypeGroup, models.GranteeTypeDomain, models.GranteeTypeAnyone:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown grantee_type %q", req.GranteeType)
+	}
+	switch req.Role {
+	case models.RoleOwner, models.RoleOrganizer, models.RoleWriter, models.RoleCommenter, models.RoleReader:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", req.Role)
+	}
+
+	permission := &models.FilePermission{
+		GranteeType: granteeType,
+		Role:        req.Role,
+		AllowShare:  req.AllowShare,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if granteeType != models.GranteeTypeAnyone {
+		granteeID, err := parseID("grantee_id", req.GranteeId)
+		if err != nil {
+			return nil, err
+		}
+		permission.GranteeID = &granteeID
+	}
+
+	if err := s.fileService.GrantPermission(ctx, fileID, permission, userID); err != nil {
+		return nil, statusFromError(ctx, err, "failed to grant permission")
+	}
+	return permissionToProto(permission), nil
+}
+
+// RevokePermission отзывает права получателя на файл
+func (s *FileServiceServer) RevokePermission(ctx context.Context, req *pb.RevokePermissionRequest) (*pb.RevokePermissionResponse, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+	granteeID, err := parseID("grantee_id", req.GranteeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.fileService.RevokePermission(ctx, fileID, granteeID, userID); err != nil {
+		return nil, statusFromError(ctx, err, "failed to revoke permission")
+	}
+	return &pb.RevokePermissionResponse{}, nil
+}
+
+// ListRevisions возвращает сохраненные ревизии содержимого файла
+func (s *FileServiceServer) ListRevisions(ctx context.Context, req *pb.ListRevisionsRequest) (*pb.RevisionList, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.fileService.ListRevisions(ctx, fileID, userID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to list revisions")
+	}
+
+	list := &pb.RevisionList{Revisions: make([]*pb.RevisionInfo, 0, len(revisions))}
+	for i := range revisions {
+		list.Revisions = append(list.Revisions, revisionToProto(&revisions[i]))
+	}
+	return list, nil
+}
+
+// RestoreRevision делает содержимое ревизии текущим содержимым файла
+func (s *FileServiceServer) RestoreRevision(ctx context.Context, req *pb.RestoreRevisionRequest) (*pb.FileInfo, error) {
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return nil, err
+	}
+	if req.RevisionId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "revision_id is required")
+	}
+
+	opts := models.WriteOptions{ExpectedVersion: req.ExpectedVersion}
+	if err := s.fileService.RestoreRevision(ctx, fileID, req.RevisionId, userID, opts); err != nil {
+		return nil, statusFromError(ctx, err, "failed to restore revision")
+	}
+	return s.fileInfo(ctx, fileID, userID)
+}
+
+// fileInfo читает файл после изменения, чтобы вернуть его актуальное состояние
+func (s *FileServiceServer) fileInfo(ctx context.Context, fileID, userID uuid.UUID) (*pb.FileInfo, error) {
+	file, err := s.fileService.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to get file")
+	}
+	return fileToProto(file), nil
+}
+
+// findByName ищет файл по имени в папке
+func (s *FileServiceServer) findByName(ctx context.Context, parentID *uuid.UUID, name string, userID uuid.UUID) (*pb.FileInfo, error) {
+	files, err := s.fileService.ListFolderContents(ctx, parentID, userID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to list folder")
+	}
+	for i := range files {
+		if files[i].Name == name {
+			return fileToProto(&files[i]), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "file %q not found", name)
+}
+
+func parseUserAndFile(userIDValue, fileIDValue string) (uuid.UUID, uuid.UUID, error) {
+	userID, err := parseID("user_id", userIDValue)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	fileID, err := parseID("file_id", fileIDValue)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userID, fileID, nil
+}
+
+// validateName те же ограничения на имя, что и в HTTP API
+func validateName(name string) error {
+	switch {
+	case name == "":
+		return status.Error(codes.InvalidArgument, "name is required")
+	case name == "." || name == "..":
+		return status.Errorf(codes.InvalidArgument, "invalid name %q", name)
+	case strings.ContainsAny(name, "/\\"):
+		return status.Error(codes.InvalidArgument, "name cannot contain path separators")
+	}
+	return nil
+}
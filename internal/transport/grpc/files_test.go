@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	pb "homecloud-file-service/internal/transport/grpc/protos"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeFileService реализует только методы, которые вызывает тест
+type fakeFileService struct {
+	interfaces.FileService
+	err      error
+	conflict models.ConflictPolicy
+}
+
+func (f *fakeFileService) GetFile(ctx context.Context, fileID, userID uuid.UUID) (*models.File, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.File{ID: fileID, OwnerID: userID, Name: "report.txt", Version: 3}, nil
+}
+
+func (f *fakeFileService) CreateFolder(ctx context.Context, name string, parentID *uuid.UUID, ownerID uuid.UUID, conflict models.ConflictPolicy) (*models.File, error) {
+	f.conflict = conflict
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.File{ID: uuid.New(), OwnerID: ownerID, ParentID: parentID, Name: name, IsFolder: true}, nil
+}
+
+func (f *fakeFileService) RenameFile(ctx context.Context, fileID uuid.UUID, newName string, userID uuid.UUID, opts models.WriteOptions) error {
+	if opts.ExpectedVersion != 0 && opts.ExpectedVersion != 3 {
+		return errdefs.ErrPreconditionFailed
+	}
+	return nil
+}
+
+func TestFileRPCsMapServiceErrorsToStatusCodes(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Logger.Config = zap.NewDevelopmentConfig()
+	cfg.Logger.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	lg, err := logger.New(cfg)
+	require.NoError(t, err)
+	ctx := logger.CtxWWithLogger(context.Background(), lg)
+
+	files := &fakeFileService{}
+	server := NewFileServiceServer(nil, files, nil, nil, cfg)
+	userID := uuid.NewString()
+	fileID := uuid.NewString()
+
+	code := func(err error) codes.Code {
+		return status.Code(err)
+	}
+
+	_, err = server.GetFile(ctx, &pb.GetFileRequest{UserId: "not-a-uuid", FileId: fileID})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+
+	info, err := server.GetFile(ctx, &pb.GetFileRequest{UserId: userID, FileId: fileID})
+	require.NoError(t, err)
+	assert.Equal(t, fileID, info.Id)
+	assert.Equal(t, int64(3), info.Version)
+
+	folder, err := server.CreateFolder(ctx, &pb.CreateFolderRequest{UserId: userID, Name: "photos", Conflict: pb.ConflictPolicy_CONFLICT_POLICY_RENAME})
+	require.NoError(t, err)
+	assert.True(t, folder.IsFolder)
+	assert.Equal(t, models.ConflictRename, files.conflict)
+
+	_, err = server.CreateFolder(ctx, &pb.CreateFolderRequest{UserId: userID, Name: "a/b"})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+
+	_, err = server.Rename(ctx, &pb.RenameRequest{UserId: userID, FileId: fileID, NewName: "new.txt", ExpectedVersion: 2})
+	assert.Equal(t, codes.FailedPrecondition, code(err))
+
+	files.err = &errdefs.FileExistsError{Name: "photos", Suggestion: "photos (2)"}
+	_, err = server.CreateFolder(ctx, &pb.CreateFolderRequest{UserId: userID, Name: "photos"})
+	assert.Equal(t, codes.AlreadyExists, code(err))
+	assert.Contains(t, status.Convert(err).Message(), "photos (2)")
+
+	files.err = errdefs.ErrPermissionDenied
+	_, err = server.GetFile(ctx, &pb.GetFileRequest{UserId: userID, FileId: fileID})
+	assert.Equal(t, codes.PermissionDenied, code(err))
+
+	files.err = errdefs.ErrFileNotFound
+	_, err = server.GetFile(ctx, &pb.GetFileRequest{UserId: userID, FileId: fileID})
+	assert.Equal(t, codes.NotFound, code(err))
+
+	// Текст внутренней ошибки остается в логе
+	files.err = errors.New("connection refused: db.internal:5432")
+	_, err = server.GetFile(ctx, &pb.GetFileRequest{UserId: userID, FileId: fileID})
+	assert.Equal(t, codes.Internal, code(err))
+	assert.NotContains(t, status.Convert(err).Message(), "db.internal")
+}
+
+func TestServiceAuthInterceptorRequiresToken(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(token, method, header string) error {
+		ctx := context.Background()
+		if header != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(serviceTokenMetadataKey, header))
+		}
+		_, err := ServiceAuthInterceptor(token)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	// Без настроенного токена доступно только создание папок пользователя
+	assert.NoError(t, call("", pb.FileService_CreateUserDirectory_FullMethodName, ""))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("", pb.FileService_GetFile_FullMethodName, "")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("", pb.FileService_DeleteUserData_FullMethodName, "Bearer ")))
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("secret", pb.FileService_CreateUserDirectory_FullMethodName, "")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("secret", pb.FileService_GetFile_FullMethodName, "Bearer wrong")))
+	assert.NoError(t, call("secret", pb.FileService_GetFile_FullMethodName, "Bearer secret"))
+}
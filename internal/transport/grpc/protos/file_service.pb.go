@@ -9,6 +9,7 @@ package protos
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,11 +22,68 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Что делать, если имя в целевой папке уже занято
+type ConflictPolicy int32
+
+const (
+	ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED ConflictPolicy = 0 // То же, что CONFLICT_POLICY_FAIL
+	ConflictPolicy_CONFLICT_POLICY_FAIL        ConflictPolicy = 1 // Ошибка ALREADY_EXISTS
+	ConflictPolicy_CONFLICT_POLICY_RENAME      ConflictPolicy = 2 // Свободное имя вида "name (2).ext"
+	ConflictPolicy_CONFLICT_POLICY_REPLACE     ConflictPolicy = 3 // Существующий файл удаляется
+	ConflictPolicy_CONFLICT_POLICY_NEW_VERSION ConflictPolicy = 4 // Новая версия существующего файла
+)
+
+// Enum value maps for ConflictPolicy.
+var (
+	ConflictPolicy_name = map[int32]string{
+		0: "CONFLICT_POLICY_UNSPECIFIED",
+		1: "CONFLICT_POLICY_FAIL",
+		2: "CONFLICT_POLICY_RENAME",
+		3: "CONFLICT_POLICY_REPLACE",
+		4: "CONFLICT_POLICY_NEW_VERSION",
+	}
+	ConflictPolicy_value = map[string]int32{
+		"CONFLICT_POLICY_UNSPECIFIED": 0,
+		"CONFLICT_POLICY_FAIL":        1,
+		"CONFLICT_POLICY_RENAME":      2,
+		"CONFLICT_POLICY_REPLACE":     3,
+		"CONFLICT_POLICY_NEW_VERSION": 4,
+	}
+)
+
+func (x ConflictPolicy) Enum() *ConflictPolicy {
+	p := new(ConflictPolicy)
+	*p = x
+	return p
+}
+
+func (x ConflictPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ConflictPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_file_service_proto_enumTypes[0].Descriptor()
+}
+
+func (ConflictPolicy) Type() protoreflect.EnumType {
+	return &file_file_service_proto_enumTypes[0]
+}
+
+func (x ConflictPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ConflictPolicy.Descriptor instead.
+func (ConflictPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{0}
+}
+
 // Запрос на создание директории пользователя
 type CreateUserDirectoryRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // UUID пользователя
 	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`           // Имя пользователя (опционально)
+	Locale        string                 `protobuf:"bytes,3,opt,name=locale,proto3" json:"locale,omitempty"`               // Локаль имен папок, например ru или pt-BR (опционально)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -74,12 +132,21 @@ func (x *CreateUserDirectoryRequest) GetUsername() string {
 	return ""
 }
 
+func (x *CreateUserDirectoryRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
 // Ответ на создание директории пользователя
 type CreateUserDirectoryResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`                                 // Успешность операции
 	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`                                  // Сообщение о результате
 	DirectoryPath string                 `protobuf:"bytes,3,opt,name=directory_path,json=directoryPath,proto3" json:"directory_path,omitempty"` // Путь к созданной директории
+	Created       []string               `protobuf:"bytes,4,rep,name=created,proto3" json:"created,omitempty"`                                  // Созданные папки и файлы шаблона
+	Existing      int32                  `protobuf:"varint,5,opt,name=existing,proto3" json:"existing,omitempty"`                               // Записи шаблона, которые уже были
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -135,20 +202,2683 @@ func (x *CreateUserDirectoryResponse) GetDirectoryPath() string {
 	return ""
 }
 
-var File_file_service_proto protoreflect.FileDescriptor
+func (x *CreateUserDirectoryResponse) GetCreated() []string {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
 
-const file_file_service_proto_rawDesc = "" +
-	"\n" +
-	"\x12file_service.proto\x12\vfileservice\"Q\n" +
-	"\x1aCreateUserDirectoryRequest\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1a\n" +
-	"\busername\x18\x02 \x01(\tR\busername\"x\n" +
-	"\x1bCreateUserDirectoryResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
-	"\x0edirectory_path\x18\x03 \x01(\tR\rdirectoryPath2w\n" +
-	"\vFileService\x12h\n" +
-	"\x13CreateUserDirectory\x12'.fileservice.CreateUserDirectoryRequest\x1a(.fileservice.CreateUserDirectoryResponseB\n" +
+func (x *CreateUserDirectoryResponse) GetExisting() int32 {
+	if x != nil {
+		return x.Existing
+	}
+	return 0
+}
+
+// Запрос на применение шаблона к существующему пользователю
+type ApplyUserTemplateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Locale        string                 `protobuf:"bytes,3,opt,name=locale,proto3" json:"locale,omitempty"`
+	DryRun        bool                   `protobuf:"varint,4,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"` // Только показать, что будет создано
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyUserTemplateRequest) Reset() {
+	*x = ApplyUserTemplateRequest{}
+	mi := &file_file_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyUserTemplateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyUserTemplateRequest) ProtoMessage() {}
+
+func (x *ApplyUserTemplateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyUserTemplateRequest.ProtoReflect.Descriptor instead.
+func (*ApplyUserTemplateRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ApplyUserTemplateRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ApplyUserTemplateRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ApplyUserTemplateRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *ApplyUserTemplateRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type ApplyUserTemplateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Locale        string                 `protobuf:"bytes,1,opt,name=locale,proto3" json:"locale,omitempty"`
+	Created       []string               `protobuf:"bytes,2,rep,name=created,proto3" json:"created,omitempty"`
+	Existing      int32                  `protobuf:"varint,3,opt,name=existing,proto3" json:"existing,omitempty"`
+	Skipped       []string               `protobuf:"bytes,4,rep,name=skipped,proto3" json:"skipped,omitempty"` // В корзине или заняты записью другого типа
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyUserTemplateResponse) Reset() {
+	*x = ApplyUserTemplateResponse{}
+	mi := &file_file_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyUserTemplateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyUserTemplateResponse) ProtoMessage() {}
+
+func (x *ApplyUserTemplateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyUserTemplateResponse.ProtoReflect.Descriptor instead.
+func (*ApplyUserTemplateResponse) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ApplyUserTemplateResponse) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *ApplyUserTemplateResponse) GetCreated() []string {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *ApplyUserTemplateResponse) GetExisting() int32 {
+	if x != nil {
+		return x.Existing
+	}
+	return 0
+}
+
+func (x *ApplyUserTemplateResponse) GetSkipped() []string {
+	if x != nil {
+		return x.Skipped
+	}
+	return nil
+}
+
+type DeleteUserDataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Immediate     bool                   `protobuf:"varint,2,opt,name=immediate,proto3" json:"immediate,omitempty"` // Удалить безвозвратно без отсрочки
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserDataRequest) Reset() {
+	*x = DeleteUserDataRequest{}
+	mi := &file_file_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDataRequest) ProtoMessage() {}
+
+func (x *DeleteUserDataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDataRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserDataRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteUserDataRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *DeleteUserDataRequest) GetImmediate() bool {
+	if x != nil {
+		return x.Immediate
+	}
+	return false
+}
+
+type DeleteUserDataResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestedAt   *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=requested_at,json=requestedAt,proto3" json:"requested_at,omitempty"`
+	PurgeAt       *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=purge_at,json=purgeAt,proto3" json:"purge_at,omitempty"`                 // Когда данные будут удалены безвозвратно
+	TrashedCount  int32                  `protobuf:"varint,3,opt,name=trashed_count,json=trashedCount,proto3" json:"trashed_count,omitempty"` // Сколько файлов и папок верхнего уровня перенесено в корзину
+	Purged        bool                   `protobuf:"varint,4,opt,name=purged,proto3" json:"purged,omitempty"`                                 // Данные уже удалены (immediate)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserDataResponse) Reset() {
+	*x = DeleteUserDataResponse{}
+	mi := &file_file_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserDataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserDataResponse) ProtoMessage() {}
+
+func (x *DeleteUserDataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserDataResponse.ProtoReflect.Descriptor instead.
+func (*DeleteUserDataResponse) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteUserDataResponse) GetRequestedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RequestedAt
+	}
+	return nil
+}
+
+func (x *DeleteUserDataResponse) GetPurgeAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PurgeAt
+	}
+	return nil
+}
+
+func (x *DeleteUserDataResponse) GetTrashedCount() int32 {
+	if x != nil {
+		return x.TrashedCount
+	}
+	return 0
+}
+
+func (x *DeleteUserDataResponse) GetPurged() bool {
+	if x != nil {
+		return x.Purged
+	}
+	return false
+}
+
+type CancelUserDataDeletionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelUserDataDeletionRequest) Reset() {
+	*x = CancelUserDataDeletionRequest{}
+	mi := &file_file_service_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelUserDataDeletionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelUserDataDeletionRequest) ProtoMessage() {}
+
+func (x *CancelUserDataDeletionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelUserDataDeletionRequest.ProtoReflect.Descriptor instead.
+func (*CancelUserDataDeletionRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CancelUserDataDeletionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type CancelUserDataDeletionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelUserDataDeletionResponse) Reset() {
+	*x = CancelUserDataDeletionResponse{}
+	mi := &file_file_service_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelUserDataDeletionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelUserDataDeletionResponse) ProtoMessage() {}
+
+func (x *CancelUserDataDeletionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelUserDataDeletionResponse.ProtoReflect.Descriptor instead.
+func (*CancelUserDataDeletionResponse) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{7}
+}
+
+type GetUserStorageUsageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserStorageUsageRequest) Reset() {
+	*x = GetUserStorageUsageRequest{}
+	mi := &file_file_service_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserStorageUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserStorageUsageRequest) ProtoMessage() {}
+
+func (x *GetUserStorageUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserStorageUsageRequest.ProtoReflect.Descriptor instead.
+func (*GetUserStorageUsageRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetUserStorageUsageRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+// Место, занятое файлами одной категории
+type CategoryUsage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bytes         int64                  `protobuf:"varint,1,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Files         int64                  `protobuf:"varint,2,opt,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CategoryUsage) Reset() {
+	*x = CategoryUsage{}
+	mi := &file_file_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CategoryUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CategoryUsage) ProtoMessage() {}
+
+func (x *CategoryUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CategoryUsage.ProtoReflect.Descriptor instead.
+func (*CategoryUsage) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CategoryUsage) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *CategoryUsage) GetFiles() int64 {
+	if x != nil {
+		return x.Files
+	}
+	return 0
+}
+
+type UserStorageUsage struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	UserId         string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	TotalBytes     int64                  `protobuf:"varint,2,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"` // Без корзины
+	FileCount      int64                  `protobuf:"varint,3,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"`
+	FolderCount    int64                  `protobuf:"varint,4,opt,name=folder_count,json=folderCount,proto3" json:"folder_count,omitempty"`
+	TrashBytes     int64                  `protobuf:"varint,5,opt,name=trash_bytes,json=trashBytes,proto3" json:"trash_bytes,omitempty"`
+	TrashFileCount int64                  `protobuf:"varint,6,opt,name=trash_file_count,json=trashFileCount,proto3" json:"trash_file_count,omitempty"`
+	// documents, images, videos, audio, archives, other
+	Categories      map[string]*CategoryUsage `protobuf:"bytes,7,rep,name=categories,proto3" json:"categories,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	DeletionPurgeAt *timestamppb.Timestamp    `protobuf:"bytes,8,opt,name=deletion_purge_at,json=deletionPurgeAt,proto3" json:"deletion_purge_at,omitempty"` // Заполнено, если запрошено удаление данных
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *UserStorageUsage) Reset() {
+	*x = UserStorageUsage{}
+	mi := &file_file_service_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserStorageUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserStorageUsage) ProtoMessage() {}
+
+func (x *UserStorageUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserStorageUsage.ProtoReflect.Descriptor instead.
+func (*UserStorageUsage) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UserStorageUsage) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UserStorageUsage) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *UserStorageUsage) GetFileCount() int64 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+func (x *UserStorageUsage) GetFolderCount() int64 {
+	if x != nil {
+		return x.FolderCount
+	}
+	return 0
+}
+
+func (x *UserStorageUsage) GetTrashBytes() int64 {
+	if x != nil {
+		return x.TrashBytes
+	}
+	return 0
+}
+
+func (x *UserStorageUsage) GetTrashFileCount() int64 {
+	if x != nil {
+		return x.TrashFileCount
+	}
+	return 0
+}
+
+func (x *UserStorageUsage) GetCategories() map[string]*CategoryUsage {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *UserStorageUsage) GetDeletionPurgeAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeletionPurgeAt
+	}
+	return nil
+}
+
+// Файл или папка
+type FileInfo struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	OwnerId        string                 `protobuf:"bytes,2,opt,name=owner_id,json=ownerId,proto3" json:"owner_id,omitempty"`
+	ParentId       string                 `protobuf:"bytes,3,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"` // Пусто для корня пользователя
+	Name           string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	MimeType       string                 `protobuf:"bytes,5,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Size           int64                  `protobuf:"varint,6,opt,name=size,proto3" json:"size,omitempty"`
+	IsFolder       bool                   `protobuf:"varint,7,opt,name=is_folder,json=isFolder,proto3" json:"is_folder,omitempty"`
+	IsTrashed      bool                   `protobuf:"varint,8,opt,name=is_trashed,json=isTrashed,proto3" json:"is_trashed,omitempty"`
+	Starred        bool                   `protobuf:"varint,9,opt,name=starred,proto3" json:"starred,omitempty"`
+	Version        int64                  `protobuf:"varint,10,opt,name=version,proto3" json:"version,omitempty"` // Номер версии для expected_version
+	Sha256Checksum string                 `protobuf:"bytes,11,opt,name=sha256_checksum,json=sha256Checksum,proto3" json:"sha256_checksum,omitempty"`
+	Md5Checksum    string                 `protobuf:"bytes,12,opt,name=md5_checksum,json=md5Checksum,proto3" json:"md5_checksum,omitempty"`
+	CreatedAt      *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	TrashedAt      *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=trashed_at,json=trashedAt,proto3" json:"trashed_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FileInfo) Reset() {
+	*x = FileInfo{}
+	mi := &file_file_service_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileInfo) ProtoMessage() {}
+
+func (x *FileInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileInfo.ProtoReflect.Descriptor instead.
+func (*FileInfo) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *FileInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *FileInfo) GetOwnerId() string {
+	if x != nil {
+		return x.OwnerId
+	}
+	return ""
+}
+
+func (x *FileInfo) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+func (x *FileInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *FileInfo) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *FileInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileInfo) GetIsFolder() bool {
+	if x != nil {
+		return x.IsFolder
+	}
+	return false
+}
+
+func (x *FileInfo) GetIsTrashed() bool {
+	if x != nil {
+		return x.IsTrashed
+	}
+	return false
+}
+
+func (x *FileInfo) GetStarred() bool {
+	if x != nil {
+		return x.Starred
+	}
+	return false
+}
+
+func (x *FileInfo) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *FileInfo) GetSha256Checksum() string {
+	if x != nil {
+		return x.Sha256Checksum
+	}
+	return ""
+}
+
+func (x *FileInfo) GetMd5Checksum() string {
+	if x != nil {
+		return x.Md5Checksum
+	}
+	return ""
+}
+
+func (x *FileInfo) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *FileInfo) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *FileInfo) GetTrashedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TrashedAt
+	}
+	return nil
+}
+
+// Право доступа к файлу
+type PermissionInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	GranteeId     string                 `protobuf:"bytes,3,opt,name=grantee_id,json=granteeId,proto3" json:"grantee_id,omitempty"`       // Пусто для grantee_type ANYONE
+	GranteeType   string                 `protobuf:"bytes,4,opt,name=grantee_type,json=granteeType,proto3" json:"grantee_type,omitempty"` // USER, GROUP, DOMAIN, ANYONE
+	Role          string                 `protobuf:"bytes,5,opt,name=role,proto3" json:"role,omitempty"`                                  // OWNER, ORGANIZER, WRITER, COMMENTER, READER
+	AllowShare    bool                   `protobuf:"varint,6,opt,name=allow_share,json=allowShare,proto3" json:"allow_share,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PermissionInfo) Reset() {
+	*x = PermissionInfo{}
+	mi := &file_file_service_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PermissionInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PermissionInfo) ProtoMessage() {}
+
+func (x *PermissionInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PermissionInfo.ProtoReflect.Descriptor instead.
+func (*PermissionInfo) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PermissionInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PermissionInfo) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *PermissionInfo) GetGranteeId() string {
+	if x != nil {
+		return x.GranteeId
+	}
+	return ""
+}
+
+func (x *PermissionInfo) GetGranteeType() string {
+	if x != nil {
+		return x.GranteeType
+	}
+	return ""
+}
+
+func (x *PermissionInfo) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *PermissionInfo) GetAllowShare() bool {
+	if x != nil {
+		return x.AllowShare
+	}
+	return false
+}
+
+func (x *PermissionInfo) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+// Ревизия содержимого файла
+type RevisionInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RevisionId    int64                  `protobuf:"varint,1,opt,name=revision_id,json=revisionId,proto3" json:"revision_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	MimeType      string                 `protobuf:"bytes,4,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Md5Checksum   string                 `protobuf:"bytes,5,opt,name=md5_checksum,json=md5Checksum,proto3" json:"md5_checksum,omitempty"`
+	UserId        string                 `protobuf:"bytes,6,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // Кто записал ревизию
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevisionInfo) Reset() {
+	*x = RevisionInfo{}
+	mi := &file_file_service_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevisionInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevisionInfo) ProtoMessage() {}
+
+func (x *RevisionInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevisionInfo.ProtoReflect.Descriptor instead.
+func (*RevisionInfo) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *RevisionInfo) GetRevisionId() int64 {
+	if x != nil {
+		return x.RevisionId
+	}
+	return 0
+}
+
+func (x *RevisionInfo) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *RevisionInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *RevisionInfo) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *RevisionInfo) GetMd5Checksum() string {
+	if x != nil {
+		return x.Md5Checksum
+	}
+	return ""
+}
+
+func (x *RevisionInfo) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *RevisionInfo) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type GetFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetFileRequest) Reset() {
+	*x = GetFileRequest{}
+	mi := &file_file_service_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFileRequest) ProtoMessage() {}
+
+func (x *GetFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFileRequest.ProtoReflect.Descriptor instead.
+func (*GetFileRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetFileRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *GetFileRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+type ListFolderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FolderId      string                 `protobuf:"bytes,2,opt,name=folder_id,json=folderId,proto3" json:"folder_id,omitempty"` // Пусто для корня пользователя
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFolderRequest) Reset() {
+	*x = ListFolderRequest{}
+	mi := &file_file_service_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFolderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFolderRequest) ProtoMessage() {}
+
+func (x *ListFolderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFolderRequest.ProtoReflect.Descriptor instead.
+func (*ListFolderRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListFolderRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListFolderRequest) GetFolderId() string {
+	if x != nil {
+		return x.FolderId
+	}
+	return ""
+}
+
+type FileList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*FileInfo            `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileList) Reset() {
+	*x = FileList{}
+	mi := &file_file_service_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileList) ProtoMessage() {}
+
+func (x *FileList) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileList.ProtoReflect.Descriptor instead.
+func (*FileList) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *FileList) GetFiles() []*FileInfo {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type CreateFolderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ParentId      string                 `protobuf:"bytes,2,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"` // Пусто для корня пользователя
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Conflict      ConflictPolicy         `protobuf:"varint,4,opt,name=conflict,proto3,enum=fileservice.ConflictPolicy" json:"conflict,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateFolderRequest) Reset() {
+	*x = CreateFolderRequest{}
+	mi := &file_file_service_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateFolderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateFolderRequest) ProtoMessage() {}
+
+func (x *CreateFolderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateFolderRequest.ProtoReflect.Descriptor instead.
+func (*CreateFolderRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *CreateFolderRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CreateFolderRequest) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+func (x *CreateFolderRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateFolderRequest) GetConflict() ConflictPolicy {
+	if x != nil {
+		return x.Conflict
+	}
+	return ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED
+}
+
+type MoveRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId          string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	NewParentId     string                 `protobuf:"bytes,3,opt,name=new_parent_id,json=newParentId,proto3" json:"new_parent_id,omitempty"` // Пусто для корня пользователя
+	Conflict        ConflictPolicy         `protobuf:"varint,4,opt,name=conflict,proto3,enum=fileservice.ConflictPolicy" json:"conflict,omitempty"`
+	ExpectedVersion int64                  `protobuf:"varint,5,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"` // Если не 0 - FAILED_PRECONDITION при другой версии файла
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *MoveRequest) Reset() {
+	*x = MoveRequest{}
+	mi := &file_file_service_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MoveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MoveRequest) ProtoMessage() {}
+
+func (x *MoveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MoveRequest.ProtoReflect.Descriptor instead.
+func (*MoveRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *MoveRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *MoveRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *MoveRequest) GetNewParentId() string {
+	if x != nil {
+		return x.NewParentId
+	}
+	return ""
+}
+
+func (x *MoveRequest) GetConflict() ConflictPolicy {
+	if x != nil {
+		return x.Conflict
+	}
+	return ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED
+}
+
+func (x *MoveRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type CopyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	NewParentId   string                 `protobuf:"bytes,3,opt,name=new_parent_id,json=newParentId,proto3" json:"new_parent_id,omitempty"` // Пусто для корня пользователя
+	NewName       string                 `protobuf:"bytes,4,opt,name=new_name,json=newName,proto3" json:"new_name,omitempty"`               // Пусто - имя исходного файла
+	Conflict      ConflictPolicy         `protobuf:"varint,5,opt,name=conflict,proto3,enum=fileservice.ConflictPolicy" json:"conflict,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CopyRequest) Reset() {
+	*x = CopyRequest{}
+	mi := &file_file_service_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CopyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CopyRequest) ProtoMessage() {}
+
+func (x *CopyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CopyRequest.ProtoReflect.Descriptor instead.
+func (*CopyRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *CopyRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CopyRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *CopyRequest) GetNewParentId() string {
+	if x != nil {
+		return x.NewParentId
+	}
+	return ""
+}
+
+func (x *CopyRequest) GetNewName() string {
+	if x != nil {
+		return x.NewName
+	}
+	return ""
+}
+
+func (x *CopyRequest) GetConflict() ConflictPolicy {
+	if x != nil {
+		return x.Conflict
+	}
+	return ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED
+}
+
+type RenameRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId          string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	NewName         string                 `protobuf:"bytes,3,opt,name=new_name,json=newName,proto3" json:"new_name,omitempty"`
+	ExpectedVersion int64                  `protobuf:"varint,4,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RenameRequest) Reset() {
+	*x = RenameRequest{}
+	mi := &file_file_service_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenameRequest) ProtoMessage() {}
+
+func (x *RenameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenameRequest.ProtoReflect.Descriptor instead.
+func (*RenameRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *RenameRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *RenameRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *RenameRequest) GetNewName() string {
+	if x != nil {
+		return x.NewName
+	}
+	return ""
+}
+
+func (x *RenameRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type DeleteRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId          string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Permanent       bool                   `protobuf:"varint,3,opt,name=permanent,proto3" json:"permanent,omitempty"` // Удалить безвозвратно, минуя корзину
+	ExpectedVersion int64                  `protobuf:"varint,4,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_file_service_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *DeleteRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetPermanent() bool {
+	if x != nil {
+		return x.Permanent
+	}
+	return false
+}
+
+func (x *DeleteRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_file_service_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{22}
+}
+
+type RestoreRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestoreRequest) Reset() {
+	*x = RestoreRequest{}
+	mi := &file_file_service_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreRequest) ProtoMessage() {}
+
+func (x *RestoreRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreRequest.ProtoReflect.Descriptor instead.
+func (*RestoreRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RestoreRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *RestoreRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+type SetStarredRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Starred       bool                   `protobuf:"varint,3,opt,name=starred,proto3" json:"starred,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetStarredRequest) Reset() {
+	*x = SetStarredRequest{}
+	mi := &file_file_service_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetStarredRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetStarredRequest) ProtoMessage() {}
+
+func (x *SetStarredRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetStarredRequest.ProtoReflect.Descriptor instead.
+func (*SetStarredRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SetStarredRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SetStarredRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *SetStarredRequest) GetStarred() bool {
+	if x != nil {
+		return x.Starred
+	}
+	return false
+}
+
+type ListPermissionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPermissionsRequest) Reset() {
+	*x = ListPermissionsRequest{}
+	mi := &file_file_service_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPermissionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPermissionsRequest) ProtoMessage() {}
+
+func (x *ListPermissionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPermissionsRequest.ProtoReflect.Descriptor instead.
+func (*ListPermissionsRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ListPermissionsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListPermissionsRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+type PermissionList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Permissions   []*PermissionInfo      `protobuf:"bytes,1,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PermissionList) Reset() {
+	*x = PermissionList{}
+	mi := &file_file_service_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PermissionList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PermissionList) ProtoMessage() {}
+
+func (x *PermissionList) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PermissionList.ProtoReflect.Descriptor instead.
+func (*PermissionList) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *PermissionList) GetPermissions() []*PermissionInfo {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+type GrantPermissionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // Владелец файла
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	GranteeId     string                 `protobuf:"bytes,3,opt,name=grantee_id,json=granteeId,proto3" json:"grantee_id,omitempty"`
+	GranteeType   string                 `protobuf:"bytes,4,opt,name=grantee_type,json=granteeType,proto3" json:"grantee_type,omitempty"` // По умолчанию USER
+	Role          string                 `protobuf:"bytes,5,opt,name=role,proto3" json:"role,omitempty"`
+	AllowShare    bool                   `protobuf:"varint,6,opt,name=allow_share,json=allowShare,proto3" json:"allow_share,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GrantPermissionRequest) Reset() {
+	*x = GrantPermissionRequest{}
+	mi := &file_file_service_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GrantPermissionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GrantPermissionRequest) ProtoMessage() {}
+
+func (x *GrantPermissionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GrantPermissionRequest.ProtoReflect.Descriptor instead.
+func (*GrantPermissionRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *GrantPermissionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *GrantPermissionRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *GrantPermissionRequest) GetGranteeId() string {
+	if x != nil {
+		return x.GranteeId
+	}
+	return ""
+}
+
+func (x *GrantPermissionRequest) GetGranteeType() string {
+	if x != nil {
+		return x.GranteeType
+	}
+	return ""
+}
+
+func (x *GrantPermissionRequest) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *GrantPermissionRequest) GetAllowShare() bool {
+	if x != nil {
+		return x.AllowShare
+	}
+	return false
+}
+
+type RevokePermissionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	GranteeId     string                 `protobuf:"bytes,3,opt,name=grantee_id,json=granteeId,proto3" json:"grantee_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokePermissionRequest) Reset() {
+	*x = RevokePermissionRequest{}
+	mi := &file_file_service_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokePermissionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokePermissionRequest) ProtoMessage() {}
+
+func (x *RevokePermissionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokePermissionRequest.ProtoReflect.Descriptor instead.
+func (*RevokePermissionRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *RevokePermissionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *RevokePermissionRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *RevokePermissionRequest) GetGranteeId() string {
+	if x != nil {
+		return x.GranteeId
+	}
+	return ""
+}
+
+type RevokePermissionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokePermissionResponse) Reset() {
+	*x = RevokePermissionResponse{}
+	mi := &file_file_service_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokePermissionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokePermissionResponse) ProtoMessage() {}
+
+func (x *RevokePermissionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokePermissionResponse.ProtoReflect.Descriptor instead.
+func (*RevokePermissionResponse) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{29}
+}
+
+type ListRevisionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRevisionsRequest) Reset() {
+	*x = ListRevisionsRequest{}
+	mi := &file_file_service_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRevisionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRevisionsRequest) ProtoMessage() {}
+
+func (x *ListRevisionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRevisionsRequest.ProtoReflect.Descriptor instead.
+func (*ListRevisionsRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *ListRevisionsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListRevisionsRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+type RevisionList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revisions     []*RevisionInfo        `protobuf:"bytes,1,rep,name=revisions,proto3" json:"revisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevisionList) Reset() {
+	*x = RevisionList{}
+	mi := &file_file_service_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevisionList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevisionList) ProtoMessage() {}
+
+func (x *RevisionList) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevisionList.ProtoReflect.Descriptor instead.
+func (*RevisionList) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *RevisionList) GetRevisions() []*RevisionInfo {
+	if x != nil {
+		return x.Revisions
+	}
+	return nil
+}
+
+type RestoreRevisionRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId          string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	RevisionId      int64                  `protobuf:"varint,3,opt,name=revision_id,json=revisionId,proto3" json:"revision_id,omitempty"`
+	ExpectedVersion int64                  `protobuf:"varint,4,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RestoreRevisionRequest) Reset() {
+	*x = RestoreRevisionRequest{}
+	mi := &file_file_service_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreRevisionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreRevisionRequest) ProtoMessage() {}
+
+func (x *RestoreRevisionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreRevisionRequest.ProtoReflect.Descriptor instead.
+func (*RestoreRevisionRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *RestoreRevisionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *RestoreRevisionRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *RestoreRevisionRequest) GetRevisionId() int64 {
+	if x != nil {
+		return x.RevisionId
+	}
+	return 0
+}
+
+func (x *RestoreRevisionRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+// Часть содержимого файла
+type Chunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Header        *UploadHeader          `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`  // Только в первом сообщении загрузки
+	File          *FileInfo              `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`      // Только в первой части скачивания
+	Offset        int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"` // Смещение data от начала файла
+	Data          []byte                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Sha256        string                 `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"` // SHA-256 data в hex. При загрузке необязателен, при несовпадении DATA_LOSS
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chunk) Reset() {
+	*x = Chunk{}
+	mi := &file_file_service_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chunk) ProtoMessage() {}
+
+func (x *Chunk) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *Chunk) GetHeader() *UploadHeader {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *Chunk) GetFile() *FileInfo {
+	if x != nil {
+		return x.File
+	}
+	return nil
+}
+
+func (x *Chunk) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *Chunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Chunk) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+// Параметры загрузки
+type UploadHeader struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UploadId        string                 `protobuf:"bytes,2,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"` // UUID загрузки. Пусто - новая загрузка, ID приходит в метаданных x-upload-id
+	FileId          string                 `protobuf:"bytes,3,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`       // Загрузить новую версию существующего файла
+	ParentId        string                 `protobuf:"bytes,4,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"` // Пусто для корня пользователя
+	Name            string                 `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`                         // Имя нового файла, не нужно вместе с file_id
+	Size            int64                  `protobuf:"varint,6,opt,name=size,proto3" json:"size,omitempty"`                        // Полный размер файла
+	Sha256          string                 `protobuf:"bytes,7,opt,name=sha256,proto3" json:"sha256,omitempty"`                     // SHA-256 всего файла в hex, сверяется по завершении
+	Conflict        ConflictPolicy         `protobuf:"varint,8,opt,name=conflict,proto3,enum=fileservice.ConflictPolicy" json:"conflict,omitempty"`
+	ExpectedVersion int64                  `protobuf:"varint,9,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"` // Для file_id: FAILED_PRECONDITION, если файл успел измениться
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *UploadHeader) Reset() {
+	*x = UploadHeader{}
+	mi := &file_file_service_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadHeader) ProtoMessage() {}
+
+func (x *UploadHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadHeader.ProtoReflect.Descriptor instead.
+func (*UploadHeader) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *UploadHeader) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadHeader) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetConflict() ConflictPolicy {
+	if x != nil {
+		return x.Conflict
+	}
+	return ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED
+}
+
+func (x *UploadHeader) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type UploadResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"` // Сколько байт принято подряд с начала файла
+	File          *FileInfo              `protobuf:"bytes,3,opt,name=file,proto3" json:"file,omitempty"`      // Заполнено, когда приняты все size байт
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadResult) Reset() {
+	*x = UploadResult{}
+	mi := &file_file_service_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadResult) ProtoMessage() {}
+
+func (x *UploadResult) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadResult.ProtoReflect.Descriptor instead.
+func (*UploadResult) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *UploadResult) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *UploadResult) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *UploadResult) GetFile() *FileInfo {
+	if x != nil {
+		return x.File
+	}
+	return nil
+}
+
+type UploadStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UploadId      string                 `protobuf:"bytes,2,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadStatusRequest) Reset() {
+	*x = UploadStatusRequest{}
+	mi := &file_file_service_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadStatusRequest) ProtoMessage() {}
+
+func (x *UploadStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadStatusRequest.ProtoReflect.Descriptor instead.
+func (*UploadStatusRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *UploadStatusRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UploadStatusRequest) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+type UploadStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadStatus) Reset() {
+	*x = UploadStatus{}
+	mi := &file_file_service_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadStatus) ProtoMessage() {}
+
+func (x *UploadStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadStatus.ProtoReflect.Descriptor instead.
+func (*UploadStatus) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *UploadStatus) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *UploadStatus) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *UploadStatus) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadStatus) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+type FileRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	UserId          string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	FileId          string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Offset          int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`                                          // С какого байта начать
+	Length          int64                  `protobuf:"varint,4,opt,name=length,proto3" json:"length,omitempty"`                                          // 0 - до конца файла
+	ChunkSize       int32                  `protobuf:"varint,5,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`                   // 0 - размер по умолчанию, 1 МБ
+	ExpectedVersion int64                  `protobuf:"varint,6,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"` // FAILED_PRECONDITION, если версия файла другая
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *FileRequest) Reset() {
+	*x = FileRequest{}
+	mi := &file_file_service_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileRequest) ProtoMessage() {}
+
+func (x *FileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_file_service_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileRequest.ProtoReflect.Descriptor instead.
+func (*FileRequest) Descriptor() ([]byte, []int) {
+	return file_file_service_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *FileRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *FileRequest) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *FileRequest) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *FileRequest) GetChunkSize() int32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+func (x *FileRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+var File_file_service_proto protoreflect.FileDescriptor
+
+const file_file_service_proto_rawDesc = "" +
+	"\n" +
+	"\x12file_service.proto\x12\vfileservice\x1a\x1fgoogle/protobuf/timestamp.proto\"i\n" +
+	"\x1aCreateUserDirectoryRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x16\n" +
+	"\x06locale\x18\x03 \x01(\tR\x06locale\"\xae\x01\n" +
+	"\x1bCreateUserDirectoryResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
+	"\x0edirectory_path\x18\x03 \x01(\tR\rdirectoryPath\x12\x18\n" +
+	"\acreated\x18\x04 \x03(\tR\acreated\x12\x1a\n" +
+	"\bexisting\x18\x05 \x01(\x05R\bexisting\"\x80\x01\n" +
+	"\x18ApplyUserTemplateRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x16\n" +
+	"\x06locale\x18\x03 \x01(\tR\x06locale\x12\x17\n" +
+	"\adry_run\x18\x04 \x01(\bR\x06dryRun\"\x83\x01\n" +
+	"\x19ApplyUserTemplateResponse\x12\x16\n" +
+	"\x06locale\x18\x01 \x01(\tR\x06locale\x12\x18\n" +
+	"\acreated\x18\x02 \x03(\tR\acreated\x12\x1a\n" +
+	"\bexisting\x18\x03 \x01(\x05R\bexisting\x12\x18\n" +
+	"\askipped\x18\x04 \x03(\tR\askipped\"N\n" +
+	"\x15DeleteUserDataRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1c\n" +
+	"\timmediate\x18\x02 \x01(\bR\timmediate\"\xcb\x01\n" +
+	"\x16DeleteUserDataResponse\x12=\n" +
+	"\frequested_at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\vrequestedAt\x125\n" +
+	"\bpurge_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\apurgeAt\x12#\n" +
+	"\rtrashed_count\x18\x03 \x01(\x05R\ftrashedCount\x12\x16\n" +
+	"\x06purged\x18\x04 \x01(\bR\x06purged\"8\n" +
+	"\x1dCancelUserDataDeletionRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\" \n" +
+	"\x1eCancelUserDataDeletionResponse\"5\n" +
+	"\x1aGetUserStorageUsageRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\";\n" +
+	"\rCategoryUsage\x12\x14\n" +
+	"\x05bytes\x18\x01 \x01(\x03R\x05bytes\x12\x14\n" +
+	"\x05files\x18\x02 \x01(\x03R\x05files\"\xcb\x03\n" +
+	"\x10UserStorageUsage\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1f\n" +
+	"\vtotal_bytes\x18\x02 \x01(\x03R\n" +
+	"totalBytes\x12\x1d\n" +
+	"\n" +
+	"file_count\x18\x03 \x01(\x03R\tfileCount\x12!\n" +
+	"\ffolder_count\x18\x04 \x01(\x03R\vfolderCount\x12\x1f\n" +
+	"\vtrash_bytes\x18\x05 \x01(\x03R\n" +
+	"trashBytes\x12(\n" +
+	"\x10trash_file_count\x18\x06 \x01(\x03R\x0etrashFileCount\x12M\n" +
+	"\n" +
+	"categories\x18\a \x03(\v2-.fileservice.UserStorageUsage.CategoriesEntryR\n" +
+	"categories\x12F\n" +
+	"\x11deletion_purge_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x0fdeletionPurgeAt\x1aY\n" +
+	"\x0fCategoriesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.fileservice.CategoryUsageR\x05value:\x028\x01\"\x84\x04\n" +
+	"\bFileInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\bowner_id\x18\x02 \x01(\tR\aownerId\x12\x1b\n" +
+	"\tparent_id\x18\x03 \x01(\tR\bparentId\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x1b\n" +
+	"\tmime_type\x18\x05 \x01(\tR\bmimeType\x12\x12\n" +
+	"\x04size\x18\x06 \x01(\x03R\x04size\x12\x1b\n" +
+	"\tis_folder\x18\a \x01(\bR\bisFolder\x12\x1d\n" +
+	"\n" +
+	"is_trashed\x18\b \x01(\bR\tisTrashed\x12\x18\n" +
+	"\astarred\x18\t \x01(\bR\astarred\x12\x18\n" +
+	"\aversion\x18\n" +
+	" \x01(\x03R\aversion\x12'\n" +
+	"\x0fsha256_checksum\x18\v \x01(\tR\x0esha256Checksum\x12!\n" +
+	"\fmd5_checksum\x18\f \x01(\tR\vmd5Checksum\x129\n" +
+	"\n" +
+	"created_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x129\n" +
+	"\n" +
+	"trashed_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\ttrashedAt\"\xeb\x01\n" +
+	"\x0ePermissionInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x1d\n" +
+	"\n" +
+	"grantee_id\x18\x03 \x01(\tR\tgranteeId\x12!\n" +
+	"\fgrantee_type\x18\x04 \x01(\tR\vgranteeType\x12\x12\n" +
+	"\x04role\x18\x05 \x01(\tR\x04role\x12\x1f\n" +
+	"\vallow_share\x18\x06 \x01(\bR\n" +
+	"allowShare\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xf0\x01\n" +
+	"\fRevisionInfo\x12\x1f\n" +
+	"\vrevision_id\x18\x01 \x01(\x03R\n" +
+	"revisionId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x1b\n" +
+	"\tmime_type\x18\x04 \x01(\tR\bmimeType\x12!\n" +
+	"\fmd5_checksum\x18\x05 \x01(\tR\vmd5Checksum\x12\x17\n" +
+	"\auser_id\x18\x06 \x01(\tR\x06userId\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"B\n" +
+	"\x0eGetFileRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\"I\n" +
+	"\x11ListFolderRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tfolder_id\x18\x02 \x01(\tR\bfolderId\"7\n" +
+	"\bFileList\x12+\n" +
+	"\x05files\x18\x01 \x03(\v2\x15.fileservice.FileInfoR\x05files\"\x98\x01\n" +
+	"\x13CreateFolderRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tparent_id\x18\x02 \x01(\tR\bparentId\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x127\n" +
+	"\bconflict\x18\x04 \x01(\x0e2\x1b.fileservice.ConflictPolicyR\bconflict\"\xc7\x01\n" +
+	"\vMoveRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\"\n" +
+	"\rnew_parent_id\x18\x03 \x01(\tR\vnewParentId\x127\n" +
+	"\bconflict\x18\x04 \x01(\x0e2\x1b.fileservice.ConflictPolicyR\bconflict\x12)\n" +
+	"\x10expected_version\x18\x05 \x01(\x03R\x0fexpectedVersion\"\xb7\x01\n" +
+	"\vCopyRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\"\n" +
+	"\rnew_parent_id\x18\x03 \x01(\tR\vnewParentId\x12\x19\n" +
+	"\bnew_name\x18\x04 \x01(\tR\anewName\x127\n" +
+	"\bconflict\x18\x05 \x01(\x0e2\x1b.fileservice.ConflictPolicyR\bconflict\"\x87\x01\n" +
+	"\rRenameRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x19\n" +
+	"\bnew_name\x18\x03 \x01(\tR\anewName\x12)\n" +
+	"\x10expected_version\x18\x04 \x01(\x03R\x0fexpectedVersion\"\x8a\x01\n" +
+	"\rDeleteRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x1c\n" +
+	"\tpermanent\x18\x03 \x01(\bR\tpermanent\x12)\n" +
+	"\x10expected_version\x18\x04 \x01(\x03R\x0fexpectedVersion\"\x10\n" +
+	"\x0eDeleteResponse\"B\n" +
+	"\x0eRestoreRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\"_\n" +
+	"\x11SetStarredRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x18\n" +
+	"\astarred\x18\x03 \x01(\bR\astarred\"J\n" +
+	"\x16ListPermissionsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\"O\n" +
+	"\x0ePermissionList\x12=\n" +
+	"\vpermissions\x18\x01 \x03(\v2\x1b.fileservice.PermissionInfoR\vpermissions\"\xc1\x01\n" +
+	"\x16GrantPermissionRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x1d\n" +
+	"\n" +
+	"grantee_id\x18\x03 \x01(\tR\tgranteeId\x12!\n" +
+	"\fgrantee_type\x18\x04 \x01(\tR\vgranteeType\x12\x12\n" +
+	"\x04role\x18\x05 \x01(\tR\x04role\x12\x1f\n" +
+	"\vallow_share\x18\x06 \x01(\bR\n" +
+	"allowShare\"j\n" +
+	"\x17RevokePermissionRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x1d\n" +
+	"\n" +
+	"grantee_id\x18\x03 \x01(\tR\tgranteeId\"\x1a\n" +
+	"\x18RevokePermissionResponse\"H\n" +
+	"\x14ListRevisionsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\"G\n" +
+	"\fRevisionList\x127\n" +
+	"\trevisions\x18\x01 \x03(\v2\x19.fileservice.RevisionInfoR\trevisions\"\x96\x01\n" +
+	"\x16RestoreRevisionRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x1f\n" +
+	"\vrevision_id\x18\x03 \x01(\x03R\n" +
+	"revisionId\x12)\n" +
+	"\x10expected_version\x18\x04 \x01(\x03R\x0fexpectedVersion\"\xa9\x01\n" +
+	"\x05Chunk\x121\n" +
+	"\x06header\x18\x01 \x01(\v2\x19.fileservice.UploadHeaderR\x06header\x12)\n" +
+	"\x04file\x18\x02 \x01(\v2\x15.fileservice.FileInfoR\x04file\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\"\x9e\x02\n" +
+	"\fUploadHeader\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tupload_id\x18\x02 \x01(\tR\buploadId\x12\x17\n" +
+	"\afile_id\x18\x03 \x01(\tR\x06fileId\x12\x1b\n" +
+	"\tparent_id\x18\x04 \x01(\tR\bparentId\x12\x12\n" +
+	"\x04name\x18\x05 \x01(\tR\x04name\x12\x12\n" +
+	"\x04size\x18\x06 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\a \x01(\tR\x06sha256\x127\n" +
+	"\bconflict\x18\b \x01(\x0e2\x1b.fileservice.ConflictPolicyR\bconflict\x12)\n" +
+	"\x10expected_version\x18\t \x01(\x03R\x0fexpectedVersion\"n\n" +
+	"\fUploadResult\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12)\n" +
+	"\x04file\x18\x03 \x01(\v2\x15.fileservice.FileInfoR\x04file\"K\n" +
+	"\x13UploadStatusRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tupload_id\x18\x02 \x01(\tR\buploadId\"\x92\x01\n" +
+	"\fUploadStatus\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x129\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"\xb9\x01\n" +
+	"\vFileRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
+	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12\x16\n" +
+	"\x06length\x18\x04 \x01(\x03R\x06length\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x05 \x01(\x05R\tchunkSize\x12)\n" +
+	"\x10expected_version\x18\x06 \x01(\x03R\x0fexpectedVersion*\xa5\x01\n" +
+	"\x0eConflictPolicy\x12\x1f\n" +
+	"\x1bCONFLICT_POLICY_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14CONFLICT_POLICY_FAIL\x10\x01\x12\x1a\n" +
+	"\x16CONFLICT_POLICY_RENAME\x10\x02\x12\x1b\n" +
+	"\x17CONFLICT_POLICY_REPLACE\x10\x03\x12\x1f\n" +
+	"\x1bCONFLICT_POLICY_NEW_VERSION\x10\x042\xc3\r\n" +
+	"\vFileService\x12h\n" +
+	"\x13CreateUserDirectory\x12'.fileservice.CreateUserDirectoryRequest\x1a(.fileservice.CreateUserDirectoryResponse\x12b\n" +
+	"\x11ApplyUserTemplate\x12%.fileservice.ApplyUserTemplateRequest\x1a&.fileservice.ApplyUserTemplateResponse\x12Y\n" +
+	"\x0eDeleteUserData\x12\".fileservice.DeleteUserDataRequest\x1a#.fileservice.DeleteUserDataResponse\x12q\n" +
+	"\x16CancelUserDataDeletion\x12*.fileservice.CancelUserDataDeletionRequest\x1a+.fileservice.CancelUserDataDeletionResponse\x12]\n" +
+	"\x13GetUserStorageUsage\x12'.fileservice.GetUserStorageUsageRequest\x1a\x1d.fileservice.UserStorageUsage\x12=\n" +
+	"\aGetFile\x12\x1b.fileservice.GetFileRequest\x1a\x15.fileservice.FileInfo\x12C\n" +
+	"\n" +
+	"ListFolder\x12\x1e.fileservice.ListFolderRequest\x1a\x15.fileservice.FileList\x12G\n" +
+	"\fCreateFolder\x12 .fileservice.CreateFolderRequest\x1a\x15.fileservice.FileInfo\x127\n" +
+	"\x04Move\x12\x18.fileservice.MoveRequest\x1a\x15.fileservice.FileInfo\x127\n" +
+	"\x04Copy\x12\x18.fileservice.CopyRequest\x1a\x15.fileservice.FileInfo\x12;\n" +
+	"\x06Rename\x12\x1a.fileservice.RenameRequest\x1a\x15.fileservice.FileInfo\x12A\n" +
+	"\x06Delete\x12\x1a.fileservice.DeleteRequest\x1a\x1b.fileservice.DeleteResponse\x12=\n" +
+	"\aRestore\x12\x1b.fileservice.RestoreRequest\x1a\x15.fileservice.FileInfo\x12C\n" +
+	"\n" +
+	"SetStarred\x12\x1e.fileservice.SetStarredRequest\x1a\x15.fileservice.FileInfo\x12S\n" +
+	"\x0fListPermissions\x12#.fileservice.ListPermissionsRequest\x1a\x1b.fileservice.PermissionList\x12S\n" +
+	"\x0fGrantPermission\x12#.fileservice.GrantPermissionRequest\x1a\x1b.fileservice.PermissionInfo\x12_\n" +
+	"\x10RevokePermission\x12$.fileservice.RevokePermissionRequest\x1a%.fileservice.RevokePermissionResponse\x12M\n" +
+	"\rListRevisions\x12!.fileservice.ListRevisionsRequest\x1a\x19.fileservice.RevisionList\x12M\n" +
+	"\x0fRestoreRevision\x12#.fileservice.RestoreRevisionRequest\x1a\x15.fileservice.FileInfo\x12=\n" +
+	"\n" +
+	"UploadFile\x12\x12.fileservice.Chunk\x1a\x19.fileservice.UploadResult(\x01\x12N\n" +
+	"\x0fGetUploadStatus\x12 .fileservice.UploadStatusRequest\x1a\x19.fileservice.UploadStatus\x12>\n" +
+	"\fDownloadFile\x12\x18.fileservice.FileRequest\x1a\x12.fileservice.Chunk0\x01B\n" +
 	"Z\b./protosb\x06proto3"
 
 var (
@@ -163,19 +2893,123 @@ func file_file_service_proto_rawDescGZIP() []byte {
 	return file_file_service_proto_rawDescData
 }
 
-var file_file_service_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_file_service_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_file_service_proto_msgTypes = make([]protoimpl.MessageInfo, 40)
 var file_file_service_proto_goTypes = []any{
-	(*CreateUserDirectoryRequest)(nil),  // 0: fileservice.CreateUserDirectoryRequest
-	(*CreateUserDirectoryResponse)(nil), // 1: fileservice.CreateUserDirectoryResponse
+	(ConflictPolicy)(0),                    // 0: fileservice.ConflictPolicy
+	(*CreateUserDirectoryRequest)(nil),     // 1: fileservice.CreateUserDirectoryRequest
+	(*CreateUserDirectoryResponse)(nil),    // 2: fileservice.CreateUserDirectoryResponse
+	(*ApplyUserTemplateRequest)(nil),       // 3: fileservice.ApplyUserTemplateRequest
+	(*ApplyUserTemplateResponse)(nil),      // 4: fileservice.ApplyUserTemplateResponse
+	(*DeleteUserDataRequest)(nil),          // 5: fileservice.DeleteUserDataRequest
+	(*DeleteUserDataResponse)(nil),         // 6: fileservice.DeleteUserDataResponse
+	(*CancelUserDataDeletionRequest)(nil),  // 7: fileservice.CancelUserDataDeletionRequest
+	(*CancelUserDataDeletionResponse)(nil), // 8: fileservice.CancelUserDataDeletionResponse
+	(*GetUserStorageUsageRequest)(nil),     // 9: fileservice.GetUserStorageUsageRequest
+	(*CategoryUsage)(nil),                  // 10: fileservice.CategoryUsage
+	(*UserStorageUsage)(nil),               // 11: fileservice.UserStorageUsage
+	(*FileInfo)(nil),                       // 12: fileservice.FileInfo
+	(*PermissionInfo)(nil),                 // 13: fileservice.PermissionInfo
+	(*RevisionInfo)(nil),                   // 14: fileservice.RevisionInfo
+	(*GetFileRequest)(nil),                 // 15: fileservice.GetFileRequest
+	(*ListFolderRequest)(nil),              // 16: fileservice.ListFolderRequest
+	(*FileList)(nil),                       // 17: fileservice.FileList
+	(*CreateFolderRequest)(nil),            // 18: fileservice.CreateFolderRequest
+	(*MoveRequest)(nil),                    // 19: fileservice.MoveRequest
+	(*CopyRequest)(nil),                    // 20: fileservice.CopyRequest
+	(*RenameRequest)(nil),                  // 21: fileservice.RenameRequest
+	(*DeleteRequest)(nil),                  // 22: fileservice.DeleteRequest
+	(*DeleteResponse)(nil),                 // 23: fileservice.DeleteResponse
+	(*RestoreRequest)(nil),                 // 24: fileservice.RestoreRequest
+	(*SetStarredRequest)(nil),              // 25: fileservice.SetStarredRequest
+	(*ListPermissionsRequest)(nil),         // 26: fileservice.ListPermissionsRequest
+	(*PermissionList)(nil),                 // 27: fileservice.PermissionList
+	(*GrantPermissionRequest)(nil),         // 28: fileservice.GrantPermissionRequest
+	(*RevokePermissionRequest)(nil),        // 29: fileservice.RevokePermissionRequest
+	(*RevokePermissionResponse)(nil),       // 30: fileservice.RevokePermissionResponse
+	(*ListRevisionsRequest)(nil),           // 31: fileservice.ListRevisionsRequest
+	(*RevisionList)(nil),                   // 32: fileservice.RevisionList
+	(*RestoreRevisionRequest)(nil),         // 33: fileservice.RestoreRevisionRequest
+	(*Chunk)(nil),                          // 34: fileservice.Chunk
+	(*UploadHeader)(nil),                   // 35: fileservice.UploadHeader
+	(*UploadResult)(nil),                   // 36: fileservice.UploadResult
+	(*UploadStatusRequest)(nil),            // 37: fileservice.UploadStatusRequest
+	(*UploadStatus)(nil),                   // 38: fileservice.UploadStatus
+	(*FileRequest)(nil),                    // 39: fileservice.FileRequest
+	nil,                                    // 40: fileservice.UserStorageUsage.CategoriesEntry
+	(*timestamppb.Timestamp)(nil),          // 41: google.protobuf.Timestamp
 }
 var file_file_service_proto_depIdxs = []int32{
-	0, // 0: fileservice.FileService.CreateUserDirectory:input_type -> fileservice.CreateUserDirectoryRequest
-	1, // 1: fileservice.FileService.CreateUserDirectory:output_type -> fileservice.CreateUserDirectoryResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	41, // 0: fileservice.DeleteUserDataResponse.requested_at:type_name -> google.protobuf.Timestamp
+	41, // 1: fileservice.DeleteUserDataResponse.purge_at:type_name -> google.protobuf.Timestamp
+	40, // 2: fileservice.UserStorageUsage.categories:type_name -> fileservice.UserStorageUsage.CategoriesEntry
+	41, // 3: fileservice.UserStorageUsage.deletion_purge_at:type_name -> google.protobuf.Timestamp
+	41, // 4: fileservice.FileInfo.created_at:type_name -> google.protobuf.Timestamp
+	41, // 5: fileservice.FileInfo.updated_at:type_name -> google.protobuf.Timestamp
+	41, // 6: fileservice.FileInfo.trashed_at:type_name -> google.protobuf.Timestamp
+	41, // 7: fileservice.PermissionInfo.created_at:type_name -> google.protobuf.Timestamp
+	41, // 8: fileservice.RevisionInfo.created_at:type_name -> google.protobuf.Timestamp
+	12, // 9: fileservice.FileList.files:type_name -> fileservice.FileInfo
+	0,  // 10: fileservice.CreateFolderRequest.conflict:type_name -> fileservice.ConflictPolicy
+	0,  // 11: fileservice.MoveRequest.conflict:type_name -> fileservice.ConflictPolicy
+	0,  // 12: fileservice.CopyRequest.conflict:type_name -> fileservice.ConflictPolicy
+	13, // 13: fileservice.PermissionList.permissions:type_name -> fileservice.PermissionInfo
+	14, // 14: fileservice.RevisionList.revisions:type_name -> fileservice.RevisionInfo
+	35, // 15: fileservice.Chunk.header:type_name -> fileservice.UploadHeader
+	12, // 16: fileservice.Chunk.file:type_name -> fileservice.FileInfo
+	0,  // 17: fileservice.UploadHeader.conflict:type_name -> fileservice.ConflictPolicy
+	12, // 18: fileservice.UploadResult.file:type_name -> fileservice.FileInfo
+	41, // 19: fileservice.UploadStatus.expires_at:type_name -> google.protobuf.Timestamp
+	10, // 20: fileservice.UserStorageUsage.CategoriesEntry.value:type_name -> fileservice.CategoryUsage
+	1,  // 21: fileservice.FileService.CreateUserDirectory:input_type -> fileservice.CreateUserDirectoryRequest
+	3,  // 22: fileservice.FileService.ApplyUserTemplate:input_type -> fileservice.ApplyUserTemplateRequest
+	5,  // 23: fileservice.FileService.DeleteUserData:input_type -> fileservice.DeleteUserDataRequest
+	7,  // 24: fileservice.FileService.CancelUserDataDeletion:input_type -> fileservice.CancelUserDataDeletionRequest
+	9,  // 25: fileservice.FileService.GetUserStorageUsage:input_type -> fileservice.GetUserStorageUsageRequest
+	15, // 26: fileservice.FileService.GetFile:input_type -> fileservice.GetFileRequest
+	16, // 27: fileservice.FileService.ListFolder:input_type -> fileservice.ListFolderRequest
+	18, // 28: fileservice.FileService.CreateFolder:input_type -> fileservice.CreateFolderRequest
+	19, // 29: fileservice.FileService.Move:input_type -> fileservice.MoveRequest
+	20, // 30: fileservice.FileService.Copy:input_type -> fileservice.CopyRequest
+	21, // 31: fileservice.FileService.Rename:input_type -> fileservice.RenameRequest
+	22, // 32: fileservice.FileService.Delete:input_type -> fileservice.DeleteRequest
+	24, // 33: fileservice.FileService.Restore:input_type -> fileservice.RestoreRequest
+	25, // 34: fileservice.FileService.SetStarred:input_type -> fileservice.SetStarredRequest
+	26, // 35: fileservice.FileService.ListPermissions:input_type -> fileservice.ListPermissionsRequest
+	28, // 36: fileservice.FileService.GrantPermission:input_type -> fileservice.GrantPermissionRequest
+	29, // 37: fileservice.FileService.RevokePermission:input_type -> fileservice.RevokePermissionRequest
+	31, // 38: fileservice.FileService.ListRevisions:input_type -> fileservice.ListRevisionsRequest
+	33, // 39: fileservice.FileService.RestoreRevision:input_type -> fileservice.RestoreRevisionRequest
+	34, // 40: fileservice.FileService.UploadFile:input_type -> fileservice.Chunk
+	37, // 41: fileservice.FileService.GetUploadStatus:input_type -> fileservice.UploadStatusRequest
+	39, // 42: fileservice.FileService.DownloadFile:input_type -> fileservice.FileRequest
+	2,  // 43: fileservice.FileService.CreateUserDirectory:output_type -> fileservice.CreateUserDirectoryResponse
+	4,  // 44: fileservice.FileService.ApplyUserTemplate:output_type -> fileservice.ApplyUserTemplateResponse
+	6,  // 45: fileservice.FileService.DeleteUserData:output_type -> fileservice.DeleteUserDataResponse
+	8,  // 46: fileservice.FileService.CancelUserDataDeletion:output_type -> fileservice.CancelUserDataDeletionResponse
+	11, // 47: fileservice.FileService.GetUserStorageUsage:output_type -> fileservice.UserStorageUsage
+	12, // 48: fileservice.FileService.GetFile:output_type -> fileservice.FileInfo
+	17, // 49: fileservice.FileService.ListFolder:output_type -> fileservice.FileList
+	12, // 50: fileservice.FileService.CreateFolder:output_type -> fileservice.FileInfo
+	12, // 51: fileservice.FileService.Move:output_type -> fileservice.FileInfo
+	12, // 52: fileservice.FileService.Copy:output_type -> fileservice.FileInfo
+	12, // 53: fileservice.FileService.Rename:output_type -> fileservice.FileInfo
+	23, // 54: fileservice.FileService.Delete:output_type -> fileservice.DeleteResponse
+	12, // 55: fileservice.FileService.Restore:output_type -> fileservice.FileInfo
+	12, // 56: fileservice.FileService.SetStarred:output_type -> fileservice.FileInfo
+	27, // 57: fileservice.FileService.ListPermissions:output_type -> fileservice.PermissionList
+	13, // 58: fileservice.FileService.GrantPermission:output_type -> fileservice.PermissionInfo
+	30, // 59: fileservice.FileService.RevokePermission:output_type -> fileservice.RevokePermissionResponse
+	32, // 60: fileservice.FileService.ListRevisions:output_type -> fileservice.RevisionList
+	12, // 61: fileservice.FileService.RestoreRevision:output_type -> fileservice.FileInfo
+	36, // 62: fileservice.FileService.UploadFile:output_type -> fileservice.UploadResult
+	38, // 63: fileservice.FileService.GetUploadStatus:output_type -> fileservice.UploadStatus
+	34, // 64: fileservice.FileService.DownloadFile:output_type -> fileservice.Chunk
+	43, // [43:65] is the sub-list for method output_type
+	21, // [21:43] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
 }
 
 func init() { file_file_service_proto_init() }
@@ -188,13 +3022,14 @@ func file_file_service_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_file_service_proto_rawDesc), len(file_file_service_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      1,
+			NumMessages:   40,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_file_service_proto_goTypes,
 		DependencyIndexes: file_file_service_proto_depIdxs,
+		EnumInfos:         file_file_service_proto_enumTypes,
 		MessageInfos:      file_file_service_proto_msgTypes,
 	}.Build()
 	File_file_service_proto = out.File
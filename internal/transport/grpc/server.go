@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"sync"
 
 	"homecloud-file-service/config"
 	"homecloud-file-service/internal/interfaces"
 	"homecloud-file-service/internal/logger"
-	"homecloud-file-service/internal/models"
 	pb "homecloud-file-service/internal/transport/grpc/protos"
 
 	"github.com/google/uuid"
@@ -46,27 +46,68 @@ func LoggerInterceptor(log *logger.Logger) grpc.UnaryServerInterceptor {
 	}
 }
 
+// loggerServerStream подменяет контекст потока контекстом с логгером
+type loggerServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggerServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// LoggerStreamInterceptor добавляет логгер в контекст потоковых gRPC вызовов
+func LoggerStreamInterceptor(log *logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctxWithLogger := logger.CtxWWithLogger(ss.Context(), log)
+
+		log.Info(ctxWithLogger, "gRPC stream started",
+			zap.String("method", info.FullMethod))
+
+		err := handler(srv, &loggerServerStream{ServerStream: ss, ctx: ctxWithLogger})
+		if err != nil {
+			log.Error(ctxWithLogger, "gRPC stream failed",
+				zap.String("method", info.FullMethod),
+				zap.Error(err))
+		} else {
+			log.Info(ctxWithLogger, "gRPC stream completed",
+				zap.String("method", info.FullMethod))
+		}
+
+		return err
+	}
+}
+
 // FileServiceServer реализация gRPC сервера для файлового сервиса
 type FileServiceServer struct {
 	pb.UnimplementedFileServiceServer
 	storageService interfaces.StorageService
 	fileService    interfaces.FileService
+	uploadSessions interfaces.UploadSessionStore
+	userTemplate   interfaces.UserTemplateApplier
 	config         *config.Config
+
+	// activeUploads загрузки, в которые сейчас пишет какой-то поток
+	activeUploads sync.Map
 }
 
 // NewFileServiceServer создает новый экземпляр gRPC сервера
-func NewFileServiceServer(storageService interfaces.StorageService, fileService interfaces.FileService, cfg *config.Config) *FileServiceServer {
+func NewFileServiceServer(storageService interfaces.StorageService, fileService interfaces.FileService, uploadSessions interfaces.UploadSessionStore, userTemplate interfaces.UserTemplateApplier, cfg *config.Config) *FileServiceServer {
 	return &FileServiceServer{
 		storageService: storageService,
 		fileService:    fileService,
+		uploadSessions: uploadSessions,
+		userTemplate:   userTemplate,
 		config:         cfg,
 	}
 }
 
-// CreateUserDirectory создает директорию для пользователя при регистрации
+// CreateUserDirectory создает директорию для пользователя при регистрации и заполняет ее
+// по шаблону. Повторный вызов (например, ретрай от сервиса авторизации) безопасен:
+// уже созданные папки не дублируются
 func (s *FileServiceServer) CreateUserDirectory(ctx context.Context, req *pb.CreateUserDirectoryRequest) (*pb.CreateUserDirectoryResponse, error) {
 	lg := logger.GetLoggerFromCtx(ctx)
-	lg.Info(ctx, "CreateUserDirectory called", zap.String("userID", req.UserId))
+	lg.Info(ctx, "CreateUserDirectory called", zap.String("userID", req.UserId), zap.String("locale", req.Locale))
 	
 	// Валидация входных данных
 	if req.UserId == "" {
@@ -84,41 +125,35 @@ func (s *FileServiceServer) CreateUserDirectory(ctx context.Context, req *pb.Cre
 	// Формируем путь к директории пользователя
 	userDirPath := filepath.Join(s.config.Storage.BasePath, s.config.Storage.UserDirName, req.UserId)
 
-	// Создаем стандартные поддиректории и записи в БД
-	standardDirs := []string{"documents", "photos", "videos", "music", "downloads"}
-	
-	for _, dirName := range standardDirs {
-		// Создаем запись в базе данных через FileService
-		createReq := &models.CreateFileRequest{
-			Name:     dirName,
-			ParentID: nil, // Корневая папка пользователя
-			IsFolder: true,
-			MimeType: "application/x-directory",
-			Size:     0,
-		}
-
-		_, err := s.fileService.CreateFile(ctx, createReq, userID)
-		if err != nil {
-			lg.Error(ctx, "Failed to create folder record in database", 
-				zap.String("dir", dirName), 
-				zap.Error(err))
-			return &pb.CreateUserDirectoryResponse{
-				Success:       false,
-				Message:       fmt.Sprintf("Failed to create folder record in database for %s: %v", dirName, err),
-				DirectoryPath: "",
-			}, nil
-		}
+	// Каталог пользователя в корне хранилища: по нему проверка хранилища находит
+	// пользователей, даже если все их содержимое лежит в хранилище блобов
+	if err := s.storageService.CreateDirectory(ctx, req.UserId); err != nil {
+		lg.Error(ctx, "Failed to create user directory", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "failed to create user directory")
+	}
 
-		lg.Info(ctx, "Created folder record in database", zap.String("dir", dirName))
+	// Папки и файлы шаблона: уже существующие пропускаются
+	report, err := s.userTemplate.Apply(ctx, userID, req.Username, req.Locale, false)
+	if err != nil {
+		lg.Error(ctx, "Failed to apply user template", zap.Error(err))
+		return &pb.CreateUserDirectoryResponse{
+			Success:       false,
+			Message:       fmt.Sprintf("Failed to apply user template: %v", err),
+			DirectoryPath: "",
+		}, nil
 	}
 
 	lg.Info(ctx, "User directory and database records created successfully", 
 		zap.String("userID", req.UserId), 
-		zap.String("path", userDirPath))
+		zap.String("path", userDirPath),
+		zap.Int("created", len(report.Created)),
+		zap.Int("existing", report.Existing))
 	
 	return &pb.CreateUserDirectoryResponse{
 		Success:       true,
 		Message:       "User directory and database records created successfully",
 		DirectoryPath: userDirPath,
+		Created:       report.Created,
+		Existing:      int32(report.Existing),
 	}, nil
 }
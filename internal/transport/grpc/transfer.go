@@ -0,0 +1,417 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	pb "homecloud-file-service/internal/transport/grpc/protos"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultChunkSize размер части скачивания, если клиент его не задал
+	defaultChunkSize = 1 << 20
+	// maxChunkSize предел части в обе стороны, с запасом до лимита сообщения gRPC в 4 МБ
+	maxChunkSize = 2 << 20
+	// uploadCheckpointBytes через сколько принятых байт данные сбрасываются на диск
+	// и отмечаются в сессии. Оборванный поток продолжается с последней отметки
+	uploadCheckpointBytes = 8 << 20
+	// uploadIDMetadataKey заголовок ответа с ID загрузки, он уходит клиенту до первой части
+	uploadIDMetadataKey = "x-upload-id"
+)
+
+// UploadFile принимает файл потоком частей. Загрузка хранится в тех же сессиях, что
+// и tus, и по завершении создает файл тем же вызовом сервиса, что и HTTP загрузка.
+// Поток, закрытый до приема всех байт, возвращает принятый offset без файла
+func (s *FileServiceServer) UploadFile(stream pb.FileService_UploadFileServer) error {
+	ctx := stream.Context()
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "upload header is required")
+	}
+	if err != nil {
+		return err
+	}
+	header := first.Header
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "first message must carry the upload header")
+	}
+	userID, err := parseID("user_id", header.UserId)
+	if err != nil {
+		return err
+	}
+	uploadID := header.UploadId
+	if uploadID == "" {
+		uploadID = uuid.NewString()
+	} else if _, err := uuid.Parse(uploadID); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid upload_id format")
+	}
+
+	// В одну загрузку пишет только один поток
+	if _, busy := s.activeUploads.LoadOrStore(uploadID, struct{}{}); busy {
+		return status.Error(codes.Aborted, "upload is in progress in another stream")
+	}
+	defer s.activeUploads.Delete(uploadID)
+
+	session, err := s.uploadSession(ctx, uploadID, userID, header)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendHeader(metadata.Pairs(uploadIDMetadataKey, session.ID)); err != nil {
+		return err
+	}
+
+	if err := s.receiveChunks(ctx, stream, session, first); err != nil {
+		return err
+	}
+
+	result := &pb.UploadResult{UploadId: session.ID, Offset: session.Offset}
+	if !session.Completed() {
+		lg.Info(ctx, "gRPC upload paused", zap.String("uploadID", session.ID),
+			zap.Int64("offset", session.Offset), zap.Int64("size", session.Size))
+		return stream.SendAndClose(result)
+	}
+
+	result.File, err = s.completeUpload(ctx, session)
+	if err != nil {
+		return err
+	}
+	lg.Info(ctx, "gRPC upload completed", zap.String("uploadID", session.ID), zap.String("fileID", result.File.Id))
+	return stream.SendAndClose(result)
+}
+
+// GetUploadStatus сообщает, с какого offset продолжать загрузку
+func (s *FileServiceServer) GetUploadStatus(ctx context.Context, req *pb.UploadStatusRequest) (*pb.UploadStatus, error) {
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if req.UploadId == "" {
+		return nil, status.Error(codes.InvalidArgument, "upload_id is required")
+	}
+
+	session, err := s.uploadSessions.Get(ctx, req.UploadId)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to get upload")
+	}
+	if session == nil || session.UserID != userID || session.Expired(time.Now()) {
+		return nil, status.Error(codes.NotFound, "upload not found")
+	}
+	return &pb.UploadStatus{
+		UploadId:  session.ID,
+		Offset:    session.Offset,
+		Size:      session.Size,
+		ExpiresAt: timestamppb.New(session.ExpiresAt),
+	}, nil
+}
+
+// DownloadFile отдает содержимое файла частями с контрольной суммой каждой. Send
+// блокируется, пока клиент не освободит окно HTTP/2, поэтому медленный клиент
+// не заставляет сервер читать файл впрок
+func (s *FileServiceServer) DownloadFile(req *pb.FileRequest, stream pb.FileService_DownloadFileServer) error {
+	ctx := stream.Context()
+
+	userID, fileID, err := parseUserAndFile(req.UserId, req.FileId)
+	if err != nil {
+		return err
+	}
+	if req.Offset < 0 || req.Length < 0 {
+		return status.Error(codes.InvalidArgument, "offset and length cannot be negative")
+	}
+	chunkSize := int64(req.ChunkSize)
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+	if chunkSize < 0 || chunkSize > maxChunkSize {
+		return status.Errorf(codes.InvalidArgument, "chunk_size must be between 1 and %d", maxChunkSize)
+	}
+
+	file, err := s.fileService.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return statusFromError(ctx, err, "failed to get file")
+	}
+	if file.IsFolder {
+		return status.Error(codes.InvalidArgument, "cannot download a folder")
+	}
+	if req.ExpectedVersion != 0 && req.ExpectedVersion != file.Version {
+		return status.Errorf(codes.FailedPrecondition, "file version is %d, expected %d", file.Version, req.ExpectedVersion)
+	}
+
+	content, err := s.fileService.GetFileContent(ctx, fileID, userID)
+	if err != nil {
+		return statusFromError(ctx, err, "failed to open file content")
+	}
+	defer content.Close()
+
+	size := content.Size()
+	if req.Offset > size {
+		return status.Errorf(codes.OutOfRange, "offset %d is beyond file size %d", req.Offset, size)
+	}
+	end := size
+	if req.Length > 0 && req.Offset+req.Length < size {
+		end = req.Offset + req.Length
+	}
+
+	// Первая часть несет описание файла, даже если данных в ней нет
+	chunk := &pb.Chunk{File: fileToProto(file), Offset: req.Offset}
+	for offset := req.Offset; ; {
+		n := min(chunkSize, end-offset)
+		if n > 0 {
+			// Буфер у каждой части свой: отправленное сообщение может читаться позже
+			data := make([]byte, n)
+			read, err := content.ReadAt(data, offset)
+			if err != nil && !(errors.Is(err, io.EOF) && int64(read) == n) {
+				return statusFromError(ctx, fmt.Errorf("failed to read file content: %w", err), "failed to read file")
+			}
+			sum := sha256.Sum256(data)
+			chunk.Offset = offset
+			chunk.Data = data
+			chunk.Sha256 = hex.EncodeToString(sum[:])
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+
+		offset += n
+		if offset >= end {
+			return nil
+		}
+		chunk = &pb.Chunk{}
+	}
+}
+
+// uploadSession возвращает сессию загрузки uploadID или создает ее по заголовку
+func (s *FileServiceServer) uploadSession(ctx context.Context, uploadID string, userID uuid.UUID, header *pb.UploadHeader) (*models.ResumableSession, error) {
+	session, err := s.uploadSessions.Get(ctx, uploadID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to get upload")
+	}
+	if session != nil {
+		if session.UserID != userID || session.Expired(time.Now()) {
+			return nil, status.Error(codes.NotFound, "upload not found")
+		}
+		if header.Size != session.Size {
+			return nil, status.Errorf(codes.InvalidArgument, "size %d does not match upload size %d", header.Size, session.Size)
+		}
+		return session, nil
+	}
+
+	if header.Size < 0 {
+		return nil, status.Error(codes.InvalidArgument, "size cannot be negative")
+	}
+	conflict, err := conflictFromProto(header.Conflict)
+	if err != nil {
+		return nil, err
+	}
+	fileID, err := parseOptionalID("file_id", header.FileId)
+	if err != nil {
+		return nil, err
+	}
+	parentID, err := parseOptionalID("parent_id", header.ParentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileID != nil {
+		// Устаревшую версию отклоняем до передачи данных, а не после
+		file, err := s.fileService.GetFile(ctx, *fileID, userID)
+		if err != nil {
+			return nil, statusFromError(ctx, err, "failed to get file")
+		}
+		if file.IsFolder {
+			return nil, status.Error(codes.InvalidArgument, "cannot upload content to a folder")
+		}
+		if header.ExpectedVersion != 0 && header.ExpectedVersion != file.Version {
+			return nil, status.Errorf(codes.FailedPrecondition, "file version is %d, expected %d", file.Version, header.ExpectedVersion)
+		}
+	} else if err := validateName(header.Name); err != nil {
+		return nil, err
+	}
+
+	session = &models.ResumableSession{
+		ID:              uploadID,
+		UserID:          userID,
+		FilePath:        header.Name,
+		Size:            header.Size,
+		Checksum:        strings.ToLower(header.Sha256),
+		ParentID:        parentID,
+		Conflict:        conflict,
+		FileID:          fileID,
+		ExpectedVersion: header.ExpectedVersion,
+	}
+	if err := s.uploadSessions.Save(ctx, session); err != nil {
+		return nil, statusFromError(ctx, err, "failed to create upload")
+	}
+	file, err := os.Create(s.uploadSessions.PartPath(session.ID))
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		s.uploadSessions.Delete(ctx, session.ID)
+		return nil, statusFromError(ctx, fmt.Errorf("failed to create upload file: %w", err), "failed to create upload")
+	}
+	return session, nil
+}
+
+// receiveChunks пишет части потока во временный файл сессии, пока клиент не закроет
+// поток. Принятое сохраняется в сессии и при обрыве потока, и при отклоненной части
+func (s *FileServiceServer) receiveChunks(ctx context.Context, stream pb.FileService_UploadFileServer, session *models.ResumableSession, first *pb.Chunk) error {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	file, err := os.OpenFile(s.uploadSessions.PartPath(session.ID), os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return statusFromError(ctx, fmt.Errorf("failed to open upload file: %w", err), "failed to process upload")
+	}
+	defer file.Close()
+
+	next := session.Offset
+	// Отметка переживает отмену контекста потока: принятые до обрыва данные не теряются
+	checkpoint := func() error {
+		if next == session.Offset {
+			return nil
+		}
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync upload file: %w", err)
+		}
+		session.AddReceived(session.Offset, next-1)
+		return s.uploadSessions.Save(context.WithoutCancel(ctx), session)
+	}
+
+	write := func(chunk *pb.Chunk) error {
+		if len(chunk.Data) == 0 {
+			return nil
+		}
+		if chunk.Offset != next {
+			return status.Errorf(codes.FailedPrecondition, "chunk offset %d does not match upload offset %d", chunk.Offset, next)
+		}
+		if len(chunk.Data) > maxChunkSize {
+			return status.Errorf(codes.InvalidArgument, "chunk exceeds %d bytes", maxChunkSize)
+		}
+		if next+int64(len(chunk.Data)) > session.Size {
+			return status.Errorf(codes.OutOfRange, "chunk exceeds upload size %d", session.Size)
+		}
+		if chunk.Sha256 != "" {
+			sum := sha256.Sum256(chunk.Data)
+			if !strings.EqualFold(hex.EncodeToString(sum[:]), chunk.Sha256) {
+				return status.Errorf(codes.DataLoss, "checksum mismatch for chunk at offset %d", chunk.Offset)
+			}
+		}
+		if _, err := file.WriteAt(chunk.Data, next); err != nil {
+			return statusFromError(ctx, fmt.Errorf("failed to write chunk: %w", err), "failed to process upload")
+		}
+		next += int64(len(chunk.Data))
+
+		if next-session.Offset >= uploadCheckpointBytes {
+			if err := checkpoint(); err != nil {
+				return statusFromError(ctx, err, "failed to process upload")
+			}
+		}
+		return nil
+	}
+
+	err = write(first)
+	for err == nil {
+		var chunk *pb.Chunk
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		if chunk.Header != nil {
+			err = status.Error(codes.InvalidArgument, "upload header is allowed only in the first message")
+			break
+		}
+		err = write(chunk)
+	}
+
+	if cpErr := checkpoint(); cpErr != nil {
+		lg.Error(ctx, "Failed to save upload progress", zap.String("uploadID", session.ID), zap.Error(cpErr))
+		if err == nil {
+			err = status.Error(codes.Internal, "failed to process upload")
+		}
+	}
+	return err
+}
+
+// completeUpload сверяет SHA-256 принятого файла и создает из него файл или новую
+// версию file_id. Как и в tus, сессия остается, если повтор может помочь
+func (s *FileServiceServer) completeUpload(ctx context.Context, session *models.ResumableSession) (*pb.FileInfo, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	file, err := os.Open(s.uploadSessions.PartPath(session.ID))
+	if err != nil {
+		return nil, statusFromError(ctx, fmt.Errorf("failed to open upload file: %w", err), "failed to finalize upload")
+	}
+	defer file.Close()
+
+	if session.Checksum != "" {
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, file); err != nil {
+			return nil, statusFromError(ctx, fmt.Errorf("failed to calculate checksum: %w", err), "failed to finalize upload")
+		}
+		if actual := hex.EncodeToString(hasher.Sum(nil)); actual != session.Checksum {
+			// Собранный файл испорчен, продолжать эту загрузку бессмысленно
+			s.uploadSessions.Delete(ctx, session.ID)
+			lg.Error(ctx, "Checksum mismatch", zap.String("uploadID", session.ID),
+				zap.String("expected", session.Checksum), zap.String("actual", actual))
+			return nil, status.Error(codes.DataLoss, "checksum verification failed")
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, statusFromError(ctx, fmt.Errorf("failed to seek to start: %w", err), "failed to finalize upload")
+		}
+	}
+
+	var fileID uuid.UUID
+	if session.FileID != nil {
+		var result *models.UploadResult
+		result, err = s.fileService.UploadFile(ctx, *session.FileID, file, session.UserID,
+			models.WriteOptions{ExpectedVersion: session.ExpectedVersion})
+		if err == nil {
+			fileID = result.FileID
+		}
+	} else {
+		var created *models.File
+		created, err = s.fileService.CreateFile(ctx, &models.CreateFileRequest{
+			Name:          session.FilePath,
+			Size:          session.Size,
+			ContentReader: file,
+			ParentID:      session.ParentID,
+			Conflict:      session.Conflict,
+		}, session.UserID)
+		if err == nil {
+			fileID = created.ID
+		}
+	}
+	if err != nil {
+		if errdefs.Is(err, errdefs.ErrFileExists) || errdefs.Is(err, errdefs.ErrPreconditionFailed) {
+			s.uploadSessions.Delete(ctx, session.ID)
+		}
+		return nil, statusFromError(ctx, err, "failed to create file")
+	}
+
+	if err := s.uploadSessions.Delete(ctx, session.ID); err != nil {
+		lg.Error(ctx, "Failed to delete upload session", zap.String("uploadID", session.ID), zap.Error(err))
+	}
+	return s.fileInfo(ctx, fileID, session.UserID)
+}
@@ -0,0 +1,169 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+	pb "homecloud-file-service/internal/transport/grpc/protos"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memoryFileService хранит один файл в памяти
+type memoryFileService struct {
+	interfaces.FileService
+	file    *models.File
+	content []byte
+}
+
+type memoryBlob struct {
+	*bytes.Reader
+}
+
+func (memoryBlob) Close() error { return nil }
+
+func (f *memoryFileService) CreateFile(ctx context.Context, req *models.CreateFileRequest, ownerID uuid.UUID) (*models.File, error) {
+	content, err := io.ReadAll(req.ContentReader)
+	if err != nil {
+		return nil, err
+	}
+	f.content = content
+	f.file = &models.File{ID: uuid.New(), OwnerID: ownerID, Name: req.Name, Size: int64(len(content)), Version: 1}
+	return f.file, nil
+}
+
+func (f *memoryFileService) GetFile(ctx context.Context, fileID, userID uuid.UUID) (*models.File, error) {
+	return f.file, nil
+}
+
+func (f *memoryFileService) GetFileContent(ctx context.Context, fileID, userID uuid.UUID) (interfaces.BlobReader, error) {
+	return memoryBlob{bytes.NewReader(f.content)}, nil
+}
+
+func chunkOf(offset int64, data []byte) *pb.Chunk {
+	sum := sha256.Sum256(data)
+	return &pb.Chunk{Offset: offset, Data: data, Sha256: hex.EncodeToString(sum[:])}
+}
+
+func TestStreamingUploadResumesAndDownloadsWithChecksums(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Storage.TempPath = filepath.Join(root, "temp")
+	cfg.Logger.Config = zap.NewDevelopmentConfig()
+	cfg.Logger.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	lg, err := logger.New(cfg)
+	require.NoError(t, err)
+
+	sessions, err := repository.NewUploadSessionStore(cfg)
+	require.NoError(t, err)
+	files := &memoryFileService{}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.StreamInterceptor(LoggerStreamInterceptor(lg)), grpc.UnaryInterceptor(LoggerInterceptor(lg)))
+	pb.RegisterFileServiceServer(server, NewFileServiceServer(nil, files, sessions, nil, cfg))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewFileServiceClient(conn)
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(content)
+	userID := uuid.NewString()
+	uploadID := uuid.NewString()
+	header := &pb.UploadHeader{UserId: userID, UploadId: uploadID, Name: "data.bin", Size: int64(len(content)), Sha256: hex.EncodeToString(sum[:])}
+
+	upload := func(chunks ...*pb.Chunk) (*pb.UploadResult, error) {
+		stream, err := client.UploadFile(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.Chunk{Header: header}))
+		for _, chunk := range chunks {
+			if err := stream.Send(chunk); err != nil {
+				break
+			}
+		}
+		return stream.CloseAndRecv()
+	}
+
+	// Первая половина, затем поток закрыт: файла еще нет
+	result, err := upload(chunkOf(0, content[:4000]), chunkOf(4000, content[4000:5000]))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), result.Offset)
+	assert.Nil(t, result.File)
+
+	uploadStatus, err := client.GetUploadStatus(ctx, &pb.UploadStatusRequest{UserId: userID, UploadId: uploadID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), uploadStatus.Offset)
+
+	// Часть не с того смещения и часть с неверной суммой отклоняются, принятое остается
+	_, err = upload(chunkOf(4000, content[4000:6000]))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	bad := chunkOf(5500, content[5500:6000])
+	bad.Data = bytes.Repeat([]byte("x"), 500)
+	_, err = upload(chunkOf(5000, content[5000:5500]), bad)
+	assert.Equal(t, codes.DataLoss, status.Code(err))
+
+	uploadStatus, err = client.GetUploadStatus(ctx, &pb.UploadStatusRequest{UserId: userID, UploadId: uploadID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5500), uploadStatus.Offset)
+
+	result, err = upload(chunkOf(5500, content[5500:]))
+	require.NoError(t, err)
+	require.NotNil(t, result.File)
+	assert.Equal(t, "data.bin", result.File.Name)
+	assert.Equal(t, content, files.content)
+
+	_, err = client.GetUploadStatus(ctx, &pb.UploadStatusRequest{UserId: userID, UploadId: uploadID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Скачивание с середины частями по 3000 байт
+	download, err := client.DownloadFile(ctx, &pb.FileRequest{UserId: userID, FileId: result.File.Id, Offset: 1000, ChunkSize: 3000})
+	require.NoError(t, err)
+	var received []byte
+	var chunks int
+	for {
+		chunk, err := download.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if chunks == 0 {
+			require.NotNil(t, chunk.File)
+		}
+		assert.Equal(t, int64(1000+len(received)), chunk.Offset)
+		assert.Equal(t, chunkOf(0, chunk.Data).Sha256, chunk.Sha256)
+		received = append(received, chunk.Data...)
+		chunks++
+	}
+	assert.Equal(t, 3, chunks)
+	assert.Equal(t, content[1000:], received)
+
+	download, err = client.DownloadFile(ctx, &pb.FileRequest{UserId: userID, FileId: result.File.Id, ExpectedVersion: 7})
+	require.NoError(t, err)
+	_, err = download.Recv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
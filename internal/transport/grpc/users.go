@@ -0,0 +1,103 @@
+package grpc
+
+import (
+	"context"
+
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	pb "homecloud-file-service/internal/transport/grpc/protos"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Вызовы ниже - пара к CreateUserDirectory: их делает сервис авторизации после изменения
+// шаблона папок, при удалении учетной записи и когда ему нужно занятое пользователем место
+
+// ApplyUserTemplate применяет шаблон папок к уже существующему пользователю, например
+// после изменения шаблона. Создается только недостающее
+func (s *FileServiceServer) ApplyUserTemplate(ctx context.Context, req *pb.ApplyUserTemplateRequest) (*pb.ApplyUserTemplateResponse, error) {
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.userTemplate.Apply(ctx, userID, req.Username, req.Locale, req.DryRun)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to apply user template")
+	}
+	return &pb.ApplyUserTemplateResponse{
+		Locale:   report.Locale,
+		Created:  report.Created,
+		Existing: int32(report.Existing),
+		Skipped:  report.Skipped,
+	}, nil
+}
+
+// DeleteUserData переносит все файлы пользователя в корзину и назначает их удаление.
+// Повторный вызов безопасен: отсрочка считается от первого
+func (s *FileServiceServer) DeleteUserData(ctx context.Context, req *pb.DeleteUserDataRequest) (*pb.DeleteUserDataResponse, error) {
+	lg := logger.GetLoggerFromCtx(ctx)
+
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	deletion, err := s.fileService.DeleteUserData(ctx, userID, req.Immediate)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to delete user data")
+	}
+
+	lg.Info(ctx, "User data deletion requested", zap.String("userID", userID.String()),
+		zap.Time("purgeAt", deletion.PurgeAt), zap.Bool("purged", deletion.PurgedAt != nil))
+	return &pb.DeleteUserDataResponse{
+		RequestedAt:  timestamppb.New(deletion.RequestedAt),
+		PurgeAt:      timestamppb.New(deletion.PurgeAt),
+		TrashedCount: int32(len(deletion.Trashed)),
+		Purged:       deletion.PurgedAt != nil,
+	}, nil
+}
+
+// CancelUserDataDeletion отменяет удаление, пока не истекла отсрочка
+func (s *FileServiceServer) CancelUserDataDeletion(ctx context.Context, req *pb.CancelUserDataDeletionRequest) (*pb.CancelUserDataDeletionResponse, error) {
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fileService.CancelUserDataDeletion(ctx, userID); err != nil {
+		return nil, statusFromError(ctx, err, "failed to cancel user data deletion")
+	}
+	return &pb.CancelUserDataDeletionResponse{}, nil
+}
+
+// GetUserStorageUsage возвращает место, занятое файлами пользователя, по категориям и корзину
+func (s *FileServiceServer) GetUserStorageUsage(ctx context.Context, req *pb.GetUserStorageUsageRequest) (*pb.UserStorageUsage, error) {
+	userID, err := parseID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.fileService.GetUserStorageUsage(ctx, userID)
+	if err != nil {
+		return nil, statusFromError(ctx, err, "failed to get storage usage")
+	}
+	return usageToProto(usage), nil
+}
+
+func usageToProto(usage *models.StorageUsage) *pb.UserStorageUsage {
+	result := &pb.UserStorageUsage{
+		UserId:          usage.UserID.String(),
+		TotalBytes:      usage.TotalBytes,
+		FileCount:       usage.FileCount,
+		FolderCount:     usage.FolderCount,
+		TrashBytes:      usage.TrashBytes,
+		TrashFileCount:  usage.TrashFileCount,
+		Categories:      make(map[string]*pb.CategoryUsage, len(usage.Categories)),
+		DeletionPurgeAt: timestampOrNil(usage.DeletionPurgeAt),
+	}
+	for category, c := range usage.Categories {
+		result.Categories[category] = &pb.CategoryUsage{Bytes: c.Bytes, Files: c.Files}
+	}
+	return result
+}
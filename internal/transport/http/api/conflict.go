@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// parseConflictPolicy читает политику совпадения имен из параметра conflict.
+// Без параметра остается fallback - значение из тела запроса или ConflictFail
+func parseConflictPolicy(r *http.Request, fallback models.ConflictPolicy) (models.ConflictPolicy, error) {
+	if value := r.URL.Query().Get("conflict"); value != "" {
+		return models.ParseConflictPolicy(value)
+	}
+	return models.ParseConflictPolicy(string(fallback))
+}
+
+// respondWithFileExists отвечает 409 с подсказкой свободного имени, если имя в папке занято.
+// Возвращает false, если ошибка другая
+func (h *Handler) respondWithFileExists(w http.ResponseWriter, err error) bool {
+	var exists *errdefs.FileExistsError
+	if !errdefs.As(err, &exists) {
+		return false
+	}
+
+	details := map[string]interface{}{
+		"fileName": exists.Name,
+		"message":  fmt.Sprintf("A file named '%s' already exists in this location.", exists.Name),
+	}
+	if exists.Suggestion != "" {
+		details["suggestion"] = exists.Suggestion
+		details["message"] = fmt.Sprintf("A file named '%s' already exists in this location. Try using '%s' instead.", exists.Name, exists.Suggestion)
+	}
+	if exists.ExistingID != uuid.Nil {
+		details["existingId"] = exists.ExistingID
+	}
+
+	h.respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+		"error":   "File with this name already exists",
+		"details": details,
+	})
+	return true
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errChunkDigestMismatch содержимое части не совпало с Content-Digest или Content-MD5
+var errChunkDigestMismatch = errors.New("chunk digest mismatch")
+
+// contentDigestAlgorithms алгоритмы Content-Digest (RFC 9530), которые проверяются
+var contentDigestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+type digestCheck struct {
+	name string
+	hash hash.Hash
+	want []byte
+}
+
+// parseChunkDigests разбирает Content-Digest вида `sha-256=:<base64>:` и Content-MD5.
+// Неизвестные алгоритмы Content-Digest пропускаются, но хотя бы один должен быть знаком
+func parseChunkDigests(h http.Header) ([]digestCheck, error) {
+	var checks []digestCheck
+
+	if header := h.Get("Content-Digest"); header != "" {
+		for _, member := range strings.Split(header, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+			if !ok {
+				return nil, fmt.Errorf("malformed Content-Digest member %q", member)
+			}
+			name = strings.ToLower(strings.TrimSpace(name))
+			newHash, known := contentDigestAlgorithms[name]
+			if !known {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+				return nil, fmt.Errorf("value of Content-Digest %s is not a byte sequence", name)
+			}
+			want, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+			if err != nil {
+				return nil, fmt.Errorf("value of Content-Digest %s is not valid base64", name)
+			}
+			checks = append(checks, digestCheck{name: name, hash: newHash(), want: want})
+		}
+		if len(checks) == 0 {
+			return nil, errors.New("no supported algorithm in Content-Digest (sha-256, sha-512)")
+		}
+	}
+
+	if header := h.Get("Content-MD5"); header != "" {
+		want, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header))
+		if err != nil || len(want) != md5.Size {
+			return nil, errors.New("invalid Content-MD5: expected base64 MD5 digest")
+		}
+		checks = append(checks, digestCheck{name: "md5", hash: md5.New(), want: want})
+	}
+	return checks, nil
+}
+
+// digestReader считает контрольные суммы по мере чтения и вместо io.EOF возвращает
+// errChunkDigestMismatch, если содержимое не совпало. Так часть отбрасывается тем же путем,
+// что и оборванная передача
+type digestReader struct {
+	r      io.Reader
+	checks []digestCheck
+}
+
+func newDigestReader(r io.Reader, checks []digestCheck) io.Reader {
+	if len(checks) == 0 {
+		return r
+	}
+	return &digestReader{r: r, checks: checks}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	for _, c := range d.checks {
+		c.hash.Write(p[:n])
+	}
+	if err == io.EOF {
+		for _, c := range d.checks {
+			if got := c.hash.Sum(nil); string(got) != string(c.want) {
+				return n, fmt.Errorf("%w: %s is %s", errChunkDigestMismatch, c.name, base64.StdEncoding.EncodeToString(got))
+			}
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// errInvalidRange заголовок Range не разобран - по RFC 7233 он игнорируется и отдается весь файл
+	errInvalidRange = errors.New("invalid range")
+	// errNoOverlap ни один диапазон не пересекается с содержимым - ответ 416
+	errNoOverlap = errors.New("range does not overlap content")
+)
+
+type httpRange struct {
+	start, end int64
+}
+
+func (r httpRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// parseRanges разбирает заголовок Range вида "bytes=0-99,200-,-50". Диапазоны за концом
+// содержимого отбрасываются, конец обрезается по размеру
+func parseRanges(rangeHeader string, fileSize int64) ([]httpRange, error) {
+	spec, ok := strings.CutPrefix(rangeHeader, "bytes=")
+	if !ok {
+		return nil, errInvalidRange
+	}
+
+	var ranges []httpRange
+	noOverlap := false
+	for _, r := range strings.Split(spec, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(r, "-")
+		if !ok {
+			return nil, errInvalidRange
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var rng httpRange
+		if first == "" {
+			// Последние N байт (например, -100)
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errInvalidRange
+			}
+			if n == 0 || fileSize == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > fileSize {
+				n = fileSize
+			}
+			rng = httpRange{start: fileSize - n, end: fileSize - 1}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errInvalidRange
+			}
+			end := fileSize - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, errInvalidRange
+				}
+			}
+			if start >= fileSize {
+				noOverlap = true
+				continue
+			}
+			if end >= fileSize {
+				end = fileSize - 1
+			}
+			rng = httpRange{start: start, end: end}
+		}
+		ranges = append(ranges, rng)
+	}
+
+	if len(ranges) == 0 && noOverlap {
+		return nil, errNoOverlap
+	}
+	return ranges, nil
+}
+
+// fileETag сильный ETag по SHA-256 содержимого. Для файлов без контрольной суммы - слабый по версии
+func fileETag(file *models.File) string {
+	if file.SHA256Checksum != nil && *file.SHA256Checksum != "" {
+		return `"` + *file.SHA256Checksum + `"`
+	}
+	return fmt.Sprintf(`W/"%s-%d"`, file.ID, file.Version)
+}
+
+// etagMatches проверяет список ETag из If-None-Match / If-Range. weak - слабое сравнение
+func etagMatches(header, etag string, weak bool) bool {
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	} else if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified проверяет If-None-Match и If-Modified-Since. If-Modified-Since учитывается,
+// только если If-None-Match не передан
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag, true)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !modTime.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// ifRangeMatches проверяет If-Range: диапазон отдается, только если файл не изменился,
+// иначе клиент получает весь файл заново
+func ifRangeMatches(r *http.Request, etag string, modTime time.Time) bool {
+	ir := r.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+	if strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		return etagMatches(ir, etag, false)
+	}
+	since, err := http.ParseTime(ir)
+	return err == nil && !modTime.IsZero() && modTime.Truncate(time.Second).Equal(since)
+}
+
+// serveFileContent отдает содержимое файла с поддержкой условных запросов и диапазонов:
+// ETag и Last-Modified, 304 по If-None-Match / If-Modified-Since, Range с If-Range,
+// один диапазон - 206 с Content-Range, несколько - multipart/byteranges.
+// open вызывается только если тело действительно нужно
+func (h *Handler) serveFileContent(w http.ResponseWriter, r *http.Request, file *models.File, open func() (interfaces.BlobReader, error)) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+
+	etag := fileETag(file)
+	modTime := file.UpdatedAt.UTC()
+	w.Header().Set("ETag", etag)
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if notModified(r, etag, modTime) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	reader, err := open()
+	if err != nil {
+		if lg != nil {
+			lg.Error(r.Context(), "Failed to open file content", zap.Error(err), zap.String("fileID", file.ID.String()))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to download file")
+		return
+	}
+	defer func() {
+		if err := reader.Close(); err != nil && lg != nil {
+			lg.Error(r.Context(), "Failed to close file reader", zap.Error(err))
+		}
+	}()
+
+	size := reader.Size()
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = getMimeTypeByExtension(file.Name)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+
+	var ranges []httpRange
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && ifRangeMatches(r, etag, modTime) {
+		ranges, err = parseRanges(rangeHeader, size)
+		switch {
+		case errors.Is(err, errNoOverlap):
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			h.respondWithError(w, http.StatusRequestedRangeNotSatisfiable, "Requested range not satisfiable")
+			return
+		case err != nil:
+			ranges = nil
+		}
+		// Диапазоны, в сумме больше файла, дешевле отдать целым файлом
+		var total int64
+		for _, rng := range ranges {
+			total += rng.length()
+		}
+		if total > size {
+			ranges = nil
+		}
+	}
+
+	// Содержимое читается последовательно: весь файл и каждый диапазон - одним
+	// запросом к хранилищу, а не отдельным запросом на каждый буфер io.Copy
+	var body io.Reader = io.LimitReader(reader, size)
+	status := http.StatusOK
+	length := size
+	switch len(ranges) {
+	case 0:
+		w.Header().Set("Content-Type", mimeType)
+	case 1:
+		rng := ranges[0]
+		if _, err := reader.Seek(rng.start, io.SeekStart); err != nil {
+			if lg != nil {
+				lg.Error(r.Context(), "Failed to seek file content", zap.Error(err), zap.String("fileID", file.ID.String()))
+			}
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to download file")
+			return
+		}
+		w.Header().Set("Content-Type", mimeType)
+		w.Header().Set("Content-Range", rng.contentRange(size))
+		body = io.LimitReader(reader, rng.length())
+		status = http.StatusPartialContent
+		length = rng.length()
+	default:
+		boundary := multipart.NewWriter(io.Discard).Boundary()
+		w.Header().Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+		length = multipartLength(ranges, mimeType, size, boundary)
+		status = http.StatusPartialContent
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeByteranges(pw, reader, ranges, mimeType, size, boundary))
+		}()
+		defer pr.Close()
+		body = pr
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if written, err := io.Copy(w, body); err != nil && lg != nil {
+		// Ответ уже начат, сообщить об ошибке клиенту нельзя
+		lg.Error(r.Context(), "Failed to send file content", zap.Error(err),
+			zap.Int64("written", written), zap.Int64("length", length))
+	}
+}
+
+// writeByteranges пишет тело multipart/byteranges: по части на каждый диапазон
+func writeByteranges(w io.Writer, content io.ReadSeeker, ranges []httpRange, mimeType string, size int64, boundary string) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, rng := range ranges {
+		part, err := mw.CreatePart(byterangeHeader(rng, mimeType, size))
+		if err != nil {
+			return err
+		}
+		if _, err := content.Seek(rng.start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(part, content, rng.length()); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// multipartLength считает длину тела multipart/byteranges без чтения содержимого
+func multipartLength(ranges []httpRange, mimeType string, size int64, boundary string) int64 {
+	var cw countingWriter
+	mw := multipart.NewWriter(&cw)
+	mw.SetBoundary(boundary)
+	for _, rng := range ranges {
+		mw.CreatePart(byterangeHeader(rng, mimeType, size))
+		cw += countingWriter(rng.length())
+	}
+	mw.Close()
+	return int64(cw)
+}
+
+func byterangeHeader(rng httpRange, mimeType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Range": {rng.contentRange(size)},
+		"Content-Type":  {mimeType},
+	}
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
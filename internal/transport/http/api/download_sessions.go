@@ -0,0 +1,235 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// ResumableDownloadInit открывает сессию скачивания файла file_id. Сессия хранится на диске
+// и переживает перезапуск сервиса, а в ответе сразу есть подписанная ссылка на нее
+func (h *Handler) ResumableDownloadInit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lg := logger.GetLoggerFromCtxSafe(ctx)
+	if lg != nil {
+		lg.Info(ctx, "ResumableDownloadInit handler called")
+	}
+
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Парсим fileID из query параметра
+	fileIDStr := r.URL.Query().Get("file_id")
+	if fileIDStr == "" {
+		h.respondWithError(w, http.StatusBadRequest, "file_id parameter is required")
+		return
+	}
+
+	fileID, err := uuid.Parse(fileIDStr)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid file_id")
+		return
+	}
+
+	session, err := h.fileService.InitResumableDownload(ctx, fileID, userID)
+	if err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to init resumable download", zap.Error(err))
+		}
+		h.respondWithDownloadError(w, err, "Failed to init resumable download")
+		return
+	}
+
+	token, tokenExpiresAt, err := h.fileService.SignDownloadToken(ctx, session.ID, userID)
+	if err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to sign download token", zap.Error(err))
+		}
+		h.respondWithDownloadError(w, err, "Failed to init resumable download")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"session_id":       session.ID,
+		"file_name":        session.FileName,
+		"file_size":        session.Size,
+		"checksum":         session.Checksum,
+		"mime_type":        session.MimeType,
+		"expires_at":       session.ExpiresAt,
+		"download_url":     fmt.Sprintf("/api/v1/download/resumable/%s", session.ID),
+		"token":            token,
+		"token_expires_at": tokenExpiresAt,
+		"signed_url":       signedDownloadURL(token),
+	})
+}
+
+// ResumableDownload отдает содержимое сессии: без Range - весь файл, с Range - диапазон.
+// Сессия не удаляется после последней части, чтобы клиент мог докачать повторно
+func (h *Handler) ResumableDownload(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.userDownloadSession(w, r, mux.Vars(r)["sessionID"])
+	if !ok {
+		return
+	}
+	h.serveDownloadSession(w, r, session)
+}
+
+// SignedDownload отдает содержимое сессии по подписанной ссылке без авторизации
+func (h *Handler) SignedDownload(w http.ResponseWriter, r *http.Request) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+
+	session, err := h.fileService.ResolveDownloadToken(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		if lg != nil {
+			lg.Info(r.Context(), "Signed download rejected", zap.Error(err))
+		}
+		h.respondWithDownloadError(w, err, "Failed to download file")
+		return
+	}
+	h.serveDownloadSession(w, r, session)
+}
+
+// CreateDownloadToken выдает новую подписанную ссылку на сессию, например взамен истекшей
+func (h *Handler) CreateDownloadToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lg := logger.GetLoggerFromCtxSafe(ctx)
+
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	token, expiresAt, err := h.fileService.SignDownloadToken(ctx, mux.Vars(r)["sessionID"], userID)
+	if err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to sign download token", zap.Error(err))
+		}
+		h.respondWithDownloadError(w, err, "Failed to create download token")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      token,
+		"expires_at": expiresAt,
+		"signed_url": signedDownloadURL(token),
+	})
+}
+
+// DeleteDownloadSession закрывает сессию, после чего перестают работать и ссылки на нее
+func (h *Handler) DeleteDownloadSession(w http.ResponseWriter, r *http.Request) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+
+	session, ok := h.userDownloadSession(w, r, mux.Vars(r)["sessionID"])
+	if !ok {
+		return
+	}
+	if err := h.fileService.DeleteResumableDownloadSession(r.Context(), session.ID); err != nil {
+		if lg != nil {
+			lg.Error(r.Context(), "Failed to delete download session", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete download session")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userDownloadSession возвращает сессию текущего пользователя. Чужая сессия неотличима
+// от несуществующей
+func (h *Handler) userDownloadSession(w http.ResponseWriter, r *http.Request, sessionID string) (*models.ResumableDownloadSession, bool) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	session, err := h.fileService.GetResumableDownloadSession(r.Context(), sessionID)
+	if err != nil {
+		if lg != nil {
+			lg.Info(r.Context(), "Failed to get download session", zap.Error(err))
+		}
+		h.respondWithDownloadError(w, err, "Failed to get download session")
+		return nil, false
+	}
+	if session.UserID != userID {
+		h.respondWithError(w, http.StatusNotFound, "Download session not found")
+		return nil, false
+	}
+	return session, true
+}
+
+// serveDownloadSession отдает файл сессии через serveFileContent: ETag по контрольной
+// сумме сессии позволяет клиенту докачивать с If-Range. Файл открывается заранее,
+// чтобы изменение файла или отозванный доступ дали понятный код, а не 500
+func (h *Handler) serveDownloadSession(w http.ResponseWriter, r *http.Request, session *models.ResumableDownloadSession) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+
+	reader, err := h.fileService.OpenResumableDownload(r.Context(), session.ID)
+	if err != nil {
+		if lg != nil {
+			lg.Info(r.Context(), "Failed to open download session", zap.Error(err), zap.String("sessionID", session.ID))
+		}
+		h.respondWithDownloadError(w, err, "Failed to download file")
+		return
+	}
+	opened := false
+	defer func() {
+		if !opened {
+			reader.Close()
+		}
+	}()
+
+	file := &models.File{
+		ID:       session.FileID,
+		Name:     session.FileName,
+		MimeType: session.MimeType,
+		Version:  session.Version,
+	}
+	if session.Checksum != "" {
+		file.SHA256Checksum = &session.Checksum
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	h.serveFileContent(w, r, file, func() (interfaces.BlobReader, error) {
+		opened = true
+		return reader, nil
+	})
+}
+
+// respondWithDownloadError переводит ошибки сессий скачивания в коды ответа
+func (h *Handler) respondWithDownloadError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errdefs.Is(err, errdefs.ErrNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Download session not found")
+	case errdefs.Is(err, errdefs.ErrFileNotFound):
+		h.respondWithError(w, http.StatusNotFound, "File not found")
+	case errdefs.Is(err, errdefs.ErrSessionExpired):
+		h.respondWithError(w, http.StatusGone, "Download session expired")
+	case errdefs.Is(err, errdefs.ErrInvalidToken):
+		h.respondWithError(w, http.StatusForbidden, "Invalid or expired download link")
+	case errdefs.Is(err, errdefs.ErrPermissionDenied):
+		h.respondWithError(w, http.StatusForbidden, "Access denied")
+	case errdefs.Is(err, errdefs.ErrPreconditionFailed):
+		h.respondWithError(w, http.StatusPreconditionFailed, "File has been modified, start a new download")
+	case errdefs.Is(err, errdefs.ErrInvalidInput):
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
+
+func signedDownloadURL(token string) string {
+	return "/api/v1/download/signed/" + token
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"homecloud-file-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadFileByIDRangesAndConditionalGet(t *testing.T) {
+	router, files, _ := newTestRouter(t)
+	sha := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	files.file = &models.File{
+		ID:             uuid.New(),
+		Name:           "video.mp4",
+		MimeType:       "video/mp4",
+		SHA256Checksum: &sha,
+		UpdatedAt:      modified,
+		Size:           26,
+	}
+	files.content = []byte("abcdefghijklmnopqrstuvwxyz")
+	path := "/api/v1/files/" + files.file.ID.String() + "/download"
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", testToken)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Весь файл с валидаторами
+	rec := get(nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"`+sha+`"`, rec.Header().Get("ETag"))
+	assert.Equal(t, "Fri, 01 Mar 2024 12:00:00 GMT", rec.Header().Get("Last-Modified"))
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyz", rec.Body.String())
+
+	// Один диапазон
+	rec = get(map[string]string{"Range": "bytes=2-5"})
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "bytes 2-5/26", rec.Header().Get("Content-Range"))
+	assert.Equal(t, "4", rec.Header().Get("Content-Length"))
+	assert.Equal(t, "cdef", rec.Body.String())
+
+	// Суффикс
+	rec = get(map[string]string{"Range": "bytes=-3"})
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "xyz", rec.Body.String())
+
+	// Несколько диапазонов - multipart/byteranges с точной длиной
+	rec = get(map[string]string{"Range": "bytes=0-1,24-"})
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/byteranges", mediaType)
+	assert.Equal(t, rec.Header().Get("Content-Length"), strconv.Itoa(rec.Body.Len()))
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		parts = append(parts, part.Header.Get("Content-Range")+" "+string(body))
+	}
+	assert.Equal(t, []string{"bytes 0-1/26 ab", "bytes 24-25/26 yz"}, parts)
+
+	// Диапазон за концом файла
+	rec = get(map[string]string{"Range": "bytes=100-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+	assert.Equal(t, "bytes */26", rec.Header().Get("Content-Range"))
+
+	// If-Range с устаревшим ETag - весь файл
+	rec = get(map[string]string{"Range": "bytes=2-5", "If-Range": `"stale"`})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 26, rec.Body.Len())
+	rec = get(map[string]string{"Range": "bytes=2-5", "If-Range": `"` + sha + `"`})
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+
+	// Условный GET не открывает содержимое
+	files.calls = nil
+	rec = get(map[string]string{"If-None-Match": `W/"` + sha + `"`})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+	rec = get(map[string]string{"If-Modified-Since": "Fri, 01 Mar 2024 12:00:00 GMT"})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, []string{"GetFile", "GetFile"}, files.calls)
+
+	rec = get(map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Fri, 01 Mar 2024 12:00:00 GMT"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -1,11 +1,12 @@
 package api
 
 import (
-	"bytes"
-	"mime"
 	"context"
 	_ "crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 	"archive/zip"
 
 	"homecloud-file-service/internal/auth"
+	"homecloud-file-service/internal/errdefs"
 	"homecloud-file-service/internal/interfaces"
 	"homecloud-file-service/internal/logger"
 	"homecloud-file-service/internal/models"
@@ -34,19 +36,31 @@ import (
 type Handler struct {
 	fileService    interfaces.FileService
 	storageService interfaces.StorageService
+	uploadSessions interfaces.UploadSessionStore
+	idempotency    interfaces.IdempotencyStore
 	authClient     *auth.GRPCAuthClient
 	validator      *validator.Validate
 }
 
-func NewHandler(fileService interfaces.FileService, storageService interfaces.StorageService, authClient *auth.GRPCAuthClient) *Handler {
+func NewHandler(fileService interfaces.FileService, storageService interfaces.StorageService, uploadSessions interfaces.UploadSessionStore, idempotency interfaces.IdempotencyStore, authClient *auth.GRPCAuthClient) *Handler {
 	return &Handler{
 		fileService:    fileService,
 		storageService: storageService,
+		uploadSessions: uploadSessions,
+		idempotency:    idempotency,
 		authClient:     authClient,
 		validator:      validator.New(),
 	}
 }
 
+// DebugRoutes маршруты внутреннего listener-а: метрики (в том числе фоновой проверки
+// контрольных сумм). Без аутентификации, поэтому на публичный порт не выставляются
+func DebugRoutes() http.Handler {
+	router := mux.NewRouter()
+	router.Handle("/debug/vars", expvar.Handler()).Methods("GET")
+	return router
+}
+
 // SetupRoutes настраивает маршруты API
 func SetupRoutes(handler *Handler, log *logger.Logger) http.Handler {
 	// Инициализация маршрутизатора
@@ -56,6 +70,13 @@ func SetupRoutes(handler *Handler, log *logger.Logger) http.Handler {
 	router.HandleFunc("/health", handler.HealthCheck).Methods("GET")
 	router.HandleFunc("/api/v1/health", handler.HealthCheck).Methods("GET")
 
+	// Возможности сервера tus запрашиваются без аутентификации
+	router.HandleFunc("/api/v1/tus", withTusResumable(handler.TusOptions)).Methods("OPTIONS")
+	router.HandleFunc("/api/v1/tus/{sessionID}", withTusResumable(handler.TusOptions)).Methods("OPTIONS")
+
+	// По подписанной ссылке скачивают без токена авторизации: доступ дает сама подпись
+	router.Handle("/api/v1/download/signed/{token}", auth.LoggerMiddleware(log)(http.HandlerFunc(handler.SignedDownload))).Methods("GET", "HEAD")
+
 	// API v1 с аутентификацией
 	api := router.PathPrefix("/api/v1").Subrouter()
 
@@ -64,32 +85,107 @@ func SetupRoutes(handler *Handler, log *logger.Logger) http.Handler {
 	api.Use(auth.LoggerMiddleware(log))
 	api.Use(auth.AuthMiddleware(handler.authClient))
 
-	// Регистрируем обработчики для возобновляемой загрузки
+	// Регистрируем обработчики для возобновляемой загрузки и скачивания
 	api.HandleFunc("/files/upload/resumable/{sessionID}", handler.ResumableUpload).Methods("POST", "PATCH")
-	api.HandleFunc("/files/upload/resumable", handler.ResumableUploadInit).Methods("POST")
+	api.HandleFunc("/files/upload/resumable", handler.idempotent(handler.ResumableUploadInit)).Methods("POST")
+	api.HandleFunc("/upload/resumable/{sessionID}", handler.ResumableUpload).Methods("POST", "PATCH")
+	api.HandleFunc("/upload/resumable", handler.idempotent(handler.ResumableUploadInit)).Methods("POST")
+	api.HandleFunc("/files/upload/resumable/{sessionID}", handler.GetUploadSessionStatus).Methods("GET", "HEAD")
+	api.HandleFunc("/files/upload/resumable/{sessionID}", handler.AbortUploadSession).Methods("DELETE")
+	api.HandleFunc("/files/upload/resumable", handler.ListUploadSessions).Methods("GET")
+	api.HandleFunc("/upload/resumable/{sessionID}", handler.GetUploadSessionStatus).Methods("GET", "HEAD")
+	api.HandleFunc("/upload/resumable/{sessionID}", handler.AbortUploadSession).Methods("DELETE")
+	api.HandleFunc("/upload/resumable", handler.ListUploadSessions).Methods("GET")
+	api.HandleFunc("/download/resumable/{sessionID}/token", handler.CreateDownloadToken).Methods("POST")
+	api.HandleFunc("/download/resumable/{sessionID}", handler.ResumableDownload).Methods("GET", "HEAD")
+	api.HandleFunc("/download/resumable/{sessionID}", handler.DeleteDownloadSession).Methods("DELETE")
+	api.HandleFunc("/download/resumable", handler.ResumableDownloadInit).Methods("GET", "POST")
+
+	// Возобновляемая загрузка по протоколу tus 1.0
+	api.HandleFunc("/tus", withTusResumable(handler.idempotent(handler.TusCreateUpload))).Methods("POST")
+	api.HandleFunc("/tus/{sessionID}", withTusResumable(handler.TusUploadStatus)).Methods("HEAD")
+	api.HandleFunc("/tus/{sessionID}", withTusResumable(handler.TusPatchUpload)).Methods("PATCH")
+	api.HandleFunc("/tus/{sessionID}", withTusResumable(handler.TusTerminateUpload)).Methods("DELETE")
+
+	// Прямая загрузка и скачивание по пути
+	api.HandleFunc("/upload", handler.idempotent(handler.UploadFile)).Methods("POST")
+	api.HandleFunc("/download", handler.DownloadFile).Methods("GET", "HEAD")
 
 	// Регистрируем обработчики для папок
-	api.HandleFunc("/folders", handler.CreateFolder).Methods("POST")
+	api.HandleFunc("/folders", handler.idempotent(handler.CreateFolder)).Methods("POST")
+	api.HandleFunc("/folders/contents", handler.ListFolderContents).Methods("GET")
+	api.HandleFunc("/folders/browse", handler.BrowseFolder).Methods("GET")
+	api.HandleFunc("/folders/navigate", handler.NavigateToPath).Methods("GET")
 	api.HandleFunc("/folders/{id}/contents", handler.ListFolderContents).Methods("GET")
-	api.HandleFunc("/folders/upload", handler.UploadFolder).Methods("POST")
+	api.HandleFunc("/folders/upload", handler.idempotent(handler.UploadFolder)).Methods("POST")
 	api.HandleFunc("/folders/download", handler.DownloadFolder).Methods("GET")
 
+	// Поиск и фильтры. Регистрируются до /files/{id}, иначе mux примет имя за ID
+	api.HandleFunc("/files/search", handler.SearchFiles).Methods("GET")
+	api.HandleFunc("/files/starred", handler.ListStarredFiles).Methods("GET")
+	api.HandleFunc("/files/trashed", handler.ListTrashedFiles).Methods("GET")
+	api.HandleFunc("/files/details", handler.GetFileDetails).Methods("GET")
+	api.HandleFunc("/files/upload", handler.idempotent(handler.UploadFile)).Methods("POST")  // Для совместимости с тестами
+
 	// Регистрируем обработчики для файлов
-	api.HandleFunc("/files/{id}/download", handler.DownloadFileByID).Methods("GET")
+	api.HandleFunc("/files/{id}/download", handler.DownloadFileByID).Methods("GET", "HEAD")
+	api.HandleFunc("/files/{id}/upload", handler.idempotent(handler.UploadFileByID)).Methods("POST", "PUT")
+	api.HandleFunc("/files/{id}/content", handler.GetFileContent).Methods("GET")
+	api.HandleFunc("/files/{id}/restore", handler.RestoreFile).Methods("POST")
 	api.HandleFunc("/files/{id}", handler.GetFile).Methods("GET")
+	api.HandleFunc("/files/{id}", handler.UpdateFile).Methods("PATCH", "PUT")
 	api.HandleFunc("/files/{id}", handler.DeleteFile).Methods("DELETE")
-	api.HandleFunc("/files/upload", handler.UploadFile).Methods("POST")  // Для совместимости с тестами
 	api.HandleFunc("/files", handler.ListFiles).Methods("GET")
-	api.HandleFunc("/files", handler.CreateFile).Methods("POST")
-	api.HandleFunc("/files", handler.UploadFile).Methods("PUT")  // Для совместимости с PUT запросами
+	api.HandleFunc("/files", handler.idempotent(handler.CreateFile)).Methods("POST")
+	api.HandleFunc("/files", handler.idempotent(handler.UploadFile)).Methods("PUT")  // Для совместимости с PUT запросами
+
+	// Ревизии файлов
+	api.HandleFunc("/files/{id}/revisions", handler.ListRevisions).Methods("GET")
+	api.HandleFunc("/files/{id}/revisions/{revisionId}", handler.GetRevision).Methods("GET")
+	api.HandleFunc("/files/{id}/revisions/{revisionId}/restore", handler.RestoreRevision).Methods("POST")
+
+	// Права доступа
+	api.HandleFunc("/files/{id}/permissions", handler.ListPermissions).Methods("GET")
+	api.HandleFunc("/files/{id}/permissions", handler.GrantPermission).Methods("POST")
+	api.HandleFunc("/files/{id}/permissions/{granteeId}", handler.RevokePermission).Methods("DELETE")
+
+	// Избранное, перемещение, копирование и переименование
+	api.HandleFunc("/files/{id}/star", handler.StarFile).Methods("POST")
+	api.HandleFunc("/files/{id}/star", handler.UnstarFile).Methods("DELETE")
+	api.HandleFunc("/files/{id}/unstar", handler.UnstarFile).Methods("POST")
+	api.HandleFunc("/files/{id}/move", handler.MoveFile).Methods("POST")
+	api.HandleFunc("/files/{id}/copy", handler.idempotent(handler.CopyFile)).Methods("POST")
+	api.HandleFunc("/files/{id}/rename", handler.RenameFile).Methods("POST")
+
+	// Метаданные и целостность
+	api.HandleFunc("/files/{id}/metadata", handler.GetFileMetadata).Methods("GET")
+	api.HandleFunc("/files/{id}/metadata", handler.UpdateFileMetadata).Methods("PUT", "PATCH")
+	api.HandleFunc("/files/{id}/verify", handler.VerifyFileIntegrity).Methods("POST")
+	api.HandleFunc("/files/{id}/checksums", handler.CalculateFileChecksums).Methods("POST")
+
+	// Состояние и обслуживание хранилища
+	api.HandleFunc("/storage/info", handler.GetStorageInfo).Methods("GET")
+	api.HandleFunc("/storage/scrub", handler.GetScrubStatus).Methods("GET")
+	// Проверка и исправление всего хранилища затрагивают чужие файлы, поэтому в
+	// пользовательском API их нет: это делает администратор через cmd/fsck
 
 	// --- CORS middleware ---
 	corsMiddleware := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "Content-Range"}),
+		handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "Content-Range", "Range", "If-Range", "If-None-Match", "If-Modified-Since", "If-Match", "X-Device-Name", "Content-Digest", "Content-MD5", "Idempotency-Key",
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Checksum"}),
+		handlers.ExposedHeaders([]string{"ETag", "Last-Modified", "Accept-Ranges", "Content-Range", "Content-Disposition",
+			"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Checksum-Algorithm", "Upload-Offset", "Upload-Length", "Upload-Metadata", "Upload-Expires", "X-File-ID", "Idempotent-Replayed"}),
 	)
-	return corsMiddleware(router)
+	withCORS := corsMiddleware(router)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isTusDiscovery(r) {
+			router.ServeHTTP(w, r)
+			return
+		}
+		withCORS.ServeHTTP(w, r)
+	})
 }
 
 // Вспомогательные функции
@@ -126,13 +222,14 @@ func (h *Handler) respondWithError(w http.ResponseWriter, statusCode int, messag
 	h.respondWithJSON(w, statusCode, map[string]string{"error": message})
 }
 
-// ensureFolderPath создает папки по указанному пути и возвращает ID последней папки
-func (h *Handler) ensureFolderPath(ctx context.Context, userID uuid.UUID, folderPath string) (*uuid.UUID, error) {
+// ensureFolderPath создает папки по пути внутри parentID (nil - корень) и возвращает ID последней папки.
+// Уже существующие папки используются как есть
+func (h *Handler) ensureFolderPath(ctx context.Context, userID uuid.UUID, parentID *uuid.UUID, folderPath string) (*uuid.UUID, error) {
 	lg := logger.GetLoggerFromCtxSafe(ctx)
 
 	// Разбиваем путь на части
 	parts := strings.Split(folderPath, "/")
-	var currentParentID *uuid.UUID = nil
+	currentParentID := parentID
 
 	for _, part := range parts {
 		if part == "" || part == "." {
@@ -140,7 +237,7 @@ func (h *Handler) ensureFolderPath(ctx context.Context, userID uuid.UUID, folder
 		}
 
 		// Создаем папку
-		folder, err := h.fileService.CreateFolder(ctx, part, currentParentID, userID)
+		folder, err := h.fileService.CreateFolder(ctx, part, currentParentID, userID, models.ConflictNewVersion)
 		if err != nil {
 			if lg != nil {
 				lg.Error(ctx, "Failed to create folder", zap.Error(err), zap.String("name", part))
@@ -261,6 +358,8 @@ type ResumableUploadRequest struct {
 	FilePath string `json:"filePath" validate:"required"`
 	Size     uint64 `json:"size" validate:"required,gt=0"`
 	SHA256   string `json:"sha256"`
+	// Conflict что делать при завершении, если имя уже занято
+	Conflict models.ConflictPolicy `json:"conflict"`
 }
 
 type DownloadRequest struct {
@@ -324,6 +423,13 @@ func (h *Handler) CreateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Политика при занятом имени: параметр conflict или поле conflict в теле
+	req.Conflict, err = parseConflictPolicy(r, req.Conflict)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Создаем файл
 	createdFile, err := h.fileService.CreateFile(r.Context(), &req, userID)
 	if err != nil {
@@ -334,27 +440,8 @@ func (h *Handler) CreateFile(w http.ResponseWriter, r *http.Request) {
 				zap.String("userID", userID.String()))
 		}
 
-		// Проверяем, является ли это ошибкой дублирования имени файла
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			// Генерируем альтернативное имя
-			alternativeName := generateAlternativeName(req.Name)
-
-			errorResponse := map[string]interface{}{
-				"error": "File with this name already exists",
-				"details": map[string]interface{}{
-					"fileName":   req.Name,
-					"suggestion": alternativeName,
-					"message":    fmt.Sprintf("A file named '%s' already exists in this location. Try using '%s' instead.", req.Name, alternativeName),
-				},
-			}
-
-			if lg != nil {
-				lg.Info(r.Context(), "Duplicate file name detected",
-					zap.String("fileName", req.Name),
-					zap.String("suggestedName", alternativeName))
-			}
-
-			h.respondWithJSON(w, http.StatusConflict, errorResponse)
+		// Имя занято, а политика conflict=fail
+		if h.respondWithFileExists(w, err) {
 			return
 		}
 
@@ -426,6 +513,13 @@ func (h *Handler) UpdateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Условия записи из If-Match
+	opts, err := parseIfMatch(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
 	// Парсим JSON из тела запроса
 	var req models.UpdateFileRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -434,12 +528,12 @@ func (h *Handler) UpdateFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Обновляем файл
-	updatedFile, err := h.fileService.UpdateFile(r.Context(), fileID, &req, userID)
+	updatedFile, err := h.fileService.UpdateFile(r.Context(), fileID, &req, userID, opts)
 	if err != nil {
 		if lg != nil {
 			lg.Error(r.Context(), "Failed to update file", zap.Error(err))
 		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to update file")
+		h.respondWithWriteError(w, err, "Failed to update file")
 		return
 	}
 
@@ -466,13 +560,20 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Условия записи из If-Match
+	opts, err := parseIfMatch(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
 	// Выполняем рекурсивное жесткое удаление
-	err = h.fileService.DeleteFileRecursive(r.Context(), fileID, userID)
+	err = h.fileService.DeleteFileRecursive(r.Context(), fileID, userID, opts)
 	if err != nil {
 		if lg != nil {
 			lg.Error(r.Context(), "Failed to delete file", zap.Error(err))
 		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete file")
+		h.respondWithWriteError(w, err, "Failed to delete file")
 		return
 	}
 
@@ -665,11 +766,10 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Читаем содержимое файла
-	content, err := io.ReadAll(file)
+	// Политика при занятом имени: параметр conflict или поле формы
+	conflict, err := parseConflictPolicy(r, models.ConflictPolicy(r.FormValue("conflict")))
 	if err != nil {
-		lg.Error(r.Context(), "Failed to read file content", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to read file content")
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -679,20 +779,21 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		mimeType = getMimeTypeByExtension(filePath)
 	}
 
-	// Создаем запрос на создание файла
+	// Создаем запрос на создание файла, содержимое передается потоково
 	createReq := &models.CreateFileRequest{
-		Name:     filepath.Base(filePath),
-		MimeType: mimeType,
-		Size:     int64(len(content)),
-		Content:  content,
-		IsFolder: false,
+		Name:          filepath.Base(filePath),
+		MimeType:      mimeType,
+		Size:          header.Size,
+		ContentReader: file,
+		IsFolder:      false,
+		Conflict:      conflict,
 	}
 
 	// Если путь содержит папки, создаем их
 	dirPath := filepath.Dir(filePath)
 	if dirPath != "." && dirPath != "/" {
 		// Создаем папки по пути
-		parentID, err := h.ensureFolderPath(r.Context(), userID, dirPath)
+		parentID, err := h.ensureFolderPath(r.Context(), userID, nil, dirPath)
 		if err != nil {
 			lg.Error(r.Context(), "Failed to create folder path", zap.Error(err), zap.String("path", dirPath))
 			h.respondWithError(w, http.StatusInternalServerError, "Failed to create folder structure")
@@ -706,27 +807,8 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		lg.Error(r.Context(), "Failed to create file", zap.Error(err))
 		
-		// Проверяем, является ли это ошибкой дублирования имени файла
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			// Генерируем альтернативное имя
-			alternativeName := generateAlternativeName(createReq.Name)
-
-			errorResponse := map[string]interface{}{
-				"error": "File with this name already exists",
-				"details": map[string]interface{}{
-					"fileName":   createReq.Name,
-					"suggestion": alternativeName,
-					"message":    fmt.Sprintf("A file named '%s' already exists in this location. Try using '%s' instead.", createReq.Name, alternativeName),
-				},
-			}
-
-			if lg != nil {
-				lg.Info(r.Context(), "Duplicate file name detected",
-					zap.String("fileName", createReq.Name),
-					zap.String("suggestedName", alternativeName))
-			}
-
-			h.respondWithJSON(w, http.StatusConflict, errorResponse)
+		// Имя занято, а политика conflict=fail
+		if h.respondWithFileExists(w, err) {
 			return
 		}
 
@@ -777,38 +859,12 @@ func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Скачиваем файл по ID
-    reader, filename, err := h.fileService.DownloadFile(r.Context(), file.ID, userID)
-    if err != nil {
-        lg.Error(r.Context(), "File download failed", zap.Error(err), zap.String("fileID", file.ID.String()))
-        h.respondWithError(w, http.StatusInternalServerError, "Failed to download file")
-        return
-    }
-    defer func() {
-        if err := reader.Close(); err != nil {
-            lg.Error(r.Context(), "Failed to close file reader", zap.Error(err))
-        }
-    }()
-
-    // Определяем MIME-тип
-    mimeType := "application/octet-stream"
-    if ext := filepath.Ext(filename); ext != "" {
-        mimeType = mime.TypeByExtension(ext)
-    }
-
-    // Устанавливаем заголовки
-    w.Header().Set("Content-Type", mimeType)
-    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-    w.Header().Set("Content-Length", fmt.Sprintf("%d", file.Size))
-
-    // Копируем содержимое файла в ответ
-    if _, err = io.Copy(w, reader); err != nil {
-        lg.Error(r.Context(), "Failed to send file content", zap.Error(err))
-        // Не отправляем повторный ответ об ошибке, так как часть данных уже могла быть отправлена
-        return
-    }
-
-    lg.Info(r.Context(), "File downloaded successfully", zap.String("filename", filename))
+    // Скачиваем файл по ID с учетом Range и условных заголовков
+    h.serveFileContent(w, r, file, func() (interfaces.BlobReader, error) {
+        reader, _, err := h.fileService.DownloadFile(r.Context(), file.ID, userID)
+        return reader, err
+    })
+    lg.Info(r.Context(), "File download handled", zap.String("filename", file.Name))
 }
 
 // Обработчики загрузки и скачивания по ID файла
@@ -830,25 +886,39 @@ func (h *Handler) UploadFileByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Читаем содержимое файла из тела запроса
-	content, err := io.ReadAll(r.Body)
+	// Условия записи из If-Match
+	opts, err := parseIfMatch(r)
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to read request body")
+		h.respondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
 		return
 	}
 
-	// Создаем reader из содержимого
-	reader := bytes.NewReader(content)
+	// Вместо отказа при устаревшей версии можно сохранить загрузку конфликтной копией
+	opts.ConflictCopy = r.URL.Query().Get("conflict_copy") == "true"
+	opts.Device = r.Header.Get("X-Device-Name")
 
-	// Загружаем файл
-	err = h.fileService.UploadFile(r.Context(), fileID, reader, userID)
+	// Загружаем файл, тело запроса передается в хранилище потоково
+	result, err := h.fileService.UploadFile(r.Context(), fileID, r.Body, userID, opts)
 	if err != nil {
 		lg.Error(r.Context(), "Failed to upload file", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to upload file")
+		h.respondWithWriteError(w, err, "Failed to upload file")
 		return
 	}
 
-	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "File uploaded successfully"})
+	if result.ConflictCopyID != nil {
+		h.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message":          "File has been modified, upload saved as a conflicted copy",
+			"file_id":          result.FileID,
+			"version":          result.Version,
+			"conflict_copy_id": result.ConflictCopyID,
+		})
+		return
+	}
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "File uploaded successfully",
+		"file_id": result.FileID,
+		"version": result.Version,
+	})
 }
 
 func (h *Handler) DownloadFileByID(w http.ResponseWriter, r *http.Request) {
@@ -902,97 +972,13 @@ func (h *Handler) DownloadFileByID(w http.ResponseWriter, r *http.Request) {
     }
     log(fmt.Sprintf("File found: %s, Size: %d bytes", file.Name, file.Size))
 
-    // Получаем содержимое файла
-    reader, mimeType, err := h.fileService.DownloadFile(r.Context(), fileID, userID)
-    if err != nil {
-        logError(err, "Failed to get file content")
-        h.respondWithError(w, http.StatusInternalServerError, "Failed to download file")
-        return
-    }
-
-    // Устанавливаем заголовки
-    w.Header().Set("Content-Type", mimeType)
-    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
-    w.Header().Set("Content-Length", fmt.Sprintf("%d", file.Size))
-
-    // Отправляем файл
+    // Отдаем содержимое с учетом Range и условных заголовков
     startTime := time.Now()
-    bytesCopied, err := io.Copy(w, reader)
-    
-    // Закрываем reader ПОСЛЕ копирования
-    if closeErr := reader.Close(); closeErr != nil {
-        logError(closeErr, "Failed to close reader")
-    }
-    
-    duration := time.Since(startTime)
-    
-    // Логируем результат
-    if err != nil {
-        logError(err, fmt.Sprintf("Failed to send file. Sent: %d/%d bytes", bytesCopied, file.Size))
-    } else {
-        log(fmt.Sprintf("File sent successfully. Bytes: %d, Duration: %s", bytesCopied, duration))
-    }
-}
-
-type httpRange struct {
-	start, end int64
-}
-
-func parseRanges(rangeHeader string, fileSize int64) ([]httpRange, error) {
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		return nil, fmt.Errorf("invalid range header format")
-	}
-
-	var ranges []httpRange
-	for _, r := range strings.Split(rangeHeader[6:], ",") {
-		r = strings.TrimSpace(r)
-		if r == "" {
-			continue
-		}
-
-		parts := strings.Split(r, "-")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid range format")
-		}
-
-		var start, end int64
-		var err error
-
-		if parts[0] == "" {
-			// suffix length (e.g., -100)
-			end = fileSize - 1
-			start = fileSize - 1
-			if parts[1] != "" {
-				if start, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
-					return nil, fmt.Errorf("invalid range start")
-				}
-				start = fileSize - start
-				if start < 0 {
-					start = 0
-				}
-			}
-		} else {
-			// normal range (e.g., 100-200)
-			if start, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
-				return nil, fmt.Errorf("invalid range start")
-			}
-			if parts[1] == "" {
-				end = fileSize - 1
-			} else {
-				if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
-					return nil, fmt.Errorf("invalid range end")
-				}
-			}
-		}
-
-		if start > end {
-			return nil, fmt.Errorf("invalid range: start > end")
-		}
-
-		ranges = append(ranges, httpRange{start: start, end: end})
-	}
-
-	return ranges, nil
+    h.serveFileContent(w, r, file, func() (interfaces.BlobReader, error) {
+        reader, _, err := h.fileService.DownloadFile(r.Context(), fileID, userID)
+        return reader, err
+    })
+    log(fmt.Sprintf("Download handled. Duration: %s", time.Since(startTime)))
 }
 
 func (h *Handler) GetFileContent(w http.ResponseWriter, r *http.Request) {
@@ -1020,13 +1006,16 @@ func (h *Handler) GetFileContent(w http.ResponseWriter, r *http.Request) {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to get file content")
 		return
 	}
+	defer content.Close()
 
 	// Устанавливаем заголовки
 	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", content.Size()))
 
 	// Отправляем содержимое
-	w.Write(content)
+	if _, err := io.Copy(w, content); err != nil {
+		lg.Error(r.Context(), "Failed to stream file content", zap.Error(err))
+	}
 }
 
 // Возобновляемые операции
@@ -1076,12 +1065,19 @@ func (h *Handler) ResumableUploadInit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Политика при занятом имени применяется при завершении загрузки
+	conflict, err := parseConflictPolicy(r, req.Conflict)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Если путь содержит папки, проверяем их существование или создаем
 	dirPath := filepath.Dir(req.FilePath)
 	var parentID *uuid.UUID
 	if dirPath != "." && dirPath != "/" {
 		var err error
-		parentID, err = h.ensureFolderPath(ctx, userID, dirPath)
+		parentID, err = h.ensureFolderPath(ctx, userID, nil, dirPath)
 		if err != nil {
 			lg.Error(ctx, "Failed to ensure folder path", zap.Error(err))
 			h.respondWithError(w, http.StatusInternalServerError, "Failed to prepare upload location")
@@ -1090,17 +1086,25 @@ func (h *Handler) ResumableUploadInit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Создаем сессию
-	sessionID := uuid.New().String()
-	session := uploadSession{
-		FilePath:  req.FilePath,
-		Size:      req.Size,
-		SHA256:    req.SHA256,
-		UserID:    userID,
-		ParentID:  parentID,
+	sessionID := generateSessionID()
+	session := &models.ResumableSession{
+		ID:       sessionID,
+		FilePath: req.FilePath,
+		Size:     int64(req.Size),
+		Checksum: req.SHA256,
+		UserID:   userID,
+		ParentID: parentID,
+		Conflict: conflict,
 	}
 
 	// Сохраняем сессию
-	saveSession(sessionID, session)
+	if err := h.uploadSessions.Save(ctx, session); err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to save upload session", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create upload session")
+		return
+	}
 
 	// Отправляем ответ
 	h.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
@@ -1109,162 +1113,6 @@ func (h *Handler) ResumableUploadInit(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *Handler) ResumableDownloadInit(w http.ResponseWriter, r *http.Request) {
-	lg := logger.GetLoggerFromCtxSafe(r.Context())
-	if lg != nil {
-		lg.Info(r.Context(), "ResumableDownloadInit handler called")
-	}
-
-	userID, err := h.getUserIDFromRequest(r)
-	if err != nil {
-		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
-	// Парсим fileID из query параметра
-	fileIDStr := r.URL.Query().Get("file_id")
-	if fileIDStr == "" {
-		h.respondWithError(w, http.StatusBadRequest, "file_id parameter is required")
-		return
-	}
-
-	fileID, err := uuid.Parse(fileIDStr)
-	if err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid file_id")
-		return
-	}
-
-	// Инициализируем сессию
-	session, err := h.fileService.InitResumableDownload(r.Context(), fileID, userID)
-	if err != nil {
-		if lg != nil {
-			lg.Error(r.Context(), "Failed to init resumable download", zap.Error(err))
-		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to init resumable download")
-		return
-	}
-
-	// Формируем ответ
-	response := map[string]interface{}{
-		"session_id":   session.ID,
-		"file_name":    session.FileName,
-		"file_size":    session.Size,
-		"checksum":     session.Checksum,
-		"mime_type":    session.MimeType,
-		"expires_at":   session.ExpiresAt,
-		"download_url": fmt.Sprintf("/api/v1/download/resumable/%s", session.ID),
-	}
-
-	h.respondWithJSON(w, http.StatusCreated, response)
-}
-
-func (h *Handler) ResumableDownload(w http.ResponseWriter, r *http.Request) {
-	lg := logger.GetLoggerFromCtxSafe(r.Context())
-	if lg != nil {
-		lg.Info(r.Context(), "ResumableDownload handler called")
-	}
-
-	// Получаем sessionID из URL
-	vars := mux.Vars(r)
-	sessionID := vars["sessionID"]
-	if sessionID == "" {
-		h.respondWithError(w, http.StatusBadRequest, "Session ID is required")
-		return
-	}
-
-	// Получаем сессию
-	session, err := h.fileService.GetResumableDownloadSession(r.Context(), sessionID)
-	if err != nil {
-		if lg != nil {
-			lg.Error(r.Context(), "Failed to get session", zap.Error(err))
-		}
-		h.respondWithError(w, http.StatusNotFound, "Session not found")
-		return
-	}
-
-	// Парсим Range заголовок
-	rangeHeader := r.Header.Get("Range")
-	if rangeHeader == "" {
-		h.respondWithError(w, http.StatusBadRequest, "Range header is required")
-		return
-	}
-
-	start, end, err := h.parseRangeHeader(rangeHeader, session.Size)
-	if err != nil {
-		h.respondWithError(w, http.StatusRequestedRangeNotSatisfiable, "Invalid Range header")
-		return
-	}
-
-	// Получаем chunk файла
-	reader, err := h.fileService.DownloadFileChunk(r.Context(), sessionID, start, end)
-	if err != nil {
-		if lg != nil {
-			lg.Error(r.Context(), "Failed to get file chunk", zap.Error(err))
-		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to get file chunk")
-		return
-	}
-	defer reader.Close()
-
-	// Устанавливаем заголовки
-	w.Header().Set("Content-Type", session.MimeType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", session.FileName))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", end-start+1))
-	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, session.Size))
-
-	// Определяем статус ответа
-	isComplete := end+1 >= uint64(session.Size)
-	if !isComplete {
-		w.WriteHeader(http.StatusPartialContent)
-	}
-
-	// Отправляем chunk
-	if _, err := io.Copy(w, reader); err != nil {
-		if lg != nil {
-			lg.Error(r.Context(), "Failed to send chunk", zap.Error(err))
-		}
-	}
-
-	// Если скачивание завершено, удаляем сессию
-	if isComplete {
-		h.fileService.DeleteResumableDownloadSession(r.Context(), sessionID)
-	}
-}
-
-// parseRangeHeader парсит Range заголовок и возвращает start и end байты
-func (h *Handler) parseRangeHeader(rangeHeader string, fileSize int64) (uint64, uint64, error) {
-	// Ожидаемый формат: "bytes=start-end"
-	re := regexp.MustCompile(`bytes=(\d+)-(\d*)`)
-	matches := re.FindStringSubmatch(rangeHeader)
-	
-	if len(matches) < 2 {
-		return 0, 0, fmt.Errorf("invalid range header format")
-	}
-
-	start, err := strconv.ParseUint(matches[1], 10, 64)
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid start value: %w", err)
-	}
-
-	var end uint64
-	if matches[2] != "" {
-		end, err = strconv.ParseUint(matches[2], 10, 64)
-		if err != nil {
-			return 0, 0, fmt.Errorf("invalid end value: %w", err)
-		}
-	} else {
-		// Если end не указан, берем до конца файла
-		end = uint64(fileSize) - 1
-	}
-
-	// Проверяем валидность диапазона
-	if start >= uint64(fileSize) || end >= uint64(fileSize) || start > end {
-		return 0, 0, fmt.Errorf("range out of bounds")
-	}
-
-	return start, end, nil
-}
-
 // Остальные обработчики (заглушки)
 func (h *Handler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 	lg := logger.GetLoggerFromCtxSafe(r.Context())
@@ -1296,11 +1144,17 @@ func (h *Handler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	conflict, err := parseConflictPolicy(r, "")
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Создаем папку
-	folder, err := h.fileService.CreateFolder(r.Context(), req.Name, req.ParentID, userID)
+	folder, err := h.fileService.CreateFolder(r.Context(), req.Name, req.ParentID, userID, conflict)
 	if err != nil {
 		lg.Error(r.Context(), "Failed to create folder", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create folder")
+		h.respondWithWriteError(w, err, "Failed to create folder")
 		return
 	}
 
@@ -1536,11 +1390,18 @@ func (h *Handler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Условия записи из If-Match
+	opts, err := parseIfMatch(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
 	// Восстанавливаем ревизию
-	err = h.fileService.RestoreRevision(r.Context(), fileID, revisionID, userID)
+	err = h.fileService.RestoreRevision(r.Context(), fileID, revisionID, userID, opts)
 	if err != nil {
 		lg.Error(r.Context(), "Failed to restore revision", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to restore revision")
+		h.respondWithWriteError(w, err, "Failed to restore revision")
 		return
 	}
 
@@ -1754,20 +1615,33 @@ func (h *Handler) MoveFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Условия записи из If-Match
+	opts, err := parseIfMatch(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
 	// Декодируем запрос
 	var req struct {
-		NewParentID *uuid.UUID `json:"new_parent_id"`
+		NewParentID *uuid.UUID            `json:"new_parent_id"`
+		Conflict    models.ConflictPolicy `json:"conflict"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	opts.Conflict, err = parseConflictPolicy(r, req.Conflict)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Перемещаем файл
-	err = h.fileService.MoveFile(r.Context(), fileID, req.NewParentID, userID)
+	err = h.fileService.MoveFile(r.Context(), fileID, req.NewParentID, userID, opts)
 	if err != nil {
 		lg.Error(r.Context(), "Failed to move file", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to move file")
+		h.respondWithWriteError(w, err, "Failed to move file")
 		return
 	}
 
@@ -1794,19 +1668,25 @@ func (h *Handler) CopyFile(w http.ResponseWriter, r *http.Request) {
 
 	// Декодируем запрос
 	var req struct {
-		NewParentID *uuid.UUID `json:"new_parent_id"`
-		NewName     string     `json:"new_name"`
+		NewParentID *uuid.UUID            `json:"new_parent_id"`
+		NewName     string                `json:"new_name"`
+		Conflict    models.ConflictPolicy `json:"conflict"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	conflict, err := parseConflictPolicy(r, req.Conflict)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Копируем файл
-	copiedFile, err := h.fileService.CopyFile(r.Context(), fileID, req.NewParentID, req.NewName, userID)
+	copiedFile, err := h.fileService.CopyFile(r.Context(), fileID, req.NewParentID, req.NewName, userID, models.WriteOptions{Conflict: conflict})
 	if err != nil {
 		lg.Error(r.Context(), "Failed to copy file", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to copy file")
+		h.respondWithWriteError(w, err, "Failed to copy file")
 		return
 	}
 
@@ -1831,6 +1711,13 @@ func (h *Handler) RenameFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Условия записи из If-Match
+	opts, err := parseIfMatch(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
 	// Декодируем запрос
 	var req struct {
 		NewName string `json:"new_name"`
@@ -1841,10 +1728,10 @@ func (h *Handler) RenameFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Переименовываем файл
-	err = h.fileService.RenameFile(r.Context(), fileID, req.NewName, userID)
+	err = h.fileService.RenameFile(r.Context(), fileID, req.NewName, userID, opts)
 	if err != nil {
 		lg.Error(r.Context(), "Failed to rename file", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to rename file")
+		h.respondWithWriteError(w, err, "Failed to rename file")
 		return
 	}
 
@@ -1898,6 +1785,13 @@ func (h *Handler) UpdateFileMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Условия записи из If-Match
+	opts, err := parseIfMatch(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
 	// Декодируем метаданные
 	var metadata map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
@@ -1906,10 +1800,10 @@ func (h *Handler) UpdateFileMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Обновляем метаданные
-	err = h.fileService.UpdateFileMetadata(r.Context(), fileID, metadata, userID)
+	err = h.fileService.UpdateFileMetadata(r.Context(), fileID, metadata, userID, opts)
 	if err != nil {
 		lg.Error(r.Context(), "Failed to update file metadata", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to update file metadata")
+		h.respondWithWriteError(w, err, "Failed to update file metadata")
 		return
 	}
 
@@ -1997,15 +1891,24 @@ func (h *Handler) CleanupStorage(w http.ResponseWriter, r *http.Request) {
 	lg := logger.GetLoggerFromCtxSafe(r.Context())
 	lg.Info(r.Context(), "CleanupStorage handler called")
 
-	// Очищаем хранилище
-	err := h.storageService.CleanupOrphanedFiles(r.Context())
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		lg.Error(r.Context(), "Failed to get userID from request", zap.Error(err))
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Сверяем с БД только файлы самого пользователя. По умолчанию это лишь отчет,
+	// расхождения исправляются с явным dry_run=false
+	dryRun := r.URL.Query().Get("dry_run") != "false"
+	report, err := h.storageService.CheckStorage(r.Context(), []uuid.UUID{userID}, dryRun)
 	if err != nil {
 		lg.Error(r.Context(), "Failed to cleanup storage", zap.Error(err))
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to cleanup storage")
 		return
 	}
 
-	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Storage cleanup completed successfully"})
+	h.respondWithJSON(w, http.StatusOK, report)
 }
 
 func (h *Handler) OptimizeStorage(w http.ResponseWriter, r *http.Request) {
@@ -2023,6 +1926,28 @@ func (h *Handler) OptimizeStorage(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Storage optimization completed successfully"})
 }
 
+// GetScrubStatus возвращает итог последней фоновой проверки контрольных сумм файлов пользователя
+func (h *Handler) GetScrubStatus(w http.ResponseWriter, r *http.Request) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+	lg.Info(r.Context(), "GetScrubStatus handler called")
+
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		lg.Error(r.Context(), "Failed to get userID from request", zap.Error(err))
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	status, err := h.storageService.GetScrubStatus(r.Context(), userID)
+	if err != nil {
+		lg.Error(r.Context(), "Failed to get scrub status", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get scrub status")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, status)
+}
+
 // HealthCheck проверяет состояние сервиса
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
@@ -2257,14 +2182,6 @@ func (h *Handler) GetFileDetails(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, file)
 }
 
-// generateAlternativeName генерирует альтернативное имя для файла, если файл с таким именем уже существует
-func generateAlternativeName(name string) string {
-	ext := filepath.Ext(name)
-	baseName := strings.TrimSuffix(name, ext)
-	timestamp := time.Now().UnixNano()
-	return fmt.Sprintf("%s_%d%s", baseName, timestamp, ext)
-}
-
 func (h *Handler) ResumableUpload(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	lg := logger.GetLoggerFromCtxSafe(ctx)
@@ -2278,14 +2195,19 @@ func (h *Handler) ResumableUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Получаем сессию
-	session, err := getSession(sessionID)
-	if err != nil {
+	session, err := h.uploadSessions.Get(ctx, sessionID)
+	if err != nil || session == nil {
 		if lg != nil {
 			lg.Error(ctx, "Session not found", zap.Error(err), zap.String("sessionID", sessionID))
 		}
 		h.respondWithError(w, http.StatusNotFound, "Upload session not found")
 		return
 	}
+	if session.Expired(time.Now()) {
+		h.uploadSessions.Delete(ctx, sessionID)
+		h.respondWithError(w, http.StatusGone, "Upload session expired")
+		return
+	}
 
 	// Проверяем пользователя
 	userID, err := h.getUserIDFromRequest(r)
@@ -2304,8 +2226,7 @@ func (h *Handler) ResumableUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	start, end, err := parseContentRange(rangeHeader)
-	total := end
+	start, end, total, err := parseContentRange(rangeHeader)
 	if err != nil {
 		if lg != nil {
 			lg.Error(ctx, "Invalid Content-Range", zap.Error(err), zap.String("range", rangeHeader))
@@ -2315,47 +2236,43 @@ func (h *Handler) ResumableUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверяем, что размер файла совпадает с размером в сессии
-	if total != session.Size {
+	if total != nil && int64(*total) != session.Size {
 		if lg != nil {
 			lg.Error(ctx, "File size mismatch",
 				zap.Int("expected", int(session.Size)),
-				zap.Int("actual", int(total)))
+				zap.Int("actual", int(*total)))
 		}
 		h.respondWithError(w, http.StatusBadRequest, "File size mismatch")
 		return
 	}
-
-	// Создаем временный файл для загрузки
-	tempDir := filepath.Join(os.TempDir(), "resumable_uploads")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		if lg != nil {
-			lg.Error(ctx, "Failed to create temp directory", zap.Error(err))
-		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to prepare upload location")
+	if int64(end) >= session.Size {
+		h.respondWithError(w, http.StatusBadRequest, "Content-Range exceeds file size")
 		return
 	}
 
-	tempFilePath := filepath.Join(tempDir, sessionID)
-	file, err := os.OpenFile(tempFilePath, os.O_WRONLY|os.O_CREATE, 0666)
+	// Контрольные суммы части проверяются при приеме
+	digests, err := parseChunkDigests(r.Header)
 	if err != nil {
-		if lg != nil {
-			lg.Error(ctx, "Failed to open temp file", zap.Error(err))
-		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		h.respondWithError(w, http.StatusBadRequest, "Invalid chunk digest: "+err.Error())
 		return
 	}
-	defer file.Close()
 
-	// Записываем данные в нужную позицию
-	if _, err := file.Seek(int64(start), 0); err != nil {
+	// Часть пишется отдельным файлом без блокировки сессии, поэтому части одной загрузки
+	// можно передавать параллельно. Диапазон считается принятым, только если пришел целиком
+	chunkLength := int64(end-start) + 1
+	body := newDigestReader(io.LimitReader(r.Body, chunkLength), digests)
+	err = h.uploadSessions.WriteChunk(ctx, sessionID, int64(start), int64(end), body)
+	switch {
+	case errors.Is(err, errChunkDigestMismatch):
 		if lg != nil {
-			lg.Error(ctx, "Failed to seek in file", zap.Error(err))
+			lg.Error(ctx, "Chunk digest mismatch", zap.Error(err), zap.String("range", rangeHeader))
 		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		h.respondWithError(w, http.StatusBadRequest, "Chunk digest mismatch")
 		return
-	}
-
-	if _, err := io.Copy(file, r.Body); err != nil {
+	case errors.Is(err, errdefs.ErrInvalidInput):
+		h.respondWithError(w, http.StatusBadRequest, "Chunk length does not match Content-Range")
+		return
+	case err != nil:
 		if lg != nil {
 			lg.Error(ctx, "Failed to write chunk", zap.Error(err))
 		}
@@ -2363,118 +2280,56 @@ func (h *Handler) ResumableUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Проверяем, завершена ли загрузка
-	isComplete := end+1 == session.Size
+	// Учет принятых диапазонов и сборка идут под блокировкой сессии: параллельные части
+	// не должны затирать друг другу список диапазонов
+	unlock := lockSession(sessionID)
+	defer unlock()
 
-	// Синхронизируем файл
-	if err := file.Sync(); err != nil {
-		if lg != nil {
-			lg.Error(ctx, "Failed to sync file", zap.Error(err))
-		}
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to finalize upload")
+	session, err = h.uploadSessions.Get(ctx, sessionID)
+	if err != nil || session == nil {
+		// Сессию отменили или завершили, пока шла передача части
+		h.uploadSessions.Delete(ctx, sessionID)
+		h.respondWithError(w, http.StatusNotFound, "Upload session not found")
 		return
 	}
 
-	if isComplete {
-		// Проверяем контрольную сумму, если она была предоставлена
-		if session.SHA256 != "" {
-			if err := file.Sync(); err != nil {
-				if lg != nil {
-					lg.Error(ctx, "Failed to sync file", zap.Error(err))
-				}
-				h.respondWithError(w, http.StatusInternalServerError, "Failed to finalize upload")
-				return
-			}
-
-			hasher := sha256.New()
-			if _, err := file.Seek(0, 0); err != nil {
-				if lg != nil {
-					lg.Error(ctx, "Failed to seek to start", zap.Error(err))
-				}
-				h.respondWithError(w, http.StatusInternalServerError, "Failed to verify upload")
-				return
-			}
-
-			if _, err := io.Copy(hasher, file); err != nil {
-				if lg != nil {
-					lg.Error(ctx, "Failed to calculate checksum", zap.Error(err))
-				}
-				h.respondWithError(w, http.StatusInternalServerError, "Failed to verify upload")
-				return
-			}
-
-			actualSHA256 := fmt.Sprintf("%x", hasher.Sum(nil))
-			if actualSHA256 != session.SHA256 {
-				if lg != nil {
-					lg.Error(ctx, "Checksum mismatch",
-						zap.String("expected", session.SHA256),
-						zap.String("actual", actualSHA256))
-				}
-				os.Remove(tempFilePath)
-				h.respondWithError(w, http.StatusBadRequest, "Checksum verification failed")
-				return
-			}
-		}
-
-		// Читаем содержимое файла
-		if _, err := file.Seek(0, 0); err != nil {
+	// Части могут приходить в любом порядке и повторяться, загрузка завершена, когда принят каждый байт
+	session.AddReceived(int64(start), int64(end))
+	if session.Completed() {
+		// Части собираются потоково в один файл, содержимое целиком в память не читается
+		if err := h.uploadSessions.ComposeChunks(ctx, sessionID, session.Size); err != nil {
 			if lg != nil {
-				lg.Error(ctx, "Failed to seek to start", zap.Error(err))
+				lg.Error(ctx, "Failed to compose upload", zap.Error(err))
 			}
-			h.respondWithError(w, http.StatusInternalServerError, "Failed to prepare file content")
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to finalize upload")
 			return
 		}
-
-		content, err := io.ReadAll(file)
+		file, err := os.Open(h.uploadSessions.PartPath(sessionID))
 		if err != nil {
 			if lg != nil {
-				lg.Error(ctx, "Failed to read file content", zap.Error(err))
+				lg.Error(ctx, "Failed to open upload file", zap.Error(err))
 			}
-			h.respondWithError(w, http.StatusInternalServerError, "Failed to prepare file content")
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to finalize upload")
 			return
 		}
+		defer file.Close()
 
-		// Определяем MIME тип
-		mimeType := getMimeTypeByExtension(session.FilePath)
-
-		// Создаем запрос на создание файла
-		createReq := &models.CreateFileRequest{
-			Name:     filepath.Base(session.FilePath),
-			MimeType: mimeType,
-			Size:     int64(session.Size),
-			Content:  content,
-			IsFolder: false,
-			ParentID: session.ParentID,
+		createdFile, err := h.completeUploadSession(ctx, session, file)
+		if errors.Is(err, errUploadChecksumMismatch) {
+			if lg != nil {
+				lg.Error(ctx, "Checksum mismatch", zap.Error(err))
+			}
+			h.uploadSessions.Delete(ctx, sessionID)
+			h.respondWithError(w, http.StatusBadRequest, "Checksum verification failed")
+			return
 		}
-
-		// Создаем файл в системе через fileService
-		createdFile, err := h.fileService.CreateFile(ctx, createReq, session.UserID)
 		if err != nil {
 			if lg != nil {
 				lg.Error(ctx, "Failed to create file", zap.Error(err))
 			}
 
-			// Проверяем, является ли это ошибкой дублирования имени файла
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-				// Генерируем альтернативное имя
-				alternativeName := generateAlternativeName(createReq.Name)
-
-				errorResponse := map[string]interface{}{
-					"error": "File with this name already exists",
-					"details": map[string]interface{}{
-						"fileName":   createReq.Name,
-						"suggestion": alternativeName,
-						"message":    fmt.Sprintf("A file named '%s' already exists in this location. Try using '%s' instead.", createReq.Name, alternativeName),
-					},
-				}
-
-				if lg != nil {
-					lg.Info(ctx, "Duplicate file name detected",
-						zap.String("fileName", createReq.Name),
-						zap.String("suggestedName", alternativeName))
-				}
-
-				h.respondWithJSON(w, http.StatusConflict, errorResponse)
+			// Имя занято, а политика conflict=fail
+			if h.respondWithFileExists(w, err) {
 				return
 			}
 
@@ -2482,26 +2337,64 @@ func (h *Handler) ResumableUpload(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Удаляем временный файл и сессию
-		os.Remove(tempFilePath)
-		deleteSession(sessionID)
+		// Удаляем сессию вместе с принятыми данными
+		if err := h.uploadSessions.Delete(ctx, sessionID); err != nil && lg != nil {
+			lg.Error(ctx, "Failed to delete upload session", zap.Error(err))
+		}
 
 		h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
 			"message": "Upload completed successfully",
 			"file":    createdFile,
 		})
 	} else {
-		// Загрузка не завершена, отправляем статус успешной обработки части
+		// Загрузка не завершена: сохраняем принятые диапазоны, это же продлевает срок сессии
+		if err := h.uploadSessions.Save(ctx, session); err != nil {
+			if lg != nil {
+				lg.Error(ctx, "Failed to save upload session", zap.Error(err))
+			}
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+			return
+		}
 		h.respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
 			"message": "Chunk uploaded successfully",
 			"range":   rangeHeader,
+			"offset":  session.Offset,
+			"missing": session.Missing(),
 		})
 	}
 }
 
-var (
-	sessions = make(map[string]uploadSession)
-)
+// completeUploadSession сверяет SHA-256 собранного файла с заявленным в сессии
+// и создает из него файл. Временный файл читается потоково
+func (h *Handler) completeUploadSession(ctx context.Context, session *models.ResumableSession, file *os.File) (*models.File, error) {
+	if session.Checksum != "" {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek to start: %w", err)
+		}
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, file); err != nil {
+			return nil, fmt.Errorf("failed to calculate checksum: %w", err)
+		}
+		if actual := hex.EncodeToString(hasher.Sum(nil)); !strings.EqualFold(actual, session.Checksum) {
+			return nil, fmt.Errorf("%w: expected %s, actual %s", errUploadChecksumMismatch, session.Checksum, actual)
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to start: %w", err)
+	}
+
+	createReq := &models.CreateFileRequest{
+		Name:          filepath.Base(session.FilePath),
+		MimeType:      getMimeTypeByExtension(session.FilePath),
+		Size:          session.Size,
+		ContentReader: file,
+		IsFolder:      false,
+		ParentID:      session.ParentID,
+		Conflict:      session.Conflict,
+	}
+	return h.fileService.CreateFile(ctx, createReq, session.UserID)
+}
 
 
 // MIME типы по расширениям
@@ -2645,10 +2538,21 @@ func (h *Handler) UploadFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Политика действует на корневую папку и файлы в ней: при rename и replace папка
+	// создается заново, при new_version содержимое добавляется в существующую
+	conflict, err := parseConflictPolicy(r, models.ConflictPolicy(r.FormValue("conflict")))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Создаем корневую папку
-	rootFolder, err := h.fileService.CreateFolder(ctx, filepath.Base(folderPath), nil, userID)
+	rootFolder, err := h.fileService.CreateFolder(ctx, filepath.Base(folderPath), nil, userID, conflict)
 	if err != nil {
 		lg.Error(ctx, "Failed to create root folder", zap.Error(err))
+		if h.respondWithFileExists(w, err) {
+			return
+		}
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to create folder structure")
 		return
 	}
@@ -2675,40 +2579,33 @@ func (h *Handler) UploadFolder(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		content, err := io.ReadAll(rc)
-		rc.Close()
-		if err != nil {
-			lg.Error(ctx, "Failed to read file content", zap.Error(err), zap.String("file", zipFile.Name))
-			continue
-		}
-
-		// Определяем путь к файлу относительно корневой папки
-		relPath := filepath.Join(folderPath, zipFile.Name)
-		dirPath := filepath.Dir(relPath)
+		// Папки внутри архива создаются относительно корневой папки, которая могла получить другое имя
+		dirPath := filepath.Dir(filepath.Clean(zipFile.Name))
 
 		// Создаем структуру папок
-		var parentID *uuid.UUID
+		parentID := &rootFolder.ID
 		if dirPath != "." && dirPath != "/" {
-			parentID, err = h.ensureFolderPath(ctx, userID, dirPath)
+			parentID, err = h.ensureFolderPath(ctx, userID, &rootFolder.ID, dirPath)
 			if err != nil {
 				lg.Error(ctx, "Failed to create folder path", zap.Error(err), zap.String("path", dirPath))
+				rc.Close()
 				continue
 			}
-		} else {
-			parentID = &rootFolder.ID
 		}
 
 		// Создаем файл
 		createReq := &models.CreateFileRequest{
-			Name:     filepath.Base(zipFile.Name),
-			MimeType: getMimeTypeByExtension(zipFile.Name),
-			Size:     int64(len(content)),
-			Content:  content,
-			IsFolder: false,
-			ParentID: parentID,
+			Name:          filepath.Base(zipFile.Name),
+			MimeType:      getMimeTypeByExtension(zipFile.Name),
+			Size:          int64(zipFile.UncompressedSize64),
+			ContentReader: rc,
+			IsFolder:      false,
+			ParentID:      parentID,
+			Conflict:      conflict,
 		}
 
 		_, err = h.fileService.CreateFile(ctx, createReq, userID)
+		rc.Close()
 		if err != nil {
 			lg.Error(ctx, "Failed to create file", zap.Error(err), zap.String("file", zipFile.Name))
 			continue
@@ -2795,8 +2692,10 @@ func (h *Handler) DownloadFolder(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		// Записываем содержимое в архив
-		if _, err := writer.Write(content); err != nil {
+		// Записываем содержимое в архив потоково
+		_, err = io.Copy(writer, content)
+		content.Close()
+		if err != nil {
 			lg.Error(ctx, "Failed to write file content to ZIP", zap.Error(err))
 			continue
 		}
@@ -0,0 +1,527 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"homecloud-file-service/config"
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/interfaces"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+	"homecloud-file-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testToken проходит AuthMiddleware без обращения к сервису авторизации
+const testToken = "Bearer header.payload.test_signature"
+
+var testUserID = uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+
+// fakeFileService записывает вызовы; не переопределенные методы паникуют через nil-интерфейс
+type fakeFileService struct {
+	interfaces.FileService
+	calls []string
+
+	// file и content отдаются GetFile и DownloadFile, если заданы
+	file    *models.File
+	content []byte
+
+	update      *models.UpdateFileRequest
+	revisionID  int64
+	granteeID   uuid.UUID
+	permission  *models.FilePermission
+	newParentID *uuid.UUID
+	newName     string
+	metadata    map[string]interface{}
+	query       string
+	opts        models.WriteOptions
+	// writeErr возвращают изменяющие операции
+	writeErr error
+}
+
+func (f *fakeFileService) record(call string, userID uuid.UUID) {
+	if userID != testUserID {
+		call += " (wrong user)"
+	}
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeFileService) GetFile(ctx context.Context, fileID, userID uuid.UUID) (*models.File, error) {
+	f.record("GetFile", userID)
+	if f.file != nil {
+		return f.file, nil
+	}
+	return &models.File{ID: fileID, OwnerID: userID}, nil
+}
+
+func (f *fakeFileService) DownloadFile(ctx context.Context, fileID, userID uuid.UUID) (interfaces.BlobReader, string, error) {
+	f.record("DownloadFile", userID)
+	return memBlob{bytes.NewReader(f.content)}, f.file.MimeType, nil
+}
+
+func (f *fakeFileService) UpdateFile(ctx context.Context, fileID uuid.UUID, req *models.UpdateFileRequest, userID uuid.UUID, opts models.WriteOptions) (*models.File, error) {
+	f.record("UpdateFile", userID)
+	f.update = req
+	f.opts = opts
+	if f.writeErr != nil {
+		return nil, f.writeErr
+	}
+	return &models.File{ID: fileID, Name: *req.Name}, nil
+}
+
+func (f *fakeFileService) RestoreFile(ctx context.Context, fileID, userID uuid.UUID) error {
+	f.record("RestoreFile", userID)
+	return nil
+}
+
+func (f *fakeFileService) SearchFiles(ctx context.Context, userID uuid.UUID, query string) ([]models.File, error) {
+	f.record("SearchFiles", userID)
+	f.query = query
+	return []models.File{}, nil
+}
+
+func (f *fakeFileService) ListStarredFiles(ctx context.Context, userID uuid.UUID) ([]models.File, error) {
+	f.record("ListStarredFiles", userID)
+	return []models.File{}, nil
+}
+
+func (f *fakeFileService) ListTrashedFiles(ctx context.Context, userID uuid.UUID) ([]models.File, error) {
+	f.record("ListTrashedFiles", userID)
+	return []models.File{}, nil
+}
+
+func (f *fakeFileService) ListFolderContents(ctx context.Context, folderID *uuid.UUID, userID uuid.UUID) ([]models.File, error) {
+	f.record("ListFolderContents", userID)
+	return []models.File{}, nil
+}
+
+func (f *fakeFileService) GetFileDetails(ctx context.Context, userID uuid.UUID, filePath string) (*models.File, error) {
+	f.record("GetFileDetails", userID)
+	return &models.File{ID: uuid.New(), Name: filePath}, nil
+}
+
+func (f *fakeFileService) ListRevisions(ctx context.Context, fileID, userID uuid.UUID) ([]models.FileRevision, error) {
+	f.record("ListRevisions", userID)
+	return []models.FileRevision{}, nil
+}
+
+func (f *fakeFileService) GetRevision(ctx context.Context, fileID uuid.UUID, revisionID int64, userID uuid.UUID) (*models.FileRevision, error) {
+	f.record("GetRevision", userID)
+	f.revisionID = revisionID
+	return &models.FileRevision{FileID: fileID, RevisionID: revisionID}, nil
+}
+
+func (f *fakeFileService) RestoreRevision(ctx context.Context, fileID uuid.UUID, revisionID int64, userID uuid.UUID, opts models.WriteOptions) error {
+	f.record("RestoreRevision", userID)
+	f.revisionID = revisionID
+	return nil
+}
+
+func (f *fakeFileService) ListPermissions(ctx context.Context, fileID, userID uuid.UUID) ([]models.FilePermission, error) {
+	f.record("ListPermissions", userID)
+	return []models.FilePermission{}, nil
+}
+
+func (f *fakeFileService) GrantPermission(ctx context.Context, fileID uuid.UUID, permission *models.FilePermission, userID uuid.UUID) error {
+	f.record("GrantPermission", userID)
+	f.permission = permission
+	return nil
+}
+
+func (f *fakeFileService) RevokePermission(ctx context.Context, fileID, granteeID, userID uuid.UUID) error {
+	f.record("RevokePermission", userID)
+	f.granteeID = granteeID
+	return nil
+}
+
+func (f *fakeFileService) StarFile(ctx context.Context, fileID, userID uuid.UUID) error {
+	f.record("StarFile", userID)
+	return nil
+}
+
+func (f *fakeFileService) UnstarFile(ctx context.Context, fileID, userID uuid.UUID) error {
+	f.record("UnstarFile", userID)
+	return nil
+}
+
+func (f *fakeFileService) MoveFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, userID uuid.UUID, opts models.WriteOptions) error {
+	f.record("MoveFile", userID)
+	f.newParentID = newParentID
+	f.opts = opts
+	return f.writeErr
+}
+
+func (f *fakeFileService) CopyFile(ctx context.Context, fileID uuid.UUID, newParentID *uuid.UUID, newName string, userID uuid.UUID, opts models.WriteOptions) (*models.File, error) {
+	f.record("CopyFile", userID)
+	f.newParentID = newParentID
+	f.newName = newName
+	f.opts = opts
+	return &models.File{ID: uuid.New(), Name: newName}, nil
+}
+
+func (f *fakeFileService) RenameFile(ctx context.Context, fileID uuid.UUID, newName string, userID uuid.UUID, opts models.WriteOptions) error {
+	f.record("RenameFile", userID)
+	f.newName = newName
+	f.opts = opts
+	return f.writeErr
+}
+
+func (f *fakeFileService) GetFileMetadata(ctx context.Context, fileID, userID uuid.UUID) (map[string]interface{}, error) {
+	f.record("GetFileMetadata", userID)
+	return map[string]interface{}{}, nil
+}
+
+func (f *fakeFileService) UpdateFileMetadata(ctx context.Context, fileID uuid.UUID, metadata map[string]interface{}, userID uuid.UUID, opts models.WriteOptions) error {
+	f.record("UpdateFileMetadata", userID)
+	f.metadata = metadata
+	f.opts = opts
+	return f.writeErr
+}
+
+func (f *fakeFileService) UploadFile(ctx context.Context, fileID uuid.UUID, content io.Reader, userID uuid.UUID, opts models.WriteOptions) (*models.UploadResult, error) {
+	f.record("UploadFile", userID)
+	f.opts = opts
+	if f.writeErr != nil {
+		return nil, f.writeErr
+	}
+	return &models.UploadResult{FileID: fileID, Version: 2}, nil
+}
+
+func (f *fakeFileService) CreateFile(ctx context.Context, req *models.CreateFileRequest, userID uuid.UUID) (*models.File, error) {
+	f.record("CreateFile", userID)
+	if req.ContentReader != nil {
+		content, err := io.ReadAll(req.ContentReader)
+		if err != nil {
+			return nil, err
+		}
+		f.content = content
+	}
+	return &models.File{ID: uuid.New(), Name: req.Name, ParentID: req.ParentID, Size: req.Size}, nil
+}
+
+func (f *fakeFileService) DeleteFileRecursive(ctx context.Context, fileID, userID uuid.UUID, opts models.WriteOptions) error {
+	f.record("DeleteFileRecursive", userID)
+	f.opts = opts
+	return f.writeErr
+}
+
+func (f *fakeFileService) VerifyFileIntegrity(ctx context.Context, fileID, userID uuid.UUID) (bool, error) {
+	f.record("VerifyFileIntegrity", userID)
+	return true, nil
+}
+
+func (f *fakeFileService) CalculateFileChecksums(ctx context.Context, fileID, userID uuid.UUID) error {
+	f.record("CalculateFileChecksums", userID)
+	return nil
+}
+
+// memBlob содержимое файла в памяти
+type memBlob struct {
+	*bytes.Reader
+}
+
+func (memBlob) Close() error { return nil }
+
+type fakeStorageService struct {
+	interfaces.StorageService
+	calls []string
+}
+
+func (s *fakeStorageService) GetAvailableSpace(ctx context.Context) (int64, error) {
+	s.calls = append(s.calls, "GetAvailableSpace")
+	return 1024, nil
+}
+
+func (s *fakeStorageService) CheckStorage(ctx context.Context, owners []uuid.UUID, dryRun bool) (*models.StorageCheckReport, error) {
+	s.calls = append(s.calls, "CheckStorage")
+	return &models.StorageCheckReport{}, nil
+}
+
+func (s *fakeStorageService) OptimizeStorage(ctx context.Context) error {
+	s.calls = append(s.calls, "OptimizeStorage")
+	return nil
+}
+
+func newTestRouter(t *testing.T) (http.Handler, *fakeFileService, *fakeStorageService) {
+	cfg := &config.Config{}
+	cfg.Logger.Config = zap.NewDevelopmentConfig()
+	cfg.Logger.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	lg, err := logger.New(cfg)
+	require.NoError(t, err)
+
+	cfg.Storage.TempPath = t.TempDir()
+	uploadSessions, err := repository.NewUploadSessionStore(cfg)
+	require.NoError(t, err)
+	idempotency, err := repository.NewIdempotencyStore(cfg)
+	require.NoError(t, err)
+
+	files := &fakeFileService{}
+	storage := &fakeStorageService{}
+	return SetupRoutes(NewHandler(files, storage, uploadSessions, idempotency, nil), lg), files, storage
+}
+
+func TestSetupRoutesExposesFileAPI(t *testing.T) {
+	fileID := uuid.New()
+	parentID := uuid.New()
+	granteeID := uuid.New()
+	base := "/api/v1/files/" + fileID.String()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		call   string
+	}{
+		{"GET", base, "", http.StatusOK, "GetFile"},
+		{"PATCH", base, `{"name":"renamed.txt"}`, http.StatusOK, "UpdateFile"},
+		{"PUT", base, `{"name":"renamed.txt"}`, http.StatusOK, "UpdateFile"},
+		{"POST", base + "/restore", "", http.StatusOK, "RestoreFile"},
+		{"GET", "/api/v1/files/search?q=report", "", http.StatusOK, "SearchFiles"},
+		{"GET", "/api/v1/files/starred", "", http.StatusOK, "ListStarredFiles"},
+		{"GET", "/api/v1/files/trashed", "", http.StatusOK, "ListTrashedFiles"},
+		{"GET", "/api/v1/files/details?path=docs/report.pdf", "", http.StatusOK, "GetFileDetails"},
+		{"GET", "/api/v1/folders/browse?folder_id=" + parentID.String(), "", http.StatusOK, "ListFolderContents"},
+		{"GET", base + "/revisions", "", http.StatusOK, "ListRevisions"},
+		{"GET", base + "/revisions/3", "", http.StatusOK, "GetRevision"},
+		{"POST", base + "/revisions/3/restore", "", http.StatusOK, "RestoreRevision"},
+		{"GET", base + "/permissions", "", http.StatusOK, "ListPermissions"},
+		{"POST", base + "/permissions", `{"grantee_id":"` + granteeID.String() + `","grantee_type":"user","role":"reader"}`, http.StatusCreated, "GrantPermission"},
+		{"DELETE", base + "/permissions/" + granteeID.String(), "", http.StatusOK, "RevokePermission"},
+		{"POST", base + "/star", "", http.StatusOK, "StarFile"},
+		{"DELETE", base + "/star", "", http.StatusOK, "UnstarFile"},
+		{"POST", base + "/unstar", "", http.StatusOK, "UnstarFile"},
+		{"POST", base + "/move", `{"new_parent_id":"` + parentID.String() + `"}`, http.StatusOK, "MoveFile"},
+		{"POST", base + "/copy", `{"new_parent_id":"` + parentID.String() + `","new_name":"copy.txt"}`, http.StatusOK, "CopyFile"},
+		{"POST", base + "/rename", `{"new_name":"renamed.txt"}`, http.StatusOK, "RenameFile"},
+		{"GET", base + "/metadata", "", http.StatusOK, "GetFileMetadata"},
+		{"PUT", base + "/metadata", `{"color":"red"}`, http.StatusOK, "UpdateFileMetadata"},
+		{"POST", base + "/verify", "", http.StatusOK, "VerifyFileIntegrity"},
+		{"POST", base + "/checksums", "", http.StatusOK, "CalculateFileChecksums"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			router, files, _ := newTestRouter(t)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", testToken)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.Equal(t, []string{tt.call}, files.calls)
+
+			switch tt.call {
+			case "UpdateFile":
+				require.NotNil(t, files.update)
+				assert.Equal(t, "renamed.txt", *files.update.Name)
+			case "SearchFiles":
+				assert.Equal(t, "report", files.query)
+			case "GetRevision", "RestoreRevision":
+				assert.Equal(t, int64(3), files.revisionID)
+			case "GrantPermission":
+				require.NotNil(t, files.permission)
+				assert.Equal(t, granteeID, *files.permission.GranteeID)
+				assert.Equal(t, "reader", files.permission.Role)
+			case "RevokePermission":
+				assert.Equal(t, granteeID, files.granteeID)
+			case "MoveFile":
+				require.NotNil(t, files.newParentID)
+				assert.Equal(t, parentID, *files.newParentID)
+			case "CopyFile":
+				assert.Equal(t, "copy.txt", files.newName)
+			case "RenameFile":
+				assert.Equal(t, "renamed.txt", files.newName)
+			case "UpdateFileMetadata":
+				assert.Equal(t, "red", files.metadata["color"])
+			}
+		})
+	}
+
+	t.Run("storage", func(t *testing.T) {
+		router, _, storage := newTestRouter(t)
+		for _, route := range []struct{ method, path string }{
+			{"GET", "/api/v1/storage/info"},
+		} {
+			req := httptest.NewRequest(route.method, route.path, nil)
+			req.Header.Set("Authorization", testToken)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code, route.path)
+		}
+
+		// Обслуживание всего хранилища пользователю недоступно
+		for _, path := range []string{"/api/v1/storage/cleanup", "/api/v1/storage/optimize"} {
+			req := httptest.NewRequest("POST", path, nil)
+			req.Header.Set("Authorization", testToken)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotFound, rec.Code, path)
+		}
+		assert.Equal(t, []string{"GetAvailableSpace"}, storage.calls)
+	})
+
+	t.Run("metrics only on debug listener", func(t *testing.T) {
+		router, _, _ := newTestRouter(t)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = httptest.NewRecorder()
+		DebugRoutes().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("requires authorization", func(t *testing.T) {
+		router, files, _ := newTestRouter(t)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("POST", base+"/star", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Empty(t, files.calls)
+	})
+}
+
+func TestIfMatchPreconditions(t *testing.T) {
+	fileID := uuid.New()
+	base := "/api/v1/files/" + fileID.String()
+
+	send := func(router http.Handler, method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", testToken)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	router, files, _ := newTestRouter(t)
+	rec := send(router, "POST", base+"/upload", "content", `"3"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.WriteOptions{ExpectedVersion: 3}, files.opts)
+
+	rec = send(router, "POST", base+"/rename", `{"new_name":"a.txt"}`, `"9f86d081884c7d659a2feaa0c55ad015"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.WriteOptions{ExpectedSHA256: "9f86d081884c7d659a2feaa0c55ad015"}, files.opts)
+
+	req := httptest.NewRequest("POST", base+"/upload?conflict_copy=true", strings.NewReader("content"))
+	req.Header.Set("Authorization", testToken)
+	req.Header.Set("If-Match", `"3"`)
+	req.Header.Set("X-Device-Name", "Laptop")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.WriteOptions{ExpectedVersion: 3, ConflictCopy: true, Device: "Laptop"}, files.opts)
+
+	rec = send(router, "DELETE", base, "", "*")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.WriteOptions{}, files.opts)
+
+	// Файл изменился - 412
+	files.writeErr = fmt.Errorf("%w: expected version 3, current version 4", errdefs.ErrPreconditionFailed)
+	for _, tt := range []struct{ method, path, body string }{
+		{"PATCH", base, `{"name":"a.txt"}`},
+		{"POST", base + "/upload", "content"},
+		{"POST", base + "/move", `{"new_parent_id":null}`},
+		{"POST", base + "/rename", `{"new_name":"a.txt"}`},
+		{"PUT", base + "/metadata", `{"color":"red"}`},
+		{"DELETE", base, ""},
+	} {
+		rec = send(router, tt.method, tt.path, tt.body, `"3"`)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code, tt.method+" "+tt.path)
+	}
+
+	// Некорректный заголовок не доходит до сервиса
+	files.calls = nil
+	rec = send(router, "POST", base+"/rename", `{"new_name":"a.txt"}`, "3")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, files.calls)
+}
+
+func TestIdempotencyKeyReplaysCreateResponse(t *testing.T) {
+	send := func(router http.Handler, path, key, name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"name":"`+name+`","mime_type":"text/plain"}`))
+		req.Header.Set("Authorization", testToken)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	router, files, _ := newTestRouter(t)
+	first := send(router, "/api/v1/files", "retry-1", "a.txt")
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	// Повтор получает тот же ответ, файл не создается второй раз
+	second := send(router, "/api/v1/files", "retry-1", "a.txt")
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, []string{"CreateFile"}, files.calls)
+
+	// Тот же ключ для другого запроса - ошибка клиента
+	rec := send(router, "/api/v1/folders", "retry-1", "a.txt")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = send(router, "/api/v1/files", "retry-1", "b.txt")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// Новый ключ - новый запрос
+	rec = send(router, "/api/v1/files", "retry-2", "a.txt")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, []string{"CreateFile", "CreateFile"}, files.calls)
+}
+
+func TestConflictPolicyIsPassedAndFileExistsIsReported(t *testing.T) {
+	fileID := uuid.New()
+	base := "/api/v1/files/" + fileID.String()
+
+	send := func(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", testToken)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	router, files, _ := newTestRouter(t)
+	rec := send(router, "POST", base+"/copy?conflict=rename", `{"new_name":"a.txt"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.ConflictRename, files.opts.Conflict)
+
+	rec = send(router, "POST", base+"/move", `{"new_parent_id":null,"conflict":"new_version"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.ConflictNewVersion, files.opts.Conflict)
+
+	// Неизвестная политика не доходит до сервиса
+	files.calls = nil
+	rec = send(router, "POST", base+"/move?conflict=overwrite", `{"new_parent_id":null}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, files.calls)
+
+	// Занятое имя - 409 с подсказкой
+	files.writeErr = &errdefs.FileExistsError{Name: "a.txt", ExistingID: uuid.New(), Suggestion: "a (2).txt"}
+	rec = send(router, "POST", base+"/move", `{"new_parent_id":null}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var body struct {
+		Details struct {
+			Suggestion string `json:"suggestion"`
+		} `json:"details"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "a (2).txt", body.Details.Suggestion)
+}
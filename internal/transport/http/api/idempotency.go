@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxIdempotencyKeyLength ограничение длины Idempotency-Key
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize ответы больше не сохраняются, повтор такого запроса выполнится заново
+	maxIdempotentBodySize = 1 << 20
+)
+
+// idempotencyInFlight ключи запросов, которые выполняются прямо сейчас. Повтор, пришедший
+// до завершения исходного запроса, получает 409 вместо второго выполнения
+var idempotencyInFlight = struct {
+	sync.Mutex
+	keys map[string]struct{}
+}{keys: make(map[string]struct{})}
+
+func acquireIdempotencyKey(key string) (func(), bool) {
+	idempotencyInFlight.Lock()
+	defer idempotencyInFlight.Unlock()
+	if _, busy := idempotencyInFlight.keys[key]; busy {
+		return nil, false
+	}
+	idempotencyInFlight.keys[key] = struct{}{}
+	return func() {
+		idempotencyInFlight.Lock()
+		delete(idempotencyInFlight.keys, key)
+		idempotencyInFlight.Unlock()
+	}, true
+}
+
+// idempotent выполняет запрос с Idempotency-Key один раз: ответ сохраняется, и повтор с тем же
+// ключом получает его копию с заголовком Idempotent-Replayed. Ответы 5xx не сохраняются,
+// чтобы после сбоя повтор мог выполниться. Без заголовка запрос выполняется как обычно
+func (h *Handler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || h.idempotency == nil {
+			next(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		lg := logger.GetLoggerFromCtxSafe(ctx)
+
+		if len(key) > maxIdempotencyKeyLength {
+			h.respondWithError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+		userID, err := h.getUserIDFromRequest(r)
+		if err != nil {
+			h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		// Ключи разных пользователей не пересекаются
+		storeKey := userID.String() + ":" + key
+
+		release, ok := acquireIdempotencyKey(storeKey)
+		if !ok {
+			h.respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			return
+		}
+		defer release()
+
+		saved, err := h.idempotency.Get(ctx, storeKey)
+		if err != nil {
+			if lg != nil {
+				lg.Error(ctx, "Failed to get idempotent response", zap.Error(err))
+			}
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+			return
+		}
+		if saved != nil {
+			bodySHA256, err := (&bodyHasher{ReadCloser: r.Body, hash: sha256.New()}).sum()
+			if err != nil {
+				h.respondWithError(w, http.StatusBadRequest, "Failed to read request body")
+				return
+			}
+			if saved.Fingerprint != idempotencyFingerprint(r, bodySHA256) {
+				h.respondWithError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				return
+			}
+			if lg != nil {
+				lg.Info(ctx, "Replaying idempotent response", zap.String("key", key), zap.Int("status", saved.StatusCode))
+			}
+			for name, values := range saved.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(saved.StatusCode)
+			w.Write(saved.Body)
+			return
+		}
+
+		// Тело хешируется по мере чтения обработчиком, без второго прохода и буфера в памяти
+		body := &bodyHasher{ReadCloser: r.Body, hash: sha256.New()}
+		r.Body = body
+		rec := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		if rec.status >= http.StatusInternalServerError || rec.overflow {
+			return
+		}
+		bodySHA256, err := body.sum()
+		if err != nil {
+			// Без хеша тела повтор нельзя отличить от другого запроса - ответ не сохраняем
+			if lg != nil {
+				lg.Error(ctx, "Failed to hash idempotent request body", zap.Error(err))
+			}
+			return
+		}
+		response := &models.IdempotentResponse{
+			Key:         storeKey,
+			Fingerprint: idempotencyFingerprint(r, bodySHA256),
+			StatusCode:  rec.status,
+			Header:      replayableHeader(rec.header),
+			Body:        rec.body.Bytes(),
+		}
+		if err := h.idempotency.Save(ctx, response); err != nil && lg != nil {
+			// Ответ клиенту уже отправлен, повтор просто выполнится заново
+			lg.Error(ctx, "Failed to save idempotent response", zap.Error(err))
+		}
+	}
+}
+
+// idempotencyFingerprint отличает повтор запроса от другого запроса с тем же ключом:
+// метод, URL, тип и длина тела и SHA-256 самого тела
+func idempotencyFingerprint(r *http.Request, bodySHA256 string) string {
+	return strings.Join([]string{
+		r.Method,
+		r.URL.RequestURI(),
+		r.Header.Get("Content-Type"),
+		strconv.FormatInt(r.ContentLength, 10),
+		bodySHA256,
+	}, " ")
+}
+
+// bodyHasher считает SHA-256 тела запроса, пока его читает обработчик
+type bodyHasher struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func (b *bodyHasher) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	return n, err
+}
+
+// sum дочитывает то, что обработчик не прочитал, и возвращает SHA-256 всего тела
+func (b *bodyHasher) sum() (string, error) {
+	if _, err := io.Copy(b.hash, b.ReadCloser); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b.hash.Sum(nil)), nil
+}
+
+// responseCapture передает ответ клиенту и попутно запоминает его для повторов
+type responseCapture struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+	overflow    bool
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		c.status = status
+		c.header = c.ResponseWriter.Header().Clone()
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.overflow {
+		if c.body.Len()+len(p) > maxIdempotentBodySize {
+			c.overflow = true
+			c.body.Reset()
+		} else {
+			c.body.Write(p)
+		}
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// replayableHeader заголовки ответа, которые имеет смысл повторить. CORS-заголовки
+// выставляет middleware при каждом запросе, длину и дату - сам сервер
+func replayableHeader(header http.Header) map[string][]string {
+	replay := make(map[string][]string, len(header))
+	for name, values := range header {
+		if strings.HasPrefix(name, "Access-Control-") || name == "Content-Length" || name == "Date" {
+			continue
+		}
+		replay[name] = values
+	}
+	return replay
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/models"
+)
+
+// parseIfMatch переводит If-Match в условия записи. Номер версии ("3") - ожидаемая версия
+// файла из поля version, ETag из скачивания ("<sha256>") - ожидаемое содержимое.
+// Пустой заголовок и "*" условий не задают
+func parseIfMatch(r *http.Request) (models.WriteOptions, error) {
+	var opts models.WriteOptions
+
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return opts, nil
+	}
+	if strings.Contains(header, ",") {
+		return opts, fmt.Errorf("multiple entity tags are not supported")
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return opts, fmt.Errorf("entity tag must be quoted")
+	}
+	tag = tag[1 : len(tag)-1]
+
+	if version, err := strconv.ParseInt(tag, 10, 64); err == nil && version > 0 {
+		opts.ExpectedVersion = version
+		return opts, nil
+	}
+	if strings.HasPrefix(header, "W/") {
+		// Слабый ETag из скачивания не привязан к содержимому
+		return opts, fmt.Errorf("weak entity tag cannot be used as a precondition")
+	}
+	opts.ExpectedSHA256 = tag
+	return opts, nil
+}
+
+// respondWithWriteError отвечает на ошибку изменяющей операции: 412, если файл изменился
+// с версии, указанной в If-Match, 409, если имя в целевой папке занято
+func (h *Handler) respondWithWriteError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errdefs.Is(err, errdefs.ErrPreconditionFailed):
+		h.respondWithError(w, http.StatusPreconditionFailed, "File has been modified")
+	case h.respondWithFileExists(w, err):
+	case errdefs.Is(err, errdefs.ErrInvalidInput):
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.respondWithError(w, http.StatusInternalServerError, message)
+	}
+}
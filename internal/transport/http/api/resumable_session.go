@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"regexp"
@@ -8,20 +9,8 @@ import (
 	"strconv"
 )
 
-// session - структура для хранения данных сессии загрузки
-type uploadSession struct {
-	FilePath  string
-	Size      uint64
-	SHA256    string
-	UserID    uuid.UUID
-	ParentID  *uuid.UUID
-}
-
-// Мьютекс для защиты sessionStore
-var sessionMutex = sync.RWMutex{}
-
-// sessionStore - хранилище сессий загрузки
-var sessionStore = make(map[string]uploadSession)
+// errUploadChecksumMismatch собранный файл не совпал с контрольной суммой, заявленной при создании сессии
+var errUploadChecksumMismatch = errors.New("upload checksum mismatch")
 
 // регулярное выражение для парсинга start и end из Content-Range
 var rangeRegexRes = regexp.MustCompile(`bytes (\d+)-(\d+)/(\d+|\*)`)
@@ -31,48 +20,69 @@ func generateSessionID() string {
 	return uuid.New().String()
 }
 
-// saveSession - сохраняет информацию о сессии загрузки
-func saveSession(id string, session uploadSession) {
-	sessionMutex.Lock()
-	defer sessionMutex.Unlock()
-	sessionStore[id] = session
-}
+// sessionLocks блокировки отдельных сессий: части одной загрузки пишутся по очереди,
+// иначе два запроса с одним смещением оба приняли бы данные
+var sessionLocks = struct {
+	sync.Mutex
+	locks map[string]*sessionLock
+}{locks: make(map[string]*sessionLock)}
 
-// getSession - получает сессию загрузки по sessionID
-func getSession(sessionID string) (uploadSession, error) {
-	sessionMutex.RLock()
-	defer sessionMutex.RUnlock()
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int
+}
 
-	session, found := sessionStore[sessionID]
-	if !found {
-		return uploadSession{}, fmt.Errorf("session not found: %s", sessionID)
+// lockSession захватывает блокировку сессии и возвращает функцию ее освобождения
+func lockSession(sessionID string) func() {
+	sessionLocks.Lock()
+	sl, ok := sessionLocks.locks[sessionID]
+	if !ok {
+		sl = &sessionLock{}
+		sessionLocks.locks[sessionID] = sl
 	}
-	return session, nil
-}
+	sl.refs++
+	sessionLocks.Unlock()
 
-// deleteSession - удаляет сессию загрузки
-func deleteSession(sessionID string) {
-	sessionMutex.Lock()
-	defer sessionMutex.Unlock()
-	delete(sessionStore, sessionID)
+	sl.mu.Lock()
+	return func() {
+		sl.mu.Unlock()
+		sessionLocks.Lock()
+		sl.refs--
+		if sl.refs == 0 {
+			delete(sessionLocks.locks, sessionID)
+		}
+		sessionLocks.Unlock()
+	}
 }
 
-// parseContentRange - извлекает начальный и конечный байты из заголовка Content-Range
-func parseContentRange(rangeHeader string) (start, end uint64, err error) {
+// parseContentRange - извлекает начальный и конечный байты и полный размер из заголовка Content-Range.
+// total равен nil, если размер не указан ("*")
+func parseContentRange(rangeHeader string) (start, end uint64, total *uint64, err error) {
 	matches := rangeRegexRes.FindStringSubmatch(rangeHeader)
-	if len(matches) < 3 {
-		return 0, 0, fmt.Errorf("invalid Content-Range format")
+	if len(matches) < 4 {
+		return 0, 0, nil, fmt.Errorf("invalid Content-Range format")
 	}
 
 	start, err = strconv.ParseUint(matches[1], 10, 64)
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid start value: %v", err)
+		return 0, 0, nil, fmt.Errorf("invalid start value: %v", err)
 	}
 
 	end, err = strconv.ParseUint(matches[2], 10, 64)
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid end value: %v", err)
+		return 0, 0, nil, fmt.Errorf("invalid end value: %v", err)
+	}
+	if end < start {
+		return 0, 0, nil, fmt.Errorf("end %d is before start %d", end, start)
 	}
 
-	return start, end, nil
-} 
\ No newline at end of file
+	if matches[3] != "*" {
+		size, err := strconv.ParseUint(matches[3], 10, 64)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid total value: %v", err)
+		}
+		total = &size
+	}
+
+	return start, end, total, nil
+}
@@ -0,0 +1,458 @@
+package api
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"homecloud-file-service/internal/errdefs"
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// Протокол возобновляемой загрузки tus 1.0 (https://tus.io/protocols/resumable-upload)
+// поверх тех же сессий загрузки, что и ResumableUploadInit / ResumableUpload
+const (
+	tusVersion            = "1.0.0"
+	tusExtensions         = "creation,termination,checksum,expiration"
+	tusChecksumAlgorithms = "sha1,sha256,md5"
+	tusOffsetContentType  = "application/offset+octet-stream"
+	// tusBasePath путь ресурса создания загрузок, Location новой загрузки строится от него
+	tusBasePath = "/api/v1/tus"
+	// statusChecksumMismatch код ответа tus на несовпадение Upload-Checksum
+	statusChecksumMismatch = 460
+)
+
+// withTusResumable добавляет Tus-Resumable в каждый ответ и отклоняет запросы
+// неподдерживаемой версии протокола. OPTIONS по протоколу версию не передает
+func withTusResumable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isTusDiscovery запрос возможностей сервера tus. Это OPTIONS без CORS-заголовков,
+// CORS-middleware такие запросы отклоняет, поэтому они идут в маршрутизатор напрямую
+func isTusDiscovery(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Access-Control-Request-Method") == "" &&
+		strings.HasPrefix(r.URL.Path, tusBasePath)
+}
+
+// TusOptions сообщает версию и расширения протокола
+func (h *Handler) TusOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TusCreateUpload создает загрузку (расширение creation). Имя и папка берутся из Upload-Metadata:
+// path - относительный путь с именем файла, либо filename; sha256 - необязательная контрольная
+// сумма всего файла в hex
+func (h *Handler) TusCreateUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lg := logger.GetLoggerFromCtxSafe(ctx)
+
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		h.respondWithError(w, http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid Upload-Length header")
+		return
+	}
+
+	rawMetadata := r.Header.Get("Upload-Metadata")
+	metadata, err := parseTusMetadata(rawMetadata)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid Upload-Metadata header")
+		return
+	}
+
+	filePath := metadata["path"]
+	if filePath == "" {
+		filePath = metadata["filename"]
+	}
+	if filePath == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Upload-Metadata must contain filename or path")
+		return
+	}
+	filePath = filepath.Clean(filePath)
+	if filepath.IsAbs(filePath) || strings.Contains(filePath, "..") {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid file path")
+		return
+	}
+
+	// Политика при занятом имени: ключ conflict в Upload-Metadata или параметр запроса
+	conflict, err := parseConflictPolicy(r, models.ConflictPolicy(metadata["conflict"]))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dirPath := filepath.Dir(filePath)
+	session := &models.ResumableSession{
+		ID:       generateSessionID(),
+		FilePath: filePath,
+		Size:     size,
+		Checksum: metadata["sha256"],
+		UserID:   userID,
+		Metadata: rawMetadata,
+		Conflict: conflict,
+	}
+	if dirPath != "." && dirPath != "/" {
+		session.ParentID, err = h.ensureFolderPath(ctx, userID, nil, dirPath)
+		if err != nil {
+			if lg != nil {
+				lg.Error(ctx, "Failed to ensure folder path", zap.Error(err))
+			}
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to prepare upload location")
+			return
+		}
+	}
+
+	if err := h.uploadSessions.Save(ctx, session); err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to save upload session", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+	tempFilePath := h.uploadSessions.PartPath(session.ID)
+	file, err := os.Create(tempFilePath)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to create upload file", zap.Error(err))
+		}
+		h.uploadSessions.Delete(ctx, session.ID)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to prepare upload location")
+		return
+	}
+
+	if lg != nil {
+		lg.Info(ctx, "tus upload created", zap.String("sessionID", session.ID),
+			zap.String("path", filePath), zap.Int64("size", size))
+	}
+
+	w.Header().Set("Location", tusBasePath+"/"+session.ID)
+	w.Header().Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	// Пустой файл завершен сразу, частей для него не будет
+	if size == 0 {
+		unlock := lockSession(session.ID)
+		defer unlock()
+		if !h.finishTusUpload(w, r, session, tempFilePath) {
+			return
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// TusUploadStatus отдает смещение загрузки, с которого клиент продолжает передачу
+func (h *Handler) TusUploadStatus(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionID"]
+	session, ok := h.userUploadSession(w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+	w.Header().Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	if session.Metadata != "" {
+		w.Header().Set("Upload-Metadata", session.Metadata)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// TusPatchUpload принимает часть файла с текущего смещения. С Upload-Checksum часть
+// сохраняется, только если контрольная сумма совпала; без нее при обрыве соединения
+// остается все, что успело прийти. Последняя часть завершает загрузку созданием файла
+func (h *Handler) TusPatchUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lg := logger.GetLoggerFromCtxSafe(ctx)
+	sessionID := mux.Vars(r)["sessionID"]
+
+	if r.Header.Get("Content-Type") != tusOffsetContentType {
+		h.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+tusOffsetContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid Upload-Offset header")
+		return
+	}
+	hasher, expectedSum, err := parseUploadChecksum(r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid Upload-Checksum header: "+err.Error())
+		return
+	}
+
+	unlock := lockSession(sessionID)
+	defer unlock()
+
+	session, ok := h.userUploadSession(w, r, sessionID)
+	if !ok {
+		return
+	}
+	if offset != session.Offset {
+		h.respondWithError(w, http.StatusConflict, "Upload-Offset does not match current offset")
+		return
+	}
+
+	tempFilePath := h.uploadSessions.PartPath(sessionID)
+	file, err := os.OpenFile(tempFilePath, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to open temp file", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		return
+	}
+	defer file.Close()
+
+	// Все, что записано дальше принятого смещения, при ошибке отбрасывается
+	discard := func() {
+		if err := file.Truncate(offset); err != nil && lg != nil {
+			lg.Error(ctx, "Failed to discard rejected chunk", zap.Error(err))
+		}
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to seek in file", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		return
+	}
+
+	var dst io.Writer = file
+	if hasher != nil {
+		dst = io.MultiWriter(file, hasher)
+	}
+	remaining := session.Size - offset
+	written, copyErr := io.Copy(dst, io.LimitReader(r.Body, remaining))
+	if copyErr == nil && written == remaining {
+		// Данные за объявленной длиной загрузки не принимаются
+		if n, _ := r.Body.Read(make([]byte, 1)); n > 0 {
+			discard()
+			h.respondWithError(w, http.StatusRequestEntityTooLarge, "Chunk exceeds Upload-Length")
+			return
+		}
+	}
+	if copyErr != nil && (hasher != nil || written == 0) {
+		discard()
+		if lg != nil {
+			lg.Error(ctx, "Failed to write chunk", zap.Error(copyErr))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		return
+	}
+	if hasher != nil && string(hasher.Sum(nil)) != string(expectedSum) {
+		discard()
+		h.respondWithError(w, statusChecksumMismatch, "Checksum Mismatch")
+		return
+	}
+
+	if err := file.Sync(); err != nil {
+		discard()
+		if lg != nil {
+			lg.Error(ctx, "Failed to sync file", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		return
+	}
+
+	// Сохранение сессии продлевает ее срок
+	session.AddReceived(offset, offset+written-1)
+	if err := h.uploadSessions.Save(ctx, session); err != nil {
+		discard()
+		if lg != nil {
+			lg.Error(ctx, "Failed to save upload session", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		return
+	}
+
+	if copyErr != nil {
+		// Соединение оборвалось: принятая часть сохранена, клиент продолжит с нового смещения
+		if lg != nil {
+			lg.Error(ctx, "tus chunk interrupted", zap.Error(copyErr),
+				zap.String("sessionID", sessionID), zap.Int64("offset", session.Offset))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to process upload")
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	if session.Completed() {
+		if err := file.Close(); err != nil {
+			if lg != nil {
+				lg.Error(ctx, "Failed to close temp file", zap.Error(err))
+			}
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to finalize upload")
+			return
+		}
+		if !h.finishTusUpload(w, r, session, tempFilePath) {
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TusTerminateUpload прерывает загрузку и удаляет принятые данные (расширение termination)
+func (h *Handler) TusTerminateUpload(w http.ResponseWriter, r *http.Request) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+	sessionID := mux.Vars(r)["sessionID"]
+
+	unlock := lockSession(sessionID)
+	defer unlock()
+
+	if _, ok := h.userUploadSession(w, r, sessionID); !ok {
+		return
+	}
+	if err := h.uploadSessions.Delete(r.Context(), sessionID); err != nil {
+		if lg != nil {
+			lg.Error(r.Context(), "Failed to delete upload session", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to terminate upload")
+		return
+	}
+
+	if lg != nil {
+		lg.Info(r.Context(), "tus upload terminated", zap.String("sessionID", sessionID))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finishTusUpload создает файл из полностью принятой загрузки и удаляет сессию.
+// Возвращает false, если ответ с ошибкой уже отправлен
+func (h *Handler) finishTusUpload(w http.ResponseWriter, r *http.Request, session *models.ResumableSession, tempFilePath string) bool {
+	ctx := r.Context()
+	lg := logger.GetLoggerFromCtxSafe(ctx)
+
+	file, err := os.Open(tempFilePath)
+	if err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to open temp file", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to finalize upload")
+		return false
+	}
+	created, err := h.completeUploadSession(ctx, session, file)
+	file.Close()
+
+	switch {
+	case errors.Is(err, errUploadChecksumMismatch):
+		// Собранный файл испорчен, продолжать эту загрузку бессмысленно
+		h.uploadSessions.Delete(ctx, session.ID)
+		if lg != nil {
+			lg.Error(ctx, "Checksum mismatch", zap.Error(err))
+		}
+		h.respondWithError(w, statusChecksumMismatch, "Checksum verification failed")
+		return false
+	case errors.Is(err, errdefs.ErrFileExists):
+		h.uploadSessions.Delete(ctx, session.ID)
+		h.respondWithFileExists(w, err)
+		return false
+	case err != nil:
+		// Сессия остается: PATCH без данных с конечным смещением повторит создание файла
+		if lg != nil {
+			lg.Error(ctx, "Failed to create file", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create file")
+		return false
+	}
+
+	h.uploadSessions.Delete(ctx, session.ID)
+	w.Header().Set("X-File-ID", created.ID.String())
+	if lg != nil {
+		lg.Info(ctx, "tus upload completed", zap.String("sessionID", session.ID), zap.String("fileID", created.ID.String()))
+	}
+	return true
+}
+
+// parseTusMetadata разбирает Upload-Metadata: пары "ключ значение-в-base64" через запятую,
+// значение может отсутствовать
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata value for %q: %w", key, err)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// parseUploadChecksum разбирает Upload-Checksum вида "sha1 <base64>". Без заголовка
+// возвращает nil-хешер
+func parseUploadChecksum(header string) (hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, nil, errors.New("malformed checksum")
+	}
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errors.New("malformed checksum")
+	}
+
+	var hasher hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		hasher = sha1.New()
+	case "sha256":
+		hasher = sha256.New()
+	case "md5":
+		hasher = md5.New()
+	default:
+		return nil, nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if len(sum) != hasher.Size() {
+		return nil, nil, errors.New("malformed checksum")
+	}
+	return hasher, sum, nil
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTusUploadLifecycle(t *testing.T) {
+	router, files, _ := newTestRouter(t)
+
+	do := func(method, path string, headers map[string]string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", testToken)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	patch := func(location, offset, chunk, checksum string) *httptest.ResponseRecorder {
+		headers := map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset}
+		if checksum != "" {
+			headers["Upload-Checksum"] = checksum
+		}
+		return do("PATCH", location, headers, chunk)
+	}
+	sha1Of := func(s string) string {
+		sum := sha1.Sum([]byte(s))
+		return "sha1 " + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	create := func() string {
+		rec := do("POST", "/api/v1/tus", map[string]string{
+			"Upload-Length":   "11",
+			"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("hello.txt")) + ",is_confidential",
+		}, "")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.NotEmpty(t, rec.Header().Get("Upload-Expires"))
+		return rec.Header().Get("Location")
+	}
+
+	t.Run("discovery", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/v1/tus", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "1.0.0", rec.Header().Get("Tus-Version"))
+		assert.Equal(t, "creation,termination,checksum,expiration", rec.Header().Get("Tus-Extension"))
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		rec := do("POST", "/api/v1/tus", map[string]string{"Tus-Resumable": "0.2.2", "Upload-Length": "1"}, "")
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assert.Equal(t, "1.0.0", rec.Header().Get("Tus-Version"))
+	})
+
+	t.Run("upload in chunks", func(t *testing.T) {
+		location := create()
+		require.True(t, strings.HasPrefix(location, "/api/v1/tus/"), location)
+
+		rec := do("HEAD", location, nil, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("Upload-Offset"))
+		assert.Equal(t, "11", rec.Header().Get("Upload-Length"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+		assert.Equal(t, http.StatusConflict, patch(location, "3", "hello ", "").Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, do("PATCH", location, map[string]string{"Upload-Offset": "0"}, "hello ").Code)
+
+		// Часть с неверной контрольной суммой отбрасывается
+		assert.Equal(t, statusChecksumMismatch, patch(location, "0", "hello ", sha1Of("other")).Code)
+		assert.Equal(t, "0", do("HEAD", location, nil, "").Header().Get("Upload-Offset"))
+
+		rec = patch(location, "0", "hello ", sha1Of("hello "))
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		assert.Equal(t, "6", rec.Header().Get("Upload-Offset"))
+		assert.Equal(t, "1.0.0", rec.Header().Get("Tus-Resumable"))
+
+		rec = patch(location, "6", "world", "")
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		assert.Equal(t, "11", rec.Header().Get("Upload-Offset"))
+		assert.NotEmpty(t, rec.Header().Get("X-File-ID"))
+		assert.Equal(t, "hello world", string(files.content))
+		assert.Equal(t, []string{"CreateFile"}, files.calls)
+
+		assert.Equal(t, http.StatusNotFound, do("HEAD", location, nil, "").Code)
+	})
+
+	t.Run("termination", func(t *testing.T) {
+		location := create()
+		assert.Equal(t, http.StatusNoContent, do("DELETE", location, nil, "").Code)
+		assert.Equal(t, http.StatusNotFound, do("HEAD", location, nil, "").Code)
+	})
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"homecloud-file-service/internal/logger"
+	"homecloud-file-service/internal/models"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// uploadSessionStatus состояние сессии возобновляемой загрузки для клиента
+type uploadSessionStatus struct {
+	SessionID string `json:"session_id"`
+	FilePath  string `json:"file_path"`
+	Size      int64  `json:"size"`
+	// Offset сколько байт принято подряд с начала файла
+	Offset    int64              `json:"offset"`
+	Received  []models.ByteRange `json:"received"`
+	Missing   []models.ByteRange `json:"missing"`
+	CreatedAt time.Time          `json:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
+
+func newUploadSessionStatus(session *models.ResumableSession) uploadSessionStatus {
+	received := session.Received
+	if received == nil {
+		received = []models.ByteRange{}
+	}
+	return uploadSessionStatus{
+		SessionID: session.ID,
+		FilePath:  session.FilePath,
+		Size:      session.Size,
+		Offset:    session.Offset,
+		Received:  received,
+		Missing:   session.Missing(),
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+	}
+}
+
+// GetUploadSessionStatus сообщает, что уже принято: смещение, принятые и недостающие диапазоны.
+// HEAD отдает только заголовки Upload-Offset и Upload-Length
+func (h *Handler) GetUploadSessionStatus(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.userUploadSession(w, r, mux.Vars(r)["sessionID"])
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	h.respondWithJSON(w, http.StatusOK, newUploadSessionStatus(session))
+}
+
+// ListUploadSessions возвращает незавершенные загрузки текущего пользователя
+func (h *Handler) ListUploadSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lg := logger.GetLoggerFromCtxSafe(ctx)
+
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessions, err := h.uploadSessions.ListByUser(ctx, userID)
+	if err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to list upload sessions", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to list upload sessions")
+		return
+	}
+
+	statuses := make([]uploadSessionStatus, 0, len(sessions))
+	for i := range sessions {
+		statuses = append(statuses, newUploadSessionStatus(&sessions[i]))
+	}
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": statuses,
+	})
+}
+
+// AbortUploadSession прерывает загрузку и удаляет принятые данные
+func (h *Handler) AbortUploadSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lg := logger.GetLoggerFromCtxSafe(ctx)
+	sessionID := mux.Vars(r)["sessionID"]
+
+	unlock := lockSession(sessionID)
+	defer unlock()
+
+	if _, ok := h.userUploadSession(w, r, sessionID); !ok {
+		return
+	}
+	if err := h.uploadSessions.Delete(ctx, sessionID); err != nil {
+		if lg != nil {
+			lg.Error(ctx, "Failed to delete upload session", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to abort upload")
+		return
+	}
+
+	if lg != nil {
+		lg.Info(ctx, "Upload session aborted", zap.String("sessionID", sessionID))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userUploadSession находит сессию загрузки текущего пользователя. Чужие сессии не видны,
+// истекшие удаляются с ответом 410
+func (h *Handler) userUploadSession(w http.ResponseWriter, r *http.Request, sessionID string) (*models.ResumableSession, bool) {
+	lg := logger.GetLoggerFromCtxSafe(r.Context())
+
+	userID, err := h.getUserIDFromRequest(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	session, err := h.uploadSessions.Get(r.Context(), sessionID)
+	if err != nil {
+		if lg != nil {
+			lg.Error(r.Context(), "Failed to get upload session", zap.Error(err))
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get upload")
+		return nil, false
+	}
+	if session == nil || session.UserID != userID {
+		h.respondWithError(w, http.StatusNotFound, "Upload not found")
+		return nil, false
+	}
+	if session.Expired(time.Now()) {
+		h.uploadSessions.Delete(r.Context(), sessionID)
+		h.respondWithError(w, http.StatusGone, "Upload expired")
+		return nil, false
+	}
+	return session, true
+}
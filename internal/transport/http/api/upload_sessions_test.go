@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"homecloud-file-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSessionTracksRangesAndCanBeAborted(t *testing.T) {
+	router, files, _ := newTestRouter(t)
+
+	do := func(method, path, contentRange, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", testToken)
+		if contentRange != "" {
+			req.Header.Set("Content-Range", contentRange)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	create := func() string {
+		rec := do("POST", "/api/v1/upload/resumable", "", `{"filePath": "digits.txt", "size": 10}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var resp struct {
+			SessionID string `json:"session_id"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return "/api/v1/upload/resumable/" + resp.SessionID
+	}
+	status := func(path string) uploadSessionStatus {
+		rec := do("GET", path, "", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var st uploadSessionStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+		return st
+	}
+
+	path := create()
+	st := status(path)
+	assert.Equal(t, int64(0), st.Offset)
+	assert.Equal(t, []models.ByteRange{{Start: 0, End: 9}}, st.Missing)
+
+	// Части приходят не по порядку и с перекрытием
+	require.Equal(t, http.StatusAccepted, do("PATCH", path, "bytes 6-9/10", "6789").Code)
+	require.Equal(t, http.StatusAccepted, do("PATCH", path, "bytes 2-7/*", "234567").Code)
+	st = status(path)
+	assert.Equal(t, int64(0), st.Offset)
+	assert.Equal(t, []models.ByteRange{{Start: 2, End: 9}}, st.Received)
+	assert.Equal(t, []models.ByteRange{{Start: 0, End: 1}}, st.Missing)
+
+	head := do("HEAD", path, "", "")
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Equal(t, "0", head.Header().Get("Upload-Offset"))
+	assert.Equal(t, "10", head.Header().Get("Upload-Length"))
+
+	assert.Equal(t, http.StatusBadRequest, do("PATCH", path, "bytes 0-1/10", "0").Code, "short chunk")
+	assert.Equal(t, http.StatusBadRequest, do("PATCH", path, "bytes 8-10/11", "89a").Code, "wrong size")
+
+	// Незавершенные сессии видны в списке
+	aborted := create()
+	rec := do("GET", "/api/v1/upload/resumable", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Sessions []uploadSessionStatus `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list.Sessions, 2)
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", aborted, "", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", aborted, "", "").Code)
+
+	// Последний недостающий диапазон завершает загрузку
+	rec = do("PATCH", path, "bytes 0-1/10", "01")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "0123456789", string(files.content))
+	assert.Equal(t, http.StatusNotFound, do("GET", path, "", "").Code)
+}
+
+func TestResumableUploadAcceptsParallelChunksWithDigests(t *testing.T) {
+	router, files, _ := newTestRouter(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	const chunkSize = 8192
+	send := func(path string, start int, chunk []byte, digest string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", path, bytes.NewReader(chunk))
+		req.Header.Set("Authorization", testToken)
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+len(chunk)-1, len(content)))
+		req.Header.Set("Content-Digest", digest)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	digestOf := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/upload/resumable", strings.NewReader(
+		fmt.Sprintf(`{"filePath": "big.bin", "size": %d, "sha256": "%x"}`, len(content), sha256.Sum256(content))))
+	req.Header.Set("Authorization", testToken)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		SessionID string `json:"session_id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	path := "/api/v1/upload/resumable/" + created.SessionID
+
+	// Часть, не совпавшая с Content-Digest, не принимается
+	rec = send(path, 0, content[:chunkSize], digestOf([]byte("something else")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "digest mismatch")
+
+	var wg sync.WaitGroup
+	codes := make(chan int, len(content)/chunkSize)
+	for start := 0; start < len(content); start += chunkSize {
+		chunk := content[start : start+chunkSize]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- send(path, start, chunk, digestOf(chunk)).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	completed := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			completed++
+		} else {
+			assert.Equal(t, http.StatusAccepted, code)
+		}
+	}
+	assert.Equal(t, 1, completed)
+	assert.Equal(t, content, files.content)
+}